@@ -78,6 +78,17 @@ func action(cliCtx *cli.Context) error {
 	}
 	AMF = amf
 
+	// SIGUSR1 drains the AMF before terminating it, for the planned removal of the AMF from its set
+	drainCh := make(chan os.Signal, 1)
+	signal.Notify(drainCh, syscall.SIGUSR1)
+	go func() {
+		for range drainCh {
+			if errDrain := amf.Drain(0); errDrain != nil {
+				logger.MainLog.Warnf("Drain AMF: %v", errDrain)
+			}
+		}
+	}()
+
 	amf.Start()
 
 	return nil
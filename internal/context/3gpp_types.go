@@ -13,6 +13,7 @@ const (
 	MaxNumOfSlice                     int   = 1024
 	MaxNumOfAllowedSnssais            int   = 8
 	MaxValueOfAmfUeNgapId             int64 = 1099511627775
+	MaxValueOfTrsr                    int64 = 0xffff
 	MaxNumOfServedGuamiList           int   = 256
 	MaxNumOfPDUSessions               int   = 256
 	MaxNumOfDRBs                      int   = 32
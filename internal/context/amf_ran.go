@@ -73,6 +73,8 @@ func (ran *AmfRan) NewRanUe(ranUeNgapID int64) (*RanUe, error) {
 		// store to RanUeList only when RANUENGAPID is specified
 		// (otherwise, will be stored only in amfContext.RanUePool)
 		ran.RanUeList.Store(ranUeNgapID, &ranUe)
+		// the messages received for the UE so far were keyed on the RAN UE NGAP ID
+		ueAffinity.Bind(amfUeNgapID, InitialUeKey(ran.Conn, ranUeNgapID))
 	}
 	self.RanUePool.Store(ranUe.AmfUeNgapId, &ranUe)
 	ranUe.Log.Infof("New RanUe [RanUeNgapID:%d][AmfUeNgapID:%d]", ranUe.RanUeNgapId, ranUe.AmfUeNgapId)
@@ -135,6 +137,34 @@ func (ran *AmfRan) RanID() string {
 	}
 }
 
+// Key identifies the RAN in the OAM resource URIs as <node type>-<PLMN ID>-<node ID>,
+// by its N2 address until it has sent its global RAN node ID
+func (ran *AmfRan) Key() string {
+	if id := ran.RanId; id != nil && id.PlmnId != nil {
+		plmnId := id.PlmnId.Mcc + id.PlmnId.Mnc
+		switch ran.RanPresent {
+		case RanPresentGNbId:
+			if id.GNbId != nil {
+				return "gnb-" + plmnId + "-" + id.GNbId.GNBValue
+			}
+		case RanPresentNgeNbId:
+			return "ngenb-" + plmnId + "-" + id.NgeNbId
+		case RanPresentN3IwfId:
+			return "n3iwf-" + plmnId + "-" + id.N3IwfId
+		case RanPresentTngfId:
+			return "tngf-" + plmnId + "-" + id.TngfId
+		case RanPresentTwifId:
+			return "twif-" + plmnId + "-" + id.TwifId
+		case RanPresentWagfId:
+			return "wagf-" + plmnId + "-" + id.WagfId
+		}
+	}
+	if ran.Conn != nil && ran.Conn.RemoteAddr() != nil {
+		return ran.Conn.RemoteAddr().String()
+	}
+	return ""
+}
+
 func (ran *AmfRan) UeRatType() models.RatType {
 	// In TS 23.501 5.3.2.3
 	// For 3GPP access the AMF determines the RAT type the UE is camping on based
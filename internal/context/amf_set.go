@@ -0,0 +1,111 @@
+package context
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+var (
+	// serializes the take over of the UE contexts, so that a UE context is added to the UE pool once
+	takeOverMu sync.Mutex
+	// set once the planned removal of the AMF has started
+	draining atomic.Bool
+)
+
+// StartDraining marks the AMF as being removed from its set (TS 23.501 5.21.2.2.1), no longer taking new UEs.
+// It returns false if the AMF is already draining.
+func StartDraining() bool {
+	return draining.CompareAndSwap(false, true)
+}
+
+// IsDraining reports whether the planned removal of the AMF has started
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// GuamiOfGuti returns the GUAMI of the 5G-GUTI string (<MCC><MNC><AMF ID><5G-TMSI>)
+func GuamiOfGuti(guti string) (models.Guami, bool) {
+	var mncLen int
+	switch len(guti) {
+	case 19:
+		mncLen = 2
+	case 20:
+		mncLen = 3
+	default:
+		return models.Guami{}, false
+	}
+	return models.Guami{
+		PlmnId: &models.PlmnIdNid{
+			Mcc: guti[:3],
+			Mnc: guti[3 : 3+mncLen],
+		},
+		AmfId: guti[3+mncLen : 9+mncLen],
+	}, true
+}
+
+// IsServedGuami reports whether the GUAMI is served by this AMF
+func (context *AMFContext) IsServedGuami(guami models.Guami) bool {
+	return factory.ContainsGuami(context.ServedGuamiList, guami)
+}
+
+// IsBackedUpGuami reports whether the GUAMI is served by another AMF of the set which this AMF backs up
+func (context *AMFContext) IsBackedUpGuami(guami models.Guami) bool {
+	return factory.ContainsGuami(context.BackedUpGuamiList, guami)
+}
+
+// BackupAmfName returns the name of the AMF taking over the served GUAMI when this AMF fails,
+// empty if there is none
+func (context *AMFContext) BackupAmfName(guami models.Guami) string {
+	for _, backup := range context.BackupAmfList {
+		if factory.GuamiEqual(backup.Guami, guami) {
+			return backup.AmfName
+		}
+	}
+	return ""
+}
+
+// TakeOverUe adds to the UE pool the context of a UE registered with a failed AMF of the set, whose GUAMI
+// this AMF backs up. The context is found by the 5G-GUTI in the UE context store, which must be shared by
+// the AMFs of the set for the contexts checkpointed by the failed AMF to be found (see factory.AmfSet).
+func (context *AMFContext) TakeOverUe(guti string) (*AmfUe, bool) {
+	guami, ok := GuamiOfGuti(guti)
+	if !ok || !context.IsBackedUpGuami(guami) {
+		return nil, false
+	}
+	store := getUeContextStore()
+	if store == nil {
+		return nil, false
+	}
+
+	takeOverMu.Lock()
+	defer takeOverMu.Unlock()
+	if ue, ok := context.AmfUeFindByGuti(guti); ok {
+		return ue, true
+	}
+	snapshot, err := store.FindByGuti(guti)
+	if err != nil {
+		logger.CtxLog.Errorf("Find UE context of GUTI[%s] error: %+v", guti, err)
+		return nil, false
+	}
+	if snapshot == nil || snapshot.Supi == "" {
+		return nil, false
+	}
+	if _, ok := context.AmfUeFindBySupi(snapshot.Supi); ok {
+		// the UE has registered with this AMF since the context was checkpointed
+		return nil, false
+	}
+
+	ue := &AmfUe{}
+	ue.init()
+	ue.restore(snapshot)
+	// the 5G-TMSI was allocated by the failed AMF, not to be freed here;
+	// the UE gets a 5G-GUTI of this AMF at its next registration
+	ue.Tmsi = 0
+	context.AddAmfUeToUePool(ue, ue.Supi)
+	ue.GmmLog.Infof("Take over UE context of GUAMI[%s %s] from failed AMF", guami.PlmnId, guami.AmfId)
+	return ue, true
+}
@@ -0,0 +1,76 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/fsm"
+)
+
+func TestGuamiOfGuti(t *testing.T) {
+	guami, ok := GuamiOfGuti("20893cafe0000000001")
+	require.True(t, ok)
+	require.Equal(t, "208", guami.PlmnId.Mcc)
+	require.Equal(t, "93", guami.PlmnId.Mnc)
+	require.Equal(t, "cafe00", guami.AmfId)
+
+	guami, ok = GuamiOfGuti("208930cafe0000000001")
+	require.True(t, ok)
+	require.Equal(t, "930", guami.PlmnId.Mnc)
+	require.Equal(t, "cafe00", guami.AmfId)
+
+	_, ok = GuamiOfGuti("cafe0000000001")
+	require.False(t, ok)
+}
+
+func TestTakeOverUe(t *testing.T) {
+	self := GetSelf()
+	store := NewMemoryUeContextStore()
+	SetUeContextStore(store)
+	defer SetUeContextStore(nil)
+	require.NoError(t, SetUeContextStoreKey(make([]byte, 32)))
+	defer func() { require.NoError(t, SetUeContextStoreKey(nil)) }()
+	plmnId := &models.PlmnIdNid{Mcc: "208", Mnc: "93"}
+	self.ServedGuamiList = []models.Guami{{PlmnId: plmnId, AmfId: "cafe00"}}
+	self.BackedUpGuamiList = []models.Guami{{PlmnId: plmnId, AmfId: "cafe01"}}
+	defer func() {
+		self.ServedGuamiList = nil
+		self.BackedUpGuamiList = nil
+	}()
+
+	// the context checkpointed by the failed AMF of the set
+	peerUe := &AmfUe{}
+	peerUe.init()
+	peerUe.Supi = "imsi-208930000000002"
+	peerUe.Tmsi = 5
+	peerUe.Guti = "20893CAFE0100000005"
+	peerUe.State[models.AccessType__3_GPP_ACCESS] = fsm.NewState(Registered)
+	peerUe.SecurityContextAvailable = true
+	peerUe.ULCount.Set(0, 3)
+	require.NoError(t, store.Save(peerUe.Snapshot()))
+
+	// the UE contexts of the other AMFs are not restored at start
+	restored, err := self.RestoreUeContexts(store)
+	require.NoError(t, err)
+	require.Equal(t, 0, restored)
+
+	_, ok := self.TakeOverUe("20893cafe0200000005")
+	require.False(t, ok, "GUAMI not backed up")
+	_, ok = self.TakeOverUe("20893CAFE0100000006")
+	require.False(t, ok, "no UE context of the GUTI")
+
+	ue, ok := self.TakeOverUe(peerUe.Guti)
+	require.True(t, ok)
+	require.Equal(t, peerUe.Supi, ue.Supi)
+	require.True(t, ue.SecurityContextAvailable)
+	require.Equal(t, peerUe.ULCount.Get(), ue.ULCount.Get())
+	require.Zero(t, ue.Tmsi, "the 5G-TMSI of the failed AMF is not kept")
+
+	again, ok := self.TakeOverUe(peerUe.Guti)
+	require.True(t, ok)
+	require.Same(t, ue, again)
+
+	ue.Remove()
+}
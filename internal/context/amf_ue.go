@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/free5gc/amf/internal/logger"
 	business_metrics "github.com/free5gc/amf/internal/metrics/business"
@@ -44,6 +45,16 @@ const (
 	RecommendRanNodePresentTAI     int32 = 1
 )
 
+const (
+	// fcForKamfHorizontalDerivation is the FC of the KAMF to KAMF' derivation (TS 33.501 Annex A.13)
+	fcForKamfHorizontalDerivation = "72"
+	// kamfDerivationDirectionUplink is the DIRECTION of the KAMF' derived from an uplink NAS COUNT,
+	// 0x01 being that of a downlink NAS COUNT on N2 handover
+	kamfDerivationDirectionUplink = 0x00
+	// NasCountRefreshThreshold is the NAS COUNT (24 bits) from which the NAS keys of a UE are refreshed
+	NasCountRefreshThreshold uint32 = 0xff0000
+)
+
 // GMM state for UE
 const (
 	Deregistered            fsm.StateType = "Deregistered"
@@ -82,6 +93,10 @@ type AmfUe struct {
 	Pei                    string
 	Tmsi                   int32 // 5G-Tmsi
 	Guti                   string
+	OldTmsi                int32  // 5G-TMSI replaced by the one of Guti, valid until the UE acknowledged Guti
+	OldGuti                string // "" once the UE acknowledged Guti
+	GutiAllocatedAt        time.Time
+	GutiServiceRequests    int // service requests of the UE since Guti was allocated
 	GroupID                string
 	EBI                    int32
 	EventSubscriptionsInfo map[string]*AmfUeEventSubscription
@@ -104,6 +119,7 @@ type AmfUe struct {
 	UdmGroupId                        string
 	SubscribedNssai                   []models.SubscribedSnssai
 	AccessAndMobilitySubscriptionData *models.AccessAndMobilitySubscriptionData
+	parametersUpdate                  parametersUpdateState
 	BackupAmfInfo                     []models.BackupAmfInfo
 	/* contex abut ausf */
 	AusfGroupId                       string
@@ -113,6 +129,7 @@ type AmfUe struct {
 	AuthenticationCtx                 *models.UeAuthenticationCtx
 	AuthFailureCauseSynchFailureTimes int
 	IdentityRequestSendTimes          int
+	authentication                    authenticationState
 	ABBA                              []uint8
 	Kseaf                             string
 	Kamf                              string
@@ -136,15 +153,15 @@ type AmfUe struct {
 	/* Related Context */
 	RanUe map[models.AccessType]*RanUe
 	/* other */
-	onGoing                         map[models.AccessType]*OnGoing
-	UeRadioCapability               string // OCTET string
-	Capability5GMM                  nasType.Capability5GMM
-	ConfigurationUpdateIndication   nasType.ConfigurationUpdateIndication
-	ConfigurationUpdateCommandFlags *ConfigurationUpdateCommandFlags
+	onGoing                       map[models.AccessType]*OnGoing
+	UeRadioCapability             string // OCTET string
+	Capability5GMM                nasType.Capability5GMM
+	ConfigurationUpdateIndication nasType.ConfigurationUpdateIndication
 	/* context related to Paging */
 	UeRadioCapabilityForPaging                 *UERadioCapabilityForPaging
 	InfoOnRecommendedCellsAndRanNodesForPaging *InfoOnRecommendedCellsAndRanNodesForPaging
 	UESpecificDRX                              uint8
+	LastSeenRanId                              *models.GlobalRanNodeId // RAN serving the UE before it went CM-IDLE
 	/* Security Context */
 	SecurityContextAvailable bool
 	UESecurityCapability     nasType.UESecurityCapability // for security command
@@ -158,8 +175,12 @@ type AmfUe struct {
 	NCC                      uint8     // 0..7
 	ULCount                  security.Count
 	DLCount                  security.Count
+	KamfChanged              bool // KAMF derived horizontally, signalled to the UE by the next Security Mode Command
+	NasCountsRestored        bool // NAS COUNTs restored from the UE context store, possibly behind those of the UE
+	nasSecurity              nasSecurityState
 	CipheringAlg             uint8
 	IntegrityAlg             uint8
+	PendingServiceRequest    *nasMessage.ServiceRequest // resumed once the NAS keys refreshed by KamfChanged are in use
 	/* Registration Area */
 	RegistrationArea map[models.AccessType][]models.Tai
 	LadnInfo         []factory.Ladn
@@ -169,6 +190,8 @@ type AmfUe struct {
 	NetworkSliceInfo                  *models.AuthorizedNetworkSliceInfo
 	AllowedNssai                      map[models.AccessType][]models.AllowedSnssai
 	ConfiguredNssai                   []models.ConfiguredSnssai
+	sliceAdmission                    sliceAdmissionState
+	sliceAvailability                 sliceAvailabilityState
 	NetworkSlicingSubscriptionChanged bool
 	SdmSubscriptionId                 string
 	UeCmRegistered                    map[models.AccessType]bool
@@ -186,13 +209,25 @@ type AmfUe struct {
 	T3570 *Timer
 	/* T3555 (for configuration update command) */
 	T3555 *Timer
+	/* Changes of the UE configuration pending or awaiting acknowledgement, see QueueConfigurationUpdate */
+	configurationUpdate configurationUpdate
 	/* Ue Context Release Cause */
 	ReleaseCause map[models.AccessType]*CauseAll
+	/* Downlink NAS not delivered due to handover, re-sent once the handover completes */
+	bufferedDlNas    map[models.AccessType][]*DlNasMessage
+	dlNasBufferTimer map[models.AccessType]*Timer
+	dlNasMu          sync.Mutex
+	/* Spans of the ongoing procedures and of the message being handled, see StartProcedureSpan */
+	procedureSpans map[models.AccessType]trace.Span
+	activeSpan     trace.Span
+	traceMu        sync.Mutex
 	/* T3502 (Assigned by AMF, and used by UE to initialize registration procedure) */
 	T3502Value             int        // Second
 	T3512Value             int        // default 54 min
 	Non3gppDeregTimerValue int        // default 54 min
 	Lock                   sync.Mutex // Update context to prevent race condition
+	eventKey               uint64     // accessed atomically, see EventKey
+	nfWait                 ueNfWait   // see AwaitNf
 
 	// logger
 	NASLog      *logrus.Entry
@@ -267,6 +302,8 @@ type ConfigurationUpdateCommandFlags struct {
 	NeedConfiguredNSSAI                          bool
 	NeedNetworkSlicingIndication                 bool
 	NeedOperatordefinedAccessCategoryDefinitions bool
+	// NeedRegistration requests the UE to perform a registration procedure once it acknowledged the command
+	NeedRegistration bool
 }
 
 func (ue *AmfUe) init() {
@@ -287,6 +324,8 @@ func (ue *AmfUe) init() {
 	ue.onGoing[models.AccessType__3_GPP_ACCESS] = new(OnGoing)
 	ue.onGoing[models.AccessType__3_GPP_ACCESS].Procedure = OnGoingProcedureNothing
 	ue.ReleaseCause = make(map[models.AccessType]*CauseAll)
+	ue.bufferedDlNas = make(map[models.AccessType][]*DlNasMessage)
+	ue.dlNasBufferTimer = make(map[models.AccessType]*Timer)
 	ue.UeCmRegistered = make(map[models.AccessType]bool)
 	ue.GmmLog = logger.GmmLog
 	ue.NASLog = logger.GmmLog
@@ -317,6 +356,11 @@ func (ue *AmfUe) Remove() {
 	ue.StopT3522()
 	ue.StopT3570()
 	ue.StopT3555()
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		if msgs := ue.TakeBufferedDlNas(anType); len(msgs) > 0 {
+			ue.GmmLog.Warnf("Drop %d buffered downlink NAS message(s) of %s", len(msgs), anType)
+		}
+	}
 
 	for _, ranUe := range ue.RanUe {
 		if err := ranUe.Remove(); err != nil {
@@ -329,7 +373,12 @@ func (ue *AmfUe) Remove() {
 			business_metrics.DecrUeCmIdleStateGauge(accessType)
 		}
 	}
-	tmsiGenerator.FreeID(int64(ue.Tmsi))
+	ue.endProcedureSpans()
+	GetSelf().FreeTmsi(int64(ue.Tmsi))
+	ue.ReleaseOldGuti()
+	ue.ReleaseSlices(models.AccessType__3_GPP_ACCESS)
+	ue.ReleaseSlices(models.AccessType_NON_3_GPP_ACCESS)
+	ue.deleteCheckpoint()
 	if len(ue.Supi) > 0 {
 		GetSelf().UePool.Delete(ue.Supi)
 	}
@@ -351,14 +400,25 @@ func (ue *AmfUe) DetachRanUe(anType models.AccessType) {
 		business_metrics.DecrUeConnectivityGauge(anType)
 	}
 
+	if ranUe, ok := ue.RanUe[anType]; ok && ranUe.Ran != nil && anType == models.AccessType__3_GPP_ACCESS {
+		ue.LastSeenRanId = ranUe.Ran.RanId
+	}
+
 	delete(ue.RanUe, anType)
 	ue.UpdateLogFields(anType)
+	// a Configuration Update Command no T3555 supervises is not acknowledged over the released connection
+	if sent, sentAnType := ue.ConfigurationUpdateSent(); sent != nil && sentAnType == anType && ue.T3555 == nil {
+		ue.AbortConfigurationUpdate()
+	}
+	// whatever the release, the UE is kept with the NAS COUNTs it will resume with
+	ue.Checkpoint()
 }
 
 // Don't call this function directly. Use gmm_common.AttachRanUeToAmfUeAndReleaseOldIfAny().
 func (ue *AmfUe) AttachRanUe(ranUe *RanUe) {
 	ue.RanUe[ranUe.Ran.AnType] = ranUe
 	ranUe.AmfUe = ue
+	ue.bindEventKey(ranUe)
 	ue.UpdateLogFields(ranUe.Ran.AnType)
 }
 
@@ -414,6 +474,15 @@ func (ue *AmfUe) GetCmInfo() (cmInfos []models.CmInfo) {
 	return
 }
 
+// ReleaseOldGuti frees the 5G-TMSI of the 5G-GUTI replaced by ReallocateGutiToUe, once the UE no longer uses it
+func (ue *AmfUe) ReleaseOldGuti() {
+	if ue.OldGuti == "" {
+		return
+	}
+	GetSelf().FreeTmsi(int64(ue.OldTmsi))
+	ue.OldTmsi, ue.OldGuti = 0, ""
+}
+
 func (ue *AmfUe) InAllowedNssai(targetSNssai models.Snssai, anType models.AccessType) bool {
 	for _, allowedSnssai := range ue.AllowedNssai[anType] {
 		if openapi.SnssaiEqualFold(*allowedSnssai.AllowedSnssai, targetSNssai) {
@@ -497,6 +566,7 @@ func (ue *AmfUe) DerivateKamf() {
 		return
 	}
 	ue.Kamf = hex.EncodeToString(KamfBytes)
+	ue.NasCountsRestored = false
 }
 
 // Algorithm key Derivation function defined in TS 33.501 Annex A.9
@@ -580,6 +650,51 @@ func (ue *AmfUe) DerivateNH(syncInput []byte) {
 	}
 }
 
+// HorizontalKamf returns the KAMF' derived from the current KAMF and the uplink NAS COUNT of the last NAS message
+// received from the UE, as defined in TS 33.501 Annex A.13; the derivation on N2 handover is not supported
+func (ue *AmfUe) HorizontalKamf() (string, error) {
+	P0 := []byte{kamfDerivationDirectionUplink}
+	L0 := ueauth.KDFLen(P0)
+	P1 := make([]byte, 4)
+	binary.BigEndian.PutUint32(P1, ue.ULCount.Get())
+	L1 := ueauth.KDFLen(P1)
+
+	KamfBytes, err := hex.DecodeString(ue.Kamf)
+	if err != nil {
+		return "", err
+	}
+	kamf, err := ueauth.GetKDFValue(KamfBytes, fcForKamfHorizontalDerivation, P0, L0, P1, L1)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(kamf), nil
+}
+
+// DerivateHorizontalKamf replaces the KAMF by the one derived horizontally (TS 33.501 6.9.3), the NAS keys are
+// refreshed once the UE has been sent a Security Mode Command with the K_AMF_change_flag
+func (ue *AmfUe) DerivateHorizontalKamf() {
+	kamf, err := ue.HorizontalKamf()
+	if err != nil {
+		logger.CtxLog.Error(err)
+		return
+	}
+	ue.Kamf = kamf
+	ue.KamfChanged = true
+	ue.NasCountsRestored = false
+}
+
+// NasCountNearWrapAround reports whether a NAS COUNT is about to wrap around, the NAS keys then need to be
+// refreshed through a horizontal KAMF derivation (TS 33.501 6.9.4.3)
+func (ue *AmfUe) NasCountNearWrapAround() bool {
+	return ue.ULCount.Get() >= NasCountRefreshThreshold || ue.DLCount.Get() >= NasCountRefreshThreshold
+}
+
+// NasKeysNeedRefresh reports whether the NAS keys are refreshed before the NAS COUNTs are used further: a NAS
+// COUNT is about to wrap around, or the NAS COUNTs were restored and may have been used by the UE already
+func (ue *AmfUe) NasKeysNeedRefresh() bool {
+	return ue.NasCountsRestored || ue.NasCountNearWrapAround()
+}
+
 func (ue *AmfUe) UpdateSecurityContext(anType models.AccessType) {
 	ue.DerivateAnKey(anType)
 	switch anType {
@@ -658,6 +773,7 @@ func (ue *AmfUe) ClearRegistrationRequestData(accessType models.AccessType) {
 	ue.IdentityRequestSendTimes = 0
 	ue.ServingAmfChanged = false
 	ue.RegistrationAcceptForNon3GPPAccess = nil
+	ue.parametersUpdate.registrationSor = nil
 	if ranUe := ue.RanUe[accessType]; ranUe != nil {
 		ranUe.UeContextRequest = factory.AmfConfig.Configuration.DefaultUECtxReq
 	}
@@ -762,6 +878,7 @@ func (ue *AmfUe) CopyDataFromUeContextModel(ueContext *models.UeContext) {
 			ue.NH = nh
 		}
 		ue.NCC = uint8(seafData.Ncc)
+		ue.takeOverSecurityContext()
 	} else {
 		ue.SecurityContextAvailable = false
 	}
@@ -854,6 +971,7 @@ func (ue *AmfUe) CopyDataFromUeContextModel(ueContext *models.UeContext) {
 						overflow := uint16((uint32(mmContext.NasUplinkCount) & 0x00ffff00) >> 8)
 						sqn := uint8(uint32(mmContext.NasUplinkCount & 0x000000ff))
 						ue.ULCount.Set(overflow, sqn)
+						ue.MarkUplinkCountAccepted()
 					}
 
 					// TS 29.518 Table 6.1.6.3.2.1
@@ -880,6 +998,15 @@ func (ue *AmfUe) CopyDataFromUeContextModel(ueContext *models.UeContext) {
 			}
 		}
 	}
+	// the old AMF either handed over a KAMF' it derived or requires its derivation from the uplink NAS COUNT,
+	// the UE deriving the same KAMF' when the K_AMF_change_flag is signalled (TS 33.501 6.9.3)
+	if seafData := ueContext.SeafData; seafData != nil {
+		if seafData.KeyAmfHDerivationInd {
+			ue.DerivateHorizontalKamf()
+		} else if seafData.KeyAmfChangeInd {
+			ue.KamfChanged = true
+		}
+	}
 	if ueContext.TraceData != nil {
 		ue.TraceData = ueContext.TraceData
 	}
@@ -0,0 +1,130 @@
+package context
+
+import (
+	"time"
+
+	"github.com/free5gc/openapi/models"
+)
+
+// MaxAuthenticationRecords is the number of the last authentications of a UE kept for audit
+const MaxAuthenticationRecords = 16
+
+// Reasons of the primary authentication of a UE
+const (
+	AuthenticationReasonNoSecurityContext   = "no_security_context"
+	AuthenticationReasonInitialRegistration = "initial_registration"
+	AuthenticationReasonRegistrations       = "registrations"
+	AuthenticationReasonInterval            = "interval"
+	AuthenticationReasonPlmnChange          = "plmn_change"
+	AuthenticationReasonRestored            = "restored"
+)
+
+// Results of the primary authentication of a UE
+const (
+	AuthenticationResultOngoing = "ongoing"
+	AuthenticationResultSuccess = "success"
+	AuthenticationResultFailure = "failure"
+	AuthenticationResultAborted = "aborted"
+)
+
+// AuthenticationRecord is a primary authentication of the UE
+type AuthenticationRecord struct {
+	Time        time.Time
+	AccessType  models.AccessType
+	ServingPlmn *models.PlmnId
+	Reason      string
+	AuthType    models.AusfUeAuthenticationAuthType
+	Result      string
+}
+
+// authenticationState is the authentication history of a UE and what the re-authentication policy depends on
+type authenticationState struct {
+	history []AuthenticationRecord
+	// registrations is the number of registrations of the UE since its last successful authentication
+	registrations int
+	// authenticatedAt and authenticatedPlmn are when and in which serving PLMN the security context of the UE was
+	// established, the PLMN being nil when unknown
+	authenticatedAt   time.Time
+	authenticatedPlmn *models.PlmnId
+}
+
+// CountRegistration counts a registration procedure of the UE since its last successful authentication
+func (ue *AmfUe) CountRegistration() {
+	ue.authentication.registrations++
+}
+
+// RegistrationsSinceAuthentication returns the number of registrations of the UE since its last successful
+// authentication, the current one included
+func (ue *AmfUe) RegistrationsSinceAuthentication() int {
+	return ue.authentication.registrations
+}
+
+// LastAuthentication returns when and in which serving PLMN the security context of the UE was established
+func (ue *AmfUe) LastAuthentication() (time.Time, *models.PlmnId) {
+	return ue.authentication.authenticatedAt, ue.authentication.authenticatedPlmn
+}
+
+// StartAuthentication records the primary authentication of the UE started over the access, ending the one still
+// ongoing
+func (ue *AmfUe) StartAuthentication(anType models.AccessType, reason string) {
+	ue.EndAuthentication(AuthenticationResultAborted)
+	record := AuthenticationRecord{
+		Time:       time.Now(),
+		AccessType: anType,
+		Reason:     reason,
+		Result:     AuthenticationResultOngoing,
+	}
+	if ue.Tai.PlmnId != nil {
+		plmnId := *ue.Tai.PlmnId
+		record.ServingPlmn = &plmnId
+	}
+	history := &ue.authentication.history
+	if len(*history) == MaxAuthenticationRecords {
+		*history = append((*history)[:0], (*history)[1:]...)
+	}
+	*history = append(*history, record)
+}
+
+// SetAuthenticationType records the authentication method the AUSF selected for the ongoing authentication
+func (ue *AmfUe) SetAuthenticationType(authType models.AusfUeAuthenticationAuthType) {
+	if record := ue.ongoingAuthentication(); record != nil {
+		record.AuthType = authType
+	}
+}
+
+// EndAuthentication records the result of the ongoing authentication, returning false when none was ongoing.
+// The security context established by a successful one is aged from now on.
+func (ue *AmfUe) EndAuthentication(result string) bool {
+	record := ue.ongoingAuthentication()
+	if record == nil {
+		return false
+	}
+	record.Result = result
+	if result == AuthenticationResultSuccess {
+		ue.authentication.registrations = 0
+		ue.authentication.authenticatedAt = time.Now()
+		ue.authentication.authenticatedPlmn = record.ServingPlmn
+	}
+	return true
+}
+
+// AuthenticationHistory returns the last authentications of the UE, the oldest first
+func (ue *AmfUe) AuthenticationHistory() []AuthenticationRecord {
+	return append([]AuthenticationRecord(nil), ue.authentication.history...)
+}
+
+// takeOverSecurityContext ages the security context taken over from another AMF from now on, the serving PLMN of
+// its authentication being unknown
+func (ue *AmfUe) takeOverSecurityContext() {
+	ue.authentication.registrations = 0
+	ue.authentication.authenticatedAt = time.Now()
+	ue.authentication.authenticatedPlmn = nil
+}
+
+func (ue *AmfUe) ongoingAuthentication() *AuthenticationRecord {
+	history := ue.authentication.history
+	if len(history) == 0 || history[len(history)-1].Result != AuthenticationResultOngoing {
+		return nil
+	}
+	return &history[len(history)-1]
+}
@@ -0,0 +1,59 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestAuthenticationHistory(t *testing.T) {
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	ue := &AmfUe{}
+	ue.Tai.PlmnId = &plmnId
+	anType := models.AccessType__3_GPP_ACCESS
+	require.False(t, ue.EndAuthentication(AuthenticationResultSuccess))
+
+	// a successful authentication restarts the count of registrations
+	ue.CountRegistration()
+	ue.StartAuthentication(anType, AuthenticationReasonInitialRegistration)
+	ue.SetAuthenticationType(models.AusfUeAuthenticationAuthType__5_G_AKA)
+	require.True(t, ue.EndAuthentication(AuthenticationResultSuccess))
+	require.Zero(t, ue.RegistrationsSinceAuthentication())
+	authenticatedAt, authenticatedPlmn := ue.LastAuthentication()
+	require.False(t, authenticatedAt.IsZero())
+	require.Equal(t, &plmnId, authenticatedPlmn)
+	ue.CountRegistration()
+	require.Equal(t, 1, ue.RegistrationsSinceAuthentication())
+
+	// the authentication started again is aborted, a failed one keeps the security context aged as before
+	ue.StartAuthentication(anType, AuthenticationReasonRegistrations)
+	ue.StartAuthentication(anType, AuthenticationReasonRegistrations)
+	require.True(t, ue.EndAuthentication(AuthenticationResultFailure))
+	require.False(t, ue.EndAuthentication(AuthenticationResultAborted))
+	require.Equal(t, 1, ue.RegistrationsSinceAuthentication())
+	history := ue.AuthenticationHistory()
+	require.Len(t, history, 3)
+	require.Equal(t, AuthenticationRecord{
+		Time:        history[0].Time,
+		AccessType:  anType,
+		ServingPlmn: &plmnId,
+		Reason:      AuthenticationReasonInitialRegistration,
+		AuthType:    models.AusfUeAuthenticationAuthType__5_G_AKA,
+		Result:      AuthenticationResultSuccess,
+	}, history[0])
+	require.Equal(t, AuthenticationResultAborted, history[1].Result)
+	require.Equal(t, AuthenticationResultFailure, history[2].Result)
+
+	// only the last authentications are kept
+	for i := 0; i < MaxAuthenticationRecords; i++ {
+		ue.StartAuthentication(anType, AuthenticationReasonInterval)
+		ue.EndAuthentication(AuthenticationResultSuccess)
+	}
+	history = ue.AuthenticationHistory()
+	require.Len(t, history, MaxAuthenticationRecords)
+	for _, record := range history {
+		require.Equal(t, AuthenticationReasonInterval, record.Reason)
+	}
+}
@@ -0,0 +1,289 @@
+package context
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/nas"
+	"github.com/free5gc/openapi/models"
+)
+
+type CaptureProtocol string
+
+const (
+	CaptureProtocolNgap CaptureProtocol = "NGAP"
+	CaptureProtocolNas  CaptureProtocol = "NAS"
+)
+
+type CaptureDirection string
+
+const (
+	CaptureDirectionUplink   CaptureDirection = "UPLINK"
+	CaptureDirectionDownlink CaptureDirection = "DOWNLINK"
+)
+
+// CaptureFilter selects the messages of a capture session; an empty field selects all the messages
+type CaptureFilter struct {
+	Supi string `json:"supi,omitempty"`
+	// key of the RAN (see AmfRan.Key)
+	Ran                string  `json:"ran,omitempty"`
+	NgapProcedureCodes []int64 `json:"ngapProcedureCodes,omitempty"`
+	NasMessageTypes    []int64 `json:"nasMessageTypes,omitempty"`
+}
+
+// CapturedPacket is an NGAP message as sent or received on the SCTP association of the RAN,
+// or a plaintext NAS message as decoded or before being encoded
+type CapturedPacket struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Protocol  CaptureProtocol  `json:"protocol"`
+	Direction CaptureDirection `json:"direction"`
+	Supi      string           `json:"supi,omitempty"`
+	Ran       string           `json:"ran,omitempty"`
+	// NGAP procedure code or NAS message type
+	MessageType int64    `json:"messageType"`
+	AmfAddr     net.Addr `json:"-"`
+	RanAddr     net.Addr `json:"-"`
+	Data        []byte   `json:"-"`
+}
+
+// CaptureSession keeps the latest messages matching its filter, from its start until it is stopped
+type CaptureSession struct {
+	Id         string        `json:"id"`
+	Filter     CaptureFilter `json:"filter"`
+	Nas        bool          `json:"nas"` // capture the plaintext NAS messages as well
+	MaxPackets int           `json:"maxPackets"`
+	StartTime  time.Time     `json:"startTime"`
+
+	mu      sync.Mutex
+	stopped bool
+	// ring buffer of the captured packets, packets[next] is the oldest once MaxPackets is reached
+	packets  []CapturedPacket
+	next     int
+	received int
+}
+
+// CaptureSessionStatus is the state of a capture session as returned through OAM
+type CaptureSessionStatus struct {
+	*CaptureSession
+	Stopped  bool `json:"stopped"`
+	Packets  int  `json:"packets"`
+	Received int  `json:"received"`
+}
+
+// ErrCaptureSessionLimit is returned when factory.AmfCaptureMaxSessions capture sessions are kept
+var ErrCaptureSessionLimit = errors.New("maximum number of capture sessions reached")
+
+var (
+	captureMu       sync.RWMutex
+	captureSessions = make(map[string]*CaptureSession)
+	lastCaptureId   uint64
+	// number of the running capture sessions, checked before building the captured packets
+	runningCaptures atomic.Int32
+)
+
+// StartCapture starts a capture session keeping up to maxPackets messages. The sessions are limited to
+// factory.AmfCaptureMaxSessions, stopped sessions included as they keep their messages until removed.
+func StartCapture(filter CaptureFilter, captureNas bool, maxPackets int) (*CaptureSession, error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if len(captureSessions) >= factory.AmfCaptureMaxSessions {
+		return nil, ErrCaptureSessionLimit
+	}
+	session := &CaptureSession{
+		Id:         strconv.FormatUint(atomic.AddUint64(&lastCaptureId, 1), 10),
+		Filter:     filter,
+		Nas:        captureNas,
+		MaxPackets: maxPackets,
+		StartTime:  time.Now(),
+	}
+	captureSessions[session.Id] = session
+	runningCaptures.Add(1)
+	return session, nil
+}
+
+// FindCaptureSession returns the capture session of the ID, running or stopped
+func FindCaptureSession(id string) (*CaptureSession, bool) {
+	captureMu.RLock()
+	defer captureMu.RUnlock()
+	session, ok := captureSessions[id]
+	return session, ok
+}
+
+// CaptureSessions returns the capture sessions ordered by start time
+func CaptureSessions() []*CaptureSession {
+	captureMu.RLock()
+	sessions := make([]*CaptureSession, 0, len(captureSessions))
+	for _, session := range captureSessions {
+		sessions = append(sessions, session)
+	}
+	captureMu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+	return sessions
+}
+
+// RemoveCaptureSession stops the capture session and discards its messages
+func RemoveCaptureSession(id string) bool {
+	captureMu.Lock()
+	session, ok := captureSessions[id]
+	delete(captureSessions, id)
+	captureMu.Unlock()
+	if ok {
+		session.Stop()
+	}
+	return ok
+}
+
+// Stop stops capturing messages, those captured are kept for export
+func (s *CaptureSession) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		runningCaptures.Add(-1)
+	}
+}
+
+func (s *CaptureSession) Status() CaptureSessionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return CaptureSessionStatus{
+		CaptureSession: s,
+		Stopped:        s.stopped,
+		Packets:        len(s.packets),
+		Received:       s.received,
+	}
+}
+
+// Packets returns the captured messages ordered by timestamp
+func (s *CaptureSession) Packets() []CapturedPacket {
+	s.mu.Lock()
+	packets := make([]CapturedPacket, 0, len(s.packets))
+	packets = append(packets, s.packets[s.next:]...)
+	packets = append(packets, s.packets[:s.next]...)
+	s.mu.Unlock()
+	sort.SliceStable(packets, func(i, j int) bool {
+		return packets[i].Timestamp.Before(packets[j].Timestamp)
+	})
+	return packets
+}
+
+func (s *CaptureSession) matches(packet *CapturedPacket) bool {
+	if packet.Protocol == CaptureProtocolNas && !s.Nas {
+		return false
+	}
+	if s.Filter.Supi != "" && s.Filter.Supi != packet.Supi {
+		return false
+	}
+	if s.Filter.Ran != "" && s.Filter.Ran != packet.Ran {
+		return false
+	}
+	switch packet.Protocol {
+	case CaptureProtocolNgap:
+		return len(s.Filter.NgapProcedureCodes) == 0 || containsInt64(s.Filter.NgapProcedureCodes, packet.MessageType)
+	case CaptureProtocolNas:
+		return len(s.Filter.NasMessageTypes) == 0 || containsInt64(s.Filter.NasMessageTypes, packet.MessageType)
+	}
+	return true
+}
+
+// add stores the packet; the oldest packet is dropped once MaxPackets is reached
+func (s *CaptureSession) add(packet CapturedPacket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.MaxPackets <= 0 {
+		return
+	}
+	s.received++
+	if len(s.packets) < s.MaxPackets {
+		s.packets = append(s.packets, packet)
+		return
+	}
+	s.packets[s.next] = packet
+	s.next = (s.next + 1) % s.MaxPackets
+}
+
+func containsInt64(list []int64, v int64) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCapturing reports whether a capture session is running
+func IsCapturing() bool {
+	return runningCaptures.Load() > 0
+}
+
+func capturePacket(packet CapturedPacket) {
+	captureMu.RLock()
+	defer captureMu.RUnlock()
+	copied := false
+	for _, session := range captureSessions {
+		if !session.matches(&packet) {
+			continue
+		}
+		if !copied {
+			// the buffer may be reused by the caller
+			packet.Data = append([]byte(nil), packet.Data...)
+			copied = true
+		}
+		session.add(packet)
+	}
+}
+
+// CaptureNgap captures the NGAP message sent to or received from the RAN, of the UE if ranUe is not nil
+func CaptureNgap(ran *AmfRan, ranUe *RanUe, direction CaptureDirection, timestamp time.Time, msg []byte) {
+	if !IsCapturing() || ran == nil || len(msg) < 2 {
+		return
+	}
+	packet := CapturedPacket{
+		Timestamp: timestamp,
+		Protocol:  CaptureProtocolNgap,
+		Direction: direction,
+		Ran:       ran.Key(),
+		// the procedure code follows the choice of the NGAP-PDU in its APER encoding
+		MessageType: int64(msg[1]),
+		Data:        msg,
+	}
+	if ranUe != nil && ranUe.AmfUe != nil {
+		packet.Supi = ranUe.AmfUe.Supi
+	}
+	if ran.Conn != nil {
+		packet.AmfAddr = ran.Conn.LocalAddr()
+		packet.RanAddr = ran.Conn.RemoteAddr()
+	}
+	capturePacket(packet)
+}
+
+// CaptureNas captures the plaintext NAS message of the UE, uplink once decoded or downlink before being encoded
+func CaptureNas(ue *AmfUe, anType models.AccessType, direction CaptureDirection, msg *nas.Message, plain []byte) {
+	if !IsCapturing() || ue == nil || msg == nil || len(plain) == 0 {
+		return
+	}
+	packet := CapturedPacket{
+		Timestamp: time.Now(),
+		Protocol:  CaptureProtocolNas,
+		Direction: direction,
+		Supi:      ue.Supi,
+		Data:      plain,
+	}
+	if msg.GmmMessage != nil {
+		packet.MessageType = int64(msg.GmmHeader.GetMessageType())
+	} else if msg.GsmMessage != nil {
+		packet.MessageType = int64(msg.GsmHeader.GetMessageType())
+	}
+	if ranUe := ue.RanUe[anType]; ranUe != nil && ranUe.Ran != nil {
+		packet.Ran = ranUe.Ran.Key()
+	}
+	capturePacket(packet)
+}
@@ -0,0 +1,215 @@
+package context
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+
+	"github.com/free5gc/sctp"
+)
+
+// The capture is exported as pcapng: the NGAP messages on an interface of raw IP packets carrying them
+// in SCTP DATA chunks, the plaintext NAS messages on an interface of Wireshark exported PDUs dissected as NAS-5GS
+const (
+	pcapngBlockSectionHeader      uint32 = 0x0A0D0D0A
+	pcapngBlockInterface          uint32 = 0x00000001
+	pcapngBlockEnhancedPacket     uint32 = 0x00000006
+	pcapngByteOrderMagic          uint32 = 0x1A2B3C4D
+	pcapngOptionEnd               uint16 = 0
+	pcapngOptionComment           uint16 = 1
+	pcapngLinkTypeRaw             uint16 = 101
+	pcapngLinkTypeUpperPdu        uint16 = 252
+	pcapngSnapLen                 uint32 = 0
+	pcapngInterfaceNgap           uint32 = 0
+	pcapngInterfaceNas            uint32 = 1
+	exportedPduTagEnd             uint16 = 0
+	exportedPduTagProtoName       uint16 = 12
+	exportedPduNas5gs                    = "nas-5gs"
+	ipProtocolSctp                uint8  = 132
+	sctpChunkData                 uint8  = 0
+	sctpDataFlagsUnfragmented     uint8  = 0x03
+	sctpPpidNgap                  uint32 = 60
+	defaultNgapPort                      = 38412
+	sctpDataChunkHeaderLen               = 16
+	ipv4HeaderLen                        = 20
+	ipv6HeaderLen                        = 40
+	pcapngEnhancedPacketHeaderLen        = 28
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// WritePcap writes the captured messages in pcapng format
+func (s *CaptureSession) WritePcap(w io.Writer) error {
+	var buf bytes.Buffer
+	writePcapngSectionHeader(&buf)
+	writePcapngInterface(&buf, pcapngLinkTypeRaw)
+	writePcapngInterface(&buf, pcapngLinkTypeUpperPdu)
+
+	var tsn uint32
+	for _, packet := range s.Packets() {
+		var interfaceId uint32
+		var data []byte
+		switch packet.Protocol {
+		case CaptureProtocolNgap:
+			tsn++
+			interfaceId = pcapngInterfaceNgap
+			data = ngapIpPacket(&packet, tsn)
+		case CaptureProtocolNas:
+			interfaceId = pcapngInterfaceNas
+			data = nasExportedPdu(packet.Data)
+		default:
+			continue
+		}
+		writePcapngEnhancedPacket(&buf, interfaceId, &packet, data)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writePcapngSectionHeader(buf *bytes.Buffer) {
+	const length = 28
+	le := binary.LittleEndian
+	buf.Write(le.AppendUint32(nil, pcapngBlockSectionHeader))
+	buf.Write(le.AppendUint32(nil, length))
+	buf.Write(le.AppendUint32(nil, pcapngByteOrderMagic))
+	buf.Write(le.AppendUint16(nil, 1)) // major version
+	buf.Write(le.AppendUint16(nil, 0)) // minor version
+	buf.Write(le.AppendUint64(nil, 0xFFFFFFFFFFFFFFFF))
+	buf.Write(le.AppendUint32(nil, length))
+}
+
+func writePcapngInterface(buf *bytes.Buffer, linkType uint16) {
+	const length = 20
+	le := binary.LittleEndian
+	buf.Write(le.AppendUint32(nil, pcapngBlockInterface))
+	buf.Write(le.AppendUint32(nil, length))
+	buf.Write(le.AppendUint16(nil, linkType))
+	buf.Write(le.AppendUint16(nil, 0))
+	buf.Write(le.AppendUint32(nil, pcapngSnapLen))
+	buf.Write(le.AppendUint32(nil, length))
+}
+
+// writePcapngEnhancedPacket writes the packet, commented with the direction, SUPI and RAN of the message
+func writePcapngEnhancedPacket(buf *bytes.Buffer, interfaceId uint32, packet *CapturedPacket, data []byte) {
+	comment := string(packet.Direction)
+	if packet.Supi != "" {
+		comment += " SUPI[" + packet.Supi + "]"
+	}
+	if packet.Ran != "" {
+		comment += " RAN[" + packet.Ran + "]"
+	}
+	length := pcapngEnhancedPacketHeaderLen + pad4(len(data)) + 4 + pad4(len(comment)) + 4 + 4
+
+	le := binary.LittleEndian
+	ts := uint64(packet.Timestamp.UnixMicro())
+	buf.Write(le.AppendUint32(nil, pcapngBlockEnhancedPacket))
+	buf.Write(le.AppendUint32(nil, uint32(length)))
+	buf.Write(le.AppendUint32(nil, interfaceId))
+	buf.Write(le.AppendUint32(nil, uint32(ts>>32)))
+	buf.Write(le.AppendUint32(nil, uint32(ts)))
+	buf.Write(le.AppendUint32(nil, uint32(len(data))))
+	buf.Write(le.AppendUint32(nil, uint32(len(data))))
+	buf.Write(data)
+	buf.Write(make([]byte, pad4(len(data))-len(data)))
+	buf.Write(le.AppendUint16(nil, pcapngOptionComment))
+	buf.Write(le.AppendUint16(nil, uint16(len(comment))))
+	buf.WriteString(comment)
+	buf.Write(make([]byte, pad4(len(comment))-len(comment)))
+	buf.Write(le.AppendUint16(nil, pcapngOptionEnd))
+	buf.Write(le.AppendUint16(nil, 0))
+	buf.Write(le.AppendUint32(nil, uint32(length)))
+}
+
+// nasExportedPdu frames the plaintext NAS message as a Wireshark exported PDU for the NAS-5GS dissector
+func nasExportedPdu(msg []byte) []byte {
+	be := binary.BigEndian
+	pdu := be.AppendUint16(nil, exportedPduTagProtoName)
+	pdu = be.AppendUint16(pdu, uint16(pad4(len(exportedPduNas5gs))))
+	pdu = append(pdu, exportedPduNas5gs...)
+	pdu = append(pdu, make([]byte, pad4(len(exportedPduNas5gs))-len(exportedPduNas5gs))...)
+	pdu = be.AppendUint16(pdu, exportedPduTagEnd)
+	pdu = be.AppendUint16(pdu, 0)
+	return append(pdu, msg...)
+}
+
+// ngapIpPacket frames the NGAP message in an SCTP DATA chunk of PPID 60 in an IP packet
+// between the addresses of the AMF and the RAN
+func ngapIpPacket(packet *CapturedPacket, tsn uint32) []byte {
+	amfIp, amfPort := captureAddr(packet.AmfAddr)
+	ranIp, ranPort := captureAddr(packet.RanAddr)
+	srcIp, srcPort, dstIp, dstPort := ranIp, ranPort, amfIp, amfPort
+	if packet.Direction == CaptureDirectionDownlink {
+		srcIp, srcPort, dstIp, dstPort = amfIp, amfPort, ranIp, ranPort
+	}
+
+	be := binary.BigEndian
+	sctpPacket := be.AppendUint16(nil, uint16(srcPort))
+	sctpPacket = be.AppendUint16(sctpPacket, uint16(dstPort))
+	sctpPacket = be.AppendUint32(sctpPacket, 0) // verification tag
+	sctpPacket = be.AppendUint32(sctpPacket, 0) // checksum
+	sctpPacket = append(sctpPacket, sctpChunkData, sctpDataFlagsUnfragmented)
+	sctpPacket = be.AppendUint16(sctpPacket, uint16(sctpDataChunkHeaderLen+len(packet.Data)))
+	sctpPacket = be.AppendUint32(sctpPacket, tsn)
+	sctpPacket = be.AppendUint16(sctpPacket, 0)           // stream identifier
+	sctpPacket = be.AppendUint16(sctpPacket, uint16(tsn)) // stream sequence number
+	sctpPacket = be.AppendUint32(sctpPacket, sctpPpidNgap)
+	sctpPacket = append(sctpPacket, packet.Data...)
+	sctpPacket = append(sctpPacket, make([]byte, pad4(len(packet.Data))-len(packet.Data))...)
+	// the CRC32c checksum is carried in little endian (RFC 9260 Appendix A)
+	binary.LittleEndian.PutUint32(sctpPacket[8:12], crc32.Checksum(sctpPacket, castagnoli))
+
+	if srcIp.To4() != nil && dstIp.To4() != nil {
+		ipPacket := make([]byte, ipv4HeaderLen, ipv4HeaderLen+len(sctpPacket))
+		ipPacket[0] = 0x45
+		be.PutUint16(ipPacket[2:4], uint16(ipv4HeaderLen+len(sctpPacket)))
+		be.PutUint16(ipPacket[6:8], 0x4000) // don't fragment
+		ipPacket[8] = 64                    // TTL
+		ipPacket[9] = ipProtocolSctp
+		copy(ipPacket[12:16], srcIp.To4())
+		copy(ipPacket[16:20], dstIp.To4())
+		be.PutUint16(ipPacket[10:12], ipv4Checksum(ipPacket))
+		return append(ipPacket, sctpPacket...)
+	}
+
+	ipPacket := make([]byte, ipv6HeaderLen, ipv6HeaderLen+len(sctpPacket))
+	ipPacket[0] = 0x60
+	be.PutUint16(ipPacket[4:6], uint16(len(sctpPacket)))
+	ipPacket[6] = ipProtocolSctp
+	ipPacket[7] = 64 // hop limit
+	copy(ipPacket[8:24], srcIp.To16())
+	copy(ipPacket[24:40], dstIp.To16())
+	return append(ipPacket, sctpPacket...)
+}
+
+func ipv4Checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// captureAddr returns the IP address and port of the SCTP endpoint, unspecified if unknown
+func captureAddr(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *sctp.SCTPAddr:
+		if len(a.IPAddrs) > 0 {
+			return a.IPAddrs[0].IP, a.Port
+		}
+		return net.IPv4zero, a.Port
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	}
+	return net.IPv4zero, defaultNgapPort
+}
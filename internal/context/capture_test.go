@@ -0,0 +1,169 @@
+package context
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/nas"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/sctp"
+)
+
+func TestCaptureSessionFilter(t *testing.T) {
+	ran := &AmfRan{
+		RanPresent: RanPresentGNbId,
+		RanId: &models.GlobalRanNodeId{
+			PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"},
+			GNbId:  &models.GNbId{BitLength: 24, GNBValue: "000102"},
+		},
+	}
+	ranUe := &RanUe{Ran: ran, AmfUe: &AmfUe{Supi: "imsi-208930000000001"}}
+	otherRanUe := &RanUe{Ran: ran, AmfUe: &AmfUe{Supi: "imsi-208930000000002"}}
+	uplinkNasTransport := []byte{0x00, byte(ngapType.ProcedureCodeUplinkNASTransport), 0x40, 0x00}
+	ngSetupResponse := []byte{0x20, byte(ngapType.ProcedureCodeNGSetup), 0x00, 0x00}
+
+	require.False(t, IsCapturing())
+	session, err := StartCapture(CaptureFilter{
+		Supi:               "imsi-208930000000001",
+		NgapProcedureCodes: []int64{ngapType.ProcedureCodeUplinkNASTransport},
+	}, false, 2)
+	require.NoError(t, err)
+	defer RemoveCaptureSession(session.Id)
+	require.True(t, IsCapturing())
+
+	now := time.Now()
+	CaptureNgap(ran, ranUe, CaptureDirectionUplink, now.Add(2*time.Millisecond), uplinkNasTransport)
+	CaptureNgap(ran, otherRanUe, CaptureDirectionUplink, now, uplinkNasTransport)
+	CaptureNgap(ran, nil, CaptureDirectionDownlink, now, ngSetupResponse)
+	CaptureNgap(ran, ranUe, CaptureDirectionUplink, now.Add(time.Millisecond), uplinkNasTransport)
+	CaptureNas(ranUe.AmfUe, models.AccessType__3_GPP_ACCESS, CaptureDirectionUplink, nas.NewMessage(), []byte{0x7e})
+
+	packets := session.Packets()
+	require.Len(t, packets, 2)
+	require.True(t, packets[0].Timestamp.Before(packets[1].Timestamp))
+	require.Equal(t, "gnb-20893-000102", packets[0].Ran)
+	require.Equal(t, "imsi-208930000000001", packets[0].Supi)
+	require.Equal(t, int64(ngapType.ProcedureCodeUplinkNASTransport), packets[0].MessageType)
+
+	// the oldest packet is dropped once the session is full
+	CaptureNgap(ran, ranUe, CaptureDirectionUplink, now.Add(3*time.Millisecond), uplinkNasTransport)
+	status := session.Status()
+	require.Equal(t, 2, status.Packets)
+	require.Equal(t, 3, status.Received)
+	packets = session.Packets()
+	require.Equal(t, now.Add(time.Millisecond), packets[0].Timestamp)
+	require.Equal(t, now.Add(3*time.Millisecond), packets[1].Timestamp)
+
+	session.Stop()
+	require.False(t, IsCapturing())
+	CaptureNgap(ran, ranUe, CaptureDirectionUplink, now.Add(4*time.Millisecond), uplinkNasTransport)
+	require.Equal(t, 3, session.Status().Received)
+
+	_, ok := FindCaptureSession(session.Id)
+	require.True(t, ok)
+	require.True(t, RemoveCaptureSession(session.Id))
+	_, ok = FindCaptureSession(session.Id)
+	require.False(t, ok)
+}
+
+func TestCaptureSessionLimit(t *testing.T) {
+	sessions := make([]*CaptureSession, 0, factory.AmfCaptureMaxSessions)
+	defer func() {
+		for _, session := range sessions {
+			RemoveCaptureSession(session.Id)
+		}
+	}()
+	for i := 0; i < factory.AmfCaptureMaxSessions; i++ {
+		session, err := StartCapture(CaptureFilter{}, false, 1)
+		require.NoError(t, err)
+		sessions = append(sessions, session)
+	}
+
+	// the stopped sessions count as they keep their packets
+	sessions[0].Stop()
+	_, err := StartCapture(CaptureFilter{}, false, 1)
+	require.ErrorIs(t, err, ErrCaptureSessionLimit)
+
+	require.True(t, RemoveCaptureSession(sessions[0].Id))
+	session, err := StartCapture(CaptureFilter{}, false, 1)
+	require.NoError(t, err)
+	sessions = append(sessions, session)
+}
+
+func TestCaptureSessionWritePcap(t *testing.T) {
+	session := &CaptureSession{MaxPackets: 10, Nas: true}
+	ngapMsg := []byte{0x00, byte(ngapType.ProcedureCodeNGSetup), 0x00, 0x01, 0x02}
+	session.add(CapturedPacket{
+		Timestamp: time.Now(),
+		Protocol:  CaptureProtocolNgap,
+		Direction: CaptureDirectionDownlink,
+		AmfAddr: &sctp.SCTPAddr{
+			IPAddrs: []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}},
+			Port:    38412,
+		},
+		RanAddr: &sctp.SCTPAddr{
+			IPAddrs: []net.IPAddr{{IP: net.ParseIP("10.0.0.2")}},
+			Port:    9487,
+		},
+		Data: ngapMsg,
+	})
+	session.add(CapturedPacket{
+		Timestamp: time.Now(),
+		Protocol:  CaptureProtocolNas,
+		Direction: CaptureDirectionUplink,
+		Supi:      "imsi-208930000000001",
+		Data:      []byte{0x7e, 0x00, 0x41},
+	})
+
+	var pcap bytes.Buffer
+	require.NoError(t, session.WritePcap(&pcap))
+
+	// section header, 2 interfaces and 2 enhanced packets, each block ending with its length
+	le := binary.LittleEndian
+	var blocks []uint32
+	var packets [][]byte
+	for b := pcap.Bytes(); len(b) > 0; {
+		require.GreaterOrEqual(t, len(b), 12)
+		blockType, length := le.Uint32(b[0:4]), le.Uint32(b[4:8])
+		require.Zero(t, length%4)
+		require.Equal(t, length, le.Uint32(b[length-4:length]))
+		blocks = append(blocks, blockType)
+		if blockType == pcapngBlockEnhancedPacket {
+			capturedLen := le.Uint32(b[20:24])
+			packets = append(packets, b[28:28+capturedLen])
+		}
+		b = b[length:]
+	}
+	require.Equal(t, []uint32{
+		pcapngBlockSectionHeader, pcapngBlockInterface, pcapngBlockInterface,
+		pcapngBlockEnhancedPacket, pcapngBlockEnhancedPacket,
+	}, blocks)
+
+	// IPv4 from the AMF to the RAN, carrying the NGAP message in an SCTP DATA chunk of PPID 60
+	ip := packets[0]
+	require.Equal(t, byte(0x45), ip[0])
+	require.Equal(t, ipProtocolSctp, ip[9])
+	require.Zero(t, ipv4Checksum(ip[:ipv4HeaderLen]))
+	require.Equal(t, net.ParseIP("10.0.0.1").To4(), net.IP(ip[12:16]))
+	require.Equal(t, net.ParseIP("10.0.0.2").To4(), net.IP(ip[16:20]))
+	sctpPacket := append([]byte(nil), ip[ipv4HeaderLen:]...)
+	require.Equal(t, uint16(38412), binary.BigEndian.Uint16(sctpPacket[0:2]))
+	require.Equal(t, uint16(9487), binary.BigEndian.Uint16(sctpPacket[2:4]))
+	checksum := le.Uint32(sctpPacket[8:12])
+	copy(sctpPacket[8:12], []byte{0, 0, 0, 0})
+	require.Equal(t, crc32.Checksum(sctpPacket, castagnoli), checksum)
+	require.Equal(t, sctpPpidNgap, binary.BigEndian.Uint32(sctpPacket[24:28]))
+	require.Equal(t, ngapMsg, sctpPacket[28:28+len(ngapMsg)])
+
+	// exported PDU for the NAS-5GS dissector
+	require.Equal(t, append(nasExportedPdu(nil), 0x7e, 0x00, 0x41), packets[1])
+	require.Equal(t, []byte("nas-5gs"), packets[1][4:11])
+}
@@ -68,6 +68,8 @@ func AttachSourceUeTargetUe(sourceUe, targetUe *RanUe) {
 	targetUe.AmfUe = amfUe
 	targetUe.SourceUe = sourceUe
 	sourceUe.TargetUe = targetUe
+	// the messages of the target NG-RAN node are handled by the worker of the source UE
+	ueAffinity.Bind(targetUe.AmfUeNgapId, ueAffinity.Key(sourceUe.AmfUeNgapId))
 }
 
 func DetachSourceUeTargetUe(ranUe *RanUe) {
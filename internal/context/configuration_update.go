@@ -0,0 +1,92 @@
+package context
+
+import (
+	"github.com/free5gc/openapi/models"
+)
+
+// configurationUpdate is the state of the generic UE configuration update procedure of a UE (TS 24.501 5.4.4)
+type configurationUpdate struct {
+	// pending are the changes queued per access until the UE can be sent a Configuration Update Command
+	pending map[models.AccessType]*ConfigurationUpdateCommandFlags
+	// sent are the changes of the command awaiting the acknowledgement of the UE while T3555 runs,
+	// a single command being acknowledged at a time
+	sent       *ConfigurationUpdateCommandFlags
+	sentAnType models.AccessType
+}
+
+// Merge adds the changes flagged in other to the flags
+func (flags *ConfigurationUpdateCommandFlags) Merge(other *ConfigurationUpdateCommandFlags) {
+	flags.NeedGUTI = flags.NeedGUTI || other.NeedGUTI
+	flags.NeedNITZ = flags.NeedNITZ || other.NeedNITZ
+	flags.NeedTaiList = flags.NeedTaiList || other.NeedTaiList
+	flags.NeedRejectNSSAI = flags.NeedRejectNSSAI || other.NeedRejectNSSAI
+	flags.NeedAllowedNSSAI = flags.NeedAllowedNSSAI || other.NeedAllowedNSSAI
+	flags.NeedSmsIndication = flags.NeedSmsIndication || other.NeedSmsIndication
+	flags.NeedMicoIndication = flags.NeedMicoIndication || other.NeedMicoIndication
+	flags.NeedLadnInformation = flags.NeedLadnInformation || other.NeedLadnInformation
+	flags.NeedServiceAreaList = flags.NeedServiceAreaList || other.NeedServiceAreaList
+	flags.NeedConfiguredNSSAI = flags.NeedConfiguredNSSAI || other.NeedConfiguredNSSAI
+	flags.NeedNetworkSlicingIndication = flags.NeedNetworkSlicingIndication || other.NeedNetworkSlicingIndication
+	flags.NeedOperatordefinedAccessCategoryDefinitions = flags.NeedOperatordefinedAccessCategoryDefinitions ||
+		other.NeedOperatordefinedAccessCategoryDefinitions
+	flags.NeedRegistration = flags.NeedRegistration || other.NeedRegistration
+}
+
+// QueueConfigurationUpdate merges the changes into the ones pending to be sent to the UE over the access
+func (ue *AmfUe) QueueConfigurationUpdate(anType models.AccessType, flags *ConfigurationUpdateCommandFlags) {
+	if ue.configurationUpdate.pending == nil {
+		ue.configurationUpdate.pending = make(map[models.AccessType]*ConfigurationUpdateCommandFlags)
+	}
+	pending := ue.configurationUpdate.pending[anType]
+	if pending == nil {
+		pending = new(ConfigurationUpdateCommandFlags)
+		ue.configurationUpdate.pending[anType] = pending
+	}
+	pending.Merge(flags)
+}
+
+// HasPendingConfigurationUpdate reports whether changes are pending to be sent to the UE over the access
+func (ue *AmfUe) HasPendingConfigurationUpdate(anType models.AccessType) bool {
+	return ue.configurationUpdate.pending[anType] != nil
+}
+
+// TakePendingConfigurationUpdate returns the changes pending over the access and clears them,
+// nil while a command awaits acknowledgement
+func (ue *AmfUe) TakePendingConfigurationUpdate(anType models.AccessType) *ConfigurationUpdateCommandFlags {
+	if ue.configurationUpdate.sent != nil {
+		return nil
+	}
+	flags := ue.configurationUpdate.pending[anType]
+	delete(ue.configurationUpdate.pending, anType)
+	return flags
+}
+
+// SetConfigurationUpdateSent records the changes of the command sent over the access awaiting acknowledgement
+func (ue *AmfUe) SetConfigurationUpdateSent(anType models.AccessType, flags *ConfigurationUpdateCommandFlags) {
+	ue.configurationUpdate.sent = flags
+	ue.configurationUpdate.sentAnType = anType
+}
+
+// ConfigurationUpdateSent returns the changes of the command awaiting acknowledgement and its access, nil if none
+func (ue *AmfUe) ConfigurationUpdateSent() (*ConfigurationUpdateCommandFlags, models.AccessType) {
+	return ue.configurationUpdate.sent, ue.configurationUpdate.sentAnType
+}
+
+// AbortConfigurationUpdate stops T3555 and forgets the command awaiting acknowledgement, the pending changes are kept.
+// The 5G-GUTI replaced by the command is released, the reallocation not being retried over it.
+func (ue *AmfUe) AbortConfigurationUpdate() {
+	ue.StopT3555()
+	if sent := ue.configurationUpdate.sent; sent != nil && sent.NeedGUTI {
+		ue.ReleaseOldGuti()
+	}
+	ue.configurationUpdate.sent = nil
+}
+
+// ClearConfigurationUpdate aborts the procedure and drops the changes pending over the access,
+// the UE being deregistered from it
+func (ue *AmfUe) ClearConfigurationUpdate(anType models.AccessType) {
+	if ue.configurationUpdate.sent != nil && ue.configurationUpdate.sentAnType == anType {
+		ue.AbortConfigurationUpdate()
+	}
+	delete(ue.configurationUpdate.pending, anType)
+}
@@ -0,0 +1,64 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestConfigurationUpdateQueue(t *testing.T) {
+	ue := &AmfUe{}
+	anType := models.AccessType__3_GPP_ACCESS
+	require.False(t, ue.HasPendingConfigurationUpdate(anType))
+	require.Nil(t, ue.TakePendingConfigurationUpdate(anType))
+
+	// the queued changes are merged
+	ue.QueueConfigurationUpdate(anType, &ConfigurationUpdateCommandFlags{NeedGUTI: true})
+	ue.QueueConfigurationUpdate(anType, &ConfigurationUpdateCommandFlags{NeedAllowedNSSAI: true, NeedRegistration: true})
+	require.True(t, ue.HasPendingConfigurationUpdate(anType))
+	require.False(t, ue.HasPendingConfigurationUpdate(models.AccessType_NON_3_GPP_ACCESS))
+	flags := ue.TakePendingConfigurationUpdate(anType)
+	require.Equal(t, &ConfigurationUpdateCommandFlags{
+		NeedGUTI:         true,
+		NeedAllowedNSSAI: true,
+		NeedRegistration: true,
+	}, flags)
+	require.False(t, ue.HasPendingConfigurationUpdate(anType))
+
+	// the changes queued while a command awaits acknowledgement are held back until it ends
+	ue.SetConfigurationUpdateSent(anType, flags)
+	ue.QueueConfigurationUpdate(anType, &ConfigurationUpdateCommandFlags{NeedNITZ: true})
+	require.Nil(t, ue.TakePendingConfigurationUpdate(anType))
+	sent, sentAnType := ue.ConfigurationUpdateSent()
+	require.Same(t, flags, sent)
+	require.Equal(t, anType, sentAnType)
+	ue.AbortConfigurationUpdate()
+	require.Equal(t, &ConfigurationUpdateCommandFlags{NeedNITZ: true}, ue.TakePendingConfigurationUpdate(anType))
+
+	// the deregistration from the access drops its changes
+	ue.SetConfigurationUpdateSent(anType, flags)
+	ue.QueueConfigurationUpdate(anType, &ConfigurationUpdateCommandFlags{NeedTaiList: true})
+	ue.ClearConfigurationUpdate(anType)
+	sent, _ = ue.ConfigurationUpdateSent()
+	require.Nil(t, sent)
+	require.False(t, ue.HasPendingConfigurationUpdate(anType))
+}
+
+func TestConfigurationUpdateReleasedConnection(t *testing.T) {
+	ue := &AmfUe{}
+	ue.init()
+	anType := models.AccessType__3_GPP_ACCESS
+	flags := &ConfigurationUpdateCommandFlags{NeedRegistration: true}
+
+	// the command sent without T3555 is no longer awaited once the connection is released
+	ue.RanUe[anType] = &RanUe{}
+	ue.SetConfigurationUpdateSent(anType, flags)
+	ue.DetachRanUe(models.AccessType_NON_3_GPP_ACCESS)
+	sent, _ := ue.ConfigurationUpdateSent()
+	require.Same(t, flags, sent)
+	ue.DetachRanUe(anType)
+	sent, _ = ue.ConfigurationUpdateSent()
+	require.Nil(t, sent)
+}
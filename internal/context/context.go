@@ -26,6 +26,7 @@ var (
 	tmsiGenerator                    *idgenerator.IDGenerator = nil
 	amfUeNGAPIDGenerator             *idgenerator.IDGenerator = nil
 	amfStatusSubscriptionIDGenerator *idgenerator.IDGenerator = nil
+	trsrGenerator                    *idgenerator.IDGenerator = nil
 )
 
 func init() {
@@ -38,6 +39,8 @@ func init() {
 	GetSelf().PlmnSupportList = make([]factory.PlmnSupportItem, 0, MaxNumOfPLMNs)
 	GetSelf().NfService = make(map[models.ServiceName]models.NrfNfManagementNfService)
 	GetSelf().NetworkName.Full = "free5GC"
+	GetSelf().TraceCollector = NewTraceCollector(factory.AmfTraceDefaultMaxRecords)
+	trsrGenerator = idgenerator.NewGenerator(1, MaxValueOfTrsr)
 	tmsiGenerator = idgenerator.NewGenerator(1, math.MaxInt32)
 	amfStatusSubscriptionIDGenerator = idgenerator.NewGenerator(1, math.MaxInt32)
 	amfUeNGAPIDGenerator = idgenerator.NewGenerator(1, MaxValueOfAmfUeNgapId)
@@ -58,6 +61,8 @@ type AMFContext struct {
 	LadnPool                     map[string]factory.Ladn // dnn as key
 	SupportTaiLists              []models.Tai
 	ServedGuamiList              []models.Guami
+	BackupAmfList                []factory.BackupAmf // backup AMF of the served GUAMIs
+	BackedUpGuamiList            []models.Guami      // GUAMIs of the failed AMFs of the set this AMF takes over
 	PlmnSupportList              []factory.PlmnSupportItem
 	RelativeCapacity             int64
 	NfId                         string
@@ -70,10 +75,12 @@ type AMFContext struct {
 	HttpIPv6Address              string
 	TNLWeightFactor              int64
 	SupportDnnLists              []string
-	AMFStatusSubscriptions       sync.Map // map[subscriptionID]models.SubscriptionData
+	AMFStatusSubscriptions       sync.Map        // map[subscriptionID]models.SubscriptionData
+	TraceCollector               *TraceCollector // Cell Traffic Trace reports kept for retrieval
 	NrfUri                       string
 	NrfCertPem                   string
 	SecurityAlgorithm            SecurityAlgorithm
+	HorizontalKamfDerivation     bool // hand a KAMF derived horizontally to the new AMF on inter-AMF mobility
 	NetworkName                  factory.NetworkName
 	NgapIpList                   []string // NGAP Server IP
 	NgapPort                     int
@@ -82,16 +89,22 @@ type AMFContext struct {
 	Non3gppDeregTimerValue       int    // unit is second
 	TimeZone                     string // "[+-]HH:MM[+][1-2]", Refer to TS 29.571 - 5.2.2 Simple Data Types
 	// read-only fields
-	T3513Cfg factory.TimerValue
-	T3522Cfg factory.TimerValue
-	T3550Cfg factory.TimerValue
-	T3560Cfg factory.TimerValue
-	T3565Cfg factory.TimerValue
-	T3570Cfg factory.TimerValue
-	T3555Cfg factory.TimerValue
-	Locality string
+	T3513Cfg  factory.TimerValue
+	T3522Cfg  factory.TimerValue
+	T3550Cfg  factory.TimerValue
+	T3560Cfg  factory.TimerValue
+	T3565Cfg  factory.TimerValue
+	T3570Cfg  factory.TimerValue
+	T3555Cfg  factory.TimerValue
+	PagingCfg *factory.Paging
+	TraceCfg  *factory.Trace
+	Locality  string
 
 	OAuth2Required bool
+	// read-only NAS security policy, nil when the failing uplink NAS messages are only discarded
+	NasSecurityCfg *factory.NasSecurity
+	// read-only roaming partners, nil when the UEs of all PLMNs are served as if they were at home
+	RoamingCfg *factory.Roaming
 }
 
 type AMFContextEventSubscription struct {
@@ -128,6 +141,10 @@ func InitAmfContext(context *AMFContext) {
 
 	context.InitNFService(config.GetServiceNameList(), config.GetVersion())
 	context.ServedGuamiList = configuration.ServedGumaiList
+	if amfSet := config.GetAmfSet(); amfSet != nil {
+		context.BackupAmfList = amfSet.BackupAmfList
+		context.BackedUpGuamiList = amfSet.BackedUpGuamiList
+	}
 	context.SupportTaiLists = configuration.SupportTAIList
 	context.PlmnSupportList = configuration.PlmnSupportList
 	context.SupportDnnLists = configuration.SupportDnnList
@@ -140,6 +157,7 @@ func InitAmfContext(context *AMFContext) {
 	if security != nil {
 		context.SecurityAlgorithm.IntegrityOrder = getIntAlgOrder(security.IntegrityOrder)
 		context.SecurityAlgorithm.CipheringOrder = getEncAlgOrder(security.CipheringOrder)
+		context.HorizontalKamfDerivation = security.HorizontalKamfDerivation
 	}
 	context.NetworkName = configuration.NetworkName
 	context.TimeZone = nasConvert.GetTimeZone(time.Now())
@@ -153,7 +171,13 @@ func InitAmfContext(context *AMFContext) {
 	context.T3565Cfg = configuration.T3565
 	context.T3570Cfg = configuration.T3570
 	context.T3555Cfg = configuration.T3555
+	context.PagingCfg = config.GetPaging()
+	context.TraceCfg = config.GetTrace()
+	context.NasSecurityCfg = config.GetNasSecurity()
+	context.RoamingCfg = config.GetRoaming()
+	context.TraceCollector = NewTraceCollector(context.TraceCfg.MaxRecords)
 	context.Locality = configuration.Locality
+	initSliceOutages(config.GetSliceOutages())
 }
 
 func getIntAlgOrder(integrityOrder []string) (intOrder []uint8) {
@@ -198,15 +222,21 @@ func NewPlmnSupportItem() (item factory.PlmnSupportItem) {
 }
 
 func (context *AMFContext) TmsiAllocate() int32 {
-	tmsi, err := tmsiGenerator.Allocate()
-	if err != nil {
-		logger.CtxLog.Errorf("Allocate TMSI error: %+v", err)
-		return -1
+	for {
+		tmsi, err := tmsiGenerator.Allocate()
+		if err != nil {
+			logger.CtxLog.Errorf("Allocate TMSI error: %+v", err)
+			return -1
+		}
+		// the TMSI of a restored UE stays allocated until the UE is removed
+		if _, restored := restoredTmsi.Load(int32(tmsi)); !restored {
+			return int32(tmsi)
+		}
 	}
-	return int32(tmsi)
 }
 
 func (context *AMFContext) FreeTmsi(tmsi int64) {
+	restoredTmsi.Delete(int32(tmsi))
 	tmsiGenerator.FreeID(tmsi)
 }
 
@@ -221,6 +251,16 @@ func (context *AMFContext) AllocateGutiToUe(ue *AmfUe) {
 	plmnID := servedGuami.PlmnId.Mcc + servedGuami.PlmnId.Mnc
 	tmsiStr := fmt.Sprintf("%08x", ue.Tmsi)
 	ue.Guti = plmnID + servedGuami.AmfId + tmsiStr
+	ue.GutiAllocatedAt = time.Now()
+	ue.GutiServiceRequests = 0
+}
+
+// ReallocateGutiToUe assigns a new 5G-GUTI to the registered UE, its current 5G-GUTI staying valid
+// until ReleaseOldGuti once the UE acknowledged the new one (TS 24.501 5.4.4.2)
+func (context *AMFContext) ReallocateGutiToUe(ue *AmfUe) {
+	ue.ReleaseOldGuti()
+	ue.OldTmsi, ue.OldGuti = ue.Tmsi, ue.Guti
+	context.AllocateGutiToUe(ue)
 }
 
 func (context *AMFContext) AllocateRegistrationArea(ue *AmfUe, anType models.AccessType) {
@@ -452,7 +492,8 @@ func (context *AMFContext) AmfUeFindByGuti(guti string) (*AmfUe, bool) {
 	var ok bool
 	context.UePool.Range(func(key, value interface{}) bool {
 		candidate := value.(*AmfUe)
-		if ok = (candidate.Guti == guti); ok {
+		// the UE may not have received its reallocated 5G-GUTI yet
+		if ok = (candidate.Guti == guti || (candidate.OldGuti != "" && candidate.OldGuti == guti)); ok {
 			ue = candidate
 			return false
 		}
@@ -541,6 +582,8 @@ func (context *AMFContext) Reset() {
 	context.SupportTaiLists = context.SupportTaiLists[:0]
 	context.PlmnSupportList = context.PlmnSupportList[:0]
 	context.ServedGuamiList = context.ServedGuamiList[:0]
+	context.BackupAmfList = nil
+	context.BackedUpGuamiList = nil
 	context.RelativeCapacity = 0xff
 	context.NfId = ""
 	context.UriScheme = models.UriScheme_HTTPS
@@ -552,6 +595,8 @@ func (context *AMFContext) Reset() {
 	context.NrfUri = ""
 	context.NrfCertPem = ""
 	context.OAuth2Required = false
+	context.RoamingCfg = nil
+	initSliceOutages(nil)
 }
 
 // Create new AMF context
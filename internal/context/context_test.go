@@ -0,0 +1,78 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestReallocateGutiToUe(t *testing.T) {
+	self := GetSelf()
+	self.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		self.ServedGuamiList = nil
+	}()
+
+	ue := self.NewAmfUe("imsi-208930000000003")
+	defer ue.Remove()
+	firstGuti := ue.Guti
+	ue.GutiServiceRequests = 3
+
+	// the replaced 5G-GUTI still finds the UE until it is released
+	self.ReallocateGutiToUe(ue)
+	require.NotEqual(t, firstGuti, ue.Guti)
+	require.Equal(t, firstGuti, ue.OldGuti)
+	require.Zero(t, ue.GutiServiceRequests)
+	for _, guti := range []string{firstGuti, ue.Guti} {
+		found, ok := self.AmfUeFindByGuti(guti)
+		require.True(t, ok)
+		require.Same(t, ue, found)
+	}
+
+	ue.ReleaseOldGuti()
+	require.Empty(t, ue.OldGuti)
+	_, ok := self.AmfUeFindByGuti(firstGuti)
+	require.False(t, ok)
+	found, ok := self.AmfUeFindByGuti(ue.Guti)
+	require.True(t, ok)
+	require.Same(t, ue, found)
+
+	// the 5G-GUTI replaced by an aborted Configuration Update Command is released too
+	secondGuti := ue.Guti
+	self.ReallocateGutiToUe(ue)
+	ue.SetConfigurationUpdateSent(models.AccessType__3_GPP_ACCESS, &ConfigurationUpdateCommandFlags{NeedGUTI: true})
+	ue.AbortConfigurationUpdate()
+	require.Empty(t, ue.OldGuti)
+	_, ok = self.AmfUeFindByGuti(secondGuti)
+	require.False(t, ok)
+}
+
+func TestDerivateHorizontalKamf(t *testing.T) {
+	kamf := "a1f3b2c4d5e6f708192a3b4c5d6e7f80a1f3b2c4d5e6f708192a3b4c5d6e7f80"
+	ue := &AmfUe{Kamf: kamf}
+	ue.ULCount.Set(1, 2)
+	require.False(t, ue.NasCountNearWrapAround())
+
+	// the KAMF' depends on the uplink NAS COUNT
+	derived, err := ue.HorizontalKamf()
+	require.NoError(t, err)
+	require.Len(t, derived, 64)
+	require.NotEqual(t, kamf, derived)
+	ue.ULCount.Set(1, 3)
+	other, err := ue.HorizontalKamf()
+	require.NoError(t, err)
+	require.NotEqual(t, derived, other)
+
+	// KDF of TS 33.220 B.2 with FC = 0x72, P0 = 0x00 (uplink), P1 = 0x00000102 (TS 33.501 A.13)
+	require.Equal(t, "7f7e8dbd89b23a99e2f24b827347928cfc58d7847f235675e791ac1d5de49dba", derived)
+
+	ue.ULCount.Set(1, 2)
+	ue.DerivateHorizontalKamf()
+	require.Equal(t, derived, ue.Kamf)
+	require.True(t, ue.KamfChanged)
+
+	ue.DLCount.Set(uint16(NasCountRefreshThreshold>>8), 0)
+	require.True(t, ue.NasCountNearWrapAround())
+}
@@ -0,0 +1,105 @@
+package context
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+)
+
+const (
+	// MaxNumOfSentDlNas is the number of downlink NAS PDUs remembered per RanUe,
+	// so that a NAS Non Delivery Indication can be related to the procedure which sent the PDU
+	MaxNumOfSentDlNas int = 8
+	// DlNasBufferTimeout is how long undelivered downlink NAS PDUs wait for the handover to complete
+	DlNasBufferTimeout = 10 * time.Second
+)
+
+// DlNasProcedure is the procedure a downlink NAS PDU sent in Downlink NAS Transport belongs to
+type DlNasProcedure string
+
+const (
+	DlNasProcedureGmm                 DlNasProcedure = "5GMM"
+	DlNasProcedureDlNasTransport      DlNasProcedure = "DlNasTransport"
+	DlNasProcedureN1N2MessageTransfer DlNasProcedure = "N1N2MessageTransfer"
+	DlNasProcedureConfigurationUpdate DlNasProcedure = "ConfigurationUpdate"
+)
+
+type DlNasOrigin struct {
+	Procedure    DlNasProcedure
+	PduSessionId int32
+	// N1N2 Message Transfer only, where the transfer failure is notified (TS 29.518 5.2.2.3.1)
+	FailureNotifyUri string
+	ResourceUri      string
+}
+
+type DlNasMessage struct {
+	NasPdu                  []byte
+	MobilityRestrictionList *ngapType.MobilityRestrictionList
+	Origin                  DlNasOrigin
+}
+
+// RecordDlNas remembers the downlink NAS PDU sent to the UE; the oldest one is forgotten
+// once MaxNumOfSentDlNas PDUs are remembered
+func (ranUe *RanUe) RecordDlNas(msg *DlNasMessage) {
+	ranUe.dlNasMu.Lock()
+	defer ranUe.dlNasMu.Unlock()
+	if len(ranUe.sentDlNas) >= MaxNumOfSentDlNas {
+		ranUe.sentDlNas = ranUe.sentDlNas[1:]
+	}
+	ranUe.sentDlNas = append(ranUe.sentDlNas, msg)
+}
+
+// TakeDlNas returns and forgets the sent downlink NAS message carrying the NAS PDU
+func (ranUe *RanUe) TakeDlNas(nasPdu []byte) *DlNasMessage {
+	ranUe.dlNasMu.Lock()
+	defer ranUe.dlNasMu.Unlock()
+	for i := len(ranUe.sentDlNas) - 1; i >= 0; i-- {
+		if msg := ranUe.sentDlNas[i]; bytes.Equal(msg.NasPdu, nasPdu) {
+			ranUe.sentDlNas = append(ranUe.sentDlNas[:i], ranUe.sentDlNas[i+1:]...)
+			return msg
+		}
+	}
+	return nil
+}
+
+// BufferDlNas keeps the downlink NAS message which the NG-RAN could not deliver because of a handover,
+// until TakeBufferedDlNas is called once the handover completes. If it is not called within DlNasBufferTimeout,
+// the buffered messages are handed to onTimeout.
+func (ue *AmfUe) BufferDlNas(anType models.AccessType, msg *DlNasMessage, onTimeout func([]*DlNasMessage)) {
+	ue.dlNasMu.Lock()
+	defer ue.dlNasMu.Unlock()
+	ue.bufferedDlNas[anType] = append(ue.bufferedDlNas[anType], msg)
+	if ue.dlNasBufferTimer[anType] != nil {
+		return
+	}
+	var timer *Timer
+	timer = ue.NewTimer(DlNasBufferTimeout, 0, func(expireTimes int32) {}, func() {
+		ue.dlNasMu.Lock()
+		if ue.dlNasBufferTimer[anType] != timer {
+			// taken just before the timeout
+			ue.dlNasMu.Unlock()
+			return
+		}
+		msgs := ue.bufferedDlNas[anType]
+		delete(ue.bufferedDlNas, anType)
+		delete(ue.dlNasBufferTimer, anType)
+		ue.dlNasMu.Unlock()
+		onTimeout(msgs)
+	})
+	ue.dlNasBufferTimer[anType] = timer
+}
+
+// TakeBufferedDlNas returns and clears the buffered downlink NAS messages of the access, in sending order
+func (ue *AmfUe) TakeBufferedDlNas(anType models.AccessType) []*DlNasMessage {
+	ue.dlNasMu.Lock()
+	defer ue.dlNasMu.Unlock()
+	msgs := ue.bufferedDlNas[anType]
+	delete(ue.bufferedDlNas, anType)
+	if timer := ue.dlNasBufferTimer[anType]; timer != nil {
+		timer.Stop()
+		delete(ue.dlNasBufferTimer, anType)
+	}
+	return msgs
+}
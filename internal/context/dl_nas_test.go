@@ -0,0 +1,56 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestRecordDlNas(t *testing.T) {
+	ranUe := &RanUe{}
+	for i := 0; i < MaxNumOfSentDlNas+2; i++ {
+		ranUe.RecordDlNas(&DlNasMessage{
+			NasPdu: []byte{0x7e, byte(i)},
+			Origin: DlNasOrigin{Procedure: DlNasProcedureGmm},
+		})
+	}
+	ranUe.RecordDlNas(&DlNasMessage{
+		NasPdu: []byte{0x7e, 0xff},
+		Origin: DlNasOrigin{
+			Procedure:        DlNasProcedureN1N2MessageTransfer,
+			PduSessionId:     1,
+			FailureNotifyUri: "http://smf/callback",
+		},
+	})
+
+	// the oldest PDUs are forgotten
+	require.Nil(t, ranUe.TakeDlNas([]byte{0x7e, 0x00}))
+	require.Nil(t, ranUe.TakeDlNas([]byte{0x7e, 0x02}))
+
+	msg := ranUe.TakeDlNas([]byte{0x7e, 0xff})
+	require.NotNil(t, msg)
+	require.Equal(t, DlNasProcedureN1N2MessageTransfer, msg.Origin.Procedure)
+	require.Equal(t, int32(1), msg.Origin.PduSessionId)
+	// a PDU is taken only once
+	require.Nil(t, ranUe.TakeDlNas([]byte{0x7e, 0xff}))
+	require.NotNil(t, ranUe.TakeDlNas([]byte{0x7e, 0x03}))
+}
+
+func TestBufferDlNas(t *testing.T) {
+	ue := &AmfUe{}
+	ue.init()
+
+	onTimeout := func([]*DlNasMessage) {
+		t.Error("buffered messages must not time out once taken")
+	}
+	first := &DlNasMessage{NasPdu: []byte{0x7e, 0x01}}
+	second := &DlNasMessage{NasPdu: []byte{0x7e, 0x02}}
+	ue.BufferDlNas(models.AccessType__3_GPP_ACCESS, first, onTimeout)
+	ue.BufferDlNas(models.AccessType__3_GPP_ACCESS, second, onTimeout)
+
+	require.Empty(t, ue.TakeBufferedDlNas(models.AccessType_NON_3_GPP_ACCESS))
+	require.Equal(t, []*DlNasMessage{first, second}, ue.TakeBufferedDlNas(models.AccessType__3_GPP_ACCESS))
+	require.Empty(t, ue.TakeBufferedDlNas(models.AccessType__3_GPP_ACCESS))
+}
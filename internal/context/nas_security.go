@@ -0,0 +1,115 @@
+package context
+
+import (
+	business_metrics "github.com/free5gc/amf/internal/metrics/business"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/nas/security"
+	"github.com/free5gc/openapi/models"
+)
+
+// NasReplayWindow is the number of uplink NAS COUNTs below the highest one accepted that a NAS message received
+// out of order may still use, each uplink NAS COUNT being accepted once (TS 33.501 6.4.3.1)
+const NasReplayWindow = 32
+
+// Reasons of the uplink NAS messages failing the NAS security policy
+const (
+	NasSecurityFailureMac               = "mac_failure"
+	NasSecurityFailureReplay            = "replay"
+	NasSecurityFailureSecurityHeader    = "security_header"
+	NasSecurityFailureNoSecurityContext = "no_security_context"
+)
+
+// nasSecurityState is the replay protection of the uplink NAS COUNTs and the failure count of a UE
+type nasSecurityState struct {
+	// accepted is set once ULCount was accepted in the current NAS security context
+	accepted bool
+	// window has bit n set when ULCount-1-n was accepted
+	window uint32
+	// failures is the number of consecutive uplink NAS messages failing the NAS security policy
+	failures int
+}
+
+// EstimateUplinkCount returns the uplink NAS COUNT of a NAS message carrying the sequence number: the one in the
+// replay window if the message came out of order, the next one with that sequence number otherwise
+func (ue *AmfUe) EstimateUplinkCount(sqn uint8) security.Count {
+	count := ue.ULCount
+	if sqn < count.SQN() && count.SQN()-sqn > NasReplayWindow {
+		count.SetOverflow(count.Overflow() + 1)
+	}
+	count.SetSQN(sqn)
+	return count
+}
+
+// UplinkCountReplayed reports whether the uplink NAS COUNT was already accepted or is too old to tell
+func (ue *AmfUe) UplinkCountReplayed(count security.Count) bool {
+	if !ue.nasSecurity.accepted {
+		return false
+	}
+	highest, value := ue.ULCount.Get(), count.Get()
+	switch {
+	case value > highest:
+		return false
+	case value == highest, highest-value > NasReplayWindow:
+		return true
+	default:
+		return ue.nasSecurity.window&(1<<(highest-value-1)) != 0
+	}
+}
+
+// AcceptUplinkCount records the uplink NAS COUNT of a NAS message that passed the integrity check, ending the
+// series of failures of the UE
+func (ue *AmfUe) AcceptUplinkCount(count security.Count) {
+	state := &ue.nasSecurity
+	state.failures = 0
+	highest, value := ue.ULCount.Get(), count.Get()
+	switch {
+	case !state.accepted:
+		state.window = 0
+	case value > highest:
+		shift := value - highest
+		if shift > NasReplayWindow {
+			state.window = 0
+		} else {
+			state.window = state.window<<shift | 1<<(shift-1)
+		}
+	default:
+		state.window |= 1 << (highest - value - 1)
+		return
+	}
+	state.accepted = true
+	ue.ULCount = count
+}
+
+// MarkUplinkCountAccepted considers ULCount, taken over from another AMF, as already used by the UE
+func (ue *AmfUe) MarkUplinkCountAccepted() {
+	ue.nasSecurity.accepted = true
+	ue.nasSecurity.window = 0
+}
+
+// ResetNasCounts sets the NAS COUNTs to zero for a new NAS security context
+func (ue *AmfUe) ResetNasCounts() {
+	ue.ULCount.Set(0, 0)
+	ue.DLCount.Set(0, 0)
+	ue.nasSecurity.accepted = false
+	ue.nasSecurity.window = 0
+}
+
+// RecordNasSecurityFailure counts an uplink NAS message failing the NAS security policy, an alarm being raised when
+// the UE reaches the maximum number of consecutive failures
+func (ue *AmfUe) RecordNasSecurityFailure(anType models.AccessType, reason string) {
+	ue.nasSecurity.failures++
+	business_metrics.IncrNasSecurityFailureCounter(anType, reason)
+	if cfg := GetSelf().NasSecurityCfg; cfg != nil && ue.nasSecurity.failures == cfg.MaxFailures {
+		ue.NASLog.Errorf("NAS security alarm: %d consecutive uplink NAS messages over %s failed the security policy, "+
+			"last one for %s", ue.nasSecurity.failures, anType, reason)
+		business_metrics.IncrNasSecurityAlarmCounter(anType)
+	}
+}
+
+// NasSecurityReleaseRequired reports whether the NAS signalling connection of the UE has to be released, the UE
+// having just reached the maximum number of consecutive failures
+func (ue *AmfUe) NasSecurityReleaseRequired() bool {
+	cfg := GetSelf().NasSecurityCfg
+	return cfg != nil && cfg.MaxFailures > 0 && cfg.FailureAction == factory.NasSecurityFailureActionRelease &&
+		ue.nasSecurity.failures == cfg.MaxFailures
+}
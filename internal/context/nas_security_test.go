@@ -0,0 +1,69 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestUplinkCountReplayProtection(t *testing.T) {
+	ue := &AmfUe{}
+	count := ue.EstimateUplinkCount(0)
+	require.False(t, ue.UplinkCountReplayed(count))
+	ue.AcceptUplinkCount(count)
+	require.True(t, ue.UplinkCountReplayed(count))
+
+	// messages skipped by the UE may still come out of order, but once
+	count = ue.EstimateUplinkCount(5)
+	require.Equal(t, uint32(5), count.Get())
+	ue.AcceptUplinkCount(count)
+	late := ue.EstimateUplinkCount(3)
+	require.Equal(t, uint32(3), late.Get())
+	require.False(t, ue.UplinkCountReplayed(late))
+	ue.AcceptUplinkCount(late)
+	require.True(t, ue.UplinkCountReplayed(late))
+	require.Equal(t, uint32(5), ue.ULCount.Get())
+
+	// a sequence number far below the highest one is the next overflow
+	ue.ResetNasCounts()
+	count.Set(0, 250)
+	ue.AcceptUplinkCount(count)
+	count = ue.EstimateUplinkCount(2)
+	require.Equal(t, uint32(0x102), count.Get())
+	require.False(t, ue.UplinkCountReplayed(count))
+	ue.AcceptUplinkCount(count)
+	old := ue.ULCount
+	old.Set(0, 200)
+	require.True(t, ue.UplinkCountReplayed(old))
+
+	// a new NAS security context starts over
+	ue.ResetNasCounts()
+	require.False(t, ue.UplinkCountReplayed(ue.EstimateUplinkCount(0)))
+}
+
+func TestNasSecurityFailures(t *testing.T) {
+	self := GetSelf()
+	t.Cleanup(func() { self.NasSecurityCfg = nil })
+	self.NasSecurityCfg = &factory.NasSecurity{
+		MaxFailures:   2,
+		FailureAction: factory.NasSecurityFailureActionRelease,
+	}
+
+	ue := &AmfUe{NASLog: logger.NasLog}
+	anType := models.AccessType__3_GPP_ACCESS
+	ue.RecordNasSecurityFailure(anType, NasSecurityFailureMac)
+	require.False(t, ue.NasSecurityReleaseRequired())
+	// an accepted message ends the series of failures
+	ue.AcceptUplinkCount(ue.EstimateUplinkCount(1))
+	ue.RecordNasSecurityFailure(anType, NasSecurityFailureReplay)
+	require.False(t, ue.NasSecurityReleaseRequired())
+	ue.RecordNasSecurityFailure(anType, NasSecurityFailureSecurityHeader)
+	require.True(t, ue.NasSecurityReleaseRequired())
+
+	self.NasSecurityCfg.FailureAction = factory.NasSecurityFailureActionDrop
+	require.False(t, ue.NasSecurityReleaseRequired())
+}
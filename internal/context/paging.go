@@ -0,0 +1,146 @@
+package context
+
+import (
+	"reflect"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// PagingStrategy returns the ordered paging steps for the ongoing paging of the UE.
+// High priority paging (TS 23.501 5.22.3) always pages the whole registration area,
+// otherwise the policy of the S-NSSAI that triggered the paging takes precedence over the default strategy.
+func (ue *AmfUe) PagingStrategy() []string {
+	cfg := ue.servingAMF.PagingCfg
+	if cfg == nil {
+		return []string{factory.PagingStepRegistrationArea}
+	}
+
+	ppi := ue.onGoing[models.AccessType__3_GPP_ACCESS].Ppi
+	if cfg.PriorityThreshold > 0 && ppi > 0 && ppi <= cfg.PriorityThreshold {
+		return []string{factory.PagingStepRegistrationArea}
+	}
+
+	if snssai, ok := ue.pagingSnssai(); ok {
+		for _, policy := range cfg.SlicePolicyList {
+			if policy.Snssai != nil && openapi.SnssaiEqualFold(*policy.Snssai, snssai) {
+				return policy.Strategy
+			}
+		}
+	}
+
+	if len(cfg.Strategy) == 0 {
+		return []string{factory.PagingStepRegistrationArea}
+	}
+	return cfg.Strategy
+}
+
+// pagingSnssai returns the S-NSSAI of the PDU session the pending N1N2 message is destined to
+func (ue *AmfUe) pagingSnssai() (models.Snssai, bool) {
+	if ue.N1N2Message == nil || ue.N1N2Message.Request.JsonData == nil {
+		return models.Snssai{}, false
+	}
+	smContext, ok := ue.SmContextFindByPDUSessionID(ue.N1N2Message.Request.JsonData.PduSessionId)
+	if !ok {
+		return models.Snssai{}, false
+	}
+	return smContext.Snssai(), true
+}
+
+// PagingTargets returns the RAN nodes to be paged at the given attempt (0 for the initial paging,
+// then the T3513 expire times). Steps which yield no RAN node are skipped so that no attempt is wasted;
+// once the strategy is exhausted, its last step is repeated.
+func (ue *AmfUe) PagingTargets(attempt int) (string, []*AmfRan) {
+	strategy := ue.PagingStrategy()
+	if attempt >= len(strategy) {
+		attempt = len(strategy) - 1
+	}
+	for _, step := range strategy[attempt:] {
+		if rans := ue.pagingTargetsOfStep(step); len(rans) > 0 {
+			return step, rans
+		}
+	}
+	return "", nil
+}
+
+func (ue *AmfUe) pagingTargetsOfStep(step string) []*AmfRan {
+	switch step {
+	case factory.PagingStepLastRan:
+		if ue.LastSeenRanId == nil {
+			return nil
+		}
+		if ran, ok := ue.servingAMF.AmfRanFindByRanID(*ue.LastSeenRanId); ok {
+			return []*AmfRan{ran}
+		}
+		return nil
+	case factory.PagingStepRecommended:
+		return ue.recommendedPagingTargets()
+	case factory.PagingStepLastTai:
+		if ue.Tai.PlmnId == nil {
+			return nil
+		}
+		return ue.servingAMF.amfRansSupportingTai([]models.Tai{ue.Tai})
+	case factory.PagingStepRegistrationArea:
+		return ue.servingAMF.amfRansSupportingTai(ue.RegistrationArea[models.AccessType__3_GPP_ACCESS])
+	}
+	return nil
+}
+
+// recommendedPagingTargets uses the Recommended RAN Nodes for Paging stored from
+// UE Context Release Complete (TS 38.413 9.3.1.101)
+func (ue *AmfUe) recommendedPagingTargets() []*AmfRan {
+	info := ue.InfoOnRecommendedCellsAndRanNodesForPaging
+	if info == nil {
+		return nil
+	}
+
+	var rans []*AmfRan
+	var taiList []models.Tai
+	for _, ranNode := range info.RecommendedRanNodes {
+		switch ranNode.Present {
+		case RecommendRanNodePresentRanNode:
+			if ranNode.GlobalRanNodeId == nil {
+				continue
+			}
+			if ran, ok := ue.servingAMF.AmfRanFindByRanID(*ranNode.GlobalRanNodeId); ok {
+				rans = appendAmfRan(rans, ran)
+			}
+		case RecommendRanNodePresentTAI:
+			if ranNode.Tai != nil {
+				taiList = append(taiList, *ranNode.Tai)
+			}
+		}
+	}
+	for _, ran := range ue.servingAMF.amfRansSupportingTai(taiList) {
+		rans = appendAmfRan(rans, ran)
+	}
+	return rans
+}
+
+func (context *AMFContext) amfRansSupportingTai(taiList []models.Tai) []*AmfRan {
+	var rans []*AmfRan
+	if len(taiList) == 0 {
+		return rans
+	}
+	context.AmfRanPool.Range(func(key, value interface{}) bool {
+		ran := value.(*AmfRan)
+		for _, item := range ran.SupportedTAList {
+			if InTaiList(item.Tai, taiList) {
+				rans = append(rans, ran)
+				break
+			}
+		}
+		return true
+	})
+	return rans
+}
+
+func appendAmfRan(rans []*AmfRan, ran *AmfRan) []*AmfRan {
+	for _, r := range rans {
+		if r == ran || reflect.DeepEqual(r.RanId, ran.RanId) {
+			return rans
+		}
+	}
+	return append(rans, ran)
+}
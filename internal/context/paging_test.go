@@ -0,0 +1,117 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestPagingTargetsEscalation(t *testing.T) {
+	self := GetSelf()
+	plmnID := &models.PlmnId{Mcc: "208", Mnc: "93"}
+	tai1 := models.Tai{PlmnId: plmnID, Tac: "000001"}
+	tai2 := models.Tai{PlmnId: plmnID, Tac: "000002"}
+
+	newRan := func(key, gnbID string, tai models.Tai) *AmfRan {
+		ran := &AmfRan{
+			RanPresent:      RanPresentGNbId,
+			RanId:           &models.GlobalRanNodeId{PlmnId: plmnID, GNbId: &models.GNbId{GNBValue: gnbID}},
+			AnType:          models.AccessType__3_GPP_ACCESS,
+			SupportedTAList: []SupportedTAI{{Tai: tai}},
+			Log:             logger.NgapLog.WithField("", ""),
+		}
+		self.AmfRanPool.Store(key, ran)
+		return ran
+	}
+	ranA := newRan("ranA", "000001", tai1)
+	ranB := newRan("ranB", "000002", tai1)
+	ranC := newRan("ranC", "000003", tai2)
+
+	prevCfg := self.PagingCfg
+	defer func() {
+		self.PagingCfg = prevCfg
+		for _, key := range []string{"ranA", "ranB", "ranC"} {
+			self.AmfRanPool.Delete(key)
+		}
+	}()
+	self.PagingCfg = &factory.Paging{
+		Strategy: []string{
+			factory.PagingStepLastRan,
+			factory.PagingStepRecommended,
+			factory.PagingStepLastTai,
+			factory.PagingStepRegistrationArea,
+		},
+		PriorityThreshold: 2,
+	}
+
+	ue := &AmfUe{}
+	ue.init()
+	ue.Tai = tai1
+	ue.RegistrationArea[models.AccessType__3_GPP_ACCESS] = []models.Tai{tai1, tai2}
+	ue.LastSeenRanId = ranA.RanId
+
+	step, rans := ue.PagingTargets(0)
+	require.Equal(t, factory.PagingStepLastRan, step)
+	require.ElementsMatch(t, []*AmfRan{ranA}, rans)
+
+	// no recommended RAN node is stored, the attempt falls through to the last TAI
+	step, rans = ue.PagingTargets(1)
+	require.Equal(t, factory.PagingStepLastTai, step)
+	require.ElementsMatch(t, []*AmfRan{ranA, ranB}, rans)
+
+	step, rans = ue.PagingTargets(3)
+	require.Equal(t, factory.PagingStepRegistrationArea, step)
+	require.ElementsMatch(t, []*AmfRan{ranA, ranB, ranC}, rans)
+
+	// retries beyond the strategy keep paging the widest area
+	step, _ = ue.PagingTargets(10)
+	require.Equal(t, factory.PagingStepRegistrationArea, step)
+
+	ue.InfoOnRecommendedCellsAndRanNodesForPaging = &InfoOnRecommendedCellsAndRanNodesForPaging{
+		RecommendedRanNodes: []RecommendRanNode{
+			{Present: RecommendRanNodePresentRanNode, GlobalRanNodeId: ranB.RanId},
+			{Present: RecommendRanNodePresentTAI, Tai: &tai2},
+		},
+	}
+	step, rans = ue.PagingTargets(1)
+	require.Equal(t, factory.PagingStepRecommended, step)
+	require.ElementsMatch(t, []*AmfRan{ranB, ranC}, rans)
+
+	// high priority paging skips the escalation
+	ue.SetOnGoing(models.AccessType__3_GPP_ACCESS, &OnGoing{Procedure: OnGoingProcedurePaging, Ppi: 1})
+	step, rans = ue.PagingTargets(0)
+	require.Equal(t, factory.PagingStepRegistrationArea, step)
+	require.Len(t, rans, 3)
+}
+
+func TestPagingStrategySlicePolicy(t *testing.T) {
+	self := GetSelf()
+	prevCfg := self.PagingCfg
+	defer func() { self.PagingCfg = prevCfg }()
+
+	urllc := models.Snssai{Sst: 2, Sd: "000001"}
+	self.PagingCfg = &factory.Paging{
+		Strategy: []string{factory.PagingStepLastRan, factory.PagingStepRegistrationArea},
+		SlicePolicyList: []factory.PagingSlicePolicy{
+			{Snssai: &urllc, Strategy: []string{factory.PagingStepRegistrationArea}},
+		},
+	}
+
+	ue := &AmfUe{}
+	ue.init()
+	require.Equal(t, self.PagingCfg.Strategy, ue.PagingStrategy())
+
+	smContext := NewSmContext(1)
+	smContext.SetSnssai(urllc)
+	ue.SmContextList.Store(int32(1), smContext)
+	ue.N1N2Message = &N1N2Message{
+		Request: models.N1N2MessageTransferRequest{
+			JsonData: &models.N1N2MessageTransferReqData{PduSessionId: 1},
+		},
+	}
+	require.Equal(t, []string{factory.PagingStepRegistrationArea}, ue.PagingStrategy())
+}
@@ -0,0 +1,98 @@
+package context
+
+import (
+	"github.com/free5gc/openapi/models"
+)
+
+// parametersUpdateState holds the UE parameters update (UPU) and steering of roaming (SoR) information of the UDM
+// until the UE is CM-CONNECTED to receive it, then until the UE acknowledges it when the UDM requested so
+// (TS 23.502 4.20, TS 23.122 Annex C)
+type parametersUpdateState struct {
+	queuedUpu *models.UdmSdmUpuInfo
+	sentUpu   *models.UdmSdmUpuInfo
+	queuedSor *models.UdmSdmSorInfo
+	sentSor   *models.UdmSdmSorInfo
+	// registrationSor is the SoR information of the AM data fetched by the ongoing registration, for its
+	// Registration Accept only (TS 23.122 C.2)
+	registrationSor *models.UdmSdmSorInfo
+}
+
+// QueueUpu keeps the UE parameters update information for the UE to receive once CM-CONNECTED, replacing the one
+// not delivered yet
+func (ue *AmfUe) QueueUpu(upuInfo *models.UdmSdmUpuInfo) {
+	ue.parametersUpdate.queuedUpu = upuInfo
+}
+
+// TakeQueuedUpu returns the UE parameters update information waiting to be delivered and forgets it
+func (ue *AmfUe) TakeQueuedUpu() *models.UdmSdmUpuInfo {
+	upuInfo := ue.parametersUpdate.queuedUpu
+	ue.parametersUpdate.queuedUpu = nil
+	return upuInfo
+}
+
+// SetUpuSent records the UE parameters update information delivered to the UE, to be acknowledged when requested
+func (ue *AmfUe) SetUpuSent(upuInfo *models.UdmSdmUpuInfo) {
+	if upuInfo.UpuAckInd {
+		ue.parametersUpdate.sentUpu = upuInfo
+	}
+}
+
+// TakeSentUpu returns the UE parameters update information awaiting the acknowledgement of the UE and forgets it,
+// nil when the UE is not to acknowledge any
+func (ue *AmfUe) TakeSentUpu() *models.UdmSdmUpuInfo {
+	upuInfo := ue.parametersUpdate.sentUpu
+	ue.parametersUpdate.sentUpu = nil
+	return upuInfo
+}
+
+// QueueSor keeps the steering of roaming information for the UE to receive once CM-CONNECTED, replacing the one
+// not delivered yet
+func (ue *AmfUe) QueueSor(sorInfo *models.UdmSdmSorInfo) {
+	ue.parametersUpdate.queuedSor = sorInfo
+}
+
+// TakeQueuedSor returns the steering of roaming information waiting to be delivered and forgets it
+func (ue *AmfUe) TakeQueuedSor() *models.UdmSdmSorInfo {
+	sorInfo := ue.parametersUpdate.queuedSor
+	ue.parametersUpdate.queuedSor = nil
+	return sorInfo
+}
+
+// SetSorSent records the steering of roaming information delivered to the UE, to be acknowledged when requested
+func (ue *AmfUe) SetSorSent(sorInfo *models.UdmSdmSorInfo) {
+	if sorInfo.AckInd {
+		ue.parametersUpdate.sentSor = sorInfo
+	}
+}
+
+// TakeSentSor returns the steering of roaming information awaiting the acknowledgement of the UE and forgets it,
+// nil when the UE is not to acknowledge any
+func (ue *AmfUe) TakeSentSor() *models.UdmSdmSorInfo {
+	sorInfo := ue.parametersUpdate.sentSor
+	ue.parametersUpdate.sentSor = nil
+	return sorInfo
+}
+
+// SetRegistrationSor keeps the steering of roaming information of the AM data fetched by the ongoing registration,
+// for the Registration Accept of the registration
+func (ue *AmfUe) SetRegistrationSor(sorInfo *models.UdmSdmSorInfo) {
+	ue.parametersUpdate.registrationSor = sorInfo
+}
+
+// RegistrationSor returns the steering of roaming information for the Registration Accept, nil if none
+func (ue *AmfUe) RegistrationSor() *models.UdmSdmSorInfo {
+	return ue.parametersUpdate.registrationSor
+}
+
+// TakeRegistrationSor returns the steering of roaming information for the Registration Accept and forgets it, so
+// that it is sent in one Registration Accept only
+func (ue *AmfUe) TakeRegistrationSor() *models.UdmSdmSorInfo {
+	sorInfo := ue.parametersUpdate.registrationSor
+	ue.parametersUpdate.registrationSor = nil
+	return sorInfo
+}
+
+// HasQueuedParametersUpdate reports whether UPU or SoR information waits for the UE to be CM-CONNECTED
+func (ue *AmfUe) HasQueuedParametersUpdate() bool {
+	return ue.parametersUpdate.queuedUpu != nil || ue.parametersUpdate.queuedSor != nil
+}
@@ -0,0 +1,56 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestParametersUpdateDelivery(t *testing.T) {
+	ue := &AmfUe{}
+	require.False(t, ue.HasQueuedParametersUpdate())
+
+	// the information not delivered yet is replaced
+	ue.QueueUpu(&models.UdmSdmUpuInfo{CounterUpu: "0001"})
+	upuInfo := &models.UdmSdmUpuInfo{CounterUpu: "0002", UpuAckInd: true}
+	ue.QueueUpu(upuInfo)
+	require.True(t, ue.HasQueuedParametersUpdate())
+	require.Same(t, upuInfo, ue.TakeQueuedUpu())
+	require.False(t, ue.HasQueuedParametersUpdate())
+	require.Nil(t, ue.TakeQueuedUpu())
+
+	// only the information the UE is to acknowledge awaits its acknowledgement
+	ue.SetUpuSent(upuInfo)
+	require.Same(t, upuInfo, ue.TakeSentUpu())
+	require.Nil(t, ue.TakeSentUpu())
+	ue.SetUpuSent(&models.UdmSdmUpuInfo{})
+	require.Nil(t, ue.TakeSentUpu())
+
+	sorInfo := &models.UdmSdmSorInfo{Countersor: "0001", AckInd: true}
+	ue.QueueSor(sorInfo)
+	require.True(t, ue.HasQueuedParametersUpdate())
+	require.Same(t, sorInfo, ue.TakeQueuedSor())
+	ue.SetSorSent(sorInfo)
+	require.Same(t, sorInfo, ue.TakeSentSor())
+	ue.SetSorSent(&models.UdmSdmSorInfo{})
+	require.Nil(t, ue.TakeSentSor())
+}
+
+func TestRegistrationSor(t *testing.T) {
+	ue := &AmfUe{}
+	require.Nil(t, ue.RegistrationSor())
+
+	// the SoR information of the registration is sent in one Registration Accept only
+	sorInfo := &models.UdmSdmSorInfo{Countersor: "0001", SorMacIausf: "00112233445566778899aabbccddeeff"}
+	ue.SetRegistrationSor(sorInfo)
+	require.Same(t, sorInfo, ue.RegistrationSor())
+	require.Same(t, sorInfo, ue.TakeRegistrationSor())
+	require.Nil(t, ue.RegistrationSor())
+
+	// nor kept past the registration
+	ue.SetRegistrationSor(sorInfo)
+	ue.ClearRegistrationRequestData(models.AccessType__3_GPP_ACCESS)
+	require.Nil(t, ue.RegistrationSor())
+}
@@ -3,6 +3,7 @@ package context
 import (
 	"encoding/hex"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/mohae/deepcopy"
@@ -54,8 +55,9 @@ type RanUe struct {
 
 	/* Routing ID */
 	RoutingID string
-	/* Trace Recording Session Reference */
-	Trsr string
+	/* Trace session activated in the NG-RAN (TS 32.422) and its Trace Recording Session Reference */
+	TraceData *models.TraceData
+	Trsr      string
 	/* Ue Context Release Action */
 	ReleaseAction RelAction
 	/* context used for AMF Re-allocation procedure */
@@ -67,6 +69,10 @@ type RanUe struct {
 	/* send initial context setup request or not*/
 	InitialContextSetup bool
 
+	/* Downlink NAS sent to the UE, kept for NAS Non Delivery Indication */
+	sentDlNas []*DlNasMessage
+	dlNasMu   sync.Mutex
+
 	/* logger */
 	Log *logrus.Entry
 }
@@ -85,9 +91,11 @@ func (ranUe *RanUe) Remove() error {
 	}
 
 	ran.RanUeList.Delete(ranUe.RanUeNgapId)
+	ranUe.DeactivateTrace()
 
 	self := GetSelf()
 	self.RanUePool.Delete(ranUe.AmfUeNgapId)
+	ueAffinity.Unbind(ranUe.AmfUeNgapId)
 	amfUeNGAPIDGenerator.FreeID(ranUe.AmfUeNgapId)
 	return nil
 }
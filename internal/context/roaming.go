@@ -0,0 +1,150 @@
+package context
+
+import (
+	"strings"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// IsServedPlmn reports whether the PLMN is one of the AMF, of its served GUAMIs or its PLMN support list
+func (context *AMFContext) IsServedPlmn(plmnId models.PlmnId) bool {
+	for _, guami := range context.ServedGuamiList {
+		if guami.PlmnId != nil && guami.PlmnId.Mcc == plmnId.Mcc && guami.PlmnId.Mnc == plmnId.Mnc {
+			return true
+		}
+	}
+	for _, plmnSupportItem := range context.PlmnSupportList {
+		if plmnSupportItem.PlmnId != nil && *plmnSupportItem.PlmnId == plmnId {
+			return true
+		}
+	}
+	return false
+}
+
+// RoamingPartner returns the roaming partner of the home PLMN, nil when the AMF serves no inbound roamer from it
+func (context *AMFContext) RoamingPartner(plmnId models.PlmnId) *factory.RoamingPartner {
+	if context.RoamingCfg == nil {
+		return nil
+	}
+	for i := range context.RoamingCfg.Partners {
+		partner := &context.RoamingCfg.Partners[i]
+		if *partner.PlmnId == plmnId {
+			return partner
+		}
+	}
+	return nil
+}
+
+// HomePlmnId returns the home PLMN of the UE: the configured PLMN, of a roaming partner or of the AMF, its IMSI
+// belongs to, else the PLMN of the SUCI or 5G-GUTI it registered with. Of the PLMNs whose MCC and MNC the IMSI starts
+// with, the one the UE registered with is taken, else the one of the longest MNC, so that the IMSI of a 3-digit MNC
+// is not taken for the one of the 2-digit MNC it starts with.
+func (ue *AmfUe) HomePlmnId() models.PlmnId {
+	amfSelf := GetSelf()
+	if amfSelf.RoamingCfg == nil || !strings.HasPrefix(ue.Supi, "imsi-") {
+		return ue.PlmnId
+	}
+	imsi := strings.TrimPrefix(ue.Supi, "imsi-")
+	var home *models.PlmnId
+	match := func(plmnId models.PlmnId) {
+		if !strings.HasPrefix(imsi, plmnId.Mcc+plmnId.Mnc) || (home != nil && *home == ue.PlmnId) {
+			return
+		}
+		if home == nil || plmnId == ue.PlmnId || len(plmnId.Mnc) > len(home.Mnc) {
+			home = &plmnId
+		}
+	}
+	for _, partner := range amfSelf.RoamingCfg.Partners {
+		match(*partner.PlmnId)
+	}
+	for _, guami := range amfSelf.ServedGuamiList {
+		if guami.PlmnId != nil {
+			match(models.PlmnId{Mcc: guami.PlmnId.Mcc, Mnc: guami.PlmnId.Mnc})
+		}
+	}
+	for _, plmnSupportItem := range amfSelf.PlmnSupportList {
+		if plmnSupportItem.PlmnId != nil {
+			match(*plmnSupportItem.PlmnId)
+		}
+	}
+	if home == nil {
+		return ue.PlmnId
+	}
+	return *home
+}
+
+// ServingPlmnId returns the PLMN serving the UE, of its tracking area or else of the AMF
+func (ue *AmfUe) ServingPlmnId() models.PlmnId {
+	if ue.Tai.PlmnId != nil {
+		return *ue.Tai.PlmnId
+	}
+	amfSelf := GetSelf()
+	if len(amfSelf.ServedGuamiList) > 0 && amfSelf.ServedGuamiList[0].PlmnId != nil {
+		plmnId := amfSelf.ServedGuamiList[0].PlmnId
+		return models.PlmnId{Mcc: plmnId.Mcc, Mnc: plmnId.Mnc}
+	}
+	return models.PlmnId{}
+}
+
+// IsInboundRoamer reports whether the UE is from another PLMN than the ones of the AMF, roaming being configured
+func (ue *AmfUe) IsInboundRoamer() bool {
+	amfSelf := GetSelf()
+	if amfSelf.RoamingCfg == nil {
+		return false
+	}
+	homePlmnId := ue.HomePlmnId()
+	return homePlmnId.Mcc != "" && !amfSelf.IsServedPlmn(homePlmnId)
+}
+
+// RoamingPartner returns the roaming partner of the home PLMN of the inbound roamer, nil when the UE is at home or
+// its home PLMN is not a roaming partner
+func (ue *AmfUe) RoamingPartner() *factory.RoamingPartner {
+	if !ue.IsInboundRoamer() {
+		return nil
+	}
+	return GetSelf().RoamingPartner(ue.HomePlmnId())
+}
+
+// HomeSnssai returns the S-NSSAI of the home PLMN mapped to the S-NSSAI of the serving PLMN, the same S-NSSAI for the
+// UE at home or without mapping
+func (ue *AmfUe) HomeSnssai(servingSnssai models.Snssai) models.Snssai {
+	if partner := ue.RoamingPartner(); partner != nil {
+		for _, mapping := range partner.SnssaiMappings {
+			if openapi.SnssaiEqualFold(*mapping.ServingSnssai, servingSnssai) {
+				return *mapping.HomeSnssai
+			}
+		}
+	}
+	return servingSnssai
+}
+
+// ServingSnssai returns the S-NSSAI of the serving PLMN mapped to the S-NSSAI of the home PLMN, the same S-NSSAI for
+// the UE at home or without mapping
+func (ue *AmfUe) ServingSnssai(homeSnssai models.Snssai) models.Snssai {
+	if partner := ue.RoamingPartner(); partner != nil {
+		for _, mapping := range partner.SnssaiMappings {
+			if openapi.SnssaiEqualFold(*mapping.HomeSnssai, homeSnssai) {
+				return *mapping.ServingSnssai
+			}
+		}
+	}
+	return homeSnssai
+}
+
+// RoamingIndication returns how the PDU sessions of the DNN of the UE are routed: home routed for an inbound roamer
+// unless its roaming partner breaks out the DNN locally
+func (ue *AmfUe) RoamingIndication(dnn string) models.RoamingIndication {
+	if !ue.IsInboundRoamer() {
+		return models.RoamingIndication_NON_ROAMING
+	}
+	if partner := ue.RoamingPartner(); partner != nil {
+		for _, localBreakoutDnn := range partner.LocalBreakoutDnns {
+			if localBreakoutDnn == dnn {
+				return models.RoamingIndication_LOCAL_BREAKOUT
+			}
+		}
+	}
+	return models.RoamingIndication_HOME_ROUTED_ROAMING
+}
@@ -0,0 +1,76 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestInboundRoamer(t *testing.T) {
+	homePlmnId := models.PlmnId{Mcc: "466", Mnc: "92"}
+	servingPlmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	servingSnssai := models.Snssai{Sst: 1, Sd: "010203"}
+	homeSnssai := models.Snssai{Sst: 1, Sd: "112233"}
+
+	amfSelf := GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{
+		PlmnId: &models.PlmnIdNid{Mcc: servingPlmnId.Mcc, Mnc: servingPlmnId.Mnc},
+		AmfId:  "cafe00",
+	}}
+	defer func() {
+		amfSelf.ServedGuamiList = amfSelf.ServedGuamiList[:0]
+		amfSelf.RoamingCfg = nil
+	}()
+
+	// registered with a 5G-GUTI of the serving PLMN, the UE is known from its IMSI
+	ue := &AmfUe{Supi: "imsi-466920000000001", PlmnId: servingPlmnId}
+	require.False(t, ue.IsInboundRoamer())
+	require.Equal(t, models.RoamingIndication_NON_ROAMING, ue.RoamingIndication("internet"))
+
+	amfSelf.RoamingCfg = &factory.Roaming{
+		Partners: []factory.RoamingPartner{{
+			PlmnId:            &homePlmnId,
+			LocalBreakoutDnns: []string{"ims"},
+			SnssaiMappings: []factory.SnssaiMapping{
+				{ServingSnssai: &servingSnssai, HomeSnssai: &homeSnssai},
+			},
+		}},
+	}
+	require.True(t, amfSelf.IsServedPlmn(servingPlmnId))
+	require.False(t, amfSelf.IsServedPlmn(homePlmnId))
+	require.Equal(t, homePlmnId, ue.HomePlmnId())
+	require.Equal(t, servingPlmnId, ue.ServingPlmnId())
+	require.True(t, ue.IsInboundRoamer())
+	require.NotNil(t, ue.RoamingPartner())
+
+	require.Equal(t, homeSnssai, ue.HomeSnssai(servingSnssai))
+	require.Equal(t, servingSnssai, ue.ServingSnssai(homeSnssai))
+	require.Equal(t, models.Snssai{Sst: 2}, ue.HomeSnssai(models.Snssai{Sst: 2}))
+	require.Equal(t, models.RoamingIndication_LOCAL_BREAKOUT, ue.RoamingIndication("ims"))
+	require.Equal(t, models.RoamingIndication_HOME_ROUTED_ROAMING, ue.RoamingIndication("internet"))
+
+	// the UE of a PLMN which is not a roaming partner
+	stranger := &AmfUe{PlmnId: models.PlmnId{Mcc: "001", Mnc: "01"}}
+	require.True(t, stranger.IsInboundRoamer())
+	require.Nil(t, stranger.RoamingPartner())
+
+	// the UE at home
+	native := &AmfUe{Supi: "imsi-208930000000001", PlmnId: servingPlmnId}
+	require.False(t, native.IsInboundRoamer())
+	require.Equal(t, servingSnssai, native.HomeSnssai(servingSnssai))
+
+	// the IMSI of a PLMN with a 3-digit MNC is not taken for the one of a 2-digit MNC it starts with
+	threeDigitPlmnId := models.PlmnId{Mcc: "208", Mnc: "930"}
+	amfSelf.RoamingCfg.Partners = append(amfSelf.RoamingCfg.Partners, factory.RoamingPartner{PlmnId: &threeDigitPlmnId})
+	require.Equal(t, servingPlmnId, native.HomePlmnId())
+	require.False(t, native.IsInboundRoamer())
+	roamer := &AmfUe{Supi: "imsi-208930100000001", PlmnId: threeDigitPlmnId}
+	require.Equal(t, threeDigitPlmnId, roamer.HomePlmnId())
+	require.True(t, roamer.IsInboundRoamer())
+	roamer.PlmnId = models.PlmnId{Mcc: "001", Mnc: "01"}
+	require.Equal(t, threeDigitPlmnId, roamer.HomePlmnId())
+	require.True(t, roamer.IsInboundRoamer())
+}
@@ -0,0 +1,310 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	business_metrics "github.com/free5gc/amf/internal/metrics/business"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// SliceAdmission counts the UEs registered with the S-NSSAIs subject to network slice admission control
+// (TS 23.501 5.15.11.1), a UE registered over both accesses being counted once
+type SliceAdmission interface {
+	// Admit counts the UE registering over the access with the S-NSSAI, false when the maximum number of UEs
+	// registered with the S-NSSAI is reached
+	Admit(supi string, snssai models.Snssai, anType models.AccessType) (bool, error)
+	// Release stops counting the UE deregistered from the S-NSSAI
+	Release(supi string, snssai models.Snssai, anType models.AccessType) error
+}
+
+var (
+	sliceAdmission   SliceAdmission
+	sliceAdmissionMu sync.RWMutex
+)
+
+// SetSliceAdmission sets the network slice admission control of the S-NSSAIs; until it is set, the UEs are
+// admitted to any S-NSSAI
+func SetSliceAdmission(admission SliceAdmission) {
+	sliceAdmissionMu.Lock()
+	defer sliceAdmissionMu.Unlock()
+	sliceAdmission = admission
+}
+
+func getSliceAdmission() SliceAdmission {
+	sliceAdmissionMu.RLock()
+	defer sliceAdmissionMu.RUnlock()
+	return sliceAdmission
+}
+
+// LocalSliceAdmission is the network slice admission control of the AMF counting the UEs it serves
+type LocalSliceAdmission struct {
+	mu     sync.Mutex
+	maxUes map[string]int
+	ues    map[string]map[string]struct{}
+}
+
+func NewLocalSliceAdmission(quotas []factory.SliceQuota) *LocalSliceAdmission {
+	admission := &LocalSliceAdmission{
+		maxUes: make(map[string]int),
+		ues:    make(map[string]map[string]struct{}),
+	}
+	for _, quota := range quotas {
+		admission.maxUes[snssaiKey(*quota.Snssai)] = quota.MaxUes
+	}
+	return admission
+}
+
+func (a *LocalSliceAdmission) Admit(supi string, snssai models.Snssai, anType models.AccessType) (bool, error) {
+	key := snssaiKey(snssai)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	maxUes, ok := a.maxUes[key]
+	if !ok {
+		return true, nil
+	}
+	ues := a.ues[key]
+	if _, ok = ues[supi]; ok {
+		return true, nil
+	}
+	if len(ues) >= maxUes {
+		return false, nil
+	}
+	if ues == nil {
+		ues = make(map[string]struct{})
+		a.ues[key] = ues
+	}
+	ues[supi] = struct{}{}
+	return true, nil
+}
+
+func (a *LocalSliceAdmission) Release(supi string, snssai models.Snssai, anType models.AccessType) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.ues[snssaiKey(snssai)], supi)
+	return nil
+}
+
+// RegisteredUes returns the number of UEs counted registered with the S-NSSAI
+func (a *LocalSliceAdmission) RegisteredUes(snssai models.Snssai) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.ues[snssaiKey(snssai)])
+}
+
+// sliceAdmissionState is the S-NSSAIs subject to network slice admission control the UE is admitted to, and
+// those it was rejected from for their maximum number of UEs reached
+type sliceAdmissionState struct {
+	// admitted is the accesses over which the UE is admitted to each S-NSSAI, by S-NSSAI key
+	admitted map[string]*admittedSlice
+	// backOff is until when the UE is not admitted again to each S-NSSAI it was rejected from
+	backOff map[string]time.Time
+	// rejected is the S-NSSAIs rejected to the UE in its last registration over each access
+	rejected map[models.AccessType][]models.Snssai
+}
+
+type admittedSlice struct {
+	snssai   models.Snssai
+	accesses map[models.AccessType]bool
+}
+
+// AdmitToSlice admits the UE registering over the access to the S-NSSAI, returning false when the maximum number
+// of UEs registered with the S-NSSAI is reached or the UE is backing off from it. The UE rejected is not admitted
+// again to the S-NSSAI for backOff.
+func (ue *AmfUe) AdmitToSlice(snssai models.Snssai, anType models.AccessType, backOff time.Duration) (bool, error) {
+	admission := getSliceAdmission()
+	if admission == nil {
+		return true, nil
+	}
+	state := &ue.sliceAdmission
+	key := snssaiKey(snssai)
+	if slice, ok := state.admitted[key]; ok {
+		slice.accesses[anType] = true
+		return true, nil
+	}
+	if time.Now().Before(state.backOff[key]) {
+		return false, nil
+	}
+	admitted, err := admission.Admit(ue.Supi, snssai, anType)
+	if err != nil {
+		return false, err
+	}
+	if !admitted {
+		if backOff > 0 {
+			if state.backOff == nil {
+				state.backOff = make(map[string]time.Time)
+			}
+			state.backOff[key] = time.Now().Add(backOff)
+		}
+		business_metrics.IncrSliceAdmissionRejectCounter(key)
+		return false, nil
+	}
+	delete(state.backOff, key)
+	if state.admitted == nil {
+		state.admitted = make(map[string]*admittedSlice)
+	}
+	state.admitted[key] = &admittedSlice{
+		snssai:   snssai,
+		accesses: map[models.AccessType]bool{anType: true},
+	}
+	business_metrics.IncrSliceAdmittedUesGauge(key)
+	return true, nil
+}
+
+// SetAllowedNssai sets the allowed NSSAI of the UE over the access through the network slice admission control
+// (TS 23.502 4.2.11.2), whatever changes it. The UE is no longer counted for the S-NSSAIs it leaves, and the
+// S-NSSAIs which reached their maximum number of UEs are left out, rejected to the UE with their back-off timer
+// and returned.
+func (ue *AmfUe) SetAllowedNssai(anType models.AccessType, allowedNssai []models.AllowedSnssai) []models.Snssai {
+	var cfg *factory.SliceAdmission
+	if factory.AmfConfig != nil {
+		cfg = factory.AmfConfig.GetSliceAdmission()
+	}
+	if cfg == nil {
+		ue.AllowedNssai[anType] = allowedNssai
+		return nil
+	}
+
+	for _, snssai := range ue.AdmittedSlices(anType) {
+		if !inAllowedSnssaiList(allowedNssai, snssai) {
+			ue.ReleaseSlice(snssai, anType)
+		}
+	}
+	var admittedNssai []models.AllowedSnssai
+	var rejectedNssai []models.Snssai
+	for _, allowedSnssai := range allowedNssai {
+		snssai := *allowedSnssai.AllowedSnssai
+		if !SubjectToSliceAdmission(cfg, snssai) {
+			admittedNssai = append(admittedNssai, allowedSnssai)
+			continue
+		}
+		admitted, err := ue.AdmitToSlice(snssai, anType, cfg.BackOffTimer)
+		if err != nil {
+			ue.GmmLog.Errorf("S-NSSAI[%+v] admission error, admitted uncounted: %+v", snssai, err)
+			admitted = true
+		}
+		if admitted {
+			admittedNssai = append(admittedNssai, allowedSnssai)
+		} else {
+			ue.GmmLog.Warnf("S-NSSAI[%+v] rejected: maximum number of UEs reached", snssai)
+			rejectedNssai = append(rejectedNssai, snssai)
+		}
+	}
+	ue.AllowedNssai[anType] = admittedNssai
+	ue.setSlicesRejectedForMaxUes(anType, rejectedNssai)
+	return rejectedNssai
+}
+
+// ReleaseSlice releases the S-NSSAI the UE is no longer registered with over the access, the UE being no longer
+// counted once it is registered with the S-NSSAI over no access
+func (ue *AmfUe) ReleaseSlice(snssai models.Snssai, anType models.AccessType) {
+	key := snssaiKey(snssai)
+	slice, ok := ue.sliceAdmission.admitted[key]
+	if !ok {
+		return
+	}
+	delete(slice.accesses, anType)
+	if len(slice.accesses) > 0 {
+		return
+	}
+	delete(ue.sliceAdmission.admitted, key)
+	business_metrics.DecrSliceAdmittedUesGauge(key)
+	if admission := getSliceAdmission(); admission != nil {
+		if err := admission.Release(ue.Supi, snssai, anType); err != nil {
+			ue.GmmLog.Errorf("Release S-NSSAI[%+v] admission error: %+v", snssai, err)
+		}
+	}
+}
+
+// ReleaseSlices releases the S-NSSAIs the UE is admitted to over the access, when it deregisters from it
+func (ue *AmfUe) ReleaseSlices(anType models.AccessType) {
+	for _, snssai := range ue.AdmittedSlices(anType) {
+		ue.ReleaseSlice(snssai, anType)
+	}
+	delete(ue.sliceAdmission.rejected, anType)
+}
+
+// AdmittedSlices returns the S-NSSAIs subject to network slice admission control the UE is admitted to over
+// the access
+func (ue *AmfUe) AdmittedSlices(anType models.AccessType) []models.Snssai {
+	var snssais []models.Snssai
+	for _, slice := range ue.sliceAdmission.admitted {
+		if slice.accesses[anType] {
+			snssais = append(snssais, slice.snssai)
+		}
+	}
+	return snssais
+}
+
+// SliceBackOff returns how long the UE is still not admitted again to the S-NSSAI it was rejected from, 0 if it
+// is not backing off from it
+func (ue *AmfUe) SliceBackOff(snssai models.Snssai) time.Duration {
+	if backOff := time.Until(ue.sliceAdmission.backOff[snssaiKey(snssai)]); backOff > 0 {
+		return backOff
+	}
+	return 0
+}
+
+// setSlicesRejectedForMaxUes records the S-NSSAIs rejected to the UE over the access for their maximum number of
+// UEs reached, to be indicated to it in the rejected NSSAI
+func (ue *AmfUe) setSlicesRejectedForMaxUes(anType models.AccessType, snssais []models.Snssai) {
+	if ue.sliceAdmission.rejected == nil {
+		ue.sliceAdmission.rejected = make(map[models.AccessType][]models.Snssai)
+	}
+	ue.sliceAdmission.rejected[anType] = snssais
+}
+
+// SlicesRejectedForMaxUes returns the S-NSSAIs rejected to the UE the last time its allowed NSSAI over the access
+// was set, for their maximum number of UEs reached
+func (ue *AmfUe) SlicesRejectedForMaxUes(anType models.AccessType) []models.Snssai {
+	return ue.sliceAdmission.rejected[anType]
+}
+
+// SubjectToSliceAdmission reports whether the network slice admission control counts the UEs registered with the
+// S-NSSAI
+func SubjectToSliceAdmission(cfg *factory.SliceAdmission, snssai models.Snssai) bool {
+	for _, quota := range cfg.Quotas {
+		if openapi.SnssaiEqualFold(*quota.Snssai, snssai) {
+			return true
+		}
+	}
+	return false
+}
+
+func snssaiKey(snssai models.Snssai) string {
+	return fmt.Sprintf("%02x%s", snssai.Sst, strings.ToLower(snssai.Sd))
+}
+
+// ReadmitRegisteredUes counts again the UEs of the UE pool registered with the S-NSSAIs subject to the network
+// slice admission control, once their contexts are restored, and returns the number of S-NSSAIs of the UEs no
+// longer admitted, their maximum number of UEs being reached
+func (context *AMFContext) ReadmitRegisteredUes(cfg *factory.SliceAdmission) int {
+	notAdmitted := 0
+	context.UePool.Range(func(key, value interface{}) bool {
+		ue := value.(*AmfUe)
+		for anType, allowedNssai := range ue.AllowedNssai {
+			if state := ue.State[anType]; state == nil || !state.Is(Registered) {
+				continue
+			}
+			for _, allowedSnssai := range allowedNssai {
+				snssai := *allowedSnssai.AllowedSnssai
+				if !SubjectToSliceAdmission(cfg, snssai) {
+					continue
+				}
+				admitted, err := ue.AdmitToSlice(snssai, anType, 0)
+				if err != nil {
+					ue.GmmLog.Errorf("S-NSSAI[%+v] readmission error: %+v", snssai, err)
+				} else if !admitted {
+					ue.GmmLog.Warnf("S-NSSAI[%+v] not readmitted: maximum number of UEs reached", snssai)
+					notAdmitted++
+				}
+			}
+		}
+		return true
+	})
+	return notAdmitted
+}
@@ -0,0 +1,140 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestSliceAdmission(t *testing.T) {
+	controlled := models.Snssai{Sst: 1, Sd: "010203"}
+	uncontrolled := models.Snssai{Sst: 2}
+	admission := NewLocalSliceAdmission([]factory.SliceQuota{
+		{Snssai: &models.Snssai{Sst: 1, Sd: "010203"}, MaxUes: 1},
+	})
+	SetSliceAdmission(admission)
+	defer SetSliceAdmission(nil)
+
+	ue1 := &AmfUe{Supi: "imsi-208930000000001"}
+	ue2 := &AmfUe{Supi: "imsi-208930000000002"}
+	anType := models.AccessType__3_GPP_ACCESS
+
+	// the UE is counted once whatever the accesses it registers with the S-NSSAI over
+	admitted, err := ue1.AdmitToSlice(controlled, anType, time.Minute)
+	require.NoError(t, err)
+	require.True(t, admitted)
+	admitted, err = ue1.AdmitToSlice(controlled, models.AccessType_NON_3_GPP_ACCESS, time.Minute)
+	require.NoError(t, err)
+	require.True(t, admitted)
+	require.Equal(t, 1, admission.RegisteredUes(controlled))
+	admitted, err = admission.Admit(ue2.Supi, uncontrolled, anType)
+	require.NoError(t, err)
+	require.True(t, admitted)
+	require.Zero(t, admission.RegisteredUes(uncontrolled))
+
+	// the UE rejected for the maximum number of UEs reached backs off from the S-NSSAI
+	admitted, err = ue2.AdmitToSlice(controlled, anType, time.Minute)
+	require.NoError(t, err)
+	require.False(t, admitted)
+	ue1.ReleaseSlices(anType)
+	require.Equal(t, 1, admission.RegisteredUes(controlled))
+	ue1.ReleaseSlices(models.AccessType_NON_3_GPP_ACCESS)
+	require.Zero(t, admission.RegisteredUes(controlled))
+	require.Empty(t, ue1.AdmittedSlices(anType))
+	admitted, err = ue2.AdmitToSlice(controlled, anType, time.Minute)
+	require.NoError(t, err)
+	require.False(t, admitted)
+	require.InDelta(t, time.Minute, ue2.SliceBackOff(controlled), float64(time.Second))
+	require.Zero(t, ue2.SliceBackOff(uncontrolled))
+
+	ue2.sliceAdmission.backOff = nil
+	admitted, err = ue2.AdmitToSlice(controlled, anType, time.Minute)
+	require.NoError(t, err)
+	require.True(t, admitted)
+	require.Equal(t, []models.Snssai{controlled}, ue2.AdmittedSlices(anType))
+	ue2.ReleaseSlice(models.Snssai{Sst: 1, Sd: "010203"}, anType)
+	require.Zero(t, admission.RegisteredUes(controlled))
+}
+
+func TestReadmitRegisteredUes(t *testing.T) {
+	controlled := models.Snssai{Sst: 1, Sd: "010203"}
+	cfg := &factory.SliceAdmission{
+		Mode:   factory.SliceAdmissionModeLocal,
+		Quotas: []factory.SliceQuota{{Snssai: &models.Snssai{Sst: 1, Sd: "010203"}, MaxUes: 1}},
+	}
+	admission := NewLocalSliceAdmission(cfg.Quotas)
+	SetSliceAdmission(admission)
+	defer SetSliceAdmission(nil)
+
+	anType := models.AccessType__3_GPP_ACCESS
+	amfSelf := GetSelf()
+	var ues []*AmfUe
+	for _, supi := range []string{"imsi-208930000000001", "imsi-208930000000002", "imsi-208930000000003"} {
+		ue := &AmfUe{}
+		ue.init()
+		ue.Supi = supi
+		ue.AllowedNssai[anType] = []models.AllowedSnssai{
+			{AllowedSnssai: &models.Snssai{Sst: 1, Sd: "010203"}},
+			{AllowedSnssai: &models.Snssai{Sst: 2}},
+		}
+		amfSelf.UePool.Store(supi, ue)
+		ues = append(ues, ue)
+	}
+	defer func() {
+		for _, ue := range ues {
+			amfSelf.UePool.Delete(ue.Supi)
+		}
+	}()
+	ues[0].State[anType].Set(Registered)
+	ues[1].State[anType].Set(Registered)
+
+	// the deregistered UE is not counted, the UE beyond the maximum number of UEs is not admitted again
+	require.Equal(t, 1, amfSelf.ReadmitRegisteredUes(cfg))
+	require.Equal(t, 1, admission.RegisteredUes(controlled))
+	require.Zero(t, admission.RegisteredUes(models.Snssai{Sst: 2}))
+	require.Empty(t, ues[2].AdmittedSlices(anType))
+}
+
+func TestSetAllowedNssai(t *testing.T) {
+	controlled := models.Snssai{Sst: 1, Sd: "010203"}
+	uncontrolled := models.Snssai{Sst: 2}
+	cfg := &factory.SliceAdmission{
+		Mode:         factory.SliceAdmissionModeLocal,
+		BackOffTimer: time.Minute,
+		Quotas:       []factory.SliceQuota{{Snssai: &models.Snssai{Sst: 1, Sd: "010203"}, MaxUes: 1}},
+	}
+	admission := NewLocalSliceAdmission(cfg.Quotas)
+	SetSliceAdmission(admission)
+	defer SetSliceAdmission(nil)
+	amfConfig := factory.AmfConfig
+	factory.AmfConfig = &factory.Config{Configuration: &factory.Configuration{SliceAdmission: cfg}}
+	defer func() {
+		factory.AmfConfig = amfConfig
+	}()
+
+	anType := models.AccessType__3_GPP_ACCESS
+	allowedNssai := []models.AllowedSnssai{{AllowedSnssai: &controlled}, {AllowedSnssai: &uncontrolled}}
+	ue1 := &AmfUe{}
+	ue1.init()
+	ue1.Supi = "imsi-208930000000001"
+	ue2 := &AmfUe{}
+	ue2.init()
+	ue2.Supi = "imsi-208930000000002"
+
+	// the S-NSSAI at its maximum number of UEs is left out of the allowed NSSAI and rejected with its back-off
+	require.Empty(t, ue1.SetAllowedNssai(anType, allowedNssai))
+	require.Equal(t, allowedNssai, ue1.AllowedNssai[anType])
+	require.Equal(t, []models.Snssai{controlled}, ue2.SetAllowedNssai(anType, allowedNssai))
+	require.Equal(t, []models.AllowedSnssai{{AllowedSnssai: &uncontrolled}}, ue2.AllowedNssai[anType])
+	require.Equal(t, []models.Snssai{controlled}, ue2.SlicesRejectedForMaxUes(anType))
+	require.InDelta(t, time.Minute, ue2.SliceBackOff(controlled), float64(time.Second))
+
+	// the UE leaving the S-NSSAI is no longer counted
+	require.Empty(t, ue1.SetAllowedNssai(anType, []models.AllowedSnssai{{AllowedSnssai: &uncontrolled}}))
+	require.Zero(t, admission.RegisteredUes(controlled))
+	require.Empty(t, ue1.AdmittedSlices(anType))
+}
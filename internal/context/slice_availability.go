@@ -0,0 +1,277 @@
+package context
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// SliceOutage is an S-NSSAI of the PLMN support list out of service in some tracking areas
+type SliceOutage struct {
+	Snssai models.Snssai `json:"snssai"`
+	// TaiList is the tracking areas the S-NSSAI is out of service in, the whole PLMN when empty
+	TaiList []models.Tai `json:"taiList,omitempty"`
+	// AlternativeSnssai replaces the S-NSSAI for the UEs where it is out of service (TS 23.501 5.15.19)
+	AlternativeSnssai *models.Snssai `json:"alternativeSnssai,omitempty"`
+}
+
+var (
+	sliceOutages   []SliceOutage
+	sliceOutagesMu sync.RWMutex
+)
+
+func initSliceOutages(outages []factory.SliceOutage) {
+	sliceOutagesMu.Lock()
+	defer sliceOutagesMu.Unlock()
+	sliceOutages = nil
+	for _, outage := range outages {
+		sliceOutages = append(sliceOutages, SliceOutage{
+			Snssai:            *outage.Snssai,
+			TaiList:           outage.TaiList,
+			AlternativeSnssai: outage.AlternativeSnssai,
+		})
+	}
+}
+
+// SetSliceOutage puts the S-NSSAI out of service, replacing its previous outage
+func SetSliceOutage(outage SliceOutage) {
+	sliceOutagesMu.Lock()
+	defer sliceOutagesMu.Unlock()
+	for i := range sliceOutages {
+		if openapi.SnssaiEqualFold(sliceOutages[i].Snssai, outage.Snssai) {
+			sliceOutages[i] = outage
+			return
+		}
+	}
+	sliceOutages = append(sliceOutages, outage)
+}
+
+// RemoveSliceOutage puts the S-NSSAI back in service, returning false when it was not out of service
+func RemoveSliceOutage(snssai models.Snssai) bool {
+	sliceOutagesMu.Lock()
+	defer sliceOutagesMu.Unlock()
+	for i := range sliceOutages {
+		if openapi.SnssaiEqualFold(sliceOutages[i].Snssai, snssai) {
+			sliceOutages = append(sliceOutages[:i], sliceOutages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SliceOutages returns the S-NSSAIs out of service
+func SliceOutages() []SliceOutage {
+	sliceOutagesMu.RLock()
+	defer sliceOutagesMu.RUnlock()
+	return append([]SliceOutage(nil), sliceOutages...)
+}
+
+// SnssaiAvailableInTa reports whether the S-NSSAI is in service in the tracking area
+func SnssaiAvailableInTa(snssai models.Snssai, tai models.Tai) bool {
+	return sliceOutageInTa(snssai, tai) == nil
+}
+
+// AlternativeSnssaiInTa returns the S-NSSAI in service replacing the S-NSSAI out of service in the tracking area,
+// nil when the S-NSSAI is in service or has no alternative in service
+func AlternativeSnssaiInTa(snssai models.Snssai, tai models.Tai) *models.Snssai {
+	outage := sliceOutageInTa(snssai, tai)
+	if outage == nil || outage.AlternativeSnssai == nil {
+		return nil
+	}
+	alternative := *outage.AlternativeSnssai
+	if !SnssaiAvailableInTa(alternative, tai) {
+		return nil
+	}
+	return &alternative
+}
+
+// sliceOutageInTa returns the outage of the S-NSSAI covering the tracking area, nil when it is in service there
+func sliceOutageInTa(snssai models.Snssai, tai models.Tai) *SliceOutage {
+	sliceOutagesMu.RLock()
+	defer sliceOutagesMu.RUnlock()
+	for _, outage := range sliceOutages {
+		if openapi.SnssaiEqualFold(outage.Snssai, snssai) &&
+			(len(outage.TaiList) == 0 || InTaiList(tai, outage.TaiList)) {
+			return &outage
+		}
+	}
+	return nil
+}
+
+// sliceAvailabilityState is how the allowed NSSAI of the UE over each access was adapted to the S-NSSAIs out of
+// service in its tracking area, by access
+type sliceAvailabilityState struct {
+	// replaced is the allowed S-NSSAIs replaced by an alternative S-NSSAI
+	replaced map[models.AccessType][]sliceReplacement
+	// alternatives is the alternative S-NSSAIs added to the allowed NSSAI
+	alternatives map[models.AccessType][]models.Snssai
+	// unavailable is the allowed S-NSSAIs removed without alternative, rejected to the UE in the current
+	// registration area
+	unavailable map[models.AccessType][]models.AllowedSnssai
+}
+
+type sliceReplacement struct {
+	original    models.AllowedSnssai
+	alternative models.Snssai
+}
+
+// ApplySliceAvailability adapts the allowed NSSAI of the UE over the access to the S-NSSAIs out of service in its
+// tracking area: each of them is replaced by its alternative S-NSSAI, or removed when it has none, and restored
+// once in service again. The allowed NSSAI is set through the network slice admission control, see
+// SetAllowedNssai. It reports whether the allowed NSSAI changed.
+func (ue *AmfUe) ApplySliceAvailability(anType models.AccessType) bool {
+	state := &ue.sliceAvailability
+
+	// the S-NSSAIs the UE was allowed before they went out of service are evaluated again
+	var candidates []models.AllowedSnssai
+	for _, allowedSnssai := range ue.AllowedNssai[anType] {
+		if !inSnssaiList(state.alternatives[anType], *allowedSnssai.AllowedSnssai) {
+			candidates = append(candidates, allowedSnssai)
+		}
+	}
+	for _, replacement := range state.replaced[anType] {
+		candidates = append(candidates, replacement.original)
+	}
+	candidates = append(candidates, state.unavailable[anType]...)
+
+	var allowedNssai, unavailable []models.AllowedSnssai
+	var replaced []sliceReplacement
+	var alternatives []models.Snssai
+	inAllowedNssai := func(snssai models.Snssai) bool {
+		return inAllowedSnssaiList(allowedNssai, snssai)
+	}
+	// the S-NSSAIs in service first, not to be taken for the alternative S-NSSAIs replacing the others
+	var outOfService []models.AllowedSnssai
+	for _, candidate := range candidates {
+		switch {
+		case !SnssaiAvailableInTa(*candidate.AllowedSnssai, ue.Tai):
+			outOfService = append(outOfService, candidate)
+		case !inAllowedNssai(*candidate.AllowedSnssai):
+			allowedNssai = append(allowedNssai, candidate)
+		}
+	}
+	for _, candidate := range outOfService {
+		snssai := *candidate.AllowedSnssai
+		if inSnssaiList(replacedSnssais(replaced), snssai) || inAllowedSnssaiList(unavailable, snssai) {
+			continue
+		}
+		alternative := AlternativeSnssaiInTa(snssai, ue.Tai)
+		if alternative == nil {
+			unavailable = append(unavailable, candidate)
+			continue
+		}
+		replaced = append(replaced, sliceReplacement{original: candidate, alternative: *alternative})
+		if inAllowedNssai(*alternative) {
+			continue
+		}
+		// the alternative S-NSSAI of an inbound roamer is mapped to the HPLMN S-NSSAI of the S-NSSAI it replaces;
+		// the replacement itself is kept in the state, see ReplacedSnssai
+		allowedNssai = append(allowedNssai, models.AllowedSnssai{
+			AllowedSnssai:    alternative,
+			MappedHomeSnssai: candidate.MappedHomeSnssai,
+		})
+		alternatives = append(alternatives, *alternative)
+	}
+
+	// the alternative S-NSSAIs at their maximum number of UEs leave the S-NSSAIs they replace unavailable
+	previous := ue.AllowedNssai[anType]
+	if rejected := ue.SetAllowedNssai(anType, allowedNssai); len(rejected) > 0 {
+		admitted := replaced[:0]
+		for _, replacement := range replaced {
+			if inSnssaiList(rejected, replacement.alternative) {
+				unavailable = append(unavailable, replacement.original)
+			} else {
+				admitted = append(admitted, replacement)
+			}
+		}
+		replaced = admitted
+		var admittedAlternatives []models.Snssai
+		for _, alternative := range alternatives {
+			if !inSnssaiList(rejected, alternative) {
+				admittedAlternatives = append(admittedAlternatives, alternative)
+			}
+		}
+		alternatives = admittedAlternatives
+	}
+	changed := !reflect.DeepEqual(previous, ue.AllowedNssai[anType]) &&
+		(len(previous) != 0 || len(ue.AllowedNssai[anType]) != 0)
+	if state.replaced == nil {
+		state.replaced = make(map[models.AccessType][]sliceReplacement)
+		state.alternatives = make(map[models.AccessType][]models.Snssai)
+		state.unavailable = make(map[models.AccessType][]models.AllowedSnssai)
+	}
+	state.replaced[anType] = replaced
+	state.alternatives[anType] = alternatives
+	state.unavailable[anType] = unavailable
+	return changed
+}
+
+// ClearSliceAvailability forgets the S-NSSAIs of the UE over the access replaced or removed for being out of service
+func (ue *AmfUe) ClearSliceAvailability(anType models.AccessType) {
+	delete(ue.sliceAvailability.replaced, anType)
+	delete(ue.sliceAvailability.alternatives, anType)
+	delete(ue.sliceAvailability.unavailable, anType)
+}
+
+// AlternativeSnssai returns the alternative S-NSSAI replacing the S-NSSAI in the allowed NSSAI of the UE over the
+// access, nil when the S-NSSAI is not replaced
+func (ue *AmfUe) AlternativeSnssai(snssai models.Snssai, anType models.AccessType) *models.Snssai {
+	for _, replacement := range ue.sliceAvailability.replaced[anType] {
+		if openapi.SnssaiEqualFold(*replacement.original.AllowedSnssai, snssai) {
+			alternative := replacement.alternative
+			return &alternative
+		}
+	}
+	return nil
+}
+
+// ReplacedSnssai returns the S-NSSAI the alternative S-NSSAI replaces in the allowed NSSAI of the UE over the
+// access, nil when the S-NSSAI does not replace any
+func (ue *AmfUe) ReplacedSnssai(alternative models.Snssai, anType models.AccessType) *models.Snssai {
+	for _, replacement := range ue.sliceAvailability.replaced[anType] {
+		if openapi.SnssaiEqualFold(replacement.alternative, alternative) {
+			original := *replacement.original.AllowedSnssai
+			return &original
+		}
+	}
+	return nil
+}
+
+// SlicesUnavailableInTa returns the S-NSSAIs removed from the allowed NSSAI of the UE over the access for being out
+// of service in its tracking area, replaced or not
+func (ue *AmfUe) SlicesUnavailableInTa(anType models.AccessType) []models.Snssai {
+	snssais := replacedSnssais(ue.sliceAvailability.replaced[anType])
+	for _, allowedSnssai := range ue.sliceAvailability.unavailable[anType] {
+		snssais = append(snssais, *allowedSnssai.AllowedSnssai)
+	}
+	return snssais
+}
+
+func replacedSnssais(replaced []sliceReplacement) []models.Snssai {
+	var snssais []models.Snssai
+	for _, replacement := range replaced {
+		snssais = append(snssais, *replacement.original.AllowedSnssai)
+	}
+	return snssais
+}
+
+func inSnssaiList(snssais []models.Snssai, snssai models.Snssai) bool {
+	for _, s := range snssais {
+		if openapi.SnssaiEqualFold(s, snssai) {
+			return true
+		}
+	}
+	return false
+}
+
+func inAllowedSnssaiList(allowedNssai []models.AllowedSnssai, snssai models.Snssai) bool {
+	for _, allowedSnssai := range allowedNssai {
+		if openapi.SnssaiEqualFold(*allowedSnssai.AllowedSnssai, snssai) {
+			return true
+		}
+	}
+	return false
+}
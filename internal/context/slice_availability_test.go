@@ -0,0 +1,154 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestSliceOutages(t *testing.T) {
+	defer initSliceOutages(nil)
+
+	snssai := models.Snssai{Sst: 1, Sd: "010203"}
+	alternative := models.Snssai{Sst: 1, Sd: "112233"}
+	tai := models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000001"}
+	otherTai := models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000002"}
+
+	SetSliceOutage(SliceOutage{Snssai: snssai, TaiList: []models.Tai{tai}, AlternativeSnssai: &alternative})
+	require.False(t, SnssaiAvailableInTa(models.Snssai{Sst: 1, Sd: "010203"}, tai))
+	require.True(t, SnssaiAvailableInTa(snssai, otherTai))
+	require.Equal(t, &alternative, AlternativeSnssaiInTa(snssai, tai))
+	require.Nil(t, AlternativeSnssaiInTa(snssai, otherTai))
+
+	// the alternative S-NSSAI out of service too does not replace the S-NSSAI
+	SetSliceOutage(SliceOutage{Snssai: alternative})
+	require.Nil(t, AlternativeSnssaiInTa(snssai, tai))
+	require.Len(t, SliceOutages(), 2)
+
+	require.True(t, RemoveSliceOutage(alternative))
+	require.False(t, RemoveSliceOutage(alternative))
+	require.Equal(t, &alternative, AlternativeSnssaiInTa(snssai, tai))
+}
+
+func TestApplySliceAvailability(t *testing.T) {
+	defer initSliceOutages(nil)
+
+	replaced := models.Snssai{Sst: 1, Sd: "010203"}
+	removed := models.Snssai{Sst: 2}
+	inService := models.Snssai{Sst: 3}
+	alternative := models.Snssai{Sst: 1, Sd: "112233"}
+	anType := models.AccessType__3_GPP_ACCESS
+	ue := &AmfUe{
+		Tai: models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000001"},
+		AllowedNssai: map[models.AccessType][]models.AllowedSnssai{
+			anType: {
+				{AllowedSnssai: &replaced},
+				{AllowedSnssai: &removed},
+				{AllowedSnssai: &inService},
+			},
+		},
+	}
+	require.False(t, ue.ApplySliceAvailability(anType))
+
+	// the S-NSSAIs out of service are replaced by their alternative or removed
+	SetSliceOutage(SliceOutage{Snssai: replaced, AlternativeSnssai: &alternative})
+	SetSliceOutage(SliceOutage{Snssai: removed})
+	require.True(t, ue.ApplySliceAvailability(anType))
+	require.Equal(t, []models.AllowedSnssai{
+		{AllowedSnssai: &inService},
+		{AllowedSnssai: &alternative},
+	}, ue.AllowedNssai[anType])
+	require.Equal(t, &alternative, ue.AlternativeSnssai(replaced, anType))
+	require.Equal(t, &replaced, ue.ReplacedSnssai(alternative, anType))
+	require.Nil(t, ue.AlternativeSnssai(removed, anType))
+	require.ElementsMatch(t, []models.Snssai{replaced, removed}, ue.SlicesUnavailableInTa(anType))
+	require.False(t, ue.ApplySliceAvailability(anType))
+
+	// the S-NSSAIs back in service are restored
+	RemoveSliceOutage(replaced)
+	RemoveSliceOutage(removed)
+	require.True(t, ue.ApplySliceAvailability(anType))
+	require.ElementsMatch(t, []models.AllowedSnssai{
+		{AllowedSnssai: &replaced},
+		{AllowedSnssai: &removed},
+		{AllowedSnssai: &inService},
+	}, ue.AllowedNssai[anType])
+	require.Nil(t, ue.AlternativeSnssai(replaced, anType))
+	require.Empty(t, ue.SlicesUnavailableInTa(anType))
+}
+
+func TestApplySliceAvailabilityRoaming(t *testing.T) {
+	defer initSliceOutages(nil)
+
+	servingSnssai := models.Snssai{Sst: 1, Sd: "010203"}
+	homeSnssai := models.Snssai{Sst: 1, Sd: "aabbcc"}
+	alternative := models.Snssai{Sst: 1, Sd: "112233"}
+	anType := models.AccessType__3_GPP_ACCESS
+	ue := &AmfUe{
+		Tai: models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000001"},
+		AllowedNssai: map[models.AccessType][]models.AllowedSnssai{
+			anType: {{AllowedSnssai: &servingSnssai, MappedHomeSnssai: &homeSnssai}},
+		},
+	}
+
+	// the alternative S-NSSAI keeps the HPLMN S-NSSAI of the S-NSSAI it replaces
+	SetSliceOutage(SliceOutage{Snssai: servingSnssai, AlternativeSnssai: &alternative})
+	require.True(t, ue.ApplySliceAvailability(anType))
+	require.Equal(t, []models.AllowedSnssai{
+		{AllowedSnssai: &alternative, MappedHomeSnssai: &homeSnssai},
+	}, ue.AllowedNssai[anType])
+	require.Equal(t, &servingSnssai, ue.ReplacedSnssai(alternative, anType))
+	require.Nil(t, ue.ReplacedSnssai(servingSnssai, anType))
+}
+
+func TestApplySliceAvailabilityAdmission(t *testing.T) {
+	defer initSliceOutages(nil)
+
+	replaced := models.Snssai{Sst: 1, Sd: "010203"}
+	alternative := models.Snssai{Sst: 1, Sd: "112233"}
+	cfg := &factory.SliceAdmission{
+		Mode: factory.SliceAdmissionModeLocal,
+		Quotas: []factory.SliceQuota{
+			{Snssai: &models.Snssai{Sst: 1, Sd: "010203"}, MaxUes: 1},
+			{Snssai: &models.Snssai{Sst: 1, Sd: "112233"}, MaxUes: 1},
+		},
+	}
+	admission := NewLocalSliceAdmission(cfg.Quotas)
+	SetSliceAdmission(admission)
+	defer SetSliceAdmission(nil)
+	amfConfig := factory.AmfConfig
+	factory.AmfConfig = &factory.Config{Configuration: &factory.Configuration{SliceAdmission: cfg}}
+	defer func() {
+		factory.AmfConfig = amfConfig
+	}()
+
+	anType := models.AccessType__3_GPP_ACCESS
+	tai := models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000001"}
+	var ues []*AmfUe
+	for _, supi := range []string{"imsi-208930000000001", "imsi-208930000000002"} {
+		ue := &AmfUe{}
+		ue.init()
+		ue.Supi = supi
+		ue.Tai = tai
+		ue.AllowedNssai[anType] = []models.AllowedSnssai{{AllowedSnssai: &replaced}}
+		ues = append(ues, ue)
+	}
+	require.Empty(t, ues[0].SetAllowedNssai(anType, ues[0].AllowedNssai[anType]))
+	require.Equal(t, 1, admission.RegisteredUes(replaced))
+
+	// the replaced S-NSSAI is released, the UE beyond the maximum number of UEs of the alternative S-NSSAI is left
+	// without it
+	SetSliceOutage(SliceOutage{Snssai: replaced, AlternativeSnssai: &alternative})
+	require.True(t, ues[0].ApplySliceAvailability(anType))
+	require.Equal(t, []models.AllowedSnssai{{AllowedSnssai: &alternative}}, ues[0].AllowedNssai[anType])
+	require.Zero(t, admission.RegisteredUes(replaced))
+	require.Equal(t, 1, admission.RegisteredUes(alternative))
+	require.True(t, ues[1].ApplySliceAvailability(anType))
+	require.Empty(t, ues[1].AllowedNssai[anType])
+	require.Equal(t, []models.Snssai{alternative}, ues[1].SlicesRejectedForMaxUes(anType))
+	require.Nil(t, ues[1].AlternativeSnssai(replaced, anType))
+	require.Equal(t, []models.Snssai{replaced}, ues[1].SlicesUnavailableInTa(anType))
+}
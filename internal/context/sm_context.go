@@ -21,10 +21,11 @@ type SmContext struct {
 	plmnID       models.PlmnId
 
 	// SMF information
-	smfID  string
-	smfUri string
-	hSmfID string
-	vSmfID string
+	smfID   string
+	smfUri  string
+	hSmfID  string
+	hSmfUri string // SMF of the home PLMN of the home routed PDU session
+	vSmfID  string
 
 	// for duplicate pdu session id handling
 	ulNASTransport *nasMessage.ULNASTransport
@@ -168,6 +169,18 @@ func (c *SmContext) SetHSmfID(hsmfID string) {
 	c.hSmfID = hsmfID
 }
 
+func (c *SmContext) HSmfUri() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hSmfUri
+}
+
+func (c *SmContext) SetHSmfUri(hsmfUri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hSmfUri = hsmfUri
+}
+
 func (c *SmContext) VSmfID() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
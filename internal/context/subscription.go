@@ -0,0 +1,190 @@
+package context
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/free5gc/nas/nasMessage"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// SubscriptionChanges are the changes of the access and mobility subscription data of a UE the AMF acts on
+// (TS 23.502 4.2.2.2.2, 4.5.2)
+type SubscriptionChanges struct {
+	// MobilityRestrictions is set when the RAT restrictions, forbidden areas or service area restriction changed
+	MobilityRestrictions bool
+	// PolicyTriggers are the AM policy control request triggers of the changes reported to the PCF
+	PolicyTriggers []models.PcfAmPolicyControlRequestTrigger
+	// DeregistrationCause is the 5GMM cause of the deregistration of a UE no longer allowed to access the network,
+	// 0 while the UE is allowed
+	DeregistrationCause uint8
+}
+
+// AmDataChanges compares the access and mobility subscription data of the UE with the previous data
+func (ue *AmfUe) AmDataChanges(old *models.AccessAndMobilitySubscriptionData) SubscriptionChanges {
+	var changes SubscriptionChanges
+	data := ue.AccessAndMobilitySubscriptionData
+	if data == nil {
+		return changes
+	}
+	if old == nil {
+		old = &models.AccessAndMobilitySubscriptionData{}
+	}
+
+	if !reflect.DeepEqual(old.RatRestrictions, data.RatRestrictions) ||
+		!reflect.DeepEqual(old.ForbiddenAreas, data.ForbiddenAreas) ||
+		!reflect.DeepEqual(old.ServiceAreaRestriction, data.ServiceAreaRestriction) {
+		changes.MobilityRestrictions = true
+	}
+	if !reflect.DeepEqual(old.ServiceAreaRestriction, data.ServiceAreaRestriction) {
+		changes.PolicyTriggers = append(changes.PolicyTriggers, models.PcfAmPolicyControlRequestTrigger_SERV_AREA_CH)
+	}
+	if old.RfspIndex != data.RfspIndex {
+		changes.PolicyTriggers = append(changes.PolicyTriggers, models.PcfAmPolicyControlRequestTrigger_RFSP_CH)
+	}
+	if !reflect.DeepEqual(old.SubscribedUeAmbr, data.SubscribedUeAmbr) {
+		changes.PolicyTriggers = append(changes.PolicyTriggers, models.PcfAmPolicyControlRequestTrigger_UE_AMBR_CH)
+	}
+
+	for _, ratType := range data.RatRestrictions {
+		if ratType == ue.RatType {
+			changes.DeregistrationCause = nasMessage.Cause5GMM5GSServicesNotAllowed
+		}
+	}
+	for _, coreNetworkType := range data.CoreNetworkTypeRestrictions {
+		if coreNetworkType == models.CoreNetworkType__5_GC {
+			changes.DeregistrationCause = nasMessage.Cause5GMM5GSServicesNotAllowed
+		}
+	}
+	if changes.DeregistrationCause == 0 && ue.IsInboundRoamer() &&
+		data.RoamingRestrictions != nil && !data.RoamingRestrictions.AccessAllowed {
+		changes.DeregistrationCause = nasMessage.Cause5GMMPLMNNotAllowed
+	}
+	// the UE is not to stay in a tracking area the subscription now forbids (TS 23.501 5.3.4.1.1)
+	if changes.DeregistrationCause == 0 && ue.InForbiddenArea() {
+		changes.DeregistrationCause = nasMessage.Cause5GMMTrackingAreaNotAllowed
+	}
+	return changes
+}
+
+// InForbiddenArea reports whether the tracking area of the UE is in the forbidden areas of its subscription
+func (ue *AmfUe) InForbiddenArea() bool {
+	data := ue.AccessAndMobilitySubscriptionData
+	if data == nil {
+		return false
+	}
+	for _, area := range data.ForbiddenAreas {
+		for _, tac := range area.Tacs {
+			if strings.EqualFold(tac, ue.Tai.Tac) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InSmfSelectionData reports whether the SMF selection subscription data of the UE has the DNN for the S-NSSAI,
+// the HPLMN S-NSSAI of an inbound roamer, the UE without SMF selection data having any
+func (ue *AmfUe) InSmfSelectionData(snssai models.Snssai, dnn string) bool {
+	if ue.SmfSelectionData == nil {
+		return true
+	}
+	key := snssaiKey(snssai)
+	for snssaiHex, snssaiInfo := range ue.SmfSelectionData.SubscribedSnssaiInfos {
+		if !strings.EqualFold(snssaiHex, key) {
+			continue
+		}
+		for _, dnnInfo := range snssaiInfo.DnnInfos {
+			if subscribedDnn, ok := dnnInfo.Dnn.(string); ok && (subscribedDnn == "*" || subscribedDnn == dnn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UpdateSubscribedNssai replaces the subscribed S-NSSAIs of the UE with the ones of the subscription
+func (ue *AmfUe) UpdateSubscribedNssai(nssai *models.Nssai) {
+	var subscribedNssai []models.SubscribedSnssai
+	if nssai != nil {
+		for _, snssai := range nssai.DefaultSingleNssais {
+			subscribedNssai = append(subscribedNssai, models.SubscribedSnssai{
+				SubscribedSnssai:  &models.Snssai{Sst: snssai.Sst, Sd: snssai.Sd},
+				DefaultIndication: true,
+			})
+		}
+		for _, snssai := range nssai.SingleNssais {
+			subscribedNssai = append(subscribedNssai, models.SubscribedSnssai{
+				SubscribedSnssai: &models.Snssai{Sst: snssai.Sst, Sd: snssai.Sd},
+			})
+		}
+	}
+	ue.SubscribedNssai = subscribedNssai
+}
+
+// RestrictNssaiToSubscription removes from the allowed and configured NSSAI of the UE the S-NSSAIs no longer
+// subscribed, and adds to them the subscribed S-NSSAIs supported by the AMF: all of them to the configured NSSAI,
+// the default ones to the allowed NSSAI of an access left without an allowed S-NSSAI. The allowed NSSAI is set
+// through the network slice admission control, see SetAllowedNssai.
+func (ue *AmfUe) RestrictNssaiToSubscription() {
+	amfSelf := GetSelf()
+	for anType, allowedNssai := range ue.AllowedNssai {
+		restricted := make([]models.AllowedSnssai, 0, len(allowedNssai))
+		for _, allowedSnssai := range allowedNssai {
+			homeSnssai := allowedSnssai.MappedHomeSnssai
+			if homeSnssai == nil && allowedSnssai.AllowedSnssai != nil {
+				// an alternative S-NSSAI is allowed as long as the S-NSSAI it replaces is
+				homeSnssai = ue.ReplacedSnssai(*allowedSnssai.AllowedSnssai, anType)
+			}
+			if homeSnssai == nil {
+				homeSnssai = allowedSnssai.AllowedSnssai
+			}
+			if homeSnssai != nil && ue.InSubscribedNssai(*homeSnssai) {
+				restricted = append(restricted, allowedSnssai)
+			}
+		}
+		if len(restricted) == 0 {
+			for _, subscribedSnssai := range ue.SubscribedNssai {
+				if subscribedSnssai.DefaultIndication && amfSelf.InPlmnSupportList(*subscribedSnssai.SubscribedSnssai) {
+					restricted = append(restricted, models.AllowedSnssai{AllowedSnssai: subscribedSnssai.SubscribedSnssai})
+				}
+			}
+		}
+		ue.SetAllowedNssai(anType, restricted)
+	}
+
+	configuredNssai := make([]models.ConfiguredSnssai, 0, len(ue.ConfiguredNssai))
+	for _, configuredSnssai := range ue.ConfiguredNssai {
+		homeSnssai := configuredSnssai.MappedHomeSnssai
+		if homeSnssai == nil {
+			homeSnssai = configuredSnssai.ConfiguredSnssai
+		}
+		if homeSnssai != nil && ue.InSubscribedNssai(*homeSnssai) {
+			configuredNssai = append(configuredNssai, configuredSnssai)
+		}
+	}
+	for _, subscribedSnssai := range ue.SubscribedNssai {
+		if !amfSelf.InPlmnSupportList(*subscribedSnssai.SubscribedSnssai) ||
+			inConfiguredNssai(configuredNssai, *subscribedSnssai.SubscribedSnssai) {
+			continue
+		}
+		configuredNssai = append(configuredNssai, models.ConfiguredSnssai{
+			ConfiguredSnssai: subscribedSnssai.SubscribedSnssai,
+		})
+	}
+	ue.ConfiguredNssai = configuredNssai
+}
+
+func inConfiguredNssai(configuredNssai []models.ConfiguredSnssai, homeSnssai models.Snssai) bool {
+	for _, configuredSnssai := range configuredNssai {
+		snssai := configuredSnssai.MappedHomeSnssai
+		if snssai == nil {
+			snssai = configuredSnssai.ConfiguredSnssai
+		}
+		if snssai != nil && openapi.SnssaiEqualFold(*snssai, homeSnssai) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/nas/nasMessage"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestAmDataChanges(t *testing.T) {
+	home := models.PlmnId{Mcc: "208", Mnc: "93"}
+	ue := &AmfUe{
+		Supi:    "imsi-208930000000001",
+		PlmnId:  home,
+		RatType: models.RatType_NR,
+		Tai:     models.Tai{PlmnId: &home, Tac: "000001"},
+	}
+	old := &models.AccessAndMobilitySubscriptionData{
+		SubscribedUeAmbr: &models.AmbrRm{Uplink: "1 Gbps", Downlink: "2 Gbps"},
+	}
+
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{
+		SubscribedUeAmbr: &models.AmbrRm{Uplink: "1 Gbps", Downlink: "2 Gbps"},
+	}
+	require.Equal(t, SubscriptionChanges{}, ue.AmDataChanges(old))
+
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{
+		SubscribedUeAmbr: &models.AmbrRm{Uplink: "1 Gbps", Downlink: "1 Gbps"},
+		ForbiddenAreas:   []models.Area{{Tacs: []string{"000002"}}},
+		RfspIndex:        2,
+	}
+	require.Equal(t, SubscriptionChanges{
+		MobilityRestrictions: true,
+		PolicyTriggers: []models.PcfAmPolicyControlRequestTrigger{
+			models.PcfAmPolicyControlRequestTrigger_RFSP_CH,
+			models.PcfAmPolicyControlRequestTrigger_UE_AMBR_CH,
+		},
+	}, ue.AmDataChanges(old))
+
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{
+		SubscribedUeAmbr: old.SubscribedUeAmbr,
+		RatRestrictions:  []models.RatType{models.RatType_NR},
+	}
+	changes := ue.AmDataChanges(old)
+	require.True(t, changes.MobilityRestrictions)
+	require.Equal(t, nasMessage.Cause5GMM5GSServicesNotAllowed, changes.DeregistrationCause)
+
+	// the roaming restrictions only apply to a roaming UE
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{
+		SubscribedUeAmbr:    old.SubscribedUeAmbr,
+		RoamingRestrictions: &models.RoamingRestrictions{AccessAllowed: false},
+	}
+	require.Zero(t, ue.AmDataChanges(old).DeregistrationCause)
+	// registered with a 5G-GUTI of the visited PLMN, the UE is roaming from the home PLMN of its IMSI
+	visited := models.PlmnId{Mcc: "466", Mnc: "92"}
+	self := GetSelf()
+	self.ServedGuamiList = []models.Guami{{
+		PlmnId: &models.PlmnIdNid{Mcc: visited.Mcc, Mnc: visited.Mnc},
+		AmfId:  "cafe00",
+	}}
+	self.RoamingCfg = &factory.Roaming{Partners: []factory.RoamingPartner{{PlmnId: &home}}}
+	defer func() {
+		self.ServedGuamiList = self.ServedGuamiList[:0]
+		self.RoamingCfg = nil
+	}()
+	ue.Tai.PlmnId, ue.PlmnId = &visited, visited
+	require.Equal(t, nasMessage.Cause5GMMPLMNNotAllowed, ue.AmDataChanges(old).DeregistrationCause)
+
+	// the UE in a tracking area now forbidden is deregistered
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{
+		SubscribedUeAmbr: old.SubscribedUeAmbr,
+		ForbiddenAreas:   []models.Area{{Tacs: []string{"000002", "000001"}}},
+	}
+	changes = ue.AmDataChanges(old)
+	require.True(t, changes.MobilityRestrictions)
+	require.Equal(t, nasMessage.Cause5GMMTrackingAreaNotAllowed, changes.DeregistrationCause)
+}
+
+func TestInSmfSelectionData(t *testing.T) {
+	snssai := models.Snssai{Sst: 1, Sd: "0A0B0C"}
+	ue := &AmfUe{}
+	require.True(t, ue.InSmfSelectionData(snssai, "internet"))
+
+	ue.SmfSelectionData = &models.SmfSelectionSubscriptionData{
+		SubscribedSnssaiInfos: map[string]models.SnssaiInfo{
+			"010a0b0c": {DnnInfos: []models.DnnInfo{{Dnn: "internet"}}},
+			"02":       {DnnInfos: []models.DnnInfo{{Dnn: "*"}}},
+		},
+	}
+	require.True(t, ue.InSmfSelectionData(snssai, "internet"))
+	require.False(t, ue.InSmfSelectionData(snssai, "ims"))
+	require.True(t, ue.InSmfSelectionData(models.Snssai{Sst: 2}, "ims"))
+	require.False(t, ue.InSmfSelectionData(models.Snssai{Sst: 3}, "internet"))
+}
+
+func TestRestrictNssaiToSubscription(t *testing.T) {
+	self := GetSelf()
+	plmnSupportList := self.PlmnSupportList
+	t.Cleanup(func() { self.PlmnSupportList = plmnSupportList })
+	self.PlmnSupportList = []factory.PlmnSupportItem{{
+		SNssaiList: []models.Snssai{{Sst: 1, Sd: "010203"}, {Sst: 1, Sd: "112233"}, {Sst: 2}},
+	}}
+
+	anType := models.AccessType__3_GPP_ACCESS
+	ue := &AmfUe{
+		AllowedNssai: map[models.AccessType][]models.AllowedSnssai{
+			anType: {{AllowedSnssai: &models.Snssai{Sst: 1, Sd: "112233"}}},
+		},
+		ConfiguredNssai: []models.ConfiguredSnssai{
+			{ConfiguredSnssai: &models.Snssai{Sst: 1, Sd: "010203"}},
+			{ConfiguredSnssai: &models.Snssai{Sst: 1, Sd: "112233"}},
+		},
+	}
+
+	// the allowed S-NSSAI is no longer subscribed, the default one replaces it
+	ue.UpdateSubscribedNssai(&models.Nssai{
+		DefaultSingleNssais: []models.Snssai{{Sst: 1, Sd: "010203"}},
+		SingleNssais:        []models.Snssai{{Sst: 2}, {Sst: 3}},
+	})
+	require.Len(t, ue.SubscribedNssai, 3)
+	ue.RestrictNssaiToSubscription()
+	require.Equal(t, []models.AllowedSnssai{{AllowedSnssai: &models.Snssai{Sst: 1, Sd: "010203"}}},
+		ue.AllowedNssai[anType])
+	require.Equal(t, []models.ConfiguredSnssai{
+		{ConfiguredSnssai: &models.Snssai{Sst: 1, Sd: "010203"}},
+		{ConfiguredSnssai: &models.Snssai{Sst: 2}},
+	}, ue.ConfiguredNssai)
+}
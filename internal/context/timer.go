@@ -13,6 +13,7 @@ type Timer struct {
 	ticker        *time.Ticker
 	expireTimes   int32 // accessed atomically
 	maxRetryTimes int32 // accessed atomically
+	stopped       int32 // accessed atomically
 	done          chan bool
 }
 
@@ -69,9 +70,15 @@ func (t *Timer) ExpireTimes() int32 {
 	return atomic.LoadInt32(&t.expireTimes)
 }
 
+// Stopped reports whether Stop has been called
+func (t *Timer) Stopped() bool {
+	return atomic.LoadInt32(&t.stopped) == 1
+}
+
 // Stop turns off the timer, after Stop, no more timeout event will be triggered. User should call Stop() only once
 // otherwise it may hang on writing to done channel
 func (t *Timer) Stop() {
+	atomic.StoreInt32(&t.stopped, 1)
 	t.done <- true
 	close(t.done)
 }
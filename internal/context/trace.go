@@ -0,0 +1,112 @@
+package context
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/free5gc/ngap/ngapConvert"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+)
+
+// TraceRecord is the report of a Cell Traffic Trace (TS 32.422 4.2.2.10): the NG-RAN trace session
+// identified by the Trace Reference and Trace Recording Session Reference, correlated with the UE identities
+type TraceRecord struct {
+	TraceRef    string       `json:"traceRef"`
+	Trsr        string       `json:"trsr"`
+	Supi        string       `json:"supi,omitempty"`
+	Pei         string       `json:"pei,omitempty"`
+	Ncgi        *models.Ncgi `json:"ncgi,omitempty"`
+	Ecgi        *models.Ecgi `json:"ecgi,omitempty"`
+	TceIpv4Addr string       `json:"tceIpv4Addr,omitempty"`
+	TceIpv6Addr string       `json:"tceIpv6Addr,omitempty"`
+	Timestamp   time.Time    `json:"timestamp"`
+}
+
+// TraceCollector keeps the latest Cell Traffic Trace reports so that they can be retrieved through OAM
+// when no Trace Collection Entity is configured (or in addition to it)
+type TraceCollector struct {
+	mu         sync.RWMutex
+	records    []TraceRecord
+	maxRecords int
+}
+
+func NewTraceCollector(maxRecords int) *TraceCollector {
+	return &TraceCollector{
+		maxRecords: maxRecords,
+	}
+}
+
+// Report stores the record; the oldest record is dropped once maxRecords is reached
+func (c *TraceCollector) Report(record TraceRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxRecords <= 0 {
+		return
+	}
+	if len(c.records) >= c.maxRecords {
+		c.records = append(c.records[:0], c.records[len(c.records)-c.maxRecords+1:]...)
+	}
+	c.records = append(c.records, record)
+}
+
+// Records returns the stored records of the SUPI, or all of them if supi is empty
+func (c *TraceCollector) Records(supi string) []TraceRecord {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	records := make([]TraceRecord, 0, len(c.records))
+	for _, record := range c.records {
+		if supi == "" || record.Supi == supi {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// TraceReferenceFromNgRanTraceId returns the Trace Reference ("<MCC><MNC>-<Trace ID>" as in models.TraceData)
+// and the Trace Recording Session Reference of the NG-RAN Trace ID (TS 38.413 9.3.1.88)
+func TraceReferenceFromNgRanTraceId(nGRANTraceID ngapType.NGRANTraceID) (string, string, error) {
+	if len(nGRANTraceID.Value) != 8 {
+		return "", "", fmt.Errorf("invalid NG-RAN Trace ID length: %d", len(nGRANTraceID.Value))
+	}
+	plmnID := ngapConvert.PlmnIdToModels(ngapType.PLMNIdentity{Value: nGRANTraceID.Value[:3]})
+	traceRef := fmt.Sprintf("%s%s-%s", plmnID.Mcc, plmnID.Mnc, hex.EncodeToString(nGRANTraceID.Value[3:6]))
+	return traceRef, hex.EncodeToString(nGRANTraceID.Value[6:]), nil
+}
+
+// ActivateTrace starts the trace session of the subscriber/equipment trace in the NG-RAN node serving the RanUe.
+// The AMF allocates the Trace Recording Session Reference (TS 32.422 4.2.2.9), which is kept until the trace
+// is deactivated or the RanUe is removed.
+func (ranUe *RanUe) ActivateTrace(traceData *models.TraceData) error {
+	if traceData == nil {
+		return fmt.Errorf("trace data is nil")
+	}
+	if ranUe.Trsr == "" {
+		trsr, err := trsrGenerator.Allocate()
+		if err != nil {
+			return fmt.Errorf("allocate TRSR failed: %w", err)
+		}
+		ranUe.Trsr = fmt.Sprintf("%04x", trsr)
+	}
+	ranUe.TraceData = traceData
+	return nil
+}
+
+// DeactivateTrace ends the trace session of the RanUe and frees its Trace Recording Session Reference
+func (ranUe *RanUe) DeactivateTrace() {
+	if ranUe.Trsr != "" {
+		if trsr, err := strconv.ParseInt(ranUe.Trsr, 16, 64); err == nil {
+			trsrGenerator.FreeID(trsr)
+		}
+	}
+	ranUe.Trsr = ""
+	ranUe.TraceData = nil
+}
+
+// TraceActivated reports whether a trace session is active in the NG-RAN node serving the RanUe
+func (ranUe *RanUe) TraceActivated() bool {
+	return ranUe.TraceData != nil
+}
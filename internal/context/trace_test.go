@@ -0,0 +1,69 @@
+package context
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/ngap/ngapConvert"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestTraceActivation(t *testing.T) {
+	traceData := &models.TraceData{
+		TraceRef:   "20893-4a2b3c",
+		TraceDepth: models.TraceDepth_MINIMUM,
+		NeTypeList: "04",
+		EventList:  "0f",
+	}
+
+	ranUe := &RanUe{}
+	require.False(t, ranUe.TraceActivated())
+	require.NoError(t, ranUe.ActivateTrace(traceData))
+	require.True(t, ranUe.TraceActivated())
+	require.Len(t, ranUe.Trsr, 4)
+
+	// the TRSR is kept while the trace session lasts
+	trsr := ranUe.Trsr
+	require.NoError(t, ranUe.ActivateTrace(traceData))
+	require.Equal(t, trsr, ranUe.Trsr)
+
+	// the NG-RAN Trace ID reported by Cell Traffic Trace maps back to the trace session
+	traceActivation := ngapConvert.TraceDataToNgap(*traceData, ranUe.Trsr)
+	traceRef, reportedTrsr, err := TraceReferenceFromNgRanTraceId(traceActivation.NGRANTraceID)
+	require.NoError(t, err)
+	require.Equal(t, traceData.TraceRef, traceRef)
+	require.Equal(t, trsr, reportedTrsr)
+
+	otherRanUe := &RanUe{}
+	require.NoError(t, otherRanUe.ActivateTrace(traceData))
+	require.NotEqual(t, trsr, otherRanUe.Trsr)
+	otherRanUe.DeactivateTrace()
+
+	ranUe.DeactivateTrace()
+	require.False(t, ranUe.TraceActivated())
+	require.Empty(t, ranUe.Trsr)
+}
+
+func TestTraceCollector(t *testing.T) {
+	collector := NewTraceCollector(3)
+	for i := 0; i < 5; i++ {
+		collector.Report(TraceRecord{
+			TraceRef: "20893-000001",
+			Trsr:     fmt.Sprintf("%04x", i),
+			Supi:     fmt.Sprintf("imsi-20893000000000%d", i%2),
+		})
+	}
+
+	// the oldest records are dropped
+	records := collector.Records("")
+	require.Len(t, records, 3)
+	require.Equal(t, "0002", records[0].Trsr)
+	require.Equal(t, "0004", records[2].Trsr)
+
+	records = collector.Records("imsi-208930000000000")
+	require.Len(t, records, 2)
+	require.Equal(t, "0002", records[0].Trsr)
+	require.Equal(t, "0004", records[1].Trsr)
+}
@@ -0,0 +1,174 @@
+package context
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/free5gc/amf/internal/tracing"
+	"github.com/free5gc/nas"
+	"github.com/free5gc/openapi/models"
+)
+
+// UE procedures traced from the NAS message initiating them until the GMM state machine of the access type
+// is back to the Registered or Deregistered state
+const (
+	TracedProcedureRegistration   = "Registration"
+	TracedProcedureServiceRequest = "ServiceRequest"
+	TracedProcedureDeregistration = "Deregistration"
+)
+
+// uplinkGmmMessageNames names the spans of the uplink 5GMM messages as the NAS metrics do
+var uplinkGmmMessageNames = map[uint8]string{
+	nas.MsgTypeRegistrationRequest:                              "RegistrationRequest",
+	nas.MsgTypeRegistrationComplete:                             "RegistrationComplete",
+	nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration: "DeregistrationRequestUEOriginatingDeregistration",
+	nas.MsgTypeDeregistrationAcceptUETerminatedDeregistration:   "DeregistrationAcceptUETerminatedDeregistration",
+	nas.MsgTypeServiceRequest:                                   "ServiceRequest",
+	nas.MsgTypeConfigurationUpdateComplete:                      "ConfigurationUpdateComplete",
+	nas.MsgTypeAuthenticationResponse:                           "AuthenticationResponse",
+	nas.MsgTypeAuthenticationFailure:                            "AuthenticationFailure",
+	nas.MsgTypeIdentityResponse:                                 "IdentityResponse",
+	nas.MsgTypeSecurityModeComplete:                             "SecurityModeComplete",
+	nas.MsgTypeSecurityModeReject:                               "SecurityModeReject",
+	nas.MsgTypeNotificationResponse:                             "NotificationResponse",
+	nas.MsgTypeULNASTransport:                                   "ULNASTransport",
+	nas.MsgTypeStatus5GMM:                                       "Status5GMM",
+}
+
+// TracedProcedureOf returns the UE procedure initiated by the uplink 5GMM message, "" for the other messages
+func TracedProcedureOf(msgType uint8) string {
+	switch msgType {
+	case nas.MsgTypeRegistrationRequest:
+		return TracedProcedureRegistration
+	case nas.MsgTypeServiceRequest:
+		return TracedProcedureServiceRequest
+	case nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration:
+		return TracedProcedureDeregistration
+	}
+	return ""
+}
+
+// StartProcedureSpan starts the span of the UE procedure on the access type, ending the span of the procedure
+// it interrupts
+func (ue *AmfUe) StartProcedureSpan(anType models.AccessType, procedure string) {
+	if !tracing.Enabled() {
+		return
+	}
+	ue.traceMu.Lock()
+	defer ue.traceMu.Unlock()
+	if span := ue.procedureSpans[anType]; span != nil {
+		span.SetStatus(codes.Error, "interrupted by "+procedure)
+		span.End()
+	}
+	if ue.procedureSpans == nil {
+		ue.procedureSpans = make(map[models.AccessType]trace.Span)
+	}
+	_, span := tracing.Tracer().Start(context.Background(), procedure,
+		trace.WithNewRoot(),
+		trace.WithAttributes(tracing.AttrSupi.String(ue.Supi), tracing.AttrAccessType.String(string(anType))))
+	ue.procedureSpans[anType] = span
+}
+
+// EndProcedureSpan ends the span of the UE procedure on the access type, if any
+func (ue *AmfUe) EndProcedureSpan(anType models.AccessType, err error) {
+	ue.traceMu.Lock()
+	defer ue.traceMu.Unlock()
+	span := ue.procedureSpans[anType]
+	if span == nil {
+		return
+	}
+	delete(ue.procedureSpans, anType)
+	if ue.Supi != "" {
+		// the SUPI is only known once the UE is identified during the registration
+		span.SetAttributes(tracing.AttrSupi.String(ue.Supi))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// HasProcedureSpan reports whether a UE procedure is traced on the access type
+func (ue *AmfUe) HasProcedureSpan(anType models.AccessType) bool {
+	ue.traceMu.Lock()
+	defer ue.traceMu.Unlock()
+	return ue.procedureSpans[anType] != nil
+}
+
+// StartNasSpan starts the span of the handling of the uplink 5GMM message, under the procedure of the access type.
+// It is the parent of the spans of the SBI requests sent until the returned function ends it.
+func (ue *AmfUe) StartNasSpan(anType models.AccessType, msgType uint8) func(err error) {
+	ue.traceMu.Lock()
+	defer ue.traceMu.Unlock()
+	parent := ue.procedureSpans[anType]
+	if parent == nil {
+		return func(error) {}
+	}
+	name, ok := uplinkGmmMessageNames[msgType]
+	if !ok {
+		name = "5GMM"
+	}
+	_, span := tracing.Tracer().Start(trace.ContextWithSpan(context.Background(), parent), "NAS "+name,
+		trace.WithAttributes(tracing.AttrNasMessageType.Int(int(msgType))))
+	prevActive := ue.activeSpan
+	ue.activeSpan = span
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		ue.traceMu.Lock()
+		ue.activeSpan = prevActive
+		ue.traceMu.Unlock()
+	}
+}
+
+// RecordNgapSpan records the span of the handling of an NGAP message of the UE received at the given time,
+// under the procedure of the access type
+func (ue *AmfUe) RecordNgapSpan(anType models.AccessType, received time.Time, present string, procedureCode int64) {
+	ue.traceMu.Lock()
+	parent := ue.procedureSpans[anType]
+	ue.traceMu.Unlock()
+	if parent == nil {
+		return
+	}
+	_, span := tracing.Tracer().Start(trace.ContextWithSpan(context.Background(), parent), "NGAP "+present,
+		trace.WithTimestamp(received),
+		trace.WithAttributes(tracing.AttrNgapPdu.String(present), tracing.AttrNgapProcedureCode.Int64(procedureCode)))
+	span.End()
+}
+
+// TraceContext returns ctx carrying the span the SBI requests of the UE are sent under: the span of the message
+// being handled, or else the span of the ongoing procedure
+func (ue *AmfUe) TraceContext(ctx context.Context) context.Context {
+	ue.traceMu.Lock()
+	defer ue.traceMu.Unlock()
+	span := ue.activeSpan
+	if span == nil {
+		span = ue.procedureSpans[models.AccessType__3_GPP_ACCESS]
+	}
+	if span == nil {
+		span = ue.procedureSpans[models.AccessType_NON_3_GPP_ACCESS]
+	}
+	if span == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, span)
+}
+
+// endProcedureSpans ends the spans of the procedures interrupted by the removal of the UE
+func (ue *AmfUe) endProcedureSpans() {
+	ue.traceMu.Lock()
+	defer ue.traceMu.Unlock()
+	for anType, span := range ue.procedureSpans {
+		span.SetStatus(codes.Error, "UE context removed")
+		span.End()
+		delete(ue.procedureSpans, anType)
+	}
+	ue.activeSpan = nil
+}
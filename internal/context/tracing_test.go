@@ -0,0 +1,70 @@
+package context
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/tracing"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/nas"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestProcedureSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := tracing.Init(&factory.Tracing{Exporter: factory.TracingExporterFile, Path: path}, "")
+	require.NoError(t, err)
+
+	ue := &AmfUe{Supi: "imsi-208930000000001"}
+	anType := models.AccessType__3_GPP_ACCESS
+	ue.StartProcedureSpan(anType, TracedProcedureOf(nas.MsgTypeRegistrationRequest))
+	require.True(t, ue.HasProcedureSpan(anType))
+
+	endNasSpan := ue.StartNasSpan(anType, nas.MsgTypeRegistrationRequest)
+	_, sbiSpan := tracing.Tracer().Start(ue.TraceContext(context.Background()), "nausf-auth Authenticate")
+	sbiSpan.End()
+	endNasSpan(nil)
+	// without a message being handled, the SBI requests are sent under the procedure
+	_, sbiSpan = tracing.Tracer().Start(ue.TraceContext(context.Background()), "nudm-sdm GetAmData")
+	sbiSpan.End()
+	ue.EndProcedureSpan(anType, nil)
+	require.False(t, ue.HasProcedureSpan(anType))
+
+	// no span outside of a procedure
+	require.Equal(t, context.Background(), ue.TraceContext(context.Background()))
+	require.NoError(t, shutdown(context.Background()))
+
+	type spanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	type span struct {
+		Name        string
+		SpanContext spanContext
+		Parent      spanContext
+	}
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	spans := make(map[string]span)
+	for dec := json.NewDecoder(f); dec.More(); {
+		var s span
+		require.NoError(t, dec.Decode(&s))
+		spans[s.Name] = s
+	}
+	require.Len(t, spans, 4)
+
+	registration := spans[TracedProcedureRegistration]
+	nasSpan := spans["NAS RegistrationRequest"]
+	require.Equal(t, registration.SpanContext.SpanID, nasSpan.Parent.SpanID)
+	require.Equal(t, nasSpan.SpanContext.SpanID, spans["nausf-auth Authenticate"].Parent.SpanID)
+	require.Equal(t, registration.SpanContext.SpanID, spans["nudm-sdm GetAmData"].Parent.SpanID)
+	for _, s := range spans {
+		require.Equal(t, registration.SpanContext.TraceID, s.SpanContext.TraceID)
+	}
+}
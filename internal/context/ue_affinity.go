@@ -0,0 +1,68 @@
+package context
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+	"sync"
+)
+
+// UeAffinity maps the AMF UE NGAP IDs of a UE to one logical UE key, so that all the NGAP messages of the UE
+// are handled in sequence by the same worker, whichever UE-associated logical NG-connection they are received on.
+//
+// A UE gets its key from the first message received for it (InitialUeKey), the key is bound to the AMF UE NGAP ID
+// allocated for it and followed by the AMF UE NGAP ID allocated in the target NG-RAN node on handover.
+// The AMF UE NGAP ID is kept on path switch, and so is the key.
+type UeAffinity struct {
+	mu   sync.RWMutex
+	keys map[int64]uint64 // AMF UE NGAP ID -> UE key
+}
+
+var ueAffinity = NewUeAffinity()
+
+func NewUeAffinity() *UeAffinity {
+	return &UeAffinity{
+		keys: make(map[int64]uint64),
+	}
+}
+
+func GetUeAffinity() *UeAffinity {
+	return ueAffinity
+}
+
+// InitialUeKey returns the key of a UE not known to the AMF yet, derived from the RAN UE NGAP ID assigned by the
+// NG-RAN node on the connection, since the RAN UE NGAP IDs of different NG-RAN nodes overlap
+func InitialUeKey(conn net.Conn, ranUeNgapId int64) uint64 {
+	h := fnv.New64a()
+	if conn != nil {
+		if addr := conn.RemoteAddr(); addr != nil {
+			_, _ = h.Write([]byte(addr.String()))
+		}
+	}
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], uint64(ranUeNgapId))
+	_, _ = h.Write(id[:])
+	return h.Sum64()
+}
+
+// Key returns the key bound to the AMF UE NGAP ID, or the AMF UE NGAP ID itself if none is bound
+func (a *UeAffinity) Key(amfUeNgapId int64) uint64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if key, ok := a.keys[amfUeNgapId]; ok {
+		return key
+	}
+	return uint64(amfUeNgapId)
+}
+
+func (a *UeAffinity) Bind(amfUeNgapId int64, key uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys[amfUeNgapId] = key
+}
+
+func (a *UeAffinity) Unbind(amfUeNgapId int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.keys, amfUeNgapId)
+}
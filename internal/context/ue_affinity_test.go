@@ -0,0 +1,64 @@
+package context
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/openapi/models"
+)
+
+type affinityTestAddr string
+
+func (a affinityTestAddr) Network() string { return "sctp" }
+func (a affinityTestAddr) String() string  { return string(a) }
+
+type affinityTestConn struct {
+	net.Conn
+	addr affinityTestAddr
+}
+
+func (c *affinityTestConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestUeAffinity(t *testing.T) {
+	newRan := func(addr string) *AmfRan {
+		return &AmfRan{
+			Conn:   &affinityTestConn{addr: affinityTestAddr(addr)},
+			AnType: models.AccessType__3_GPP_ACCESS,
+			Log:    logger.NgapLog.WithField(logger.FieldRanAddr, addr),
+		}
+	}
+	sourceRan := newRan("10.0.0.1")
+	targetRan := newRan("10.0.0.2")
+
+	// the same RAN UE NGAP ID assigned by different NG-RAN nodes belongs to different UEs
+	key := InitialUeKey(sourceRan.Conn, 1)
+	require.Equal(t, key, InitialUeKey(sourceRan.Conn, 1))
+	require.NotEqual(t, key, InitialUeKey(targetRan.Conn, 1))
+
+	// the key of the Initial UE Message follows the AMF UE NGAP ID allocated for the UE
+	sourceUe, err := sourceRan.NewRanUe(1)
+	require.NoError(t, err)
+	require.Equal(t, key, GetUeAffinity().Key(sourceUe.AmfUeNgapId))
+
+	// and the AMF UE NGAP ID allocated in the target NG-RAN node on handover
+	amfUe := &AmfUe{}
+	amfUe.init()
+	amfUe.AttachRanUe(sourceUe)
+	targetUe, err := targetRan.NewRanUe(RanUeNgapIdUnspecified)
+	require.NoError(t, err)
+	AttachSourceUeTargetUe(sourceUe, targetUe)
+	require.Equal(t, key, GetUeAffinity().Key(targetUe.AmfUeNgapId))
+
+	// the key is kept on path switch
+	targetUe.RanUeNgapId = 2
+	require.NoError(t, sourceUe.SwitchToRan(targetRan, 3))
+	require.Equal(t, key, GetUeAffinity().Key(sourceUe.AmfUeNgapId))
+
+	amfUeNgapId := targetUe.AmfUeNgapId
+	require.NoError(t, targetUe.Remove())
+	require.Equal(t, uint64(amfUeNgapId), GetUeAffinity().Key(amfUeNgapId))
+	require.NoError(t, sourceUe.Remove())
+}
@@ -0,0 +1,275 @@
+package context
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UeEventStartTimeout is how long Run waits for the UE event to be started by the event loop. The event loop may be
+// blocked by the UE's own NGAP procedure waiting for the NF which called the AMF, the event is then abandoned.
+const UeEventStartTimeout = 3 * time.Second
+
+var (
+	// ErrUeEventRejected is returned when the event loop does not admit the UE event (overload or shutdown)
+	ErrUeEventRejected = errors.New("UE event rejected by the event loop")
+	// ErrUeEventNotStarted is returned when the UE event is not started within UeEventStartTimeout
+	ErrUeEventNotStarted = errors.New("UE event not started in time")
+)
+
+// UeEventLoop runs the events posted with the same key in sequence, in posting order. The NGAP messages of a UE
+// are handled by the same loop with the UE's key (see UeAffinity), so that NGAP, NAS, SBI and timer events of
+// a UE never run concurrently.
+type UeEventLoop interface {
+	Post(key uint64, event func()) bool
+	// PostTimer posts the event of a UE timer, which is never dropped while the event loop runs
+	PostTimer(key uint64, event func()) bool
+}
+
+var (
+	ueEventLoop    UeEventLoop
+	ueEventLoopMu  sync.RWMutex
+	lastUeEventKey uint64 // accessed atomically
+)
+
+// SetUeEventLoop sets the event loop the UE events are posted to; until it is set, events run in place
+func SetUeEventLoop(loop UeEventLoop) {
+	ueEventLoopMu.Lock()
+	defer ueEventLoopMu.Unlock()
+	ueEventLoop = loop
+}
+
+func getUeEventLoop() UeEventLoop {
+	ueEventLoopMu.RLock()
+	defer ueEventLoopMu.RUnlock()
+	return ueEventLoop
+}
+
+// EventKey returns the key the events of the UE are posted with. It is the key of the first RanUe attached
+// to the UE, or a new key if the UE got an event before being attached to a RanUe.
+func (ue *AmfUe) EventKey() uint64 {
+	if key := atomic.LoadUint64(&ue.eventKey); key != 0 {
+		return key
+	}
+	atomic.CompareAndSwapUint64(&ue.eventKey, 0, atomic.AddUint64(&lastUeEventKey, 1))
+	return atomic.LoadUint64(&ue.eventKey)
+}
+
+// bindEventKey makes the NGAP messages of the RanUe follow the events of the UE. The key of a UE never changes once
+// set, so that its pending events are not overtaken; a RanUe attached to a UE with a key must therefore be attached
+// from the event loop of the UE (see ngap.handleInitialUEMessageMain).
+func (ue *AmfUe) bindEventKey(ranUe *RanUe) {
+	if atomic.CompareAndSwapUint64(&ue.eventKey, 0, ueAffinity.Key(ranUe.AmfUeNgapId)) {
+		return
+	}
+	ueAffinity.Bind(ranUe.AmfUeNgapId, ue.EventKey())
+}
+
+// Post queues the event in the event loop of the UE without waiting for it to run. It returns false if the event
+// loop did not admit the event, which is then dropped: running it in place would race with the UE's other events.
+func (ue *AmfUe) Post(event func()) bool {
+	loop := getUeEventLoop()
+	if loop == nil {
+		event()
+		return true
+	}
+	if !loop.Post(ue.EventKey(), event) {
+		ue.ProducerLog.Warnln("UE event rejected by the event loop, drop it")
+		return false
+	}
+	return true
+}
+
+// Run runs the event in the event loop of the UE and waits for it to complete. The event is not run if the
+// event loop does not admit it (ErrUeEventRejected) or does not start it within UeEventStartTimeout
+// (ErrUeEventNotStarted). While the event loop waits on a request to another NF (see AwaitNf), the event
+// runs in its place. Run must not be called from the event loop itself.
+func (ue *AmfUe) Run(event func()) error {
+	loop := getUeEventLoop()
+	if loop == nil {
+		event()
+		return nil
+	}
+
+	const (
+		pending int32 = iota
+		started
+		abandoned
+	)
+	var state int32
+	done := make(chan struct{})
+	posted := loop.Post(ue.EventKey(), func() {
+		if !atomic.CompareAndSwapInt32(&state, pending, started) {
+			return
+		}
+		defer close(done)
+		event()
+	})
+	if !posted {
+		ue.ProducerLog.Warnln("UE event rejected by the event loop")
+		return ErrUeEventRejected
+	}
+
+	claim := func() bool {
+		return atomic.CompareAndSwapInt32(&state, pending, started)
+	}
+	timer := time.NewTimer(UeEventStartTimeout)
+	defer timer.Stop()
+	for {
+		waitChanged := ue.nfWait.changed()
+		if ue.nfWait.runInPlace(claim, event) {
+			return nil
+		}
+		select {
+		case <-done:
+			return nil
+		case <-waitChanged:
+		case <-timer.C:
+			if atomic.CompareAndSwapInt32(&state, pending, abandoned) {
+				ue.ProducerLog.Warnf("UE event not started in %s, abandon it", UeEventStartTimeout)
+				return ErrUeEventNotStarted
+			}
+			<-done
+			return nil
+		}
+	}
+}
+
+// ueNfWait tracks the requests to other NFs the event loop of a UE waits on. Each request admits one event
+// run in place of the event loop at a time; the event loop resumes once the request and that event completed.
+type ueNfWait struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	requests []*ueNfRequest
+	notify   chan struct{} // closed on every change of the requests
+}
+
+type ueNfRequest struct {
+	running bool // an event runs in place of the event loop
+}
+
+// AwaitNf sends the request to another NF from the event loop of the UE. The NF may call the AMF back for the
+// UE before it answers; the events Run meanwhile run in place of the event loop rather than waiting on the request.
+// They take the UE lock, so the request is awaited as usual if the caller holds it.
+func (ue *AmfUe) AwaitNf(request func()) {
+	if !ue.Lock.TryLock() {
+		request()
+		return
+	}
+	ue.Lock.Unlock()
+
+	w := &ue.nfWait
+	r := w.begin()
+	defer w.end(r)
+	request()
+}
+
+func (w *ueNfWait) begin() *ueNfRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	r := new(ueNfRequest)
+	w.requests = append(w.requests, r)
+	w.changedLocked()
+	return r
+}
+
+// end waits for the event running in place of the event loop, if any
+func (w *ueNfWait) end(r *ueNfRequest) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.requests {
+		if w.requests[i] == r {
+			w.requests = append(w.requests[:i], w.requests[i+1:]...)
+			break
+		}
+	}
+	w.changedLocked()
+	for r.running {
+		w.cond.Wait()
+	}
+}
+
+// runInPlace runs the event in place of the event loop if the latest request it waits on admits it,
+// claim tells whether the event is still to be run
+func (w *ueNfWait) runInPlace(claim func() bool, event func()) bool {
+	w.mu.Lock()
+	if len(w.requests) == 0 || w.requests[len(w.requests)-1].running || !claim() {
+		w.mu.Unlock()
+		return false
+	}
+	r := w.requests[len(w.requests)-1]
+	r.running = true
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		r.running = false
+		w.changedLocked()
+		w.cond.Broadcast()
+	}()
+	event()
+	return true
+}
+
+// changed returns a channel closed on the next change of the requests
+func (w *ueNfWait) changed() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.notify == nil {
+		w.notify = make(chan struct{})
+	}
+	return w.notify
+}
+
+func (w *ueNfWait) changedLocked() {
+	if w.cond == nil {
+		w.cond = sync.NewCond(&w.mu)
+	}
+	if w.notify != nil {
+		close(w.notify)
+		w.notify = nil
+	}
+}
+
+// postTimer is Post for the expiry and cancel events of the timers of the UE
+func (ue *AmfUe) postTimer(event func()) {
+	loop := getUeEventLoop()
+	if loop == nil {
+		event()
+		return
+	}
+	if !loop.PostTimer(ue.EventKey(), event) {
+		ue.ProducerLog.Errorln("UE timer event rejected by the stopped event loop, drop it")
+	}
+}
+
+// NewTimer is NewTimer with the expiry and cancel of the timer handled as events of the UE.
+// The events of a timer stopped before they run are dropped.
+func (ue *AmfUe) NewTimer(d time.Duration, maxRetryTimes int,
+	expiredFunc func(expireTimes int32),
+	cancelFunc func(),
+) *Timer {
+	// the callbacks may run before NewTimer returns
+	var timer atomic.Pointer[Timer]
+	stopped := func() bool {
+		t := timer.Load()
+		return t != nil && t.Stopped()
+	}
+	t := NewTimer(d, maxRetryTimes, func(expireTimes int32) {
+		ue.postTimer(func() {
+			if !stopped() {
+				expiredFunc(expireTimes)
+			}
+		})
+	}, func() {
+		ue.postTimer(func() {
+			if !stopped() {
+				cancelFunc()
+			}
+		})
+	})
+	timer.Store(t)
+	return t
+}
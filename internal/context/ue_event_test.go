@@ -0,0 +1,65 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serialEventLoop runs all the events in sequence, whatever their key
+type serialEventLoop chan func()
+
+func (l serialEventLoop) Post(key uint64, event func()) bool {
+	l <- event
+	return true
+}
+
+func (l serialEventLoop) PostTimer(key uint64, event func()) bool {
+	return l.Post(key, event)
+}
+
+func (l serialEventLoop) run() {
+	for event := range l {
+		event()
+	}
+}
+
+func TestRunWhileAwaitingNf(t *testing.T) {
+	loop := make(serialEventLoop, 16)
+	go loop.run()
+	SetUeEventLoop(loop)
+	defer func() {
+		SetUeEventLoop(nil)
+		close(loop)
+	}()
+
+	ue := new(AmfUe)
+	var callbacks int
+	runErr := make(chan error, 1)
+	requestDone := make(chan struct{})
+	ue.Post(func() {
+		defer close(requestDone)
+		ue.AwaitNf(func() {
+			// the NF calls the AMF back for the UE before it answers the request
+			go func() {
+				runErr <- ue.Run(func() {
+					callbacks++
+				})
+			}()
+			select {
+			case err := <-runErr:
+				if err != nil {
+					t.Errorf("callback not run: %v", err)
+				}
+			case <-time.After(UeEventStartTimeout / 2):
+				t.Error("the callback did not run while the event loop awaited the NF")
+			}
+		})
+	})
+	<-requestDone
+
+	// the event queued by Run is not run again by the event loop
+	require.NoError(t, ue.Run(func() {}))
+	require.Equal(t, 1, callbacks)
+}
@@ -0,0 +1,530 @@
+package context
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/free5gc/nas/nasType"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/fsm"
+)
+
+// UeContextStore keeps the contexts of the registered UEs across AMF restarts, so that the UEs in CM-IDLE can
+// request service and update their registration without registering and authenticating again, as long as their
+// security keys are kept encrypted (see SetUeContextStoreKey). The contexts are keyed by SUPI and indexed by 5G-GUTI.
+type UeContextStore interface {
+	Save(snapshot *UeSnapshot) error
+	Delete(supi string) error
+	// FindByGuti returns the stored context of the 5G-GUTI, compared case-insensitively, or nil if there is none
+	FindByGuti(guti string) (*UeSnapshot, error)
+	// Range calls f for each stored context until f returns false; f must not modify the store
+	Range(f func(snapshot *UeSnapshot) bool) error
+	Close() error
+}
+
+// UeSnapshot is the part of the AmfUe kept in a UeContextStore
+type UeSnapshot struct {
+	/* Ue Identity */
+	Supi   string        `json:"supi"`
+	Gpsi   string        `json:"gpsi,omitempty"`
+	Pei    string        `json:"pei,omitempty"`
+	PlmnId models.PlmnId `json:"plmnId"`
+	Guti   string        `json:"guti"`
+	Tmsi   int32         `json:"tmsi"`
+	// GutiAllocatedAt is when the 5G-GUTI was allocated, the zero time in the contexts stored without it
+	GutiAllocatedAt time.Time `json:"gutiAllocatedAt"`
+	/* Registration */
+	RegisteredAccessTypes  []models.AccessType                          `json:"registeredAccessTypes"`
+	RatType                models.RatType                               `json:"ratType,omitempty"`
+	Tai                    models.Tai                                   `json:"tai"`
+	LastSeenRanId          *models.GlobalRanNodeId                      `json:"lastSeenRanId,omitempty"`
+	RegistrationArea       map[models.AccessType][]models.Tai           `json:"registrationArea,omitempty"`
+	AllowedNssai           map[models.AccessType][]models.AllowedSnssai `json:"allowedNssai,omitempty"`
+	ConfiguredNssai        []models.ConfiguredSnssai                    `json:"configuredNssai,omitempty"`
+	SubscribedNssai        []models.SubscribedSnssai                    `json:"subscribedNssai,omitempty"`
+	T3502Value             int                                          `json:"t3502Value,omitempty"`
+	T3512Value             int                                          `json:"t3512Value,omitempty"`
+	Non3gppDeregTimerValue int                                          `json:"non3gppDeregTimerValue,omitempty"`
+	UESpecificDRX          uint8                                        `json:"ueSpecificDrx,omitempty"`
+	UeRadioCapability      string                                       `json:"ueRadioCapability,omitempty"`
+	/* Security Context */
+	// SealedKeys is the ueSecurityKeys encrypted with the key of the store, without which the keys are not kept
+	SealedKeys           []byte                       `json:"sealedKeys,omitempty"`
+	ABBA                 []uint8                      `json:"abba,omitempty"`
+	NgKsi                models.NgKsi                 `json:"ngKsi"`
+	UESecurityCapability nasType.UESecurityCapability `json:"ueSecurityCapability"`
+	NCC                  uint8                        `json:"ncc"`
+	ULCount              uint32                       `json:"ulCount"`
+	DLCount              uint32                       `json:"dlCount"`
+	CipheringAlg         uint8                        `json:"cipheringAlg"`
+	IntegrityAlg         uint8                        `json:"integrityAlg"`
+	/* UDM */
+	UdmId                             string                                    `json:"udmId,omitempty"`
+	NudmUECMUri                       string                                    `json:"nudmUecmUri,omitempty"`
+	NudmSDMUri                        string                                    `json:"nudmSdmUri,omitempty"`
+	SdmSubscriptionId                 string                                    `json:"sdmSubscriptionId,omitempty"`
+	UeCmRegistered                    map[models.AccessType]bool                `json:"ueCmRegistered,omitempty"`
+	AccessAndMobilitySubscriptionData *models.AccessAndMobilitySubscriptionData `json:"amData,omitempty"`
+	SmfSelectionData                  *models.SmfSelectionSubscriptionData      `json:"smfSelectionData,omitempty"`
+	/* PCF */
+	PcfId               string                                      `json:"pcfId,omitempty"`
+	PcfUri              string                                      `json:"pcfUri,omitempty"`
+	PolicyAssociationId string                                      `json:"policyAssociationId,omitempty"`
+	AmPolicyUri         string                                      `json:"amPolicyUri,omitempty"`
+	AmPolicyAssociation *models.PcfAmPolicyControlPolicyAssociation `json:"amPolicyAssociation,omitempty"`
+	/* PDU Sessions */
+	SmContexts []SmContextSnapshot `json:"smContexts,omitempty"`
+}
+
+type SmContextSnapshot struct {
+	PduSessionId int32             `json:"pduSessionId"`
+	SmContextRef string            `json:"smContextRef"`
+	Snssai       models.Snssai     `json:"snssai"`
+	Dnn          string            `json:"dnn"`
+	AccessType   models.AccessType `json:"accessType"`
+	NsInstance   string            `json:"nsInstance,omitempty"`
+	PlmnId       models.PlmnId     `json:"plmnId"`
+	SmfId        string            `json:"smfId,omitempty"`
+	SmfUri       string            `json:"smfUri"`
+	HSmfId       string            `json:"hSmfId,omitempty"`
+	HSmfUri      string            `json:"hSmfUri,omitempty"`
+	VSmfId       string            `json:"vSmfId,omitempty"`
+}
+
+// ueSecurityKeys are the keys of the NAS security context of a UE, kept encrypted in the UE context store
+type ueSecurityKeys struct {
+	Kamf    string    `json:"kamf"`
+	KnasInt [16]uint8 `json:"knasInt"`
+	KnasEnc [16]uint8 `json:"knasEnc"`
+	NH      []uint8   `json:"nh,omitempty"`
+}
+
+var (
+	ueContextStore   UeContextStore
+	ueContextStoreMu sync.RWMutex
+	// ueKeysAead encrypts the security keys of the UE contexts stored, nil if they are not stored
+	ueKeysAead cipher.AEAD
+	// TMSIs of the restored UEs, not to be allocated to other UEs
+	restoredTmsi sync.Map // map[int32]struct{}
+)
+
+// SetUeContextStore sets the store the UE contexts are checkpointed to; until it is set, they are not
+func SetUeContextStore(store UeContextStore) {
+	ueContextStoreMu.Lock()
+	defer ueContextStoreMu.Unlock()
+	ueContextStore = store
+}
+
+// SetUeContextStoreKey sets the AES key the security keys of the UE contexts are encrypted with in the UE context
+// store. Until it is set, the keys are not stored and the restored UEs have no security context: they are
+// authenticated again when they register.
+func SetUeContextStoreKey(key []byte) error {
+	var aead cipher.AEAD
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("UE context store key: %w", err)
+		}
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return fmt.Errorf("UE context store key: %w", err)
+		}
+	}
+	ueContextStoreMu.Lock()
+	defer ueContextStoreMu.Unlock()
+	ueKeysAead = aead
+	return nil
+}
+
+func getUeKeysAead() cipher.AEAD {
+	ueContextStoreMu.RLock()
+	defer ueContextStoreMu.RUnlock()
+	return ueKeysAead
+}
+
+// sealSecurityKeys encrypts the security keys of the UE bound to its SUPI, nil if they are not to be stored
+func (ue *AmfUe) sealSecurityKeys() []byte {
+	aead := getUeKeysAead()
+	if aead == nil {
+		return nil
+	}
+	plaintext, err := json.Marshal(ueSecurityKeys{
+		Kamf:    ue.Kamf,
+		KnasInt: ue.KnasInt,
+		KnasEnc: ue.KnasEnc,
+		NH:      ue.NH,
+	})
+	if err != nil {
+		ue.GmmLog.Errorf("Encode UE security keys error: %+v", err)
+		return nil
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		ue.GmmLog.Errorf("Seal UE security keys error: %+v", err)
+		return nil
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(ue.Supi))
+}
+
+// openSecurityKeys decrypts the security keys of the UE sealed by sealSecurityKeys
+func openSecurityKeys(supi string, sealed []byte) (*ueSecurityKeys, error) {
+	aead := getUeKeysAead()
+	if aead == nil {
+		return nil, fmt.Errorf("no UE context store key")
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed security keys too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(supi))
+	if err != nil {
+		return nil, err
+	}
+	keys := new(ueSecurityKeys)
+	if err = json.Unmarshal(plaintext, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func getUeContextStore() UeContextStore {
+	ueContextStoreMu.RLock()
+	defer ueContextStoreMu.RUnlock()
+	return ueContextStore
+}
+
+// Checkpoint saves the context of the registered UE in the UE context store. It is called at the end of
+// the procedures changing the context kept across restarts (registration, release to CM-IDLE).
+func (ue *AmfUe) Checkpoint() {
+	store := getUeContextStore()
+	if store == nil || ue.Supi == "" || !ue.SecurityContextAvailable {
+		return
+	}
+	snapshot := ue.Snapshot()
+	if len(snapshot.RegisteredAccessTypes) == 0 {
+		return
+	}
+	if err := store.Save(snapshot); err != nil {
+		ue.GmmLog.Errorf("Checkpoint UE context error: %+v", err)
+		return
+	}
+	ue.GmmLog.Debugf("UE context checkpointed")
+}
+
+// deleteCheckpoint removes the context of the UE from the UE context store
+func (ue *AmfUe) deleteCheckpoint() {
+	store := getUeContextStore()
+	if store == nil || ue.Supi == "" {
+		return
+	}
+	if err := store.Delete(ue.Supi); err != nil {
+		ue.GmmLog.Errorf("Delete UE context checkpoint error: %+v", err)
+	}
+}
+
+// Snapshot returns the part of the UE context kept in the UE context store
+func (ue *AmfUe) Snapshot() *UeSnapshot {
+	snapshot := &UeSnapshot{
+		Supi:                              ue.Supi,
+		Gpsi:                              ue.Gpsi,
+		Pei:                               ue.Pei,
+		PlmnId:                            ue.PlmnId,
+		Guti:                              ue.Guti,
+		Tmsi:                              ue.Tmsi,
+		GutiAllocatedAt:                   ue.GutiAllocatedAt,
+		RatType:                           ue.RatType,
+		Tai:                               ue.Tai,
+		LastSeenRanId:                     ue.LastSeenRanId,
+		RegistrationArea:                  ue.RegistrationArea,
+		AllowedNssai:                      ue.AllowedNssai,
+		ConfiguredNssai:                   ue.ConfiguredNssai,
+		SubscribedNssai:                   ue.SubscribedNssai,
+		T3502Value:                        ue.T3502Value,
+		T3512Value:                        ue.T3512Value,
+		Non3gppDeregTimerValue:            ue.Non3gppDeregTimerValue,
+		UESpecificDRX:                     ue.UESpecificDRX,
+		UeRadioCapability:                 ue.UeRadioCapability,
+		SealedKeys:                        ue.sealSecurityKeys(),
+		ABBA:                              ue.ABBA,
+		NgKsi:                             ue.NgKsi,
+		UESecurityCapability:              ue.UESecurityCapability,
+		NCC:                               ue.NCC,
+		ULCount:                           ue.ULCount.Get(),
+		DLCount:                           ue.DLCount.Get(),
+		CipheringAlg:                      ue.CipheringAlg,
+		IntegrityAlg:                      ue.IntegrityAlg,
+		UdmId:                             ue.UdmId,
+		NudmUECMUri:                       ue.NudmUECMUri,
+		NudmSDMUri:                        ue.NudmSDMUri,
+		SdmSubscriptionId:                 ue.SdmSubscriptionId,
+		UeCmRegistered:                    ue.UeCmRegistered,
+		AccessAndMobilitySubscriptionData: ue.AccessAndMobilitySubscriptionData,
+		SmfSelectionData:                  ue.SmfSelectionData,
+		PcfId:                             ue.PcfId,
+		PcfUri:                            ue.PcfUri,
+		PolicyAssociationId:               ue.PolicyAssociationId,
+		AmPolicyUri:                       ue.AmPolicyUri,
+		AmPolicyAssociation:               ue.AmPolicyAssociation,
+	}
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		if state := ue.State[anType]; state != nil && state.Is(Registered) {
+			snapshot.RegisteredAccessTypes = append(snapshot.RegisteredAccessTypes, anType)
+		}
+	}
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		smContext := value.(*SmContext)
+		snapshot.SmContexts = append(snapshot.SmContexts, SmContextSnapshot{
+			PduSessionId: smContext.PduSessionID(),
+			SmContextRef: smContext.SmContextRef(),
+			Snssai:       smContext.Snssai(),
+			Dnn:          smContext.Dnn(),
+			AccessType:   smContext.AccessType(),
+			NsInstance:   smContext.NsInstance(),
+			PlmnId:       smContext.PlmnID(),
+			SmfId:        smContext.SmfID(),
+			SmfUri:       smContext.SmfUri(),
+			HSmfId:       smContext.HSmfID(),
+			HSmfUri:      smContext.HSmfUri(),
+			VSmfId:       smContext.VSmfID(),
+		})
+		return true
+	})
+	return snapshot
+}
+
+// restore sets the UE context from the snapshot; the UE is registered and in CM-IDLE
+func (ue *AmfUe) restore(snapshot *UeSnapshot) {
+	ue.Supi = snapshot.Supi
+	ue.UnauthenticatedSupi = false
+	ue.Gpsi = snapshot.Gpsi
+	ue.Pei = snapshot.Pei
+	ue.PlmnId = snapshot.PlmnId
+	ue.Guti = snapshot.Guti
+	ue.Tmsi = snapshot.Tmsi
+	// the periodic reallocation counts from the restore when the allocation time is unknown
+	ue.GutiAllocatedAt = snapshot.GutiAllocatedAt
+	if ue.GutiAllocatedAt.IsZero() {
+		ue.GutiAllocatedAt = time.Now()
+	}
+	for _, anType := range snapshot.RegisteredAccessTypes {
+		ue.State[anType] = fsm.NewState(Registered)
+	}
+	ue.RatType = snapshot.RatType
+	ue.Tai = snapshot.Tai
+	ue.LastSeenRanId = snapshot.LastSeenRanId
+	for anType, tais := range snapshot.RegistrationArea {
+		ue.RegistrationArea[anType] = tais
+	}
+	for anType, nssai := range snapshot.AllowedNssai {
+		ue.AllowedNssai[anType] = nssai
+	}
+	ue.ConfiguredNssai = snapshot.ConfiguredNssai
+	ue.SubscribedNssai = snapshot.SubscribedNssai
+	ue.T3502Value = snapshot.T3502Value
+	ue.T3512Value = snapshot.T3512Value
+	ue.Non3gppDeregTimerValue = snapshot.Non3gppDeregTimerValue
+	ue.UESpecificDRX = snapshot.UESpecificDRX
+	ue.UeRadioCapability = snapshot.UeRadioCapability
+
+	// without its keys, the UE is authenticated again when it registers
+	if keys, err := openSecurityKeys(snapshot.Supi, snapshot.SealedKeys); err != nil {
+		ue.GmmLog.Debugf("UE security context not restored: %+v", err)
+	} else {
+		ue.SecurityContextAvailable = true
+		ue.Kamf = keys.Kamf
+		ue.KnasInt = keys.KnasInt
+		ue.KnasEnc = keys.KnasEnc
+		ue.NH = keys.NH
+	}
+	ue.ABBA = snapshot.ABBA
+	ue.NgKsi = snapshot.NgKsi
+	ue.UESecurityCapability = snapshot.UESecurityCapability
+	ue.NCC = snapshot.NCC
+	ue.ULCount.Set(uint16(snapshot.ULCount>>8), uint8(snapshot.ULCount))
+	ue.DLCount.Set(uint16(snapshot.DLCount>>8), uint8(snapshot.DLCount))
+	// the UE may have used NAS COUNTs beyond the checkpointed ones before the AMF stopped, the NAS keys
+	// are refreshed before the AMF sends it any NAS message that could reuse them
+	ue.NasCountsRestored = ue.SecurityContextAvailable
+	ue.CipheringAlg = snapshot.CipheringAlg
+	ue.IntegrityAlg = snapshot.IntegrityAlg
+
+	ue.UdmId = snapshot.UdmId
+	ue.NudmUECMUri = snapshot.NudmUECMUri
+	ue.NudmSDMUri = snapshot.NudmSDMUri
+	ue.SdmSubscriptionId = snapshot.SdmSubscriptionId
+	for anType, registered := range snapshot.UeCmRegistered {
+		ue.UeCmRegistered[anType] = registered
+	}
+	ue.AccessAndMobilitySubscriptionData = snapshot.AccessAndMobilitySubscriptionData
+	ue.SmfSelectionData = snapshot.SmfSelectionData
+	ue.ContextValid = true
+
+	ue.PcfId = snapshot.PcfId
+	ue.PcfUri = snapshot.PcfUri
+	ue.PolicyAssociationId = snapshot.PolicyAssociationId
+	ue.AmPolicyUri = snapshot.AmPolicyUri
+	ue.AmPolicyAssociation = snapshot.AmPolicyAssociation
+
+	for _, s := range snapshot.SmContexts {
+		smContext := NewSmContext(s.PduSessionId)
+		smContext.SetSmContextRef(s.SmContextRef)
+		smContext.SetSnssai(s.Snssai)
+		smContext.SetDnn(s.Dnn)
+		smContext.SetAccessType(s.AccessType)
+		smContext.SetNsInstance(s.NsInstance)
+		smContext.SetPlmnID(s.PlmnId)
+		smContext.SetSmfID(s.SmfId)
+		smContext.SetSmfUri(s.SmfUri)
+		smContext.SetHSmfID(s.HSmfId)
+		smContext.SetHSmfUri(s.HSmfUri)
+		smContext.SetVSmfID(s.VSmfId)
+		ue.StoreSmContext(s.PduSessionId, smContext)
+	}
+}
+
+// RestoreUeContexts adds the UE contexts kept in the store to the UE pool. It must be called before
+// the NG-RAN nodes connect; the GUTIs of the restored UEs are not allocated to other UEs. The contexts
+// of the UEs of the GUAMIs not served by this AMF, kept by the other AMFs of the set sharing the store, are skipped.
+func (context *AMFContext) RestoreUeContexts(store UeContextStore) (int, error) {
+	restored := 0
+	err := store.Range(func(snapshot *UeSnapshot) bool {
+		if snapshot.Supi == "" {
+			return true
+		}
+		if guami, ok := GuamiOfGuti(snapshot.Guti); !ok || !context.IsServedGuami(guami) {
+			return true
+		}
+		ue := &AmfUe{}
+		ue.init()
+		ue.restore(snapshot)
+		restoredTmsi.Store(ue.Tmsi, struct{}{})
+		context.AddAmfUeToUePool(ue, ue.Supi)
+		restored++
+		return true
+	})
+	return restored, err
+}
+
+// CheckpointUeContexts saves the contexts of the registered UEs in the UE context store from the event loop of
+// each UE, so that the UEs in CM-CONNECTED resume with their latest NAS COUNTs once the AMF restarts
+func (context *AMFContext) CheckpointUeContexts() {
+	if getUeContextStore() == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	context.UePool.Range(func(key, value interface{}) bool {
+		ue := value.(*AmfUe)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ue.Run(ue.Checkpoint); err != nil {
+				ue.GmmLog.Warnf("UE context not checkpointed: %v", err)
+			}
+		}()
+		return true
+	})
+	wg.Wait()
+}
+
+// MemoryUeContextStore is a UeContextStore in memory, which keeps the UE contexts across the restarts of
+// the AMF context but not of the process
+type MemoryUeContextStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]memoryUeSnapshot // SUPI -> stored context
+	gutis     map[string]string           // gutiIndexKey(5G-GUTI) -> SUPI
+}
+
+type memoryUeSnapshot struct {
+	guti  string
+	value []byte // encoded UeSnapshot
+}
+
+func NewMemoryUeContextStore() *MemoryUeContextStore {
+	return &MemoryUeContextStore{
+		snapshots: make(map[string]memoryUeSnapshot),
+		gutis:     make(map[string]string),
+	}
+}
+
+// gutiIndexKey is the key of the 5G-GUTI in the GUTI index of the stores, whose AMF ID may be in either case
+func gutiIndexKey(guti string) string {
+	return strings.ToUpper(guti)
+}
+
+func (s *MemoryUeContextStore) Save(snapshot *UeSnapshot) error {
+	// encoded so that the stored context does not share the maps and slices of the UE context
+	value, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encode UE context: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindex(snapshot.Supi)
+	s.snapshots[snapshot.Supi] = memoryUeSnapshot{guti: snapshot.Guti, value: value}
+	if snapshot.Guti != "" {
+		s.gutis[gutiIndexKey(snapshot.Guti)] = snapshot.Supi
+	}
+	return nil
+}
+
+func (s *MemoryUeContextStore) Delete(supi string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindex(supi)
+	delete(s.snapshots, supi)
+	return nil
+}
+
+// unindex removes the 5G-GUTI of the stored context of the SUPI from the GUTI index
+func (s *MemoryUeContextStore) unindex(supi string) {
+	stored, ok := s.snapshots[supi]
+	if !ok || stored.guti == "" {
+		return
+	}
+	if key := gutiIndexKey(stored.guti); s.gutis[key] == supi {
+		delete(s.gutis, key)
+	}
+}
+
+func (s *MemoryUeContextStore) FindByGuti(guti string) (*UeSnapshot, error) {
+	s.mu.RLock()
+	stored, ok := s.snapshots[s.gutis[gutiIndexKey(guti)]]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	snapshot := new(UeSnapshot)
+	if err := json.Unmarshal(stored.value, snapshot); err != nil {
+		return nil, fmt.Errorf("decode UE context: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (s *MemoryUeContextStore) Range(f func(snapshot *UeSnapshot) bool) error {
+	s.mu.RLock()
+	values := make([][]byte, 0, len(s.snapshots))
+	for _, stored := range s.snapshots {
+		values = append(values, stored.value)
+	}
+	s.mu.RUnlock()
+
+	for _, value := range values {
+		snapshot := new(UeSnapshot)
+		if err := json.Unmarshal(value, snapshot); err != nil {
+			return fmt.Errorf("decode UE context: %w", err)
+		}
+		if !f(snapshot) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryUeContextStore) Close() error {
+	return nil
+}
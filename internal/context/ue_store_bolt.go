@@ -0,0 +1,142 @@
+package context
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ueContextBucket = []byte("ue")
+	// gutiIndexKey(5G-GUTI) -> SUPI of the contexts in ueContextBucket
+	ueGutiBucket = []byte("ue-guti")
+)
+
+// BoltUeContextStore is a UeContextStore in a bbolt database file, which keeps the UE contexts across
+// the restarts of the AMF. The file is locked by the AMF using it.
+type BoltUeContextStore struct {
+	db *bolt.DB
+}
+
+func NewBoltUeContextStore(path string) (*BoltUeContextStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open UE context store %s: %w", path, err)
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		if _, errBucket := tx.CreateBucketIfNotExists(ueContextBucket); errBucket != nil {
+			return errBucket
+		}
+		_, errBucket := tx.CreateBucketIfNotExists(ueGutiBucket)
+		return errBucket
+	}); err != nil {
+		if errClose := db.Close(); errClose != nil {
+			err = fmt.Errorf("%w (close: %v)", err, errClose)
+		}
+		return nil, fmt.Errorf("open UE context store %s: %w", path, err)
+	}
+	return &BoltUeContextStore{db: db}, nil
+}
+
+func (s *BoltUeContextStore) Save(snapshot *UeSnapshot) error {
+	value, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encode UE context: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(snapshot.Supi)
+		if err := unindexGuti(tx, key); err != nil {
+			return err
+		}
+		if snapshot.Guti != "" {
+			if err := tx.Bucket(ueGutiBucket).Put([]byte(gutiIndexKey(snapshot.Guti)), key); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(ueContextBucket).Put(key, value)
+	})
+}
+
+func (s *BoltUeContextStore) Delete(supi string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(supi)
+		if err := unindexGuti(tx, key); err != nil {
+			return err
+		}
+		return tx.Bucket(ueContextBucket).Delete(key)
+	})
+}
+
+// unindexGuti removes the 5G-GUTI of the stored context of the SUPI from the GUTI index
+func unindexGuti(tx *bolt.Tx, supi []byte) error {
+	guti := storedGuti(tx.Bucket(ueContextBucket).Get(supi))
+	if guti == "" {
+		return nil
+	}
+	gutis := tx.Bucket(ueGutiBucket)
+	key := []byte(gutiIndexKey(guti))
+	if !bytes.Equal(gutis.Get(key), supi) {
+		return nil
+	}
+	return gutis.Delete(key)
+}
+
+// storedGuti returns the 5G-GUTI of the encoded UeSnapshot, empty if there is none
+func storedGuti(value []byte) string {
+	if value == nil {
+		return ""
+	}
+	var snapshot struct {
+		Guti string `json:"guti"`
+	}
+	if err := json.Unmarshal(value, &snapshot); err != nil {
+		return ""
+	}
+	return snapshot.Guti
+}
+
+func (s *BoltUeContextStore) FindByGuti(guti string) (*UeSnapshot, error) {
+	var snapshot *UeSnapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		supi := tx.Bucket(ueGutiBucket).Get([]byte(gutiIndexKey(guti)))
+		if supi == nil {
+			return nil
+		}
+		value := tx.Bucket(ueContextBucket).Get(supi)
+		if value == nil {
+			return nil
+		}
+		snapshot = new(UeSnapshot)
+		if err := json.Unmarshal(value, snapshot); err != nil {
+			return fmt.Errorf("decode UE context %s: %w", supi, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (s *BoltUeContextStore) Range(f func(snapshot *UeSnapshot) bool) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(ueContextBucket).Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			snapshot := new(UeSnapshot)
+			if err := json.Unmarshal(value, snapshot); err != nil {
+				return fmt.Errorf("decode UE context %s: %w", key, err)
+			}
+			if !f(snapshot) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltUeContextStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,151 @@
+package context
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/fsm"
+)
+
+func TestUeContextStore(t *testing.T) {
+	boltStore, err := NewBoltUeContextStore(filepath.Join(t.TempDir(), "ue.db"))
+	require.NoError(t, err)
+
+	for name, store := range map[string]UeContextStore{
+		"memory": NewMemoryUeContextStore(),
+		"bolt":   boltStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			self := GetSelf()
+			SetUeContextStore(store)
+			defer SetUeContextStore(nil)
+			require.NoError(t, SetUeContextStoreKey(make([]byte, 32)))
+			defer func() { require.NoError(t, SetUeContextStoreKey(nil)) }()
+			self.ServedGuamiList = []models.Guami{{
+				PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"},
+				AmfId:  "cafe00",
+			}}
+			defer func() { self.ServedGuamiList = nil }()
+
+			// the TMSI the generator allocates next
+			tmsi := self.TmsiAllocate()
+			self.FreeTmsi(int64(tmsi))
+			tmsi++
+
+			ue := &AmfUe{}
+			ue.init()
+			ue.Supi = "imsi-208930000000001"
+			ue.Tmsi = tmsi
+			ue.Guti = "20893cafe0000000001"
+			ue.GutiAllocatedAt = time.Now().Add(-time.Hour)
+			ue.State[models.AccessType__3_GPP_ACCESS] = fsm.NewState(Registered)
+			ue.SecurityContextAvailable = true
+			ue.Kamf = "kamf"
+			ue.KnasInt = [16]uint8{1, 2, 3}
+			ue.ULCount.Set(1, 5)
+			ue.DLCount.Set(2, 7)
+			ue.IntegrityAlg = 2
+			ue.RegistrationArea[models.AccessType__3_GPP_ACCESS] = []models.Tai{{Tac: "000001"}}
+			smContext := NewSmContext(1)
+			smContext.SetSmContextRef("ref-1")
+			smContext.SetSmfUri("http://smf")
+			smContext.SetAccessType(models.AccessType__3_GPP_ACCESS)
+			ue.StoreSmContext(1, smContext)
+			ue.Checkpoint()
+
+			// the context is indexed by its current 5G-GUTI
+			snapshot, err := store.FindByGuti("20893CAFE0000000001")
+			require.NoError(t, err)
+			require.NotNil(t, snapshot)
+			require.Equal(t, ue.Supi, snapshot.Supi)
+			ue.Guti = "20893cafe0000000002"
+			ue.Checkpoint()
+			snapshot, err = store.FindByGuti("20893cafe0000000001")
+			require.NoError(t, err)
+			require.Nil(t, snapshot)
+			ue.Guti = "20893cafe0000000001"
+			ue.Checkpoint()
+
+			restored, err := self.RestoreUeContexts(store)
+			require.NoError(t, err)
+			require.Equal(t, 1, restored)
+			restoredUe, ok := self.AmfUeFindBySupi(ue.Supi)
+			require.True(t, ok)
+			require.NotSame(t, ue, restoredUe)
+
+			require.Equal(t, ue.Guti, restoredUe.Guti)
+			require.True(t, ue.GutiAllocatedAt.Equal(restoredUe.GutiAllocatedAt))
+			require.True(t, restoredUe.State[models.AccessType__3_GPP_ACCESS].Is(Registered))
+			require.True(t, restoredUe.State[models.AccessType_NON_3_GPP_ACCESS].Is(Deregistered))
+			require.True(t, restoredUe.SecurityContextAvailable)
+			require.Equal(t, ue.Kamf, restoredUe.Kamf)
+			require.Equal(t, ue.KnasInt, restoredUe.KnasInt)
+			require.Equal(t, ue.ULCount.Get(), restoredUe.ULCount.Get())
+			require.Equal(t, ue.DLCount.Get(), restoredUe.DLCount.Get())
+			// the NAS keys are refreshed before the restored NAS COUNTs are used
+			require.False(t, ue.NasKeysNeedRefresh())
+			require.True(t, restoredUe.NasKeysNeedRefresh())
+			require.Equal(t, ue.RegistrationArea, restoredUe.RegistrationArea)
+			restoredSmContext, ok := restoredUe.SmContextFindByPDUSessionID(1)
+			require.True(t, ok)
+			require.Equal(t, "ref-1", restoredSmContext.SmContextRef())
+			require.Equal(t, "http://smf", restoredSmContext.SmfUri())
+
+			// the TMSI of the restored UE is not allocated to another UE
+			next := self.TmsiAllocate()
+			require.NotEqual(t, tmsi, next)
+			self.FreeTmsi(int64(next))
+
+			// the removed UE is not restored again
+			restoredUe.Remove()
+			require.NoError(t, store.Range(func(snapshot *UeSnapshot) bool {
+				t.Errorf("UE context %s not deleted", snapshot.Supi)
+				return true
+			}))
+			snapshot, err = store.FindByGuti(ue.Guti)
+			require.NoError(t, err)
+			require.Nil(t, snapshot)
+			require.NoError(t, store.Close())
+		})
+	}
+}
+
+func TestUeContextStoreKey(t *testing.T) {
+	ue := &AmfUe{}
+	ue.init()
+	ue.Supi = "imsi-208930000000001"
+	ue.Kamf = "kamf"
+	ue.KnasEnc = [16]uint8{4, 5, 6}
+
+	// the security keys are not stored without key
+	require.Nil(t, ue.Snapshot().SealedKeys)
+
+	require.NoError(t, SetUeContextStoreKey(make([]byte, 16)))
+	defer func() { require.NoError(t, SetUeContextStoreKey(nil)) }()
+	snapshot := ue.Snapshot()
+	require.NotContains(t, string(snapshot.SealedKeys), "kamf")
+	keys, err := openSecurityKeys(ue.Supi, snapshot.SealedKeys)
+	require.NoError(t, err)
+	require.Equal(t, ue.Kamf, keys.Kamf)
+	require.Equal(t, ue.KnasEnc, keys.KnasEnc)
+
+	// the keys are bound to the UE
+	_, err = openSecurityKeys("imsi-208930000000002", snapshot.SealedKeys)
+	require.Error(t, err)
+	require.Error(t, SetUeContextStoreKey([]byte{1, 2, 3}))
+
+	// the UE restored without its keys has no security context
+	snapshot.SealedKeys = nil
+	restoredUe := &AmfUe{}
+	restoredUe.init()
+	restoredUe.restore(snapshot)
+	require.False(t, restoredUe.SecurityContextAvailable)
+	require.False(t, restoredUe.NasCountsRestored)
+	// the allocation of the 5G-GUTI stored without its time counts from the restore
+	require.False(t, restoredUe.GutiAllocatedAt.IsZero())
+	require.Empty(t, restoredUe.Kamf)
+}
@@ -12,34 +12,39 @@ import (
 
 func RemoveAmfUe(ue *context.AmfUe, notifyNF bool) {
 	if notifyNF {
-		// notify SMF to release all sessions
-		ue.SmContextList.Range(func(key, value interface{}) bool {
-			smContext := value.(*context.SmContext)
-
-			problemDetail, err := consumer.GetConsumer().SendReleaseSmContextRequest(ue, smContext, nil, "", nil)
-			if problemDetail != nil {
-				ue.GmmLog.Errorf("Release SmContext Failed Problem[%+v]", problemDetail)
-			} else if err != nil {
-				ue.GmmLog.Errorf("Release SmContext Error[%v]", err.Error())
-			}
-			return true
-		})
-
-		// notify PCF to terminate AmPolicy association
-		if ue.AmPolicyAssociation != nil {
-			problemDetails, err := consumer.GetConsumer().AMPolicyControlDelete(ue)
-			if problemDetails != nil {
-				ue.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problemDetails)
-			} else if err != nil {
-				ue.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
-			}
-		}
+		ReleaseAmfUeSessions(ue)
 	}
 
 	PurgeAmfUeSubscriberData(ue)
 	ue.Remove()
 }
 
+// ReleaseAmfUeSessions releases the PDU sessions of the UE in the SMFs and its AM policy association in the PCF
+func ReleaseAmfUeSessions(ue *context.AmfUe) {
+	// notify SMF to release all sessions
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		smContext := value.(*context.SmContext)
+
+		problemDetail, err := consumer.GetConsumer().SendReleaseSmContextRequest(ue, smContext, nil, "", nil)
+		if problemDetail != nil {
+			ue.GmmLog.Errorf("Release SmContext Failed Problem[%+v]", problemDetail)
+		} else if err != nil {
+			ue.GmmLog.Errorf("Release SmContext Error[%v]", err.Error())
+		}
+		return true
+	})
+
+	// notify PCF to terminate AmPolicy association
+	if ue.AmPolicyAssociation != nil {
+		problemDetails, err := consumer.GetConsumer().AMPolicyControlDelete(ue)
+		if problemDetails != nil {
+			ue.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			ue.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
+		}
+	}
+}
+
 func PurgeAmfUeSubscriberData(ue *context.AmfUe) {
 	if ue.RanUe[models.AccessType__3_GPP_ACCESS] != nil {
 		err := PurgeSubscriberData(ue, models.AccessType__3_GPP_ACCESS)
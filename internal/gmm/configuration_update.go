@@ -0,0 +1,121 @@
+package gmm
+
+import (
+	"github.com/free5gc/amf/internal/context"
+	gmm_message "github.com/free5gc/amf/internal/gmm/message"
+	ngap_message "github.com/free5gc/amf/internal/ngap/message"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+)
+
+// UpdateConfiguration queues changes of the UE configuration to be sent to the UE registered over the access in
+// a Configuration Update Command (TS 24.501 5.4.4). The changes are merged with the ones still pending and sent
+// at once when the UE is in CM-CONNECTED state and no command awaits acknowledgement; the UE in CM-IDLE state
+// over 3GPP access is paged, the changes being sent once it is connected again.
+func UpdateConfiguration(ue *context.AmfUe, anType models.AccessType, flags *context.ConfigurationUpdateCommandFlags) {
+	if !ue.State[anType].Is(context.Registered) {
+		ue.GmmLog.Debugf("UE is not registered over %s, configuration update not queued", anType)
+		return
+	}
+	ue.QueueConfigurationUpdate(anType, flags)
+
+	if ue.CmConnect(anType) {
+		SendPendingConfigurationUpdate(ue, anType)
+		return
+	}
+	if anType == models.AccessType__3_GPP_ACCESS {
+		pageForSignalling(ue)
+	}
+}
+
+// pageForSignalling pages the UE in CM-IDLE state over 3GPP access for downlink signalling, unless it is being paged
+func pageForSignalling(ue *context.AmfUe) {
+	anType := models.AccessType__3_GPP_ACCESS
+	if ue.OnGoing(anType).Procedure == context.OnGoingProcedurePaging {
+		return
+	}
+	ue.SetOnGoing(anType, &context.OnGoing{
+		Procedure: context.OnGoingProcedurePaging,
+	})
+	pkg, err := ngap_message.BuildPaging(ue, nil, false)
+	if err != nil {
+		ue.GmmLog.Errorf("Build Paging failed : %s", err.Error())
+		return
+	}
+	ngap_message.SendPaging(ue, pkg)
+}
+
+// SendPendingConfigurationUpdate sends the changes pending over the access to the UE in CM-CONNECTED state,
+// unless a command still awaits acknowledgement
+func SendPendingConfigurationUpdate(ue *context.AmfUe, anType models.AccessType) {
+	if !ue.CmConnect(anType) {
+		return
+	}
+	flags := ue.TakePendingConfigurationUpdate(anType)
+	if flags == nil {
+		return
+	}
+	if gmm_message.SendConfigurationUpdateCommand(ue, anType, flags, func() {
+		abortConfigurationUpdate(ue)
+	}) {
+		ue.SetConfigurationUpdateSent(anType, flags)
+	}
+}
+
+// completeConfigurationUpdate ends the procedure acknowledged by the UE, releasing the NAS signalling connection of
+// the UE requested to register again when it has no PDU session to keep (TS 24.501 5.4.4.3), and sends the changes
+// queued meanwhile
+func completeConfigurationUpdate(ue *context.AmfUe) {
+	sent, anType := ue.ConfigurationUpdateSent()
+	// the UE now uses the reallocated 5G-GUTI, if any, the old one being released along
+	ue.AbortConfigurationUpdate()
+	if sent != nil && sent.NeedRegistration && !hasSmContext(ue, anType) {
+		if ranUe := ue.RanUe[anType]; ranUe != nil {
+			ue.GmmLog.Infof("Release the NAS signalling connection over %s for the UE to register again", anType)
+			ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextN2NormalRelease,
+				ngapType.CausePresentNas, ngapType.CauseNasPresentNormalRelease)
+			return
+		}
+	}
+	sendAllPendingConfigurationUpdates(ue)
+}
+
+// abortConfigurationUpdate ends the procedure T3555 gave up on, the changes queued meanwhile are still sent
+func abortConfigurationUpdate(ue *context.AmfUe) {
+	ue.AbortConfigurationUpdate()
+	sendAllPendingConfigurationUpdates(ue)
+}
+
+// sendQueuedUpdates sends the configuration update and the UE parameters update queued for the UE just accepted
+// by a Service Accept or whose registration just completed over the access
+func sendQueuedUpdates(ue *context.AmfUe, anType models.AccessType) {
+	SendPendingConfigurationUpdate(ue, anType)
+	SendQueuedParametersUpdate(ue, anType)
+}
+
+// sendQueuedUpdatesWithoutRegistrationComplete sends the updates queued for the UE right after its Registration
+// Accept over 3GPP access when T3550 is disabled, no Registration Complete being handled then. Otherwise they are
+// sent on Registration Complete, and over non-3GPP access the Registration Accept itself waits for the N3IWF.
+func sendQueuedUpdatesWithoutRegistrationComplete(ue *context.AmfUe, anType models.AccessType) {
+	if ue.T3550 != nil || anType != models.AccessType__3_GPP_ACCESS {
+		return
+	}
+	sendQueuedUpdates(ue, anType)
+}
+
+func sendAllPendingConfigurationUpdates(ue *context.AmfUe) {
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		if ue.HasPendingConfigurationUpdate(anType) {
+			SendPendingConfigurationUpdate(ue, anType)
+		}
+	}
+}
+
+func hasSmContext(ue *context.AmfUe, anType models.AccessType) bool {
+	found := false
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		found = value.(*context.SmContext).AccessType() == anType
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,49 @@
+package gmm
+
+import (
+	"time"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+)
+
+// ReallocateGuti assigns a new 5G-GUTI to the UE registered over the access and sends it in a Configuration Update
+// Command the UE acknowledges (TS 24.501 5.4.4.2). The replaced 5G-GUTI stays valid until then, a single
+// reallocation being unacknowledged at a time.
+func ReallocateGuti(ue *context.AmfUe, anType models.AccessType) {
+	if ue.OldGuti != "" {
+		ue.GmmLog.Debugf("5G-GUTI[%s] not acknowledged yet, reallocation postponed", ue.Guti)
+		return
+	}
+	if !ue.State[anType].Is(context.Registered) {
+		return
+	}
+	context.GetSelf().ReallocateGutiToUe(ue)
+	ue.GmmLog.Infof("Reallocate 5G-GUTI[%s], replacing 5G-GUTI[%s]", ue.Guti, ue.OldGuti)
+	UpdateConfiguration(ue, anType, &context.ConfigurationUpdateCommandFlags{
+		NeedGUTI: true,
+	})
+}
+
+// reallocateGutiOnServiceRequest reallocates the 5G-GUTI of the UE accepted by a service request once it is older
+// than the configured interval or has been used for the configured number of service requests
+func reallocateGutiOnServiceRequest(ue *context.AmfUe, anType models.AccessType) {
+	policy := factory.AmfConfig.GetGutiReallocation()
+	if policy == nil {
+		return
+	}
+	ue.GutiServiceRequests++
+	if (policy.ServiceRequests > 0 && ue.GutiServiceRequests >= policy.ServiceRequests) ||
+		(policy.Interval > 0 && time.Since(ue.GutiAllocatedAt) >= policy.Interval) {
+		ReallocateGuti(ue, anType)
+	}
+}
+
+// ReallocateGutiOnMobility reallocates the 5G-GUTI of the UE handed over to another tracking area
+// when the configured policy requires it
+func ReallocateGutiOnMobility(ue *context.AmfUe, anType models.AccessType) {
+	if policy := factory.AmfConfig.GetGutiReallocation(); policy != nil && policy.OnMobility {
+		ReallocateGuti(ue, anType)
+	}
+}
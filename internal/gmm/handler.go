@@ -58,22 +58,15 @@ func HandleULNASTransport(ue *context.AmfUe, anType models.AccessType,
 	case nasMessage.PayloadContainerTypeLPP:
 		return fmt.Errorf("PayloadContainerTypeLPP has not been implemented yet in UL NAS TRANSPORT")
 	case nasMessage.PayloadContainerTypeSOR:
-		return fmt.Errorf("PayloadContainerTypeSOR has not been implemented yet in UL NAS TRANSPORT")
+		ue.GmmLog.Infoln("AMF Transfer SOR Ack To UDM")
+		return handleSorAck(ue, ulNasTransport.PayloadContainer.GetPayloadContainerContents())
 	case nasMessage.PayloadContainerTypeUEPolicy:
 		ue.GmmLog.Infoln("AMF Transfer UEPolicy To PCF")
 		callback.SendN1MessageNotify(ue, models.N1MessageClass_UPDP,
 			ulNasTransport.PayloadContainer.GetPayloadContainerContents(), nil)
 	case nasMessage.PayloadContainerTypeUEParameterUpdate:
 		ue.GmmLog.Infoln("AMF Transfer UEParameterUpdate To UDM")
-		upuMac, err := nasConvert.UpuAckToModels(ulNasTransport.PayloadContainer.GetPayloadContainerContents())
-		if err != nil {
-			return err
-		}
-		err = consumer.GetConsumer().PutUpuAck(ue, upuMac)
-		if err != nil {
-			return err
-		}
-		ue.GmmLog.Debugf("UpuMac[%s] in UPU ACK NAS Msg", upuMac)
+		return handleUpuAck(ue, ulNasTransport.PayloadContainer.GetPayloadContainerContents())
 	case nasMessage.PayloadContainerTypeMultiplePayload:
 		return fmt.Errorf("PayloadContainerTypeMultiplePayload has not been implemented yet in UL NAS TRANSPORT")
 	}
@@ -136,7 +129,7 @@ func transport5GSMMessage(ue *context.AmfUe, anType models.AccessType,
 				}
 				ue.GmmLog.Warningf("Duplicated PDU session ID[%d]", pduSessionID)
 				smContext.SetDuplicatedPduSessionID(true)
-				response, _, _, err := consumer.GetConsumer().SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+				response, _, _, err := consumer.GetConsumer().SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 				if err != nil {
 					ue.GmmLog.Errorf("Failed to update smContext, local release SmContext[%d]", pduSessionID)
 					ue.DeleteSmContext(pduSessionID, smContext.AccessType())
@@ -236,7 +229,7 @@ func CreatePDUSession(ulNasTransport *nasMessage.ULNASTransport,
 	if ulNasTransport.SNSSAI != nil {
 		snssai = nasConvert.SnssaiToModels(ulNasTransport.SNSSAI)
 	} else {
-		if allowedNssai, ok := ue.AllowedNssai[anType]; ok {
+		if allowedNssai := ue.AllowedNssai[anType]; len(allowedNssai) > 0 {
 			snssai = *allowedNssai[0].AllowedSnssai
 		} else {
 			return false, errors.New("Ue doesn't have allowedNssai")
@@ -262,15 +255,18 @@ func CreatePDUSession(ulNasTransport *nasMessage.ULNASTransport,
 		}
 	}
 
+	// the S-NSSAI out of service in the tracking area is served by its alternative S-NSSAI
+	if alternative := ue.AlternativeSnssai(snssai, anType); alternative != nil {
+		ue.GmmLog.Infof("S-NSSAI[%+v] replaced by alternative S-NSSAI[%+v]", snssai, *alternative)
+		snssai = *alternative
+	}
+
 	if newSmContext, cause, errSelectSmf := consumer.GetConsumer().SelectSmf(
 		ue, anType, pduSessionID, snssai, dnn); errSelectSmf != nil {
 		ue.GmmLog.Errorf("Select SMF failed: %+v", errSelectSmf)
 		gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
 			smMessage, pduSessionID, cause, nil, 0)
 	} else {
-		ue.Lock.Lock()
-		defer ue.Lock.Unlock()
-
 		smContextRef, errResponse, problemDetail, errSendReq := consumer.GetConsumer().SendCreateSmContextRequest(
 			ue, newSmContext, nil, smMessage)
 		if errSendReq != nil {
@@ -317,7 +313,7 @@ func forward5GSMMessageToSMF(
 		smContextUpdateData.AnType = accessType
 	}
 
-	response, errResponse, problemDetail, err := consumer.GetConsumer().SendUpdateSmContextRequest(smContext,
+	response, errResponse, problemDetail, err := consumer.GetConsumer().SendUpdateSmContextRequest(ue, smContext,
 		&smContextUpdateData, smMessage, nil)
 
 	if err != nil {
@@ -437,7 +433,15 @@ func HandleRegistrationRequest(ue *context.AmfUe, anType models.AccessType, proc
 	switch ue.RegistrationType5GS {
 	case nasMessage.RegistrationType5GSInitialRegistration:
 		ue.GmmLog.Infof("RegistrationType: Initial Registration")
-		ue.SecurityContextAvailable = false // need to start authentication procedure later
+		if context.IsDraining() && ue.RanUe[anType] != nil && ue.RanUe[anType].InitialUEMessage != nil {
+			// TS 23.501 5.21.2.2.1: the AMF being removed has NG-RAN select another AMF of the set
+			ngap_message.SendRerouteNasRequest(ue, anType, nil, ue.RanUe[anType].InitialUEMessage, nil)
+			releaseReroutedUe(ue, anType)
+			return fmt.Errorf("registration rerouted to the AMF set: AMF is draining")
+		}
+		if authenticateOnInitialRegistration() {
+			ue.SecurityContextAvailable = false // need to start authentication procedure later
+		}
 	case nasMessage.RegistrationType5GSMobilityRegistrationUpdating:
 		ue.GmmLog.Infof("RegistrationType: Mobility Registration Updating")
 		if ue.State[anType].Is(context.Deregistered) {
@@ -459,6 +463,7 @@ func HandleRegistrationRequest(ue *context.AmfUe, anType models.AccessType, proc
 		ue.GmmLog.Infof("RegistrationType: %v, chage state to InitialRegistration", ue.RegistrationType5GS)
 		ue.RegistrationType5GS = nasMessage.RegistrationType5GSInitialRegistration
 	}
+	ue.CountRegistration()
 
 	mobileIdentity5GSContents := registrationRequest.MobileIdentity5GS.GetMobileIdentity5GSContents()
 	if len(mobileIdentity5GSContents) < 1 {
@@ -487,20 +492,23 @@ func HandleRegistrationRequest(ue *context.AmfUe, anType models.AccessType, proc
 		ue.PlmnId = util.PlmnIdNidToModelsPlmnId(*guamiFromUeGuti.PlmnId)
 		ue.GmmLog.Infof("MobileIdentity5GS: GUTI[%s]", guti)
 
-		// TODO: support multiple ServedGuami
-		servedGuami := amfSelf.ServedGuamiList[0]
-		if reflect.DeepEqual(guamiFromUeGuti, servedGuami) {
+		// a UE of a failed AMF of the set found by its 5G-S-TMSI has the context taken over by this AMF
+		takenOver := amfSelf.IsBackedUpGuami(guamiFromUeGuti) && ue.SecurityContextAvailable &&
+			strings.EqualFold(ue.Guti, guti)
+		if amfSelf.IsServedGuami(guamiFromUeGuti) || takenOver {
 			ue.ServingAmfChanged = false
 			// refresh 5G-GUTI according to 6.12.3 Subscription temporary identifier, TS33.501
 			if ue.SecurityContextAvailable {
 				context.GetSelf().FreeTmsi(int64(ue.Tmsi))
+				ue.ReleaseOldGuti()
 				context.GetSelf().AllocateGutiToUe(ue)
 			}
 		} else {
-			ue.GmmLog.Infof("Serving AMF has changed: guamiFromUeGuti[%+v], servedGuami[%+v]",
-				guamiFromUeGuti, servedGuami)
+			ue.GmmLog.Infof("Serving AMF has changed: guamiFromUeGuti[%+v], servedGuamiList[%+v]",
+				guamiFromUeGuti, amfSelf.ServedGuamiList)
 			ue.ServingAmfChanged = true
 			context.GetSelf().FreeTmsi(int64(ue.Tmsi))
+			ue.ReleaseOldGuti()
 			ue.Guti = guti
 		}
 	case nasMessage.MobileIdentity5GSTypeImei:
@@ -576,6 +584,23 @@ func HandleRegistrationRequest(ue *context.AmfUe, anType models.AccessType, proc
 	return nil
 }
 
+// releaseReroutedUe releases locally the N2 connection of the UE whose Initial UE Message was rerouted to another
+// AMF, which NG-RAN connects the UE with (TS 38.413 8.6.5); the context of a UE registered with none of the
+// accesses is removed as well
+func releaseReroutedUe(ue *context.AmfUe, anType models.AccessType) {
+	if err := ue.RanUe[anType].Remove(); err != nil {
+		ue.GmmLog.Errorf("Remove rerouted RanUe error: %v", err)
+	}
+	for _, accessType := range []models.AccessType{
+		models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS,
+	} {
+		if ue.RanUe[accessType] != nil || !ue.State[accessType].Is(context.Deregistered) {
+			return
+		}
+	}
+	gmm_common.RemoveAmfUe(ue, false)
+}
+
 func contextTransferFromOldAmf(ue *context.AmfUe, anType models.AccessType, oldAmfGuami models.Guami) error {
 	ue.GmmLog.Infof("ContextTransfer from old AMF[%s %s]", oldAmfGuami.PlmnId, oldAmfGuami.AmfId)
 
@@ -775,6 +800,7 @@ func HandleInitialRegistration(ue *context.AmfUe, anType models.AccessType) erro
 	}
 
 	gmm_message.SendRegistrationAccept(ue, anType, nil, nil, nil, nil, nil)
+	sendQueuedUpdatesWithoutRegistrationComplete(ue, anType)
 	return nil
 }
 
@@ -894,21 +920,23 @@ func HandleMobilityAndPeriodicRegistrationUpdating(ue *context.AmfUe, anType mod
 			gmm_message.SendRegistrationAccept(ue, anType, pduSessionStatus,
 				reactivationResult, errPduSessionId, errCause, &cxtList)
 
+			resourceUri := ue.N1N2Message.ResourceUri
 			switch requestData.N1MessageContainer.N1MessageClass {
 			case models.N1MessageClass_SM:
-				gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
-					n1Msg, requestData.PduSessionId, 0, nil, 0)
+				gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
+					n1Msg, requestData, resourceUri)
 			case models.N1MessageClass_LPP:
-				gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeLPP,
-					n1Msg, 0, 0, nil, 0)
+				gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeLPP,
+					n1Msg, requestData, resourceUri)
 			case models.N1MessageClass_SMS:
-				gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeSMS,
-					n1Msg, 0, 0, nil, 0)
+				gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeSMS,
+					n1Msg, requestData, resourceUri)
 			case models.N1MessageClass_UPDP:
-				gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeUEPolicy,
-					n1Msg, 0, 0, nil, 0)
+				gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeUEPolicy,
+					n1Msg, requestData, resourceUri)
 			}
 			ue.N1N2Message = nil
+			sendQueuedUpdatesWithoutRegistrationComplete(ue, anType)
 			return nil
 		}
 
@@ -953,6 +981,7 @@ func HandleMobilityAndPeriodicRegistrationUpdating(ue *context.AmfUe, anType mod
 
 	gmm_message.SendRegistrationAccept(ue, anType, pduSessionStatus, reactivationResult,
 		errPduSessionId, errCause, &cxtList)
+	sendQueuedUpdatesWithoutRegistrationComplete(ue, anType)
 	return nil
 }
 
@@ -1010,8 +1039,8 @@ func communicateWithUDM(ue *context.AmfUe, accessType models.AccessType) error {
 	param := Nnrf_NFDiscovery.SearchNFInstancesRequest{
 		Supi: &ue.Supi,
 	}
-	resp, err := consumer.GetConsumer().SendSearchNFInstances(
-		amfSelf.NrfUri, models.NrfNfManagementNfType_UDM, models.NrfNfManagementNfType_AMF, &param)
+	resp, err := consumer.GetConsumer().SearchNFInstancesInHomePlmn(
+		ue, amfSelf.NrfUri, models.NrfNfManagementNfType_UDM, models.NrfNfManagementNfType_AMF, &param)
 	if err != nil {
 		return errors.Errorf("AMF can not select an UDM by NRF: SendSearchNFInstances failed")
 	}
@@ -1049,6 +1078,28 @@ func communicateWithUDM(ue *context.AmfUe, accessType models.AccessType) error {
 	} else if err != nil {
 		return errors.Wrap(err, "SDM_Get AmData Error")
 	}
+	// the home PLMN of the inbound roamer may forbid its access to the serving PLMN, the UE is then not to stay
+	// registered at the UDM with this AMF
+	if amData := ue.AccessAndMobilitySubscriptionData; ue.IsInboundRoamer() &&
+		amData.RoamingRestrictions != nil && !amData.RoamingRestrictions.AccessAllowed {
+		problemDetails, err = consumer.GetConsumer().UeCmDeregistration(ue, accessType)
+		if problemDetails != nil {
+			ue.GmmLog.Errorf("UECM Deregistration Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			ue.GmmLog.Errorf("UECM Deregistration Error[%+v]", err)
+		}
+		ue.UeCmRegistered[accessType] = false
+		return errors.Errorf("access to serving PLMN[%+v] not allowed by roaming restrictions", ue.ServingPlmnId())
+	}
+	// the steering of roaming information goes in the Registration Accept of this registration only
+	if amData := ue.AccessAndMobilitySubscriptionData; amData != nil && amData.SorInfo != nil {
+		if _, errSor := gmm_message.BuildSorTransparentContainer(amData.SorInfo); errSor != nil {
+			ue.GmmLog.Warnf("SOR transparent container not included: %+v", errSor)
+		} else {
+			ue.SetRegistrationSor(amData.SorInfo)
+		}
+	}
+	ngap_message.SendTraceUpdate(ue)
 
 	problemDetails, err = consumer.GetConsumer().SDMGetSmfSelectData(ue)
 	if problemDetails != nil {
@@ -1110,11 +1161,27 @@ func handleRequestedNssai(ue *context.AmfUe, anType models.AccessType) error {
 			return fmt.Errorf("decode failed at RequestedNSSAI[%s]", err)
 		}
 
+		// the subscription of the inbound roamer is in HPLMN S-NSSAIs, mapped by its roaming partner when the UE
+		// does not provide the mapping
+		inboundRoamer := ue.IsInboundRoamer()
+		if inboundRoamer {
+			for i := range requestedNssai {
+				if requestedNssai[i].HomeSnssai == nil {
+					homeSnssai := ue.HomeSnssai(*requestedNssai[i].ServingSnssai)
+					requestedNssai[i].HomeSnssai = &homeSnssai
+				}
+			}
+		}
+
 		needSliceSelection := false
 		for _, requestedSnssai := range requestedNssai {
+			subscribedSnssai := *requestedSnssai.ServingSnssai
+			if inboundRoamer {
+				subscribedSnssai = *requestedSnssai.HomeSnssai
+			}
 			ue.GmmLog.Infof("RequestedNssai - ServingSnssai: %+v, HomeSnssai: %+v",
 				requestedSnssai.ServingSnssai, requestedSnssai.HomeSnssai)
-			if ue.InSubscribedNssai(*requestedSnssai.ServingSnssai) {
+			if ue.InSubscribedNssai(subscribedSnssai) {
 				allowedSnssai := models.AllowedSnssai{
 					AllowedSnssai: &models.Snssai{
 						Sst: requestedSnssai.ServingSnssai.Sst,
@@ -1266,16 +1333,21 @@ func handleRequestedNssai(ue *context.AmfUe, anType models.AccessType) error {
 	if len(ue.AllowedNssai[anType]) == 0 {
 		for _, snssai := range ue.SubscribedNssai {
 			if snssai.DefaultIndication {
-				if amfSelf.InPlmnSupportList(*snssai.SubscribedSnssai) {
+				servingSnssai := ue.ServingSnssai(*snssai.SubscribedSnssai)
+				if amfSelf.InPlmnSupportList(servingSnssai) {
 					allowedSnssai := models.AllowedSnssai{
-						AllowedSnssai: snssai.SubscribedSnssai,
+						AllowedSnssai: &servingSnssai,
+					}
+					// the allowed NSSAI of the inbound roamer carries the mapped HPLMN S-NSSAIs
+					if ue.IsInboundRoamer() {
+						allowedSnssai.MappedHomeSnssai = snssai.SubscribedSnssai
 					}
 					ue.AllowedNssai[anType] = append(ue.AllowedNssai[anType], allowedSnssai)
 				}
 			}
 		}
 	}
-	return nil
+	return applySliceAvailability(ue, anType)
 }
 
 func assignLadnInfo(ue *context.AmfUe, accessType models.AccessType) {
@@ -1619,8 +1691,9 @@ func HandleNotificationResponse(ue *context.AmfUe, notificationResponse *nasMess
 
 	ue.StopT3565()
 
+	var psiArray [psiArraySize]bool
 	if notificationResponse != nil && notificationResponse.PDUSessionStatus != nil {
-		psiArray := nasConvert.PSIToBooleanArray(notificationResponse.PDUSessionStatus.Buffer)
+		psiArray = nasConvert.PSIToBooleanArray(notificationResponse.PDUSessionStatus.Buffer)
 		for psi := 1; psi <= 15; psi++ {
 			pduSessionId := int32(psi)
 			if smContext, ok := ue.SmContextFindByPDUSessionID(pduSessionId); ok {
@@ -1639,9 +1712,29 @@ func HandleNotificationResponse(ue *context.AmfUe, notificationResponse *nasMess
 			}
 		}
 	}
+
+	// TS 23.502 4.2.3.3 step 4c: the pending downlink data can only be delivered if the UE
+	// re-established the user plane of its PDU session
+	if !pendingSessionReactivated(ue, &psiArray) {
+		callback.SendN1N2TransferFailureNotification(ue, models.N1N2MessageTransferCause_UE_NOT_REACHABLE_FOR_SESSION)
+	}
 	return nil
 }
 
+// pendingSessionReactivated tells whether the PDU session of the pending N1N2 message is active
+// in the UE and the UE is connected over the access of the session
+func pendingSessionReactivated(ue *context.AmfUe, psiArray *[psiArraySize]bool) bool {
+	if ue.N1N2Message == nil || ue.N1N2Message.Request.JsonData == nil {
+		return false
+	}
+	pduSessionId := ue.N1N2Message.Request.JsonData.PduSessionId
+	if !validator.IsPduSessionIdInPsiRange(pduSessionId) || !psiArray[pduSessionId] {
+		return false
+	}
+	smContext, ok := ue.SmContextFindByPDUSessionID(pduSessionId)
+	return ok && ue.CmConnect(smContext.AccessType())
+}
+
 func HandleConfigurationUpdateComplete(ue *context.AmfUe,
 	configurationUpdateComplete *nasMessage.ConfigurationUpdateComplete,
 ) error {
@@ -1652,7 +1745,7 @@ func HandleConfigurationUpdateComplete(ue *context.AmfUe,
 	}
 
 	// Stop timer T3555 in TS 24.501 Figure 5.4.4.1.1 in handler
-	ue.StopT3555()
+	completeConfigurationUpdate(ue)
 	// TODO: Send acknowledgment by Nudm_SMD_Info_Service to UDM in handler
 	//		import "github.com/free5gc/openapi/Nudm_SubscriberDataManagement" client.Info
 
@@ -1665,10 +1758,6 @@ func AuthenticationProcedure(ue *context.AmfUe, accessType models.AccessType) (b
 	// Check whether UE has SUCI and SUPI
 	if IdentityVerification(ue) {
 		ue.GmmLog.Debugln("UE has SUCI / SUPI")
-		if ue.SecurityContextIsValid() {
-			ue.GmmLog.Debugln("UE has a valid security context - skip the authentication procedure")
-			return true, nil
-		}
 	} else {
 		// Request UE's SUCI by sending identity request
 		ue.IdentityRequestSendTimes++
@@ -1676,12 +1765,34 @@ func AuthenticationProcedure(ue *context.AmfUe, accessType models.AccessType) (b
 		return false, nil
 	}
 
+	// the inbound roamers are served from the home PLMNs of the roaming partners only
+	if ue.IsInboundRoamer() && ue.RoamingPartner() == nil {
+		gmm_message.SendRegistrationReject(ue.RanUe[accessType], nasMessage.Cause5GMMPLMNNotAllowed, "")
+		return false, fmt.Errorf("home PLMN[%+v] of the UE is not a roaming partner", ue.HomePlmnId())
+	}
+
+	reason := authenticationReason(ue)
+	if reason == "" {
+		ue.GmmLog.Debugln("UE has a valid security context - skip the authentication procedure")
+		return true, nil
+	}
+	if ue.SecurityContextIsValid() {
+		// TS 24.501 5.4.1.3.2: the new partial native security context gets another ngKSI than the current one
+		if ue.NgKsi.Ksi < 6 { // ksi is range from 0 to 6
+			ue.NgKsi.Ksi += 1
+		} else {
+			ue.NgKsi.Ksi = 0
+		}
+	}
+	ue.GmmLog.Infof("Authenticate the UE: %s", reason)
+	ue.StartAuthentication(accessType, reason)
+
 	amfSelf := context.GetSelf()
 
 	// TODO: consider ausf group id, Routing ID part of SUCI
 	param := Nnrf_NFDiscovery.SearchNFInstancesRequest{}
-	resp, err := consumer.GetConsumer().SendSearchNFInstances(
-		amfSelf.NrfUri, models.NrfNfManagementNfType_AUSF, models.NrfNfManagementNfType_AMF, &param)
+	resp, err := consumer.GetConsumer().SearchNFInstancesInHomePlmn(
+		ue, amfSelf.NrfUri, models.NrfNfManagementNfType_AUSF, models.NrfNfManagementNfType_AMF, &param)
 	if err != nil {
 		ue.GmmLog.Error("AMF can not select an AUSF by NRF")
 		gmm_message.SendRegistrationReject(ue.RanUe[accessType], nasMessage.Cause5GMMCongestion, "")
@@ -1728,6 +1839,7 @@ func AuthenticationProcedure(ue *context.AmfUe, accessType models.AccessType) (b
 		return false, err
 	}
 	ue.AuthenticationCtx = response
+	ue.SetAuthenticationType(response.AuthType)
 	ue.ABBA = []uint8{0x00, 0x00} // set ABBA value as described at TS 33.501 Annex A.7.1
 
 	gmm_message.SendAuthenticationRequest(ue.RanUe[accessType])
@@ -1804,6 +1916,12 @@ func HandleServiceRequest(ue *context.AmfUe, anType models.AccessType,
 		ue.RetransmissionOfInitialNASMsg = ue.MacFailed
 	}
 
+	// the service request is accepted with the NAS and AS keys refreshed from a KAMF derived horizontally
+	if startNasKeyRefresh(ue, anType) {
+		ue.PendingServiceRequest = serviceRequest
+		return nil
+	}
+
 	serviceType := serviceRequest.GetServiceTypeValue()
 	var reactivationResult *[psiArraySize]bool
 	var errPduSessionId, errCause []uint8
@@ -1820,8 +1938,12 @@ func HandleServiceRequest(ue *context.AmfUe, anType models.AccessType,
 	}
 
 	if serviceType == nasMessage.ServiceTypeSignalling {
-		err := gmm_message.SendServiceAccept(ue, anType, cxtList, pduStatusResult, nil, nil, nil)
-		return err
+		if err := gmm_message.SendServiceAccept(ue, anType, cxtList, pduStatusResult, nil, nil, nil); err != nil {
+			return err
+		}
+		sendQueuedUpdates(ue, anType)
+		reallocateGutiOnServiceRequest(ue, anType)
+		return nil
 	}
 
 	var N1N2ReqData *models.N1N2MessageTransferReqData
@@ -1867,21 +1989,24 @@ func HandleServiceRequest(ue *context.AmfUe, anType models.AccessType,
 				if err != nil {
 					return err
 				}
+				resourceUri := ue.N1N2Message.ResourceUri
 				switch N1N2ReqData.N1MessageContainer.N1MessageClass {
 				case models.N1MessageClass_SM:
-					gmm_message.SendDLNASTransport(ue.RanUe[anType],
-						nasMessage.PayloadContainerTypeN1SMInfo, n1Msg, N1N2ReqData.PduSessionId, 0, nil, 0)
+					gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType],
+						nasMessage.PayloadContainerTypeN1SMInfo, n1Msg, N1N2ReqData, resourceUri)
 				case models.N1MessageClass_LPP:
-					gmm_message.SendDLNASTransport(ue.RanUe[anType],
-						nasMessage.PayloadContainerTypeLPP, n1Msg, 0, 0, nil, 0)
+					gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType],
+						nasMessage.PayloadContainerTypeLPP, n1Msg, N1N2ReqData, resourceUri)
 				case models.N1MessageClass_SMS:
-					gmm_message.SendDLNASTransport(ue.RanUe[anType],
-						nasMessage.PayloadContainerTypeSMS, n1Msg, 0, 0, nil, 0)
+					gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType],
+						nasMessage.PayloadContainerTypeSMS, n1Msg, N1N2ReqData, resourceUri)
 				case models.N1MessageClass_UPDP:
-					gmm_message.SendDLNASTransport(ue.RanUe[anType],
-						nasMessage.PayloadContainerTypeUEPolicy, n1Msg, 0, 0, nil, 0)
+					gmm_message.SendN1N2DLNASTransport(ue.RanUe[anType],
+						nasMessage.PayloadContainerTypeUEPolicy, n1Msg, N1N2ReqData, resourceUri)
 				}
 				ue.N1N2Message = nil
+				sendQueuedUpdates(ue, anType)
+				reallocateGutiOnServiceRequest(ue, anType)
 				return nil
 			}
 
@@ -1913,22 +2038,13 @@ func HandleServiceRequest(ue *context.AmfUe, anType models.AccessType,
 				ngap_message.AppendPDUSessionResourceSetupListCxtReq(&cxtList, smInfo.PduSessionId, *smInfo.SNssai,
 					nasPdu, n2Info)
 			}
-			err := gmm_message.SendServiceAccept(ue, anType, cxtList, pduStatusResult,
-				reactivationResult, errPduSessionId, errCause)
-			if err != nil {
-				return err
-			}
 		}
 
-		// downlink signaling
-		if ue.ConfigurationUpdateCommandFlags != nil {
-			err := gmm_message.SendServiceAccept(ue, anType, cxtList,
-				pduStatusResult, reactivationResult, errPduSessionId, errCause)
-			if err != nil {
-				return err
-			}
-			gmm_message.SendConfigurationUpdateCommand(ue, anType, ue.ConfigurationUpdateCommandFlags)
-			ue.ConfigurationUpdateCommandFlags = nil
+		// the UE paged for downlink data or for the downlink signalling queued in the AMF, accepted once
+		err := gmm_message.SendServiceAccept(ue, anType, cxtList, pduStatusResult,
+			reactivationResult, errPduSessionId, errCause)
+		if err != nil {
+			return err
 		}
 	case nasMessage.ServiceTypeData:
 		if anType == models.AccessType__3_GPP_ACCESS {
@@ -1966,6 +2082,8 @@ func HandleServiceRequest(ue *context.AmfUe, anType models.AccessType,
 		ue.GmmLog.Info(errPduSessionId, errCause)
 	}
 	ue.N1N2Message = nil
+	sendQueuedUpdates(ue, anType)
+	reallocateGutiOnServiceRequest(ue, anType)
 	return nil
 }
 
@@ -2261,16 +2379,18 @@ func HandleRegistrationComplete(ue *context.AmfUe, accessType models.AccessType,
 		})
 	}
 
-	// Send NITZ information to UE
-	configurationUpdateCommandFlags := &context.ConfigurationUpdateCommandFlags{
+	// Send NITZ information to UE, with the changes queued during the registration
+	ue.QueueConfigurationUpdate(accessType, &context.ConfigurationUpdateCommandFlags{
 		NeedNITZ: true,
-	}
-	gmm_message.SendConfigurationUpdateCommand(ue, accessType, configurationUpdateCommandFlags)
+	})
 
-	// if registrationComplete.SORTransparentContainer != nil {
-	// 	TODO: if at regsitration procedure 14b, udm provide amf Steering of Roaming info & request an ack,
-	// 	AMF provides the UE's ack with Nudm_SDM_Info (SOR not supportted in this stage)
-	// }
+	// TS 23.122 C.2: the UE acknowledges the steering of roaming information of the Registration Accept
+	if registrationComplete.SORTransparentContainer != nil {
+		if err := handleSorAck(ue, registrationComplete.SORTransparentContainer.GetSORContent()); err != nil {
+			ue.GmmLog.Errorf("Steering of roaming acknowledgement: %+v", err)
+		}
+	}
+	sendQueuedUpdates(ue, accessType)
 
 	// TODO: if
 	//	1. AMF has evaluated the support of IMS Voice over PS Sessions (TS 23.501 5.16.3.2)
@@ -2299,6 +2419,9 @@ func HandleSecurityModeComplete(ue *context.AmfUe, anType models.AccessType, pro
 	}
 
 	ue.StopT3560()
+	// the UE took the new NAS security context into use, along with a KAMF derived horizontally, the NAS COUNTs
+	// being set to zero on both sides
+	ue.KamfChanged = false
 
 	if ue.SecurityContextIsValid() {
 		// update Kgnb/Kn3iwf
@@ -2315,7 +2438,6 @@ func HandleSecurityModeComplete(ue *context.AmfUe, anType models.AccessType, pro
 		}
 	}
 
-	// TODO: AMF shall set the NAS COUNTs to zero if horizontal derivation of KAMF is performed
 	if securityModeComplete.NASMessageContainer != nil {
 		contents := securityModeComplete.NASMessageContainer.GetNASMessageContainerContents()
 		m := nas.NewMessage()
@@ -2371,41 +2493,7 @@ func HandleDeregistrationRequest(ue *context.AmfUe, anType models.AccessType,
 	ue.GmmLog.Info("Handle Deregistration Request(UE Originating)")
 
 	targetDeregistrationAccessType := deregistrationRequest.GetAccessType()
-	ue.SmContextList.Range(func(key, value interface{}) bool {
-		smContext := value.(*context.SmContext)
-
-		if smContext.AccessType() == anType ||
-			targetDeregistrationAccessType == nasMessage.AccessTypeBoth {
-			problemDetail, err := consumer.GetConsumer().SendReleaseSmContextRequest(ue, smContext, nil, "", nil)
-			if problemDetail != nil {
-				ue.GmmLog.Errorf("Release SmContext Failed Problem[%+v]", problemDetail)
-			} else if err != nil {
-				ue.GmmLog.Errorf("Release SmContext Error[%v]", err.Error())
-			}
-		}
-		return true
-	})
-
-	if ue.AmPolicyAssociation != nil {
-		terminateAmPolicyAssocaition := true
-		switch anType {
-		case models.AccessType__3_GPP_ACCESS:
-			terminateAmPolicyAssocaition = ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Deregistered)
-		case models.AccessType_NON_3_GPP_ACCESS:
-			terminateAmPolicyAssocaition = ue.State[models.AccessType__3_GPP_ACCESS].Is(context.Deregistered)
-		}
-
-		if terminateAmPolicyAssocaition {
-			problemDetails, err := consumer.GetConsumer().AMPolicyControlDelete(ue)
-			if problemDetails != nil {
-				ue.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problemDetails)
-			} else if err != nil {
-				ue.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
-			}
-		}
-	}
-
-	gmm_common.PurgeAmfUeSubscriberData(ue)
+	releaseDeregisteredUeResources(ue, anType, targetDeregistrationAccessType)
 
 	// if Deregistration type is not switch-off, send Deregistration Accept
 	if deregistrationRequest.GetSwitchOff() == 0 {
@@ -2458,6 +2546,84 @@ func HandleDeregistrationRequest(ue *context.AmfUe, anType models.AccessType,
 	return nil
 }
 
+// releaseDeregisteredUeResources releases the PDU sessions of the deregistered access, and the AM policy
+// association once the UE is deregistered over both accesses
+func releaseDeregisteredUeResources(ue *context.AmfUe, anType models.AccessType, targetDeregistrationAccessType uint8) {
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		smContext := value.(*context.SmContext)
+
+		if smContext.AccessType() == anType ||
+			targetDeregistrationAccessType == nasMessage.AccessTypeBoth {
+			problemDetail, err := consumer.GetConsumer().SendReleaseSmContextRequest(ue, smContext, nil, "", nil)
+			if problemDetail != nil {
+				ue.GmmLog.Errorf("Release SmContext Failed Problem[%+v]", problemDetail)
+			} else if err != nil {
+				ue.GmmLog.Errorf("Release SmContext Error[%v]", err.Error())
+			}
+		}
+		return true
+	})
+
+	if ue.AmPolicyAssociation != nil {
+		terminateAmPolicyAssocaition := true
+		switch anType {
+		case models.AccessType__3_GPP_ACCESS:
+			terminateAmPolicyAssocaition = ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Deregistered)
+		case models.AccessType_NON_3_GPP_ACCESS:
+			terminateAmPolicyAssocaition = ue.State[models.AccessType__3_GPP_ACCESS].Is(context.Deregistered)
+		}
+
+		if terminateAmPolicyAssocaition {
+			problemDetails, err := consumer.GetConsumer().AMPolicyControlDelete(ue)
+			if problemDetails != nil {
+				ue.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problemDetails)
+			} else if err != nil {
+				ue.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
+			}
+		}
+	}
+
+	gmm_common.PurgeAmfUeSubscriberData(ue)
+}
+
+// InitiateDeregistration starts the network-initiated deregistration of the UE registered over the access
+// (TS 23.502 4.2.2.3.3, TS 24.501 5.5.2.3). The UE in CM-IDLE state is deregistered implicitly, without paging it.
+func InitiateDeregistration(ue *context.AmfUe, anType models.AccessType, reRegistrationRequired bool,
+	cause5GMM uint8,
+) error {
+	if !ue.State[anType].Is(context.Registered) {
+		return fmt.Errorf("UE is not registered over %s", anType)
+	}
+	ranUe := ue.RanUe[anType]
+
+	accessType := nasMessage.AccessType3GPP
+	if anType == models.AccessType_NON_3_GPP_ACCESS {
+		accessType = nasMessage.AccessTypeNon3GPP
+	}
+	ue.StartProcedureSpan(anType, context.TracedProcedureDeregistration)
+	if err := GmmFSM.SendEvent(ue.State[anType], InitDeregistrationEvent, fsm.ArgsType{
+		ArgAmfUe:      ue,
+		ArgAccessType: anType,
+	}, logger.GmmLog); err != nil {
+		ue.EndProcedureSpan(anType, err)
+		return err
+	}
+	if ranUe == nil {
+		ue.GmmLog.Infof("Implicitly deregister the UE in CM-IDLE state over %s", anType)
+		releaseDeregisteredUeResources(ue, anType, accessType)
+		err := GmmFSM.SendEvent(ue.State[anType], DeregistrationAcceptEvent, fsm.ArgsType{
+			ArgAmfUe:      ue,
+			ArgAccessType: anType,
+		}, logger.GmmLog)
+		ue.EndProcedureSpan(anType, err)
+		return err
+	}
+	ue.DeregistrationTargetAccessType = accessType
+	gmm_message.SendDeregistrationRequest(ranUe, accessType, reRegistrationRequired, cause5GMM)
+	releaseDeregisteredUeResources(ue, anType, accessType)
+	return nil
+}
+
 // TS 23.502 4.2.2.3
 func HandleDeregistrationAccept(ue *context.AmfUe, anType models.AccessType,
 	deregistrationAccept *nasMessage.DeregistrationAcceptUETerminatedDeregistration,
@@ -2489,7 +2655,10 @@ func HandleDeregistrationAccept(ue *context.AmfUe, anType models.AccessType,
 	}
 
 	ue.DeregistrationTargetAccessType = 0
-	return nil
+	return GmmFSM.SendEvent(ue.State[anType], DeregistrationAcceptEvent, fsm.ArgsType{
+		ArgAmfUe:      ue,
+		ArgAccessType: anType,
+	}, logger.GmmLog)
 }
 
 func HandleStatus5GMM(ue *context.AmfUe, anType models.AccessType, status5GMM *nasMessage.Status5GMM) error {
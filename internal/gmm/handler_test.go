@@ -0,0 +1,127 @@
+package gmm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/nas/nasConvert"
+	"github.com/free5gc/nas/nasMessage"
+	"github.com/free5gc/nas/nasType"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestHandleNotificationResponse(t *testing.T) {
+	amfSelf := context.GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		amfSelf.ServedGuamiList = nil
+	}()
+
+	// the SMF counts the failure notifications of its N1N2 message transfers
+	var failureNotifications atomic.Int32
+	smf := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failureNotifications.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	smf.Config.Protocols = new(http.Protocols)
+	smf.Config.Protocols.SetHTTP1(true)
+	smf.Config.Protocols.SetUnencryptedHTTP2(true)
+	smf.Start()
+	defer smf.Close()
+
+	var psiArray [psiArraySize]bool
+	psiArray[5] = true
+	notificationResponse := &nasMessage.NotificationResponse{
+		PDUSessionStatus: &nasType.PDUSessionStatus{Len: 2, Buffer: nasConvert.PSIToBuf(psiArray)},
+	}
+
+	testCases := []struct {
+		name                string
+		connectedAccessType models.AccessType
+		expectNotification  bool
+	}{
+		{
+			name:                "reactivated over 3GPP access",
+			connectedAccessType: models.AccessType__3_GPP_ACCESS,
+		},
+		{
+			name:                "not reactivated over 3GPP access",
+			connectedAccessType: models.AccessType_NON_3_GPP_ACCESS,
+			expectNotification:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			failureNotifications.Store(0)
+			ue := amfSelf.NewAmfUe("imsi-208930000000004")
+			defer ue.Remove()
+
+			// the downlink data of the PDU session of 3GPP access is pending
+			smContext := context.NewSmContext(5)
+			smContext.SetAccessType(models.AccessType__3_GPP_ACCESS)
+			ue.StoreSmContext(5, smContext)
+			ue.N1N2Message = &context.N1N2Message{
+				Request: models.N1N2MessageTransferRequest{
+					JsonData: &models.N1N2MessageTransferReqData{
+						PduSessionId:           5,
+						N1n2FailureTxfNotifURI: smf.URL + "/n1n2-failure",
+					},
+				},
+				Status:      models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE,
+				ResourceUri: "n1-n2-messages/1",
+			}
+			ue.RanUe[tc.connectedAccessType] = &context.RanUe{}
+			defer delete(ue.RanUe, tc.connectedAccessType)
+
+			require.NoError(t, HandleNotificationResponse(ue, notificationResponse))
+			if tc.expectNotification {
+				require.Equal(t, int32(1), failureNotifications.Load())
+				require.Nil(t, ue.N1N2Message)
+			} else {
+				require.Zero(t, failureNotifications.Load())
+				require.NotNil(t, ue.N1N2Message)
+			}
+		})
+	}
+}
+
+func TestReleaseReroutedUe(t *testing.T) {
+	amfSelf := context.GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		amfSelf.ServedGuamiList = nil
+	}()
+	ran := &context.AmfRan{AnType: models.AccessType__3_GPP_ACCESS, Log: logger.NgapLog}
+
+	testCases := []struct {
+		name       string
+		registered bool
+	}{
+		{name: "deregistered UE removed"},
+		{name: "registered UE kept", registered: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ue := amfSelf.NewAmfUe("imsi-208930000000006")
+			defer ue.Remove()
+			if tc.registered {
+				ue.State[models.AccessType_NON_3_GPP_ACCESS].Set(context.Registered)
+			}
+			ranUe, err := ran.NewRanUe(1)
+			require.NoError(t, err)
+			ue.AttachRanUe(ranUe)
+
+			releaseReroutedUe(ue, models.AccessType__3_GPP_ACCESS)
+			require.Nil(t, ran.RanUeFindByRanUeNgapID(1))
+			require.Nil(t, ue.RanUe[models.AccessType__3_GPP_ACCESS])
+			_, ok := amfSelf.AmfUeFindBySupi(ue.Supi)
+			require.Equal(t, tc.registered, ok)
+		})
+	}
+}
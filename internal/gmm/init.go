@@ -39,6 +39,7 @@ var transitions = fsm.Transitions{
 	{Event: GmmMessageEvent, From: context.SecurityMode, To: context.SecurityMode},
 	{Event: GmmMessageEvent, From: context.ContextSetup, To: context.ContextSetup},
 	{Event: GmmMessageEvent, From: context.Registered, To: context.Registered},
+	{Event: GmmMessageEvent, From: context.DeregistrationInitiated, To: context.DeregistrationInitiated},
 	{Event: StartAuthEvent, From: context.Deregistered, To: context.Authentication},
 	{Event: StartAuthEvent, From: context.Registered, To: context.Authentication},
 	{Event: AuthRestartEvent, From: context.Authentication, To: context.Authentication},
@@ -62,6 +62,8 @@ func BuildDLNASTransport(ue *context.AmfUe, accessType models.AccessType, payloa
 	return nas_security.Encode(ue, m, accessType)
 }
 
+// accessType is the access type indicated to the UE; the Notification itself is always sent over the other access
+// (TS 24.501 5.6.3.1), so it is protected with the NAS bearer of that access.
 func BuildNotification(ue *context.AmfUe, accessType models.AccessType) ([]byte, error) {
 	m := nas.NewMessage()
 	m.GmmMessage = nas.NewGmmMessage()
@@ -76,15 +78,17 @@ func BuildNotification(ue *context.AmfUe, accessType models.AccessType) ([]byte,
 	notification.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
 	notification.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(nasMessage.Epd5GSMobilityManagementMessage)
 	notification.SetMessageType(nas.MsgTypeNotification)
+	sentOverAccessType := models.AccessType_NON_3_GPP_ACCESS
 	if accessType == models.AccessType__3_GPP_ACCESS {
 		notification.SetAccessType(nasMessage.AccessType3GPP)
 	} else {
 		notification.SetAccessType(nasMessage.AccessTypeNon3GPP)
+		sentOverAccessType = models.AccessType__3_GPP_ACCESS
 	}
 
 	m.GmmMessage.Notification = notification
 
-	return nas_security.Encode(ue, m, accessType)
+	return nas_security.Encode(ue, m, sentOverAccessType)
 }
 
 func BuildIdentityRequest(ue *context.AmfUe, accessType models.AccessType, typeOfIdentity uint8) ([]byte, error) {
@@ -307,7 +311,9 @@ func BuildServiceReject(ue *context.AmfUe, accessType models.AccessType, pDUSess
 }
 
 // T3346 timer are not supported
+// withRejectedNssai: the rejected NSSAI and extended rejected NSSAI of the UE are included
 func BuildRegistrationReject(ue *context.AmfUe, accessType models.AccessType, cause5GMM uint8, eapMessage string,
+	withRejectedNssai bool,
 ) ([]byte, error) {
 	m := nas.NewMessage()
 	m.GmmMessage = nas.NewGmmMessage()
@@ -343,11 +349,21 @@ func BuildRegistrationReject(ue *context.AmfUe, accessType models.AccessType, ca
 
 	m.GmmMessage.RegistrationReject = registrationReject
 
+	// the IEs following the EAP message are not defined in the NAS library
+	var ies []byte
+	if withRejectedNssai && ue != nil {
+		if rejectedNssaiNas := rejectedNssaiToNas(ue, accessType); rejectedNssaiNas != nil {
+			ies = append(ies, registrationRejectRejectedNSSAIType, rejectedNssaiNas.GetLen())
+			ies = append(ies, rejectedNssaiNas.GetRejectedNSSAIContents()...)
+		}
+		ies = append(ies, extendedRejectedNssaiToNas(ue, accessType)...)
+	}
+
 	m.SecurityHeader = nas.SecurityHeader{
 		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
 		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCiphered,
 	}
-	return nas_security.Encode(ue, m, accessType)
+	return nas_security.EncodeWithIes(ue, m, accessType, ies)
 }
 
 // TS 24.501 8.2.25
@@ -394,8 +410,8 @@ func BuildSecurityModeCommand(ue *context.AmfUe, accessType models.AccessType, e
 		securityModeCommand.Additional5GSecurityInformation.SetRINMR(0)
 	}
 
-	if ue.RegistrationType5GS == nasMessage.RegistrationType5GSPeriodicRegistrationUpdating ||
-		ue.RegistrationType5GS == nasMessage.RegistrationType5GSMobilityRegistrationUpdating {
+	// the horizontal derivation parameter is the K_AMF_change_flag of TS 33.501 6.9.3
+	if ue.KamfChanged {
 		securityModeCommand.Additional5GSecurityInformation.SetHDP(1)
 	} else {
 		securityModeCommand.Additional5GSecurityInformation.SetHDP(0)
@@ -570,13 +586,9 @@ func BuildRegistrationAccept(
 		registrationAccept.AllowedNSSAI.SetSNSSAIValue(buf)
 	}
 
-	if ue.NetworkSliceInfo != nil {
-		if len(ue.NetworkSliceInfo.RejectedNssaiInPlmn) != 0 || len(ue.NetworkSliceInfo.RejectedNssaiInTa) != 0 {
-			rejectedNssaiNas := nasConvert.RejectedNssaiToNas(
-				ue.NetworkSliceInfo.RejectedNssaiInPlmn, ue.NetworkSliceInfo.RejectedNssaiInTa)
-			registrationAccept.RejectedNSSAI = &rejectedNssaiNas
-			registrationAccept.RejectedNSSAI.SetIei(nasMessage.RegistrationAcceptRejectedNSSAIType)
-		}
+	if rejectedNssaiNas := rejectedNssaiToNas(ue, anType); rejectedNssaiNas != nil {
+		registrationAccept.RejectedNSSAI = rejectedNssaiNas
+		registrationAccept.RejectedNSSAI.SetIei(nasMessage.RegistrationAcceptRejectedNSSAIType)
 	}
 
 	if includeConfiguredNssaiCheck(ue) {
@@ -684,9 +696,21 @@ func BuildRegistrationAccept(
 		registrationAccept.NegotiatedDRXParameters.SetDRXValue(ue.UESpecificDRX)
 	}
 
+	// TS 23.122 C.2: the steering of roaming information of the UDM fetched by this registration
+	if sorInfo := ue.RegistrationSor(); sorInfo != nil {
+		sorContent, err := BuildSorTransparentContainer(sorInfo)
+		if err != nil {
+			return nil, err
+		}
+		registrationAccept.SORTransparentContainer = nasType.
+			NewSORTransparentContainer(nasMessage.RegistrationAcceptSORTransparentContainerType)
+		registrationAccept.SORTransparentContainer.SetLen(uint16(len(sorContent)))
+		registrationAccept.SORTransparentContainer.SetSORContent(sorContent)
+	}
+
 	m.GmmMessage.RegistrationAccept = registrationAccept
 
-	return nas_security.Encode(ue, m, anType)
+	return nas_security.EncodeWithIes(ue, m, anType, extendedRejectedNssaiToNas(ue, anType))
 }
 
 func includeConfiguredNssaiCheck(ue *context.AmfUe) bool {
@@ -727,6 +751,90 @@ func BuildStatus5GMM(ue *context.AmfUe, accessType models.AccessType, cause uint
 	return nas_security.Encode(ue, m, accessType)
 }
 
+// rejectedSnssaiCauseMaximumNumberOfUesReached is the cause of the S-NSSAI rejected because its maximum number of
+// UEs is reached (TS 24.501 9.11.3.46), not defined in the NAS library
+const rejectedSnssaiCauseMaximumNumberOfUesReached uint8 = 0x03
+
+// IEs of the network slice admission control not defined in the NAS library (TS 24.501 8.2.7.1, 8.2.9.1, 8.2.19.1,
+// 9.11.3.75)
+const (
+	registrationRejectRejectedNSSAIType uint8 = 0x69
+	// extendedRejectedNSSAIType is the IEI of the extended rejected NSSAI in the Registration Accept, Registration
+	// Reject and Configuration Update Command
+	extendedRejectedNSSAIType uint8 = 0x68
+	// partialExtendedRejectedNssaiWithBackOff is the type of a partial extended rejected NSSAI list whose S-NSSAIs
+	// share a back-off timer
+	partialExtendedRejectedNssaiWithBackOff  uint8 = 0x01
+	maxSnssaisInPartialExtendedRejectedNssai       = 8
+	// extendedRejectedSnssaiCauseMaximumNumberOfUesReached is the cause of the S-NSSAI rejected because its maximum
+	// number of UEs is reached in the extended rejected NSSAI
+	extendedRejectedSnssaiCauseMaximumNumberOfUesReached uint8 = 0x02
+)
+
+// rejectedNssaiToNas returns the rejected NSSAI of the UE over the access: the S-NSSAIs the NSSF rejected and
+// those rejected by the network slice admission control to the UE not supporting the extended rejected NSSAI,
+// nil when none is rejected
+func rejectedNssaiToNas(ue *context.AmfUe, anType models.AccessType) *nasType.RejectedNSSAI {
+	var rejectedNssaiNas nasType.RejectedNSSAI
+	if ue.NetworkSliceInfo != nil {
+		rejectedNssaiNas = nasConvert.RejectedNssaiToNas(
+			ue.NetworkSliceInfo.RejectedNssaiInPlmn, ue.NetworkSliceInfo.RejectedNssaiInTa)
+	}
+	buf := rejectedNssaiNas.GetRejectedNSSAIContents()
+	if !supportsExtendedRejectedNssai(ue) {
+		for _, snssai := range ue.SlicesRejectedForMaxUes(anType) {
+			buf = append(buf, nasConvert.RejectedSnssaiToNas(snssai, rejectedSnssaiCauseMaximumNumberOfUesReached)...)
+		}
+	}
+	for _, snssai := range ue.SlicesUnavailableInTa(anType) {
+		buf = append(buf, nasConvert.RejectedSnssaiToNas(snssai,
+			nasMessage.RejectedSnssaiCauseNotAvailableInCurrentRegistrationArea)...)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	rejectedNssaiNas.SetLen(uint8(len(buf)))
+	rejectedNssaiNas.SetRejectedNSSAIContents(buf)
+	return &rejectedNssaiNas
+}
+
+// extendedRejectedNssaiToNas returns the encoded extended rejected NSSAI IE of the UE supporting it over the
+// access, giving the S-NSSAIs rejected for their maximum number of UEs with the back-off timer the UE is not to
+// request them again for, nil when none is rejected
+func extendedRejectedNssaiToNas(ue *context.AmfUe, anType models.AccessType) []byte {
+	if !supportsExtendedRejectedNssai(ue) {
+		return nil
+	}
+	snssais := ue.SlicesRejectedForMaxUes(anType)
+	var buf []byte
+	for len(snssais) > 0 {
+		partial := snssais[:min(len(snssais), maxSnssaisInPartialExtendedRejectedNssai)]
+		snssais = snssais[len(partial):]
+
+		// the S-NSSAIs of the partial list share the longest of their back-off timers
+		var backOff time.Duration
+		for _, snssai := range partial {
+			backOff = max(backOff, ue.SliceBackOff(snssai))
+		}
+		buf = append(buf, partialExtendedRejectedNssaiWithBackOff<<4|uint8(len(partial)-1),
+			nasConvert.GPRSTimer3ToNas(int(backOff.Round(time.Second).Seconds())))
+		for _, snssai := range partial {
+			buf = append(buf, nasConvert.RejectedSnssaiToNas(snssai,
+				extendedRejectedSnssaiCauseMaximumNumberOfUesReached)...)
+		}
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	return append([]byte{extendedRejectedNSSAIType, uint8(len(buf))}, buf...)
+}
+
+// supportsExtendedRejectedNssai reports whether the UE indicated the support of the extended rejected NSSAI in its
+// 5GMM capability (ER-NSSAI bit, TS 24.501 9.11.3.1)
+func supportsExtendedRejectedNssai(ue *context.AmfUe) bool {
+	return ue.Capability5GMM.GetLen() >= 3 && ue.Capability5GMM.Octet[2]&0x08 != 0
+}
+
 // Fllowed by TS 24.501 - 5.4.4 Generic UE configuration update procedure - 5.4.4.1 General
 func BuildConfigurationUpdateCommand(ue *context.AmfUe, anType models.AccessType,
 	flags *context.ConfigurationUpdateCommandFlags,
@@ -793,14 +901,14 @@ func BuildConfigurationUpdateCommand(ue *context.AmfUe, anType models.AccessType
 		}
 	}
 
+	var extendedRejectedNssai []byte
 	if flags.NeedRejectNSSAI {
-		if ue.NetworkSliceInfo != nil &&
-			(len(ue.NetworkSliceInfo.RejectedNssaiInPlmn) != 0 || len(ue.NetworkSliceInfo.RejectedNssaiInTa) != 0) {
-			rejectedNssaiNas := nasConvert.RejectedNssaiToNas(
-				ue.NetworkSliceInfo.RejectedNssaiInPlmn, ue.NetworkSliceInfo.RejectedNssaiInTa)
-			configurationUpdateCommand.RejectedNSSAI = &rejectedNssaiNas
+		if rejectedNssaiNas := rejectedNssaiToNas(ue, anType); rejectedNssaiNas != nil {
+			configurationUpdateCommand.RejectedNSSAI = rejectedNssaiNas
 			configurationUpdateCommand.RejectedNSSAI.SetIei(nasMessage.ConfigurationUpdateCommandRejectedNSSAIType)
-		} else {
+		}
+		extendedRejectedNssai = extendedRejectedNssaiToNas(ue, anType)
+		if configurationUpdateCommand.RejectedNSSAI == nil && extendedRejectedNssai == nil {
 			logger.GmmLog.Warnf("Require Rejected NSSAI, but got nothing.")
 		}
 	}
@@ -845,6 +953,13 @@ func BuildConfigurationUpdateCommand(ue *context.AmfUe, anType models.AccessType
 		}
 	}
 
+	if flags.NeedMicoIndication && anType == models.AccessType__3_GPP_ACCESS {
+		// MICO mode is not supported, the UE is told to stop using it after registering again
+		configurationUpdateCommand.MICOIndication = nasType.
+			NewMICOIndication(nasMessage.ConfigurationUpdateCommandMICOIndicationType)
+		configurationUpdateCommand.MICOIndication.SetRAAI(0)
+	}
+
 	amfSelf := context.GetSelf()
 
 	if flags.NeedNITZ {
@@ -896,7 +1011,7 @@ func BuildConfigurationUpdateCommand(ue *context.AmfUe, anType models.AccessType
 		configurationUpdateCommand.ServiceAreaList != nil ||
 		configurationUpdateCommand.MICOIndication != nil ||
 		configurationUpdateCommand.ConfiguredNSSAI != nil ||
-		configurationUpdateCommand.RejectedNSSAI != nil ||
+		configurationUpdateCommand.RejectedNSSAI != nil || extendedRejectedNssai != nil ||
 		configurationUpdateCommand.NetworkSlicingIndication != nil ||
 		configurationUpdateCommand.OperatordefinedAccessCategoryDefinitions != nil ||
 		configurationUpdateCommand.SMSIndication != nil {
@@ -905,9 +1020,11 @@ func BuildConfigurationUpdateCommand(ue *context.AmfUe, anType models.AccessType
 		configurationUpdateCommand.ConfigurationUpdateIndication.SetACK(uint8(1))
 		needTimer = true
 	}
-	if configurationUpdateCommand.MICOIndication != nil {
+	if configurationUpdateCommand.MICOIndication != nil || flags.NeedRegistration {
 		// Allowed NSSAI and Configured NSSAI are optional to request to perform the registration procedure
 		configurationUpdateCommand.ConfigurationUpdateIndication.SetRED(uint8(1))
+		configurationUpdateCommand.ConfigurationUpdateIndication.SetACK(uint8(1))
+		needTimer = true
 	}
 
 	// Check if the Configuration Update Command is vaild
@@ -928,9 +1045,53 @@ func BuildConfigurationUpdateCommand(ue *context.AmfUe, anType models.AccessType
 		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCiphered,
 	}
 
-	b, err := nas_security.Encode(ue, m, anType)
+	b, err := nas_security.EncodeWithIes(ue, m, anType, extendedRejectedNssai)
 	if err != nil {
 		return nil, fmt.Errorf("BuildConfigurationUpdateCommand() err: %v", err), false
 	}
 	return b, err, needTimer
 }
+
+// SoR header bits of the SOR transparent container (TS 24.501 9.11.3.51)
+const (
+	sorDataTypeAcknowledgement uint8 = 0x01
+	sorAckRequested            uint8 = 0x08
+)
+
+// sorMacLength is the length of SOR-MAC-IAUSF and SOR-MAC-IUE, sorCounterLength the one of CounterSoR
+const (
+	sorMacLength     = 16
+	sorCounterLength = 2
+)
+
+// BuildSorTransparentContainer returns the content of the SOR transparent container delivering the steering of
+// roaming information to the UE (TS 24.501 9.11.3.51): the container the UDM provided, or else the one built of
+// SOR-MAC-IAUSF and CounterSoR without list of preferred PLMN/access technology combinations
+func BuildSorTransparentContainer(sorInfo *models.UdmSdmSorInfo) ([]byte, error) {
+	if sorInfo.SorTransparentContainer != "" {
+		return base64.StdEncoding.DecodeString(sorInfo.SorTransparentContainer)
+	}
+	sorMac, err := hex.DecodeString(sorInfo.SorMacIausf)
+	if err != nil || len(sorMac) != sorMacLength {
+		return nil, fmt.Errorf("invalid SOR-MAC-IAUSF: %s", sorInfo.SorMacIausf)
+	}
+	counter, err := hex.DecodeString(sorInfo.Countersor)
+	if err != nil || len(counter) != sorCounterLength {
+		return nil, fmt.Errorf("invalid CounterSoR: %s", sorInfo.Countersor)
+	}
+	header := uint8(0)
+	if sorInfo.AckInd {
+		header |= sorAckRequested
+	}
+	content := append([]byte{header}, sorMac...)
+	return append(content, counter...), nil
+}
+
+// SorAckToModels returns the SOR-MAC-IUE of the SOR transparent container acknowledging the steering of roaming
+// information (TS 24.501 9.11.3.51)
+func SorAckToModels(content []byte) (string, error) {
+	if len(content) != 1+sorMacLength || content[0]&sorDataTypeAcknowledgement == 0 {
+		return "", fmt.Errorf("SOR transparent container is not an acknowledgement")
+	}
+	return hex.EncodeToString(content[1:]), nil
+}
@@ -17,6 +17,30 @@ import (
 // backOffTimerUint = 7 means backoffTimer is null
 func SendDLNASTransport(ue *context.RanUe, payloadContainerType uint8, nasPdu []byte,
 	pduSessionId int32, cause uint8, backOffTimerUint *uint8, backOffTimer uint8,
+) {
+	sendDLNASTransport(ue, payloadContainerType, nasPdu, pduSessionId, cause, backOffTimerUint, backOffTimer,
+		context.DlNasOrigin{Procedure: context.DlNasProcedureDlNasTransport, PduSessionId: pduSessionId})
+}
+
+// SendN1N2DLNASTransport forwards the N1 message of an N1N2 Message Transfer to the UE;
+// if it cannot be delivered, the failure is notified to the requesting NF
+func SendN1N2DLNASTransport(ue *context.RanUe, payloadContainerType uint8, n1Msg []byte,
+	requestData *models.N1N2MessageTransferReqData, resourceUri string,
+) {
+	var pduSessionId int32
+	if payloadContainerType == nasMessage.PayloadContainerTypeN1SMInfo {
+		pduSessionId = requestData.PduSessionId
+	}
+	sendDLNASTransport(ue, payloadContainerType, n1Msg, pduSessionId, 0, nil, 0, context.DlNasOrigin{
+		Procedure:        context.DlNasProcedureN1N2MessageTransfer,
+		PduSessionId:     pduSessionId,
+		FailureNotifyUri: requestData.N1n2FailureTxfNotifURI,
+		ResourceUri:      resourceUri,
+	})
+}
+
+func sendDLNASTransport(ue *context.RanUe, payloadContainerType uint8, nasPdu []byte,
+	pduSessionId int32, cause uint8, backOffTimerUint *uint8, backOffTimer uint8, origin context.DlNasOrigin,
 ) {
 	isNasMsgSent := false
 	additionalCause := ""
@@ -48,7 +72,7 @@ func SendDLNASTransport(ue *context.RanUe, payloadContainerType uint8, nasPdu []
 	}
 
 	isNasMsgSent = true
-	ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
+	ngap_message.SendDownlinkNasTransportWithOrigin(ue, nasMsg, nil, origin)
 }
 
 func SendNotification(ue *context.RanUe, nasMsg []byte) {
@@ -69,10 +93,13 @@ func SendNotification(ue *context.RanUe, nasMsg []byte) {
 	amfUe := ue.AmfUe
 	amfUe.GmmLog.Info("Send Notification")
 
+	isNasMsgSent = true
+	ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
+
 	if context.GetSelf().T3565Cfg.Enable {
 		cfg := context.GetSelf().T3565Cfg
 		amfUe.GmmLog.Infof("Start T3565 timer")
-		amfUe.T3565 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.T3565 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			amfUe.GmmLog.Warnf("T3565 expires, retransmit Notification (retry: %d)", expireTimes)
 			timerAdditionalCause := "Timer expired, retransmit Notification"
 			isNasMsgSent = true
@@ -120,7 +147,7 @@ func SendIdentityRequest(ue *context.RanUe, accessType models.AccessType, typeOf
 
 	if context.GetSelf().T3570Cfg.Enable {
 		cfg := context.GetSelf().T3570Cfg
-		amfUe.T3570 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.T3570 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			amfUe.GmmLog.Warnf("T3570 expires, retransmit Identity Request (retry: %d)", expireTimes)
 			timerAdditionalCause := "Timer expired, retransmit Identity Request"
 			defer nasMetrics.IncrMetricsSentNasMsgs(nasMetrics.IDENTITY_REQUEST, &isNasMsgSent, 0, &timerAdditionalCause)
@@ -176,7 +203,7 @@ func SendAuthenticationRequest(ue *context.RanUe) {
 	if context.GetSelf().T3560Cfg.Enable {
 		cfg := context.GetSelf().T3560Cfg
 		amfUe.GmmLog.Infof("Start T3560 timer")
-		amfUe.T3560 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.T3560 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			amfUe.GmmLog.Warnf("T3560 expires, retransmit Authentication Request (retry: %d)", expireTimes)
 			timerAdditionalCause := "Timer expired, retry authentication request"
 			defer nasMetrics.IncrMetricsSentNasMsgs(nasMetrics.AUTHENTICATION_REQUEST, &isNasMsgSent, 0, &timerAdditionalCause)
@@ -230,10 +257,14 @@ func SendServiceAccept(amfUe *context.AmfUe, anType models.AccessType,
 	return nil
 }
 
+// SendConfigurationUpdateCommand sends the Configuration Update Command with the flagged changes and reports whether
+// it awaits the acknowledgement of the UE: T3555, unless disabled, then retransmits the command, abort being called
+// once T3555 expired the maximum number of times.
 func SendConfigurationUpdateCommand(amfUe *context.AmfUe,
 	accessType models.AccessType,
 	flags *context.ConfigurationUpdateCommandFlags,
-) {
+	abort func(),
+) bool {
 	isNasMsgSent := false
 	additionalCause := ""
 	defer nasMetrics.IncrMetricsSentNasMsgs(nasMetrics.CONFIGURATION_UPDATE_COMMAND, &isNasMsgSent, 0, &additionalCause)
@@ -241,42 +272,54 @@ func SendConfigurationUpdateCommand(amfUe *context.AmfUe,
 	if amfUe == nil {
 		additionalCause = nasMetrics.AMF_UE_NIL_ERR
 		logger.GmmLog.Error("SendConfigurationUpdateCommand: AmfUe is nil")
-		return
+		return false
 	}
 	if amfUe.RanUe[accessType] == nil {
 		additionalCause = nasMetrics.RAN_UE_NIL_ERR
 		logger.GmmLog.Error("SendConfigurationUpdateCommand: RanUe is nil")
-		return
+		return false
 	}
 
 	nasMsg, err, startT3555 := BuildConfigurationUpdateCommand(amfUe, accessType, flags)
 	if err != nil {
 		additionalCause = nasMetrics.NAS_MSG_BUILD_ERR
 		amfUe.GmmLog.Errorf("BuildConfigurationUpdateCommand Error: %+v", err)
-		return
+		return false
 	}
 	amfUe.GmmLog.Info("Send Configuration Update Command")
 
 	mobilityRestrictionList := ngap_message.BuildIEMobilityRestrictionList(amfUe)
+	origin := context.DlNasOrigin{Procedure: context.DlNasProcedureConfigurationUpdate}
 	isNasMsgSent = true
-	ngap_message.SendDownlinkNasTransport(amfUe.RanUe[accessType], nasMsg, &mobilityRestrictionList)
-
-	if startT3555 && context.GetSelf().T3555Cfg.Enable {
-		cfg := context.GetSelf().T3555Cfg
-		amfUe.GmmLog.Infof("Start T3555 timer")
-		amfUe.T3555 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
-			amfUe.GmmLog.Warnf("T3555 expires, retransmit Configuration Update Command (retry: %d)",
-				expireTimes)
-			timerAdditionalCause := "Timer expired, retry configuration update command"
-			defer nasMetrics.IncrMetricsSentNasMsgs(
-				nasMetrics.CONFIGURATION_UPDATE_COMMAND_TIMER, &isNasMsgSent, 0, &timerAdditionalCause)
-			ngap_message.SendDownlinkNasTransport(amfUe.RanUe[accessType], nasMsg, &mobilityRestrictionList)
-		}, func() {
-			amfUe.GmmLog.Warnf("T3555 Expires %d times, abort configuration update procedure",
-				cfg.MaxRetryTimes)
-		},
-		)
+	ngap_message.SendDownlinkNasTransportWithOrigin(amfUe.RanUe[accessType], nasMsg, &mobilityRestrictionList, origin)
+
+	if !startT3555 {
+		return false
 	}
+	if !context.GetSelf().T3555Cfg.Enable {
+		if flags.NeedGUTI {
+			// no retransmission supervises the acknowledgement, the UE is taken to use the reallocated 5G-GUTI
+			amfUe.ReleaseOldGuti()
+		}
+		return true
+	}
+	cfg := context.GetSelf().T3555Cfg
+	amfUe.GmmLog.Infof("Start T3555 timer")
+	amfUe.T3555 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.GmmLog.Warnf("T3555 expires, retransmit Configuration Update Command (retry: %d)",
+			expireTimes)
+		timerAdditionalCause := "Timer expired, retry configuration update command"
+		defer nasMetrics.IncrMetricsSentNasMsgs(
+			nasMetrics.CONFIGURATION_UPDATE_COMMAND_TIMER, &isNasMsgSent, 0, &timerAdditionalCause)
+		ngap_message.SendDownlinkNasTransportWithOrigin(amfUe.RanUe[accessType], nasMsg,
+			&mobilityRestrictionList, origin)
+	}, func() {
+		amfUe.GmmLog.Warnf("T3555 Expires %d times, abort configuration update procedure",
+			cfg.MaxRetryTimes)
+		abort()
+	},
+	)
+	return true
 }
 
 func SendAuthenticationReject(ue *context.RanUe, eapMsg string, cause5GMM uint8, otherCause string) {
@@ -391,6 +434,16 @@ func SendServiceReject(ue *context.RanUe, pDUSessionStatus *[16]bool, cause uint
 // T3502: This IE may be included to indicate a value for timer T3502 during the initial registration
 // eapMessage: if the REGISTRATION REJECT message is used to convey EAP-failure message
 func SendRegistrationReject(ue *context.RanUe, cause5GMM uint8, eapMessage string) {
+	sendRegistrationReject(ue, cause5GMM, eapMessage, false)
+}
+
+// SendRegistrationRejectWithRejectedNssai rejects the registration with the rejected NSSAI of the UE, and the
+// back-off timers of the S-NSSAIs rejected for their maximum number of UEs in the extended rejected NSSAI
+func SendRegistrationRejectWithRejectedNssai(ue *context.RanUe, cause5GMM uint8) {
+	sendRegistrationReject(ue, cause5GMM, "", true)
+}
+
+func sendRegistrationReject(ue *context.RanUe, cause5GMM uint8, eapMessage string, withRejectedNssai bool) {
 	isNasMsgSent := false
 	additionalCause := ""
 	defer nasMetrics.IncrMetricsSentNasMsgs(nasMetrics.REGISTRATION_REJECT, &isNasMsgSent, cause5GMM,
@@ -414,7 +467,7 @@ func SendRegistrationReject(ue *context.RanUe, cause5GMM uint8, eapMessage strin
 		ue.AmfUe.GmmLog.Info("Send Registration Reject")
 	}
 
-	nasMsg, err := BuildRegistrationReject(ue.AmfUe, ran.AnType, cause5GMM, eapMessage)
+	nasMsg, err := BuildRegistrationReject(ue.AmfUe, ran.AnType, cause5GMM, eapMessage, withRejectedNssai)
 	if err != nil {
 		additionalCause = nasMetrics.NAS_MSG_BUILD_ERR
 		if ue.AmfUe == nil {
@@ -462,7 +515,7 @@ func SendSecurityModeCommand(ue *context.RanUe, accessType models.AccessType, ea
 	if context.GetSelf().T3560Cfg.Enable {
 		cfg := context.GetSelf().T3560Cfg
 		amfUe.GmmLog.Infof("Start T3560 timer")
-		amfUe.T3560 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.T3560 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			amfUe.GmmLog.Warnf("T3560 expires, retransmit Security Mode Command (retry: %d)", expireTimes)
 			timerAdditionalCause := "Retry Security Mode Command"
 			defer nasMetrics.IncrMetricsSentNasMsgs(nasMetrics.SECURITY_MODE_COMMAND, &isNasMsgSent, 0, &timerAdditionalCause)
@@ -500,7 +553,7 @@ func SendDeregistrationRequest(ue *context.RanUe, accessType uint8, reRegistrati
 	if context.GetSelf().T3522Cfg.Enable {
 		cfg := context.GetSelf().T3522Cfg
 		amfUe.GmmLog.Infof("Start T3522 timer")
-		amfUe.T3522 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.T3522 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			amfUe.GmmLog.Warnf("T3522 expires, retransmit Deregistration Request (retry: %d)", expireTimes)
 			ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
 		}, func() {
@@ -588,6 +641,9 @@ func SendRegistrationAccept(
 		amfUe.GmmLog.Error(err.Error())
 		return
 	}
+	if sorInfo := amfUe.TakeRegistrationSor(); sorInfo != nil {
+		amfUe.SetSorSent(sorInfo)
+	}
 
 	isNasMsgSent = true
 	if anType == models.AccessType_NON_3_GPP_ACCESS {
@@ -603,7 +659,7 @@ func SendRegistrationAccept(
 	if context.GetSelf().T3550Cfg.Enable {
 		cfg := context.GetSelf().T3550Cfg
 		amfUe.GmmLog.Infof("Start T3550 timer")
-		amfUe.T3550 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		amfUe.T3550 = amfUe.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			if amfUe.RanUe[anType] == nil {
 				amfUe.GmmLog.Warnf("[NAS] UE Context released, abort retransmission of Registration Accept")
 				amfUe.T3550 = nil
@@ -0,0 +1,85 @@
+package gmm
+
+import (
+	"fmt"
+
+	"github.com/free5gc/amf/internal/context"
+	gmm_message "github.com/free5gc/amf/internal/gmm/message"
+	ngap_message "github.com/free5gc/amf/internal/ngap/message"
+	"github.com/free5gc/nas/nasMessage"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+)
+
+// startNasKeyRefresh starts, for the registered UE whose NAS COUNT is about to wrap around or was restored from
+// the UE context store, a security mode control procedure taking into use a KAMF derived horizontally
+// (TS 33.501 6.9.4.3). It reports whether the procedure was started, the UE then completing it in GMM state
+// Registered (see HandleNasKeyRefreshComplete).
+func startNasKeyRefresh(ue *context.AmfUe, anType models.AccessType) bool {
+	if !ue.SecurityContextIsValid() || ue.KamfChanged || !ue.NasKeysNeedRefresh() || ue.RanUe[anType] == nil {
+		return false
+	}
+	if ue.NasCountsRestored {
+		ue.GmmLog.Infoln("NAS COUNTs restored from the UE context store - refresh the NAS keys")
+	} else {
+		ue.GmmLog.Infoln("NAS COUNT about to wrap around - refresh the NAS keys")
+	}
+	ue.DerivateHorizontalKamf()
+	if !ue.KamfChanged {
+		return false
+	}
+	// keeping the selected algorithms
+	ue.DerivateAlgKey()
+	gmm_message.SendSecurityModeCommand(ue.RanUe[anType], anType, false, "")
+	return true
+}
+
+// HandleNasKeyRefreshComplete handles the Security Mode Complete of the NAS keys refresh of a registered UE.
+// The Service Request which triggered the refresh is resumed, the AS keys being derived from the new KAMF
+// when the UE context is set up. A UE context already set up in the NG-RAN is released instead, as its AS keys
+// cannot be refreshed: they are derived from the new KAMF at the next Service Request of the UE.
+func HandleNasKeyRefreshComplete(ue *context.AmfUe, anType models.AccessType,
+	securityModeComplete *nasMessage.SecurityModeComplete,
+) error {
+	ue.GmmLog.Info("Handle Security Mode Complete of the NAS keys refresh")
+
+	if ue.MacFailed {
+		return fmt.Errorf("NAS message integrity check failed")
+	}
+	if !ue.KamfChanged {
+		return fmt.Errorf("no NAS keys refresh ongoing")
+	}
+
+	ue.StopT3560()
+	ue.KamfChanged = false
+
+	if serviceRequest := ue.PendingServiceRequest; serviceRequest != nil {
+		ue.PendingServiceRequest = nil
+		return HandleServiceRequest(ue, anType, serviceRequest)
+	}
+	if ranUe := ue.RanUe[anType]; ranUe != nil && ranUe.InitialContextSetup {
+		ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextN2NormalRelease,
+			ngapType.CausePresentNas, ngapType.CauseNasPresentNormalRelease)
+	}
+	return nil
+}
+
+// HandleNasKeyRefreshReject handles the Security Mode Reject of the NAS keys refresh of a registered UE. The UE
+// keeps its NAS security context while the AMF moved to the new KAMF: the security context is discarded and the
+// N2 connection released, the UE being authenticated again at its next registration.
+func HandleNasKeyRefreshReject(ue *context.AmfUe, anType models.AccessType,
+	securityModeReject *nasMessage.SecurityModeReject,
+) error {
+	ue.GmmLog.Warnf("Security Mode Reject of the NAS keys refresh, cause: %d",
+		securityModeReject.Cause5GMM.GetCauseValue())
+
+	ue.StopT3560()
+	ue.KamfChanged = false
+	ue.PendingServiceRequest = nil
+	ue.SecurityContextAvailable = false
+	if ranUe := ue.RanUe[anType]; ranUe != nil {
+		ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextN2NormalRelease,
+			ngapType.CausePresentNas, ngapType.CauseNasPresentNormalRelease)
+	}
+	return nil
+}
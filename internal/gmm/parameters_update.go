@@ -0,0 +1,126 @@
+package gmm
+
+import (
+	"fmt"
+
+	"github.com/free5gc/amf/internal/context"
+	gmm_message "github.com/free5gc/amf/internal/gmm/message"
+	"github.com/free5gc/amf/internal/sbi/consumer"
+	"github.com/free5gc/nas/nasConvert"
+	"github.com/free5gc/nas/nasMessage"
+	"github.com/free5gc/openapi/models"
+)
+
+// upuAckLength is the length of the UE parameters update transparent container acknowledging the update:
+// the UE parameters update data type and UPU-MAC-IUE (TS 24.501 9.11.3.53A)
+const upuAckLength = 17
+
+// UpdateUeParameters delivers the UE parameters update information of the UDM to the UE in a Downlink NAS Transport
+// (TS 23.502 4.20.2), the UE in CM-IDLE state being paged first. The UDM learns that the UE is not reachable when
+// it is not registered.
+func UpdateUeParameters(ue *context.AmfUe, upuInfo *models.UdmSdmUpuInfo) {
+	ue.QueueUpu(upuInfo)
+	deliverParametersUpdate(ue)
+}
+
+// SteerRoaming delivers the steering of roaming information of the UDM to the registered UE in a Downlink NAS
+// Transport (TS 23.122 C.4), the same way as UpdateUeParameters
+func SteerRoaming(ue *context.AmfUe, sorInfo *models.UdmSdmSorInfo) {
+	ue.QueueSor(sorInfo)
+	deliverParametersUpdate(ue)
+}
+
+func deliverParametersUpdate(ue *context.AmfUe) {
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		if ue.State[anType].Is(context.Registered) && ue.CmConnect(anType) {
+			SendQueuedParametersUpdate(ue, anType)
+			return
+		}
+	}
+	if ue.State[models.AccessType__3_GPP_ACCESS].Is(context.Registered) {
+		pageForSignalling(ue)
+		return
+	}
+
+	ue.GmmLog.Infof("UE is not registered, report it unreachable to the UDM")
+	if upuInfo := ue.TakeQueuedUpu(); upuInfo != nil {
+		if err := consumer.GetConsumer().PutUpuAck(ue, &models.AcknowledgeInfo{
+			ProvisioningTime: upuInfo.ProvisioningTime,
+			UeNotReachable:   true,
+		}); err != nil {
+			ue.GmmLog.Errorf("Nudm_SDM_Info UPU Ack Error[%+v]", err)
+		}
+	}
+	if sorInfo := ue.TakeQueuedSor(); sorInfo != nil {
+		if err := consumer.GetConsumer().PutSorAck(ue, &models.AcknowledgeInfo{
+			ProvisioningTime: sorInfo.ProvisioningTime,
+			UeNotReachable:   true,
+		}); err != nil {
+			ue.GmmLog.Errorf("Nudm_SDM_Info SoR Ack Error[%+v]", err)
+		}
+	}
+}
+
+// SendQueuedParametersUpdate sends the UE parameters update and steering of roaming information waiting for the UE
+// to be CM-CONNECTED over the access
+func SendQueuedParametersUpdate(ue *context.AmfUe, anType models.AccessType) {
+	ranUe := ue.RanUe[anType]
+	if ranUe == nil {
+		return
+	}
+	if upuInfo := ue.TakeQueuedUpu(); upuInfo != nil {
+		ue.GmmLog.Infof("Deliver UE parameters update over %s", anType)
+		gmm_message.SendDLNASTransport(ranUe, nasMessage.PayloadContainerTypeUEParameterUpdate,
+			nasConvert.UpuInfoToNas(*upuInfo), 0, 0, nil, 0)
+		ue.SetUpuSent(upuInfo)
+	}
+	if sorInfo := ue.TakeQueuedSor(); sorInfo != nil {
+		sorContent, err := gmm_message.BuildSorTransparentContainer(sorInfo)
+		if err != nil {
+			ue.GmmLog.Errorf("Steering of roaming information not delivered: %+v", err)
+			return
+		}
+		ue.GmmLog.Infof("Deliver steering of roaming information over %s", anType)
+		gmm_message.SendDLNASTransport(ranUe, nasMessage.PayloadContainerTypeSOR, sorContent, 0, 0, nil, 0)
+		ue.SetSorSent(sorInfo)
+	}
+}
+
+// handleUpuAck checks the acknowledgement by the UE of the UE parameters update delivered to it, and provides the
+// UDM with UPU-MAC-IUE to verify against UPU-XMAC-IUE (TS 33.501 6.15.2.1)
+func handleUpuAck(ue *context.AmfUe, content []byte) error {
+	if len(content) != upuAckLength {
+		return fmt.Errorf("UE parameters update transparent container is not an acknowledgement")
+	}
+	upuMac, err := nasConvert.UpuAckToModels(content)
+	if err != nil {
+		return err
+	}
+	upuInfo := ue.TakeSentUpu()
+	if upuInfo == nil {
+		return fmt.Errorf("unexpected UE parameters update acknowledgement: none requested")
+	}
+	ue.GmmLog.Debugf("UpuMac[%s] in UPU ACK NAS Msg", upuMac)
+	return consumer.GetConsumer().PutUpuAck(ue, &models.AcknowledgeInfo{
+		UpuMacIue:        upuMac,
+		ProvisioningTime: upuInfo.ProvisioningTime,
+	})
+}
+
+// handleSorAck checks the acknowledgement by the UE of the steering of roaming information delivered to it, and
+// provides the UDM with SOR-MAC-IUE to verify against SOR-XMAC-IUE (TS 33.501 6.14.2.1)
+func handleSorAck(ue *context.AmfUe, content []byte) error {
+	sorMac, err := gmm_message.SorAckToModels(content)
+	if err != nil {
+		return err
+	}
+	sorInfo := ue.TakeSentSor()
+	if sorInfo == nil {
+		return fmt.Errorf("unexpected steering of roaming acknowledgement: none requested")
+	}
+	ue.GmmLog.Debugf("SorMac[%s] in SOR ACK", sorMac)
+	return consumer.GetConsumer().PutSorAck(ue, &models.AcknowledgeInfo{
+		SorMacIue:        sorMac,
+		ProvisioningTime: sorInfo.ProvisioningTime,
+	})
+}
@@ -0,0 +1,52 @@
+package gmm
+
+import (
+	"time"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/nas/nasMessage"
+)
+
+// authenticateOnInitialRegistration reports whether the UE sending an initial registration is authenticated
+// although it has a valid security context, unless the re-authentication policy skips it
+func authenticateOnInitialRegistration() bool {
+	policy := factory.AmfConfig.GetReauthentication()
+	return policy == nil || !policy.SkipOnInitialRegistration
+}
+
+// reauthenticationReason returns why the re-authentication policy requires the registering UE having a valid
+// security context to be authenticated again, "" when its security context is kept
+func reauthenticationReason(ue *context.AmfUe) string {
+	policy := factory.AmfConfig.GetReauthentication()
+	if policy == nil {
+		return ""
+	}
+	authenticatedAt, authenticatedPlmn := ue.LastAuthentication()
+	switch {
+	case policy.Registrations > 0 && ue.RegistrationsSinceAuthentication() >= policy.Registrations:
+		return context.AuthenticationReasonRegistrations
+	case policy.Interval > 0 && !authenticatedAt.IsZero() && time.Since(authenticatedAt) >= policy.Interval:
+		return context.AuthenticationReasonInterval
+	case policy.OnPlmnChange && authenticatedPlmn != nil && ue.Tai.PlmnId != nil &&
+		*authenticatedPlmn != *ue.Tai.PlmnId:
+		return context.AuthenticationReasonPlmnChange
+	}
+	return ""
+}
+
+// authenticationReason returns why the UE is authenticated, "" when its valid security context is kept
+func authenticationReason(ue *context.AmfUe) string {
+	if ue.SecurityContextIsValid() {
+		// the NAS COUNTs of a restored UE may have been used already, the UE gets new keys before they are reused
+		if ue.NasCountsRestored {
+			return context.AuthenticationReasonRestored
+		}
+		return reauthenticationReason(ue)
+	}
+	if ue.RegistrationType5GS == nasMessage.RegistrationType5GSInitialRegistration &&
+		authenticateOnInitialRegistration() {
+		return context.AuthenticationReasonInitialRegistration
+	}
+	return context.AuthenticationReasonNoSecurityContext
+}
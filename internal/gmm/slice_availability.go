@@ -0,0 +1,118 @@
+package gmm
+
+import (
+	"fmt"
+
+	"github.com/free5gc/amf/internal/context"
+	gmm_message "github.com/free5gc/amf/internal/gmm/message"
+	ngap_message "github.com/free5gc/amf/internal/ngap/message"
+	"github.com/free5gc/amf/internal/sbi/consumer"
+	"github.com/free5gc/nas/nasMessage"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+)
+
+// cause5GMMNoNetworkSlicesAvailable is the 5GMM cause #62 "No network slices available" (TS 24.501 9.11.3.2),
+// not defined in the NAS library
+const cause5GMMNoNetworkSlicesAvailable uint8 = 0x3e
+
+// applySliceAvailability adapts the allowed NSSAI of the UE registering over the access to the S-NSSAIs out of
+// service in its tracking area and admits the UE to its S-NSSAIs (TS 23.502 4.2.11.2). The UE is rejected when
+// none of its S-NSSAIs is in service, has an alternative or is below its maximum number of UEs.
+func applySliceAvailability(ue *context.AmfUe, anType models.AccessType) error {
+	ue.ApplySliceAvailability(anType)
+	if len(ue.AllowedNssai[anType]) > 0 {
+		return nil
+	}
+	if len(ue.SlicesRejectedForMaxUes(anType)) > 0 {
+		gmm_message.SendRegistrationRejectWithRejectedNssai(ue.RanUe[anType], cause5GMMNoNetworkSlicesAvailable)
+		return fmt.Errorf("no S-NSSAI admitted, all reached their maximum number of UEs")
+	}
+	if len(ue.SlicesUnavailableInTa(anType)) > 0 {
+		gmm_message.SendRegistrationRejectWithRejectedNssai(ue.RanUe[anType], cause5GMMNoNetworkSlicesAvailable)
+		return fmt.Errorf("no S-NSSAI available in TA[%+v]", ue.Tai)
+	}
+	return nil
+}
+
+// UpdateSliceAvailability adapts the allowed NSSAI of the registered UE to a change of the S-NSSAIs out of service.
+// The PDU sessions of the S-NSSAIs no longer allowed are released, to be established again over their alternative
+// S-NSSAI if any, and the new allowed NSSAI is sent to the UE in a Configuration Update Command. The UE left without
+// an allowed S-NSSAI is deregistered.
+func UpdateSliceAvailability(ue *context.AmfUe) {
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		if !ue.State[anType].Is(context.Registered) || !ue.ApplySliceAvailability(anType) {
+			continue
+		}
+		if len(ue.AllowedNssai[anType]) == 0 {
+			ue.GmmLog.Infof("No S-NSSAI available over %s in TA[%+v], deregister the UE", anType, ue.Tai)
+			if err := InitiateDeregistration(ue, anType, false, cause5GMMNoNetworkSlicesAvailable); err != nil {
+				ue.GmmLog.Errorf("Deregistration over %s failed: %+v", anType, err)
+			}
+			continue
+		}
+		ue.GmmLog.Infof("Allowed NSSAI over %s changed by slice availability: %+v", anType, ue.AllowedNssai[anType])
+		relocatePduSessions(ue, anType)
+		UpdateConfiguration(ue, anType, &context.ConfigurationUpdateCommandFlags{
+			NeedAllowedNSSAI: true,
+			NeedRejectNSSAI:  true,
+		})
+	}
+}
+
+// relocatePduSessions releases the PDU sessions of the UE over the access whose S-NSSAI is no longer allowed, with
+// the reactivation requested when the S-NSSAI is replaced by an alternative S-NSSAI
+func relocatePduSessions(ue *context.AmfUe, anType models.AccessType) {
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		smContext := value.(*context.SmContext)
+		if smContext.AccessType() != anType || ue.InAllowedNssai(smContext.Snssai(), anType) {
+			return true
+		}
+		cause := models.SmfPduSessionCause_REL_DUE_TO_SLICE_NOT_AVAILABLE
+		if ue.AlternativeSnssai(smContext.Snssai(), anType) != nil {
+			cause = models.SmfPduSessionCause_REL_DUE_TO_REACTIVATION
+		}
+		ue.GmmLog.Infof("Release PDU session[%d] of S-NSSAI[%+v] out of service: %s",
+			smContext.PduSessionID(), smContext.Snssai(), cause)
+		releasePduSession(ue, anType, smContext, cause)
+		return true
+	})
+}
+
+// releasePduSession releases the PDU session of the UE over the access through its SMF with the cause, forwarding
+// the release to the UE in CM-CONNECTED state, or releases it locally when the SMF does not answer
+func releasePduSession(ue *context.AmfUe, anType models.AccessType, smContext *context.SmContext,
+	cause models.SmfPduSessionCause,
+) {
+	pduSessionID := smContext.PduSessionID()
+	updateData := models.SmfPduSessionSmContextUpdateData{
+		Release: true,
+		Cause:   cause,
+	}
+	response, _, _, err := consumer.GetConsumer().SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
+	if err != nil || response == nil {
+		ue.GmmLog.Errorf("Failed to release PDU session[%d], local release: %+v", pduSessionID, err)
+		ue.DeleteSmContext(pduSessionID, anType)
+		return
+	}
+	if !ue.CmConnect(anType) {
+		return
+	}
+	var n1Msg []byte
+	if response.BinaryDataN1SmMessage != nil {
+		n1Msg, err = gmm_message.BuildDLNASTransport(ue, anType, nasMessage.PayloadContainerTypeN1SMInfo,
+			response.BinaryDataN1SmMessage, uint8(pduSessionID), nil, nil, 0)
+		if err != nil {
+			ue.GmmLog.Errorf("Build DL NAS Transport error: %+v", err)
+			return
+		}
+	}
+	if n2Info := response.BinaryDataN2SmInformation; n2Info != nil &&
+		response.JsonData.N2SmInfoType == models.N2SmInfoType_PDU_RES_REL_CMD {
+		list := ngapType.PDUSessionResourceToReleaseListRelCmd{}
+		ngap_message.AppendPDUSessionResourceToReleaseListRelCmd(&list, pduSessionID, n2Info)
+		ngap_message.SendPDUSessionResourceReleaseCommand(ue.RanUe[anType], n1Msg, list)
+	} else if n1Msg != nil {
+		ngap_message.SendDownlinkNasTransport(ue.RanUe[anType], n1Msg, nil)
+	}
+}
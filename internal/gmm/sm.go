@@ -24,6 +24,9 @@ func DeRegistered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		amfUe := args[ArgAmfUe].(*context.AmfUe)
 		accessType := args[ArgAccessType].(models.AccessType)
 		amfUe.ClearRegistrationRequestData(accessType)
+		amfUe.ClearConfigurationUpdate(accessType)
+		amfUe.ReleaseSlices(accessType)
+		amfUe.ClearSliceAvailability(accessType)
 		amfUe.GmmLog.Debugln("EntryEvent at GMM State[DeRegistered]")
 	case GmmMessageEvent:
 		amfUe := args[ArgAmfUe].(*context.AmfUe)
@@ -72,6 +75,7 @@ func Registered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		amfUe.GmmStateEnterTime = time.Now()
 		amfUe.ClearRegistrationRequestData(accessType)
 		amfUe.GmmLog.Debugln("EntryEvent at GMM State[Registered]")
+		amfUe.Checkpoint()
 		// If we have a radio connection, and we enter the registered state, then we increase the gauge
 		if amfUe.CmConnect(accessType) {
 			business_metrics.IncrUeConnectivityGauge(accessType)
@@ -101,6 +105,7 @@ func Registered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 			if err := HandleULNASTransport(amfUe, accessType, gmmMessage.ULNASTransport); err != nil {
 				logger.GmmLog.Errorln(err)
 			}
+			startNasKeyRefresh(amfUe, accessType)
 		case nas.MsgTypeConfigurationUpdateComplete:
 			if err := HandleConfigurationUpdateComplete(amfUe, gmmMessage.ConfigurationUpdateComplete); err != nil {
 				logger.GmmLog.Errorln(err)
@@ -109,6 +114,14 @@ func Registered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 			if err := HandleServiceRequest(amfUe, accessType, gmmMessage.ServiceRequest); err != nil {
 				logger.GmmLog.Errorln(err)
 			}
+		case nas.MsgTypeSecurityModeComplete:
+			if err := HandleNasKeyRefreshComplete(amfUe, accessType, gmmMessage.SecurityModeComplete); err != nil {
+				logger.GmmLog.Errorln(err)
+			}
+		case nas.MsgTypeSecurityModeReject:
+			if err := HandleNasKeyRefreshReject(amfUe, accessType, gmmMessage.SecurityModeReject); err != nil {
+				logger.GmmLog.Errorln(err)
+			}
 		case nas.MsgTypeNotificationResponse:
 			if err := HandleNotificationResponse(amfUe, gmmMessage.NotificationResponse); err != nil {
 				logger.GmmLog.Errorln(err)
@@ -223,10 +236,18 @@ func Authentication(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		}
 	case AuthSuccessEvent:
 		logger.GmmLog.Debugln(event)
+		amfUe = args[ArgAmfUe].(*context.AmfUe)
+		if amfUe.EndAuthentication(context.AuthenticationResultSuccess) {
+			// the KAMF of the new partial native security context is taken into use by a security mode control
+			// procedure selecting the algorithms again (TS 33.501 6.7.2)
+			amfUe.SecurityContextAvailable = false
+			amfUe.KamfChanged = false
+		}
 	case AuthErrorEvent:
 		amfUe = args[ArgAmfUe].(*context.AmfUe)
 		accessType = args[ArgAccessType].(models.AccessType)
 		logger.GmmLog.Debugln(event)
+		amfUe.EndAuthentication(context.AuthenticationResultFailure)
 		if err := HandleAuthenticationError(amfUe, accessType); err != nil {
 			logger.GmmLog.Errorln(err)
 		}
@@ -235,6 +256,7 @@ func Authentication(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		logger.GmmLog.Warnln("Reject authentication")
 		amfUe := args[ArgAmfUe].(*context.AmfUe)
 		accessType = args[ArgAccessType].(models.AccessType)
+		amfUe.EndAuthentication(context.AuthenticationResultFailure)
 		if amfUe.RanUe[accessType] != nil {
 			ngap_message.SendUEContextReleaseCommand(amfUe.RanUe[accessType], context.UeContextN2NormalRelease,
 				ngapType.CausePresentNas, ngapType.CauseNasPresentAuthenticationFailure)
@@ -249,6 +271,7 @@ func Authentication(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		amfUe := args[ArgAmfUe].(*context.AmfUe)
 		amfUe.GmmLog.Debugln(event)
 		amfUe.AuthenticationCtx = nil
+		amfUe.EndAuthentication(context.AuthenticationResultAborted)
 		amfUe.AuthFailureCauseSynchFailureTimes = 0
 		amfUe.IdentityRequestSendTimes = 0
 		business_metrics.DecrGmmStateGauge(string(accessType), string(state.Current()), amfUe.GmmStateEnterTime)
@@ -268,7 +291,15 @@ func SecurityMode(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		amfUe.UpdateLogFields(accessType)
 
 		amfUe.GmmLog.Debugln("EntryEvent at GMM State[SecurityMode]")
-		if amfUe.SecurityContextIsValid() {
+		if amfUe.SecurityContextIsValid() && !amfUe.KamfChanged && amfUe.NasCountNearWrapAround() {
+			amfUe.GmmLog.Infoln("NAS COUNT about to wrap around - refresh the NAS keys")
+			amfUe.DerivateHorizontalKamf()
+		}
+		if amfUe.SecurityContextIsValid() && amfUe.KamfChanged {
+			// take the KAMF derived horizontally into use, keeping the selected algorithms
+			amfUe.DerivateAlgKey()
+			gmm_message.SendSecurityModeCommand(amfUe.RanUe[accessType], accessType, false, "")
+		} else if amfUe.SecurityContextIsValid() {
 			amfUe.GmmLog.Debugln("UE has a valid security context - skip security mode control procedure")
 			if err := GmmFSM.SendEvent(state, SecurityModeSuccessEvent, fsm.ArgsType{
 				ArgAmfUe:      amfUe,
@@ -466,11 +497,13 @@ func DeregisteredInitiated(state *fsm.State, event fsm.EventType, args fsm.ArgsT
 	case fsm.EntryEvent:
 		business_metrics.IncrGmmStateGauge(string(accessType), string(state.Current()))
 		amfUe := args[ArgAmfUe].(*context.AmfUe)
-		gmmMessage := args[ArgNASMessage].(*nas.GmmMessage)
 		amfUe.GmmLog.Debugln("EntryEvent at GMM State[DeregisteredInitiated]")
-		if err := HandleDeregistrationRequest(amfUe, accessType,
-			gmmMessage.DeregistrationRequestUEOriginatingDeregistration); err != nil {
-			logger.GmmLog.Errorln(err)
+		// no NAS message when the deregistration is initiated by the network
+		if gmmMessage, ok := args[ArgNASMessage].(*nas.GmmMessage); ok {
+			if err := HandleDeregistrationRequest(amfUe, accessType,
+				gmmMessage.DeregistrationRequestUEOriginatingDeregistration); err != nil {
+				logger.GmmLog.Errorln(err)
+			}
 		}
 	case GmmMessageEvent:
 		amfUe := args[ArgAmfUe].(*context.AmfUe)
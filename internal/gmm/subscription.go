@@ -0,0 +1,23 @@
+package gmm
+
+import (
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/openapi/models"
+)
+
+// ReleaseUnsubscribedPduSessions releases the PDU sessions of the UE whose S-NSSAI and DNN its SMF selection
+// subscription data no longer has
+func ReleaseUnsubscribedPduSessions(ue *context.AmfUe) {
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		smContext := value.(*context.SmContext)
+		homeSnssai := ue.HomeSnssai(smContext.Snssai())
+		if ue.InSmfSelectionData(homeSnssai, smContext.Dnn()) {
+			return true
+		}
+		ue.GmmLog.Infof("Release PDU session[%d] of S-NSSAI[%+v] DNN[%s] no longer subscribed",
+			smContext.PduSessionID(), homeSnssai, smContext.Dnn())
+		releasePduSession(ue, smContext.AccessType(), smContext,
+			models.SmfPduSessionCause_REL_DUE_TO_SUBSCRIPTION_CHANGE)
+		return true
+	})
+}
@@ -0,0 +1,58 @@
+package business
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/metrics/utils"
+)
+
+var (
+	// nasSecurityFailureCounter Counter for the uplink NAS messages failing the NAS security policy,
+	// labeled by access type and failure reason
+	nasSecurityFailureCounter *prometheus.CounterVec
+	// nasSecurityAlarmCounter Counter for the UEs reaching the maximum number of consecutive failures,
+	// labeled by access type
+	nasSecurityAlarmCounter *prometheus.CounterVec
+)
+
+func GetNasSecurityHandlerMetrics(namespace string) []prometheus.Collector {
+	var collectors []prometheus.Collector
+
+	nasSecurityFailureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      NAS_SECURITY_FAILURE_COUNTER_NAME,
+			Help:      NAS_SECURITY_FAILURE_COUNTER_DESC,
+		}, []string{NAS_SECURITY_ACCESS_TYPE_LABEL, NAS_SECURITY_REASON_LABEL},
+	)
+
+	nasSecurityAlarmCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      NAS_SECURITY_ALARM_COUNTER_NAME,
+			Help:      NAS_SECURITY_ALARM_COUNTER_DESC,
+		}, []string{NAS_SECURITY_ACCESS_TYPE_LABEL},
+	)
+
+	collectors = append(collectors, nasSecurityFailureCounter, nasSecurityAlarmCounter)
+
+	return collectors
+}
+
+func IncrNasSecurityFailureCounter(accessType models.AccessType, reason string) {
+	if utils.IsBusinessMetricsEnabled() && IsNasSecurityMetricsEnabled() {
+		nasSecurityFailureCounter.With(prometheus.Labels{
+			NAS_SECURITY_ACCESS_TYPE_LABEL: string(accessType),
+			NAS_SECURITY_REASON_LABEL:      reason,
+		}).Inc()
+	}
+}
+
+func IncrNasSecurityAlarmCounter(accessType models.AccessType) {
+	if utils.IsBusinessMetricsEnabled() && IsNasSecurityMetricsEnabled() {
+		nasSecurityAlarmCounter.With(prometheus.Labels{NAS_SECURITY_ACCESS_TYPE_LABEL: string(accessType)}).Inc()
+	}
+}
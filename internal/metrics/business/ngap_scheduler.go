@@ -0,0 +1,96 @@
+package business
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/free5gc/util/metrics/utils"
+)
+
+var (
+	// ngapWorkerQueueDepthGauge Gauge for the number of tasks waiting in the queues of a worker, labeled by worker
+	ngapWorkerQueueDepthGauge *prometheus.GaugeVec
+	// ngapWorkerWaitHistogram Histogram for the time a task waits in the queue of a worker, labeled by worker
+	ngapWorkerWaitHistogram *prometheus.HistogramVec
+	// ngapWorkerProcessingHistogram Histogram for the time a worker takes to handle a task, labeled by worker
+	ngapWorkerProcessingHistogram *prometheus.HistogramVec
+	// ngapSchedulerRejectedCounter Counter for the tasks not admitted in a worker queue,
+	// labeled by task class and rejection reason
+	ngapSchedulerRejectedCounter *prometheus.CounterVec
+)
+
+func GetNgapSchedulerHandlerMetrics(namespace string) []prometheus.Collector {
+	var collectors []prometheus.Collector
+
+	buckets := []float64{0.0001, 0.0005, 0.0010, 0.0050, 0.0100, 0.0500, 0.1000, 0.5000, 1.0000}
+
+	ngapWorkerQueueDepthGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      NGAP_WORKER_QUEUE_DEPTH_GAUGE_NAME,
+			Help:      NGAP_WORKER_QUEUE_DEPTH_GAUGE_DESC,
+		}, []string{NGAP_WORKER_LABEL},
+	)
+
+	ngapWorkerWaitHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      NGAP_WORKER_WAIT_HISTOGRAM_NAME,
+			Help:      NGAP_WORKER_WAIT_HISTOGRAM_DESC,
+			Buckets:   buckets,
+		}, []string{NGAP_WORKER_LABEL},
+	)
+
+	ngapWorkerProcessingHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      NGAP_WORKER_PROCESSING_HISTOGRAM_NAME,
+			Help:      NGAP_WORKER_PROCESSING_HISTOGRAM_DESC,
+			Buckets:   buckets,
+		}, []string{NGAP_WORKER_LABEL},
+	)
+
+	ngapSchedulerRejectedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      NGAP_SCHEDULER_REJECTED_COUNTER_NAME,
+			Help:      NGAP_SCHEDULER_REJECTED_COUNTER_DESC,
+		}, []string{NGAP_TASK_CLASS_LABEL, NGAP_REJECTION_REASON_LABEL},
+	)
+
+	collectors = append(collectors, ngapWorkerQueueDepthGauge, ngapWorkerWaitHistogram,
+		ngapWorkerProcessingHistogram, ngapSchedulerRejectedCounter)
+
+	return collectors
+}
+
+func SetNgapWorkerQueueDepth(worker int, depth int) {
+	if utils.IsBusinessMetricsEnabled() && IsNgapSchedulerMetricsEnabled() {
+		ngapWorkerQueueDepthGauge.With(prometheus.Labels{
+			NGAP_WORKER_LABEL: strconv.Itoa(worker),
+		}).Set(float64(depth))
+	}
+}
+
+func ObserveNgapWorkerTask(worker int, wait time.Duration, processing time.Duration) {
+	if utils.IsBusinessMetricsEnabled() && IsNgapSchedulerMetricsEnabled() {
+		labels := prometheus.Labels{NGAP_WORKER_LABEL: strconv.Itoa(worker)}
+		ngapWorkerWaitHistogram.With(labels).Observe(wait.Seconds())
+		ngapWorkerProcessingHistogram.With(labels).Observe(processing.Seconds())
+	}
+}
+
+func IncrNgapSchedulerRejectedCounter(class string, reason string) {
+	if utils.IsBusinessMetricsEnabled() && IsNgapSchedulerMetricsEnabled() {
+		ngapSchedulerRejectedCounter.With(prometheus.Labels{
+			NGAP_TASK_CLASS_LABEL:       class,
+			NGAP_REJECTION_REASON_LABEL: reason,
+		}).Inc()
+	}
+}
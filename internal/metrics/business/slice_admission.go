@@ -0,0 +1,60 @@
+package business
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/free5gc/util/metrics/utils"
+)
+
+var (
+	// sliceAdmittedUesGauge Gauge for the UEs admitted by the AMF to the S-NSSAIs subject to network slice
+	// admission control, labeled by S-NSSAI
+	sliceAdmittedUesGauge *prometheus.GaugeVec
+	// sliceAdmissionRejectCounter Counter for the S-NSSAIs rejected to the UEs for the maximum number of UEs
+	// reached, labeled by S-NSSAI
+	sliceAdmissionRejectCounter *prometheus.CounterVec
+)
+
+func GetSliceAdmissionHandlerMetrics(namespace string) []prometheus.Collector {
+	var collectors []prometheus.Collector
+
+	sliceAdmittedUesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      SLICE_ADMITTED_UES_GAUGE_NAME,
+			Help:      SLICE_ADMITTED_UES_GAUGE_DESC,
+		}, []string{SLICE_ADMISSION_SNSSAI_LABEL},
+	)
+
+	sliceAdmissionRejectCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: SUBSYSTEM_NAME,
+			Name:      SLICE_ADMISSION_REJECT_COUNTER_NAME,
+			Help:      SLICE_ADMISSION_REJECT_COUNTER_DESC,
+		}, []string{SLICE_ADMISSION_SNSSAI_LABEL},
+	)
+
+	collectors = append(collectors, sliceAdmittedUesGauge, sliceAdmissionRejectCounter)
+
+	return collectors
+}
+
+func IncrSliceAdmittedUesGauge(snssai string) {
+	if utils.IsBusinessMetricsEnabled() && IsSliceAdmissionMetricsEnabled() {
+		sliceAdmittedUesGauge.With(prometheus.Labels{SLICE_ADMISSION_SNSSAI_LABEL: snssai}).Inc()
+	}
+}
+
+func DecrSliceAdmittedUesGauge(snssai string) {
+	if utils.IsBusinessMetricsEnabled() && IsSliceAdmissionMetricsEnabled() {
+		sliceAdmittedUesGauge.With(prometheus.Labels{SLICE_ADMISSION_SNSSAI_LABEL: snssai}).Dec()
+	}
+}
+
+func IncrSliceAdmissionRejectCounter(snssai string) {
+	if utils.IsBusinessMetricsEnabled() && IsSliceAdmissionMetricsEnabled() {
+		sliceAdmissionRejectCounter.With(prometheus.Labels{SLICE_ADMISSION_SNSSAI_LABEL: snssai}).Inc()
+	}
+}
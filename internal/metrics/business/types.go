@@ -10,6 +10,9 @@ const (
 	PDU_METRICS             = "pdu"
 	GMM_STATE_METRICS       = "gmm-state"
 	UE_CONNECTIVITY_METRICS = "ue-connectivity"
+	NGAP_SCHEDULER_METRICS  = "ngap-scheduler"
+	NAS_SECURITY_METRICS    = "nas-security"
+	SLICE_ADMISSION_METRICS = "slice-admission"
 )
 
 // Collectors information
@@ -39,6 +42,26 @@ const (
 	UE_CONNECTIVITY_GAUGE_NAME = "ue_connectivity"
 	UE_CONNECTIVITY_GAUGE_DESC = "Number of user equipment that are connected to the core network " +
 		"(cm-connected + gmm-registered)"
+
+	NGAP_WORKER_QUEUE_DEPTH_GAUGE_NAME    = "ngap_worker_queue_depth"
+	NGAP_WORKER_QUEUE_DEPTH_GAUGE_DESC    = "Number of NGAP messages and UE events waiting in the queue of each worker"
+	NGAP_WORKER_WAIT_HISTOGRAM_NAME       = "ngap_worker_wait_seconds"
+	NGAP_WORKER_WAIT_HISTOGRAM_DESC       = "Time the NGAP messages and UE events wait in the queue of the worker"
+	NGAP_WORKER_PROCESSING_HISTOGRAM_NAME = "ngap_worker_processing_seconds"
+	NGAP_WORKER_PROCESSING_HISTOGRAM_DESC = "Time the worker takes to handle an NGAP message or UE event"
+	NGAP_SCHEDULER_REJECTED_COUNTER_NAME  = "ngap_scheduler_rejected_total"
+	NGAP_SCHEDULER_REJECTED_COUNTER_DESC  = "Count of NGAP messages and UE events not admitted in the worker queues"
+
+	NAS_SECURITY_FAILURE_COUNTER_NAME = "nas_security_failures_total"
+	NAS_SECURITY_FAILURE_COUNTER_DESC = "Count of uplink NAS messages failing the integrity check, replayed " +
+		"or not protected as required"
+	NAS_SECURITY_ALARM_COUNTER_NAME = "nas_security_alarms_total"
+	NAS_SECURITY_ALARM_COUNTER_DESC = "Count of UEs reaching the maximum number of consecutive NAS security failures"
+
+	SLICE_ADMITTED_UES_GAUGE_NAME       = "slice_admitted_ues"
+	SLICE_ADMITTED_UES_GAUGE_DESC       = "Number of UEs admitted by the AMF to each S-NSSAI subject to admission control"
+	SLICE_ADMISSION_REJECT_COUNTER_NAME = "slice_admission_rejections_total"
+	SLICE_ADMISSION_REJECT_COUNTER_DESC = "Count of S-NSSAIs rejected to UEs for the maximum number of UEs reached"
 )
 
 // Label names
@@ -65,6 +88,18 @@ const (
 
 	// UE-Connectivity
 	UE_CONNECTIVITY_ACCESS_TYPE_LABEL = "access_type"
+
+	// NGAP scheduler
+	NGAP_WORKER_LABEL           = "worker"
+	NGAP_TASK_CLASS_LABEL       = "class"
+	NGAP_REJECTION_REASON_LABEL = "reason"
+
+	// NAS security
+	NAS_SECURITY_ACCESS_TYPE_LABEL = "access_type"
+	NAS_SECURITY_REASON_LABEL      = "reason"
+
+	// Network slice admission control
+	SLICE_ADMISSION_SNSSAI_LABEL = "snssai"
 )
 
 // Metrics Values
@@ -147,3 +182,33 @@ func IsUeConnectivityMetricsEnabled() bool {
 func EnableUeConnectivityMetrics() {
 	ueConnectivityMetricsEnabled = true
 }
+
+var ngapSchedulerMetricsEnabled bool
+
+func IsNgapSchedulerMetricsEnabled() bool {
+	return ngapSchedulerMetricsEnabled
+}
+
+func EnableNgapSchedulerMetrics() {
+	ngapSchedulerMetricsEnabled = true
+}
+
+var nasSecurityMetricsEnabled bool
+
+func IsNasSecurityMetricsEnabled() bool {
+	return nasSecurityMetricsEnabled
+}
+
+func EnableNasSecurityMetrics() {
+	nasSecurityMetricsEnabled = true
+}
+
+var sliceAdmissionMetricsEnabled bool
+
+func IsSliceAdmissionMetricsEnabled() bool {
+	return sliceAdmissionMetricsEnabled
+}
+
+func EnableSliceAdmissionMetrics() {
+	sliceAdmissionMetricsEnabled = true
+}
@@ -0,0 +1,7 @@
+package ngap
+
+// Names of the NGAP messages sent by the AMF that github.com/free5gc/util/metrics/ngap does not define,
+// used as the name label of its message counters
+const (
+	TRACE_START = "TraceStart"
+)
@@ -25,10 +25,23 @@ func Dispatch(ue *context.AmfUe, accessType models.AccessType, procedureCode int
 		return fmt.Errorf("UE State is empty (accessType=%q). Can't send GSM Message", accessType)
 	}
 
-	return gmm.GmmFSM.SendEvent(ue.State[accessType], gmm.GmmMessageEvent, fsm.ArgsType{
+	msgType := msg.GmmHeader.GetMessageType()
+	if procedure := context.TracedProcedureOf(msgType); procedure != "" {
+		ue.StartProcedureSpan(accessType, procedure)
+	}
+	endNasSpan := ue.StartNasSpan(accessType, msgType)
+
+	err := gmm.GmmFSM.SendEvent(ue.State[accessType], gmm.GmmMessageEvent, fsm.ArgsType{
 		gmm.ArgAmfUe:         ue,
 		gmm.ArgAccessType:    accessType,
 		gmm.ArgNASMessage:    msg.GmmMessage,
 		gmm.ArgProcedureCode: procedureCode,
 	}, logger.GmmLog)
+
+	endNasSpan(err)
+	// the procedure is over once the UE is back to a stable 5GMM state
+	if state := ue.State[accessType]; state.Is(context.Registered) || state.Is(context.Deregistered) {
+		ue.EndProcedureSpan(accessType, err)
+	}
+	return err
 }
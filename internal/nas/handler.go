@@ -7,7 +7,9 @@ import (
 	gmm_common "github.com/free5gc/amf/internal/gmm/common"
 	"github.com/free5gc/amf/internal/logger"
 	"github.com/free5gc/amf/internal/nas/nas_security"
+	ngap_message "github.com/free5gc/amf/internal/ngap/message"
 	"github.com/free5gc/nas"
+	"github.com/free5gc/ngap/ngapType"
 	nas_metrics "github.com/free5gc/util/metrics/nas"
 )
 
@@ -53,6 +55,9 @@ func HandleNAS(ranUe *amf_context.RanUe, procedureCode int64, nasPdu []byte, ini
 	if err != nil {
 		metricCause = nas_metrics.DECODE_NAS_MSG_ERR
 		ranUe.AmfUe.NASLog.Errorln(err)
+		if ranUe.AmfUe.NasSecurityReleaseRequired() {
+			releaseOnNasSecurityFailures(ranUe)
+		}
 		return
 	}
 
@@ -74,6 +79,18 @@ func HandleNAS(ranUe *amf_context.RanUe, procedureCode int64, nasPdu []byte, ini
 	}
 }
 
+// releaseOnNasSecurityFailures releases the NAS signalling connection of the UE whose uplink NAS messages keep
+// failing the NAS security policy, the UE context being kept for a registered UE only
+func releaseOnNasSecurityFailures(ranUe *amf_context.RanUe) {
+	ranUe.AmfUe.NASLog.Warnln("Release the NAS signalling connection of the UE failing the NAS security policy")
+	action := amf_context.UeContextReleaseUeContext
+	if ranUe.AmfUe.State[ranUe.Ran.AnType].Is(amf_context.Registered) {
+		action = amf_context.UeContextN2NormalRelease
+	}
+	ngap_message.SendUEContextReleaseCommand(ranUe, action, ngapType.CausePresentNas,
+		ngapType.CauseNasPresentAuthenticationFailure)
+}
+
 // Get5GSMobileIdentityFromNASPDU is used to find MobileIdentity from plain nas
 // return value is: mobileId, mobileIdType, err
 func GetNas5GSMobileIdentity(gmmMessage *nas.GmmMessage) (string, string, error) {
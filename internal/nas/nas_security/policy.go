@@ -0,0 +1,120 @@
+package nas_security
+
+import (
+	"fmt"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/nas"
+	"github.com/free5gc/nas/nasConvert"
+	"github.com/free5gc/nas/nasMessage"
+)
+
+// allowedWithoutIntegrity reports whether the AMF processes the 5GMM message although it is not integrity protected
+// or failed the integrity check (TS 24.501 4.4.4.3), the handlers then seeing AmfUe.MacFailed set
+func allowedWithoutIntegrity(gmmMessage *nas.GmmMessage) bool {
+	switch gmmMessage.GetMessageType() {
+	case nas.MsgTypeRegistrationRequest,
+		nas.MsgTypeAuthenticationResponse,
+		nas.MsgTypeAuthenticationFailure,
+		nas.MsgTypeSecurityModeReject,
+		nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration,
+		nas.MsgTypeDeregistrationAcceptUETerminatedDeregistration,
+		nas.MsgTypeServiceRequest:
+		return true
+	case nas.MsgTypeIdentityResponse:
+		// only when the requested identity is the SUCI
+		mobileIdentityContents := gmmMessage.IdentityResponse.MobileIdentity.GetMobileIdentityContents()
+		return len(mobileIdentityContents) >= 1 &&
+			nasConvert.GetTypeOfIdentity(mobileIdentityContents[0]) == nasMessage.MobileIdentity5GSTypeSuci
+	}
+	return false
+}
+
+// checkSecurityPolicy tells whether the uplink NAS message may be processed, given its security header type and
+// whether it passed the integrity check, returning the failure reason of the message to discard otherwise
+func checkSecurityPolicy(ue *context.AmfUe, msg *nas.Message, integrityProtected bool, initialMessage bool) (
+	string, error,
+) {
+	msgTypeText := func() string {
+		if msg.GmmMessage == nil {
+			return "Non GMM message"
+		} else {
+			return fmt.Sprintf(" message type %d", msg.GmmHeader.GetMessageType())
+		}
+	}
+
+	if err := checkSecurityHeader(ue, msg, initialMessage); err != nil {
+		if !ue.SecurityContextAvailable {
+			return context.NasSecurityFailureNoSecurityContext, fmt.Errorf("%w, %s", err, msgTypeText())
+		}
+		return context.NasSecurityFailureSecurityHeader, fmt.Errorf("%w, %s", err, msgTypeText())
+	}
+
+	if integrityProtected || !ue.SecurityContextAvailable {
+		return "", nil
+	}
+	// with a current NAS security context, the messages of the table are only processed without integrity
+	// protection when initial, the UE possibly having lost its security context
+	if msg.GmmMessage != nil && allowedWithoutIntegrity(msg.GmmMessage) && initialMessage {
+		return "", nil
+	}
+	return context.NasSecurityFailureMac, fmt.Errorf("MAC verification failed, %s", msgTypeText())
+}
+
+// checkSecurityHeader checks the security header type the message is required to have
+func checkSecurityHeader(ue *context.AmfUe, msg *nas.Message, initialMessage bool) error {
+	errNoSecurityContext := fmt.Errorf("UE Security Context is not Available")
+	errWrongSecurityHeader := fmt.Errorf("wrong security header type: 0x%0x", msg.SecurityHeader.SecurityHeaderType)
+
+	// all messages are ciphered once a NAS security context is in use, except the initial ones
+	requireCiphered := func() error {
+		if !ue.SecurityContextAvailable {
+			return errNoSecurityContext
+		}
+		if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
+			return errWrongSecurityHeader
+		}
+		return nil
+	}
+
+	if msg.GmmMessage == nil {
+		return requireCiphered()
+	}
+	switch msg.GmmHeader.GetMessageType() {
+	case nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration, nas.MsgTypeRegistrationRequest:
+		if initialMessage {
+			if msg.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedAndCiphered ||
+				msg.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext {
+				return errWrongSecurityHeader
+			}
+		} else if ue.SecurityContextAvailable {
+			return requireCiphered()
+		}
+	case nas.MsgTypeServiceRequest:
+		if initialMessage {
+			if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtected {
+				return errWrongSecurityHeader
+			}
+		} else {
+			return requireCiphered()
+		}
+	case nas.MsgTypeIdentityResponse,
+		nas.MsgTypeAuthenticationResponse,
+		nas.MsgTypeAuthenticationFailure,
+		nas.MsgTypeSecurityModeReject,
+		nas.MsgTypeDeregistrationAcceptUETerminatedDeregistration:
+		if ue.SecurityContextAvailable || !allowedWithoutIntegrity(msg.GmmMessage) {
+			return requireCiphered()
+		}
+	case nas.MsgTypeSecurityModeComplete:
+		if !ue.SecurityContextAvailable {
+			return errNoSecurityContext
+		}
+		if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext {
+			return errWrongSecurityHeader
+		}
+	default:
+		return requireCiphered()
+	}
+	return nil
+}
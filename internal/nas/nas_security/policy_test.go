@@ -0,0 +1,55 @@
+package nas_security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/nas"
+)
+
+func TestCheckSecurityPolicy(t *testing.T) {
+	newMessage := func(msgType, securityHeaderType uint8) *nas.Message {
+		m := nas.NewMessage()
+		m.GmmMessage = nas.NewGmmMessage()
+		m.GmmHeader.SetMessageType(msgType)
+		m.SecurityHeaderType = securityHeaderType
+		return m
+	}
+	ue := &context.AmfUe{SecurityContextAvailable: true}
+
+	// an initial message of the table is processed although it failed the integrity check
+	reason, err := checkSecurityPolicy(ue,
+		newMessage(nas.MsgTypeRegistrationRequest, nas.SecurityHeaderTypeIntegrityProtected), false, true)
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	// but not once the NAS signalling connection is established
+	reason, err = checkSecurityPolicy(ue,
+		newMessage(nas.MsgTypeRegistrationRequest, nas.SecurityHeaderTypeIntegrityProtectedAndCiphered), false, false)
+	require.Error(t, err)
+	require.Equal(t, context.NasSecurityFailureMac, reason)
+
+	reason, err = checkSecurityPolicy(ue,
+		newMessage(nas.MsgTypeULNASTransport, nas.SecurityHeaderTypeIntegrityProtectedAndCiphered), true, false)
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	reason, err = checkSecurityPolicy(ue,
+		newMessage(nas.MsgTypeULNASTransport, nas.SecurityHeaderTypeIntegrityProtected), true, false)
+	require.Error(t, err)
+	require.Equal(t, context.NasSecurityFailureSecurityHeader, reason)
+
+	// the messages of the table only are processed before the NAS security context is established
+	ue.SecurityContextAvailable = false
+	reason, err = checkSecurityPolicy(ue,
+		newMessage(nas.MsgTypeAuthenticationResponse, nas.SecurityHeaderTypePlainNas), false, false)
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	reason, err = checkSecurityPolicy(ue,
+		newMessage(nas.MsgTypeULNASTransport, nas.SecurityHeaderTypePlainNas), false, false)
+	require.Error(t, err)
+	require.Equal(t, context.NasSecurityFailureNoSecurityContext, reason)
+}
@@ -8,13 +8,18 @@ import (
 
 	"github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/nas"
-	"github.com/free5gc/nas/nasConvert"
 	"github.com/free5gc/nas/nasMessage"
 	"github.com/free5gc/nas/security"
 	"github.com/free5gc/openapi/models"
 )
 
 func Encode(ue *context.AmfUe, msg *nas.Message, accessType models.AccessType) ([]byte, error) {
+	return EncodeWithIes(ue, msg, accessType, nil)
+}
+
+// EncodeWithIes encodes the NAS message as Encode does, the encoded optional IEs the NAS library does not define
+// being appended to the plain message before it is protected
+func EncodeWithIes(ue *context.AmfUe, msg *nas.Message, accessType models.AccessType, ies []byte) ([]byte, error) {
 	if msg == nil {
 		return nil, fmt.Errorf("NAS Message is nil")
 	}
@@ -45,6 +50,10 @@ func Encode(ue *context.AmfUe, msg *nas.Message, accessType models.AccessType) (
 			return nil, fmt.Errorf("NAS message type %d is requierd security, but security context is not available", msgType)
 		}
 		pdu, err := msg.PlainNasEncode()
+		if err == nil {
+			pdu = append(pdu, ies...)
+			context.CaptureNas(ue, accessType, context.CaptureDirectionDownlink, msg, pdu)
+		}
 		return pdu, err
 	} else {
 		// Security protected NAS Message
@@ -58,8 +67,7 @@ func Encode(ue *context.AmfUe, msg *nas.Message, accessType models.AccessType) (
 			needCiphering = true
 		case nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext:
 			ue.NASLog.Debugln("Security header type: Integrity Protected With New 5G Security Context")
-			ue.ULCount.Set(0, 0)
-			ue.DLCount.Set(0, 0)
+			ue.ResetNasCounts()
 		default:
 			return nil, fmt.Errorf("wrong security header type: 0x%0x", msg.SecurityHeader.SecurityHeaderType)
 		}
@@ -69,6 +77,8 @@ func Encode(ue *context.AmfUe, msg *nas.Message, accessType models.AccessType) (
 		if err != nil {
 			return nil, fmt.Errorf("plain NAS encode error: %+v", err)
 		}
+		payload = append(payload, ies...)
+		context.CaptureNas(ue, accessType, context.CaptureDirectionDownlink, msg, payload)
 
 		ue.NASLog.Tracef("plain payload:\n%+v", hex.Dump(payload))
 		if needCiphering {
@@ -130,6 +140,14 @@ func Decode(ue *context.AmfUe, accessType models.AccessType, payload []byte,
 		return nil, false, fmt.Errorf("NAS payload is too short")
 	}
 
+	// the uplink NAS messages failing the security policy are counted, even the ones processed nonetheless
+	failure := ""
+	defer func() {
+		if failure != "" {
+			ue.RecordNasSecurityFailure(accessType, failure)
+		}
+	}()
+
 	ulCountNew := ue.ULCount
 
 	msg = new(nas.Message)
@@ -173,15 +191,16 @@ func Decode(ue *context.AmfUe, accessType models.AccessType, payload []byte,
 		}
 
 		if ciphered && !ue.SecurityContextAvailable {
+			failure = context.NasSecurityFailureNoSecurityContext
 			return nil, false, fmt.Errorf("NAS message is ciphered, but UE Security Context is not Available")
 		}
 
 		if ue.SecurityContextAvailable {
-			if ulCountNew.SQN() > sequenceNumber {
-				ue.NASLog.Debugf("set ULCount overflow")
-				ulCountNew.SetOverflow(ulCountNew.Overflow() + 1)
+			if msg.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext {
+				ulCountNew.SetSQN(sequenceNumber)
+			} else {
+				ulCountNew = ue.EstimateUplinkCount(sequenceNumber)
 			}
-			ulCountNew.SetSQN(sequenceNumber)
 
 			ue.NASLog.Debugf("Calculate NAS MAC (algorithm: %+v, ULCount: 0x%0x)", ue.IntegrityAlg, ulCountNew.Get())
 			ue.NASLog.Tracef("NAS integrity key0x: %0x", ue.KnasInt)
@@ -194,6 +213,10 @@ func Decode(ue *context.AmfUe, accessType models.AccessType, payload []byte,
 
 			if !reflect.DeepEqual(mac32, receivedMac32) {
 				ue.NASLog.Warnf("NAS MAC verification failed(received: 0x%08x, expected: 0x%08x)", receivedMac32, mac32)
+				failure = context.NasSecurityFailureMac
+			} else if ue.UplinkCountReplayed(ulCountNew) {
+				ue.NASLog.Warnf("NAS message replayed (ULCount: 0x%0x)", ulCountNew.Get())
+				failure = context.NasSecurityFailureReplay
 			} else {
 				ue.NASLog.Tracef("cmac value: 0x%08x", mac32)
 				integrityProtected = true
@@ -223,130 +246,17 @@ func Decode(ue *context.AmfUe, accessType models.AccessType, payload []byte,
 	if err != nil {
 		return nil, false, err
 	}
+	context.CaptureNas(ue, accessType, context.CaptureDirectionUplink, msg, payload)
 
-	msgTypeText := func() string {
-		if msg.GmmMessage == nil {
-			return "Non GMM message"
-		} else {
-			return fmt.Sprintf(" message type %d", msg.GmmHeader.GetMessageType())
-		}
-	}
-	errNoSecurityContext := func() error {
-		return fmt.Errorf("UE Security Context is not Available, %s", msgTypeText())
-	}
-	errWrongSecurityHeader := func() error {
-		return fmt.Errorf("wrong security header type: 0x%0x, %s", msg.SecurityHeader.SecurityHeaderType, msgTypeText())
-	}
-	errMacVerificationFailed := func() error {
-		return fmt.Errorf("MAC verification failed, %s", msgTypeText())
-	}
-
-	if msg.GmmMessage == nil {
-		if !ue.SecurityContextAvailable {
-			return nil, false, errNoSecurityContext()
-		}
-		if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-			return nil, false, errWrongSecurityHeader()
-		}
-		if !integrityProtected {
-			return nil, false, errMacVerificationFailed()
-		}
-	} else {
-		switch msg.GmmHeader.GetMessageType() {
-		case nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration, nas.MsgTypeRegistrationRequest:
-			if initialMessage {
-				if msg.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedAndCiphered ||
-					msg.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext {
-					return nil, false, errWrongSecurityHeader()
-				}
-			} else {
-				if ue.SecurityContextAvailable {
-					if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-						return nil, false, errWrongSecurityHeader()
-					}
-					if !integrityProtected {
-						return nil, false, errMacVerificationFailed()
-					}
-				}
-			}
-		case nas.MsgTypeServiceRequest:
-			if initialMessage {
-				if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtected {
-					return nil, false, errWrongSecurityHeader()
-				}
-			} else {
-				if !ue.SecurityContextAvailable {
-					return nil, false, errNoSecurityContext()
-				}
-				if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-					return nil, false, errWrongSecurityHeader()
-				}
-				if !integrityProtected {
-					return nil, false, errMacVerificationFailed()
-				}
-			}
-		case nas.MsgTypeIdentityResponse:
-			mobileIdentityContents := msg.IdentityResponse.MobileIdentity.GetMobileIdentityContents()
-			if len(mobileIdentityContents) >= 1 &&
-				nasConvert.GetTypeOfIdentity(mobileIdentityContents[0]) == nasMessage.MobileIdentity5GSTypeSuci {
-				// Identity is SUCI
-				if ue.SecurityContextAvailable {
-					if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-						return nil, false, errWrongSecurityHeader()
-					}
-					if !integrityProtected {
-						return nil, false, errMacVerificationFailed()
-					}
-				}
-			} else {
-				// Identity is not SUCI
-				if !ue.SecurityContextAvailable {
-					return nil, false, errNoSecurityContext()
-				}
-				if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-					return nil, false, errWrongSecurityHeader()
-				}
-				if !integrityProtected {
-					return nil, false, errMacVerificationFailed()
-				}
-			}
-		case nas.MsgTypeAuthenticationResponse,
-			nas.MsgTypeAuthenticationFailure,
-			nas.MsgTypeSecurityModeReject,
-			nas.MsgTypeDeregistrationAcceptUETerminatedDeregistration:
-			if ue.SecurityContextAvailable {
-				if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-					return nil, false, errWrongSecurityHeader()
-				}
-				if !integrityProtected {
-					return nil, false, errMacVerificationFailed()
-				}
-			}
-		case nas.MsgTypeSecurityModeComplete:
-			if !ue.SecurityContextAvailable {
-				return nil, false, errNoSecurityContext()
-			}
-			if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext {
-				return nil, false, errWrongSecurityHeader()
-			}
-			if !integrityProtected {
-				return nil, false, errMacVerificationFailed()
-			}
-		default:
-			if !ue.SecurityContextAvailable {
-				return nil, false, errNoSecurityContext()
-			}
-			if msg.SecurityHeaderType != nas.SecurityHeaderTypeIntegrityProtectedAndCiphered {
-				return nil, false, errWrongSecurityHeader()
-			}
-			if !integrityProtected {
-				return nil, false, errMacVerificationFailed()
-			}
+	if reason, errPolicy := checkSecurityPolicy(ue, msg, integrityProtected, initialMessage); errPolicy != nil {
+		if failure == "" {
+			failure = reason
 		}
+		return nil, false, errPolicy
 	}
 
 	if integrityProtected {
-		ue.ULCount = ulCountNew
+		ue.AcceptUplinkCount(ulCountNew)
 	}
 	return msg, integrityProtected, nil
 }
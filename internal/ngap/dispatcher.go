@@ -1,26 +1,21 @@
 package ngap
 
 import (
+	"fmt"
 	"net"
+	"time"
 
 	"github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/internal/tracing"
 	"github.com/free5gc/ngap"
 	"github.com/free5gc/ngap/ngapType"
 	"github.com/free5gc/sctp"
 )
 
 func Dispatch(conn net.Conn, msg []byte) {
-	amfSelf := context.GetSelf()
-
 	if len(msg) == 0 {
-		ran, ok := amfSelf.AmfRanFindByConn(conn)
-		if !ok {
-			logger.NgapLog.Warnf("Connection closed before NGSetup: %v", conn.RemoteAddr())
-			return
-		}
-		ran.Log.Infof("RAN close the connection.")
-		ran.Remove()
+		dispatchPDU(conn, nil, nil)
 		return
 	}
 
@@ -33,6 +28,53 @@ func Dispatch(conn net.Conn, msg []byte) {
 		logger.NgapLog.Error("NGAP Message is nil")
 		return
 	}
+	dispatchPDU(conn, msg, pdu)
+}
+
+// NewTask decodes the NGAP message and returns the task carrying the decoded message,
+// so that the worker handling it does not decode it again
+func NewTask(conn net.Conn, msg []byte) (Task, error) {
+	pdu, err := ngap.Decoder(msg)
+	if err != nil {
+		return Task{}, err
+	}
+	if pdu == nil {
+		return Task{}, fmt.Errorf("NGAP Message is nil")
+	}
+
+	key, ueAssociated := ueAffinityKey(conn, pdu)
+	return Task{
+		UEID:    key,
+		Class:   taskClass(pdu, ueAssociated),
+		Conn:    conn,
+		Message: msg,
+		PDU:     pdu,
+	}, nil
+}
+
+// HandleTask dispatches the NGAP message of the task, decoding it only if the task does not carry it decoded
+func HandleTask(task Task) {
+	if task.PDU == nil {
+		Dispatch(task.Conn, task.Message)
+		return
+	}
+	dispatchPDU(task.Conn, task.Message, task.PDU)
+}
+
+// dispatchPDU handles a decoded NGAP message; a nil pdu means the connection was closed
+func dispatchPDU(conn net.Conn, msg []byte, pdu *ngapType.NGAPPDU) {
+	amfSelf := context.GetSelf()
+
+	if pdu == nil {
+		ran, ok := amfSelf.AmfRanFindByConn(conn)
+		if !ok {
+			logger.NgapLog.Warnf("Connection closed before NGSetup: %v", conn.RemoteAddr())
+			return
+		}
+		ran.Log.Infof("RAN close the connection.")
+		ran.Remove()
+		return
+	}
 
 	ran, ok := amfSelf.AmfRanFindByConn(conn)
 	if !ok {
@@ -52,7 +94,48 @@ func Dispatch(conn net.Conn, msg []byte) {
 		}
 	}
 
+	if !context.IsCapturing() && !tracing.Enabled() {
+		dispatchMain(ran, pdu)
+		return
+	}
+	received := time.Now()
+	ranUe := pduRanUe(ran, pdu)
 	dispatchMain(ran, pdu)
+	// the UE of an Initial UE Message is known once it is handled
+	if ue := pduRanUe(ran, pdu); ue != nil {
+		ranUe = ue
+	}
+	context.CaptureNgap(ran, ranUe, context.CaptureDirectionUplink, received, msg)
+	if ranUe != nil && ranUe.AmfUe != nil {
+		present, procedureCode := pduProcedure(pdu)
+		ranUe.AmfUe.RecordNgapSpan(ran.AnType, received, present, procedureCode)
+	}
+}
+
+// pduRanUe returns the UE the NGAP message is about, nil for non-UE-associated messages
+func pduRanUe(ran *context.AmfRan, pdu *ngapType.NGAPPDU) *context.RanUe {
+	ueID, found := ExtractUEIDFromPDU(pdu)
+	if !found {
+		return nil
+	}
+	if pdu.Present == ngapType.NGAPPDUPresentInitiatingMessage &&
+		pdu.InitiatingMessage.ProcedureCode.Value == ngapType.ProcedureCodeInitialUEMessage {
+		return ran.RanUeFindByRanUeNgapID(int64(ueID))
+	}
+	return context.GetSelf().RanUeFindByAmfUeNgapID(int64(ueID))
+}
+
+// pduProcedure returns the type of the NGAP message and its procedure code
+func pduProcedure(pdu *ngapType.NGAPPDU) (string, int64) {
+	switch pdu.Present {
+	case ngapType.NGAPPDUPresentInitiatingMessage:
+		return "InitiatingMessage", pdu.InitiatingMessage.ProcedureCode.Value
+	case ngapType.NGAPPDUPresentSuccessfulOutcome:
+		return "SuccessfulOutcome", pdu.SuccessfulOutcome.ProcedureCode.Value
+	case ngapType.NGAPPDUPresentUnsuccessfulOutcome:
+		return "UnsuccessfulOutcome", pdu.UnsuccessfulOutcome.ProcedureCode.Value
+	}
+	return "", 0
 }
 
 func HandleSCTPNotification(conn net.Conn, notification sctp.Notification) {
@@ -0,0 +1,480 @@
+package ngap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/aper"
+	"github.com/free5gc/ngap"
+	"github.com/free5gc/ngap/ngapType"
+)
+
+var benchUserLocationInformation = ngapType.UserLocationInformation{
+	Present: ngapType.UserLocationInformationPresentUserLocationInformationNR,
+	UserLocationInformationNR: &ngapType.UserLocationInformationNR{
+		NRCGI: ngapType.NRCGI{
+			PLMNIdentity: ngapType.PLMNIdentity{Value: aper.OctetString("\x02\xf8\x39")},
+			NRCellIdentity: ngapType.NRCellIdentity{
+				Value: aper.BitString{Bytes: []byte{0x00, 0x00, 0x00, 0x00, 0x10}, BitLength: 36},
+			},
+		},
+		TAI: ngapType.TAI{
+			PLMNIdentity: ngapType.PLMNIdentity{Value: aper.OctetString("\x02\xf8\x39")},
+			TAC:          ngapType.TAC{Value: aper.OctetString("\x00\x00\x01")},
+		},
+	},
+}
+
+func buildInitialUEMessageForRegistration(ranUeNgapID int64, nasPdu []byte) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeInitialUEMessage},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+		},
+	}
+	pdu.InitiatingMessage.Value.Present = ngapType.InitiatingMessagePresentInitialUEMessage
+	msg := &ngapType.InitialUEMessage{}
+	pdu.InitiatingMessage.Value.InitialUEMessage = msg
+
+	ie := ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDNASPDU
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentNASPDU
+	ie.Value.NASPDU = &ngapType.NASPDU{Value: nasPdu}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentUserLocationInformation
+	uli := benchUserLocationInformation
+	ie.Value.UserLocationInformation = &uli
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRRCEstablishmentCause
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentRRCEstablishmentCause
+	ie.Value.RRCEstablishmentCause = &ngapType.RRCEstablishmentCause{
+		Value: ngapType.RRCEstablishmentCausePresentMoSignalling,
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+func buildUplinkNASTransport(amfUeNgapID, ranUeNgapID int64, nasPdu []byte) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeUplinkNASTransport},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+		},
+	}
+	pdu.InitiatingMessage.Value.Present = ngapType.InitiatingMessagePresentUplinkNASTransport
+	msg := &ngapType.UplinkNASTransport{}
+	pdu.InitiatingMessage.Value.UplinkNASTransport = msg
+
+	ie := ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = &ngapType.AMFUENGAPID{Value: amfUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDNASPDU
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentNASPDU
+	ie.Value.NASPDU = &ngapType.NASPDU{Value: nasPdu}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentUserLocationInformation
+	uli := benchUserLocationInformation
+	ie.Value.UserLocationInformation = &uli
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+func buildInitialContextSetupResponse(amfUeNgapID, ranUeNgapID int64) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentSuccessfulOutcome,
+		SuccessfulOutcome: &ngapType.SuccessfulOutcome{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeInitialContextSetup},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+		},
+	}
+	pdu.SuccessfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentInitialContextSetupResponse
+	msg := &ngapType.InitialContextSetupResponse{}
+	pdu.SuccessfulOutcome.Value.InitialContextSetupResponse = msg
+
+	ie := ngapType.InitialContextSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.InitialContextSetupResponseIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = &ngapType.AMFUENGAPID{Value: amfUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.InitialContextSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.InitialContextSetupResponseIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+func buildPDUSessionResourceSetupResponse(amfUeNgapID, ranUeNgapID int64) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentSuccessfulOutcome,
+		SuccessfulOutcome: &ngapType.SuccessfulOutcome{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodePDUSessionResourceSetup},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+		},
+	}
+	pdu.SuccessfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentPDUSessionResourceSetupResponse
+	msg := &ngapType.PDUSessionResourceSetupResponse{}
+	pdu.SuccessfulOutcome.Value.PDUSessionResourceSetupResponse = msg
+
+	ie := ngapType.PDUSessionResourceSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PDUSessionResourceSetupResponseIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = &ngapType.AMFUENGAPID{Value: amfUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.PDUSessionResourceSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PDUSessionResourceSetupResponseIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.PDUSessionResourceSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceSetupListSURes
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PDUSessionResourceSetupResponseIEsPresentPDUSessionResourceSetupListSURes
+	ie.Value.PDUSessionResourceSetupListSURes = &ngapType.PDUSessionResourceSetupListSURes{
+		List: []ngapType.PDUSessionResourceSetupItemSURes{{
+			PDUSessionID:                            ngapType.PDUSessionID{Value: 1},
+			PDUSessionResourceSetupResponseTransfer: make(aper.OctetString, 24),
+		}},
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+func buildHandoverRequired(amfUeNgapID, ranUeNgapID int64) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeHandoverPreparation},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+		},
+	}
+	pdu.InitiatingMessage.Value.Present = ngapType.InitiatingMessagePresentHandoverRequired
+	msg := &ngapType.HandoverRequired{}
+	pdu.InitiatingMessage.Value.HandoverRequired = msg
+
+	ie := ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = &ngapType.AMFUENGAPID{Value: amfUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDHandoverType
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentHandoverType
+	ie.Value.HandoverType = &ngapType.HandoverType{Value: ngapType.HandoverTypePresentIntra5gs}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDCause
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentCause
+	ie.Value.Cause = &ngapType.Cause{
+		Present: ngapType.CausePresentRadioNetwork,
+		RadioNetwork: &ngapType.CauseRadioNetwork{
+			Value: ngapType.CauseRadioNetworkPresentHandoverDesirableForRadioReason,
+		},
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceListHORqd
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentPDUSessionResourceListHORqd
+	ie.Value.PDUSessionResourceListHORqd = &ngapType.PDUSessionResourceListHORqd{
+		List: []ngapType.PDUSessionResourceItemHORqd{{
+			PDUSessionID:             ngapType.PDUSessionID{Value: 1},
+			HandoverRequiredTransfer: make(aper.OctetString, 4),
+		}},
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDSourceToTargetTransparentContainer
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentSourceToTargetTransparentContainer
+	ie.Value.SourceToTargetTransparentContainer = &ngapType.SourceToTargetTransparentContainer{
+		Value: make(aper.OctetString, 256),
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+func buildHandoverRequestAcknowledge(amfUeNgapID, ranUeNgapID int64) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentSuccessfulOutcome,
+		SuccessfulOutcome: &ngapType.SuccessfulOutcome{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeHandoverResourceAllocation},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+		},
+	}
+	pdu.SuccessfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentHandoverRequestAcknowledge
+	msg := &ngapType.HandoverRequestAcknowledge{}
+	pdu.SuccessfulOutcome.Value.HandoverRequestAcknowledge = msg
+
+	ie := ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = &ngapType.AMFUENGAPID{Value: amfUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceAdmittedList
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentPDUSessionResourceAdmittedList
+	ie.Value.PDUSessionResourceAdmittedList = &ngapType.PDUSessionResourceAdmittedList{
+		List: []ngapType.PDUSessionResourceAdmittedItem{{
+			PDUSessionID:                       ngapType.PDUSessionID{Value: 1},
+			HandoverRequestAcknowledgeTransfer: make(aper.OctetString, 24),
+		}},
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDTargetToSourceTransparentContainer
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentTargetToSourceTransparentContainer
+	ie.Value.TargetToSourceTransparentContainer = &ngapType.TargetToSourceTransparentContainer{
+		Value: make(aper.OctetString, 256),
+	}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+func buildHandoverNotify(amfUeNgapID, ranUeNgapID int64) ngapType.NGAPPDU {
+	pdu := ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeHandoverNotification},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+		},
+	}
+	pdu.InitiatingMessage.Value.Present = ngapType.InitiatingMessagePresentHandoverNotify
+	msg := &ngapType.HandoverNotify{}
+	pdu.InitiatingMessage.Value.HandoverNotify = msg
+
+	ie := ngapType.HandoverNotifyIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverNotifyIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = &ngapType.AMFUENGAPID{Value: amfUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverNotifyIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverNotifyIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapID}
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+
+	ie = ngapType.HandoverNotifyIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverNotifyIEsPresentUserLocationInformation
+	uli := benchUserLocationInformation
+	ie.Value.UserLocationInformation = &uli
+	msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ie)
+	return pdu
+}
+
+// registrationMix is the NGAP message sequence of a registration followed by a PDU session establishment
+func registrationMix() []ngapType.NGAPPDU {
+	nasPdu := make([]byte, 64)
+	return []ngapType.NGAPPDU{
+		buildInitialUEMessageForRegistration(1, nasPdu),
+		buildUplinkNASTransport(1, 1, nasPdu),
+		buildUplinkNASTransport(1, 1, nasPdu),
+		buildInitialContextSetupResponse(1, 1),
+		buildUplinkNASTransport(1, 1, nasPdu),
+		buildUplinkNASTransport(1, 1, nasPdu),
+		buildPDUSessionResourceSetupResponse(1, 1),
+	}
+}
+
+// handoverMix is the NGAP message sequence of an N2 handover
+func handoverMix() []ngapType.NGAPPDU {
+	return []ngapType.NGAPPDU{
+		buildHandoverRequired(1, 1),
+		buildHandoverRequestAcknowledge(2, 2),
+		buildHandoverNotify(2, 2),
+	}
+}
+
+func encodeMix(tb testing.TB, pdus []ngapType.NGAPPDU) [][]byte {
+	msgs := make([][]byte, 0, len(pdus))
+	for _, pdu := range pdus {
+		msg, err := ngap.Encoder(pdu)
+		require.NoError(tb, err)
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestNewTask(t *testing.T) {
+	conn := &mockConn{}
+	for _, msg := range encodeMix(t, append(registrationMix(), handoverMix()...)) {
+		task, err := NewTask(conn, msg)
+		require.NoError(t, err)
+		require.NotNil(t, task.PDU)
+		require.Equal(t, conn, task.Conn)
+
+		// the task is keyed on the UE key of the NGAP UE ID and carries the same message
+		// as decoding the raw bytes again
+		ueID, _ := ExtractUEID(msg)
+		if task.PDU.InitiatingMessage != nil &&
+			task.PDU.InitiatingMessage.ProcedureCode.Value == ngapType.ProcedureCodeInitialUEMessage {
+			require.Equal(t, context.InitialUeKey(conn, int64(ueID)), task.UEID)
+			require.Equal(t, TaskClassInitialUe, task.Class)
+		} else {
+			require.Equal(t, context.GetUeAffinity().Key(int64(ueID)), task.UEID)
+			require.Equal(t, TaskClassUe, task.Class)
+		}
+		pdu, err := ngap.Decoder(msg)
+		require.NoError(t, err)
+		require.Equal(t, pdu, task.PDU)
+	}
+
+	// a non-UE message is keyed on the NG-RAN node and queued ahead of the UE traffic
+	msgs := encodeMix(t, []ngapType.NGAPPDU{{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeErrorIndication},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitiatingMessageValue{
+				Present:         ngapType.InitiatingMessagePresentErrorIndication,
+				ErrorIndication: &ngapType.ErrorIndication{},
+			},
+		},
+	}})
+	task, err := NewTask(conn, msgs[0])
+	require.NoError(t, err)
+	require.Equal(t, ranNodeKey(conn), task.UEID)
+	require.Equal(t, TaskClassNonUe, task.Class)
+
+	// a UE-associated Error Indication stays behind the earlier messages of the UE
+	msgs = encodeMix(t, []ngapType.NGAPPDU{{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeErrorIndication},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitiatingMessageValue{
+				Present: ngapType.InitiatingMessagePresentErrorIndication,
+				ErrorIndication: &ngapType.ErrorIndication{
+					ProtocolIEs: ngapType.ProtocolIEContainerErrorIndicationIEs{
+						List: []ngapType.ErrorIndicationIEs{{
+							Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDAMFUENGAPID},
+							Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+							Value: ngapType.ErrorIndicationIEsValue{
+								Present:     ngapType.ErrorIndicationIEsPresentAMFUENGAPID,
+								AMFUENGAPID: &ngapType.AMFUENGAPID{Value: 7},
+							},
+						}},
+					},
+				},
+			},
+		},
+	}})
+	task, err = NewTask(conn, msgs[0])
+	require.NoError(t, err)
+	require.Equal(t, context.GetUeAffinity().Key(7), task.UEID)
+	require.Equal(t, TaskClassUe, task.Class)
+
+	_, err = NewTask(conn, []byte{0xff, 0xff, 0xff})
+	require.Error(t, err)
+}
+
+// BenchmarkDecodePipeline compares decoding each NGAP message once into the task
+// with extracting the UE ID and decoding the message again in the worker
+func BenchmarkDecodePipeline(b *testing.B) {
+	mixes := []struct {
+		name string
+		pdus []ngapType.NGAPPDU
+	}{
+		{"Registration", registrationMix()},
+		{"Handover", handoverMix()},
+	}
+	for _, mix := range mixes {
+		msgs := encodeMix(b, mix.pdus)
+
+		b.Run(mix.name+"/SingleDecode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, msg := range msgs {
+					if _, err := NewTask(nil, msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+		b.Run(mix.name+"/DoubleDecode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, msg := range msgs {
+					ExtractUEID(msg)
+					if _, err := ngap.Decoder(msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package ngap
 import (
 	"encoding/hex"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/gmm"
 	gmm_common "github.com/free5gc/amf/internal/gmm/common"
 	gmm_message "github.com/free5gc/amf/internal/gmm/message"
 	business_metrics "github.com/free5gc/amf/internal/metrics/business"
@@ -14,6 +17,7 @@ import (
 	"github.com/free5gc/amf/internal/nas/nas_security"
 	ngap_message "github.com/free5gc/amf/internal/ngap/message"
 	"github.com/free5gc/amf/internal/sbi/consumer"
+	callback "github.com/free5gc/amf/internal/sbi/processor/notifier"
 	"github.com/free5gc/amf/pkg/factory"
 	"github.com/free5gc/aper"
 	"github.com/free5gc/nas"
@@ -194,17 +198,32 @@ func handleNGResetAcknowledgeMain(ran *context.AmfRan,
 	uEAssociatedLogicalNGConnectionList *ngapType.UEAssociatedLogicalNGConnectionList,
 	criticalityDiagnostics *ngapType.CriticalityDiagnostics,
 ) {
+	// TS 38.413 8.7.4.2.1: the UE associations reset by the NG Reset of the AMF are released once the NG-RAN node
+	// acknowledged it, in sequence with the other non-UE messages of the node
 	if uEAssociatedLogicalNGConnectionList != nil {
 		ran.Log.Tracef("%d UE association(s) has been reset", len(uEAssociatedLogicalNGConnectionList.List))
 		for i, item := range uEAssociatedLogicalNGConnectionList.List {
+			var ranUe *context.RanUe
 			if item.AMFUENGAPID != nil && item.RANUENGAPID != nil {
 				ran.Log.Tracef("%d: AmfUeNgapID[%d] RanUeNgapID[%d]", i+1, item.AMFUENGAPID.Value, item.RANUENGAPID.Value)
+				ranUe = ran.FindRanUeByAmfUeNgapID(item.AMFUENGAPID.Value)
 			} else if item.AMFUENGAPID != nil {
 				ran.Log.Tracef("%d: AmfUeNgapID[%d] RanUeNgapID[-1]", i+1, item.AMFUENGAPID.Value)
+				ranUe = ran.FindRanUeByAmfUeNgapID(item.AMFUENGAPID.Value)
 			} else if item.RANUENGAPID != nil {
 				ran.Log.Tracef("%d: AmfUeNgapID[-1] RanUeNgapID[%d]", i+1, item.RANUENGAPID.Value)
+				ranUe = ran.RanUeFindByRanUeNgapID(item.RANUENGAPID.Value)
+			}
+			if ranUe != nil {
+				removeResetRanUe(ran, ranUe)
 			}
 		}
+	} else {
+		ran.Log.Trace("NG interface has been reset")
+		ran.RanUeList.Range(func(k, v interface{}) bool {
+			removeResetRanUe(ran, v.(*context.RanUe))
+			return true
+		})
 	}
 
 	if criticalityDiagnostics != nil {
@@ -212,6 +231,20 @@ func handleNGResetAcknowledgeMain(ran *context.AmfRan,
 	}
 }
 
+// removeResetRanUe removes the RanUe of a reset UE association, in the event loop of its UE if any so that it is
+// not removed under a procedure of the UE; removed in place if the event loop rejects it
+func removeResetRanUe(ran *context.AmfRan, ranUe *context.RanUe) {
+	remove := func() {
+		if err := ranUe.Remove(); err != nil {
+			ran.Log.Error(err.Error())
+		}
+	}
+	amfUe := ranUe.AmfUe
+	if amfUe == nil || !amfUe.Post(remove) {
+		remove()
+	}
+}
+
 func handleUEContextReleaseCompleteMain(ran *context.AmfRan,
 	ranUe *context.RanUe,
 	userLocationInformation *ngapType.UserLocationInformation,
@@ -240,7 +273,7 @@ func handleUEContextReleaseCompleteMain(ran *context.AmfRan,
 		}
 		return
 	}
-	// TODO: AMF shall, if supported, store it and may use it for subsequent paging
+	// Stored for subsequent paging, see the "recommended" paging step
 	if infoOnRecommendedCellsAndRANNodesForPaging != nil {
 		amfUe.InfoOnRecommendedCellsAndRanNodesForPaging = new(context.InfoOnRecommendedCellsAndRanNodesForPaging)
 
@@ -280,8 +313,8 @@ func handleUEContextReleaseCompleteMain(ran *context.AmfRan,
 			switch item.AMFPagingTarget.Present {
 			case ngapType.AMFPagingTargetPresentGlobalRANNodeID:
 				recommendedRanNode.Present = context.RecommendRanNodePresentRanNode
-				recommendedRanNode.GlobalRanNodeId = new(models.GlobalRanNodeId)
-				// TODO: recommendedRanNode.GlobalRanNodeId = ngapConvert.RanIdToModels(item.AMFPagingTarget.GlobalRANNodeID)
+				ranNodeId := ngapConvert.RanIdToModels(*item.AMFPagingTarget.GlobalRANNodeID)
+				recommendedRanNode.GlobalRanNodeId = &ranNodeId
 			case ngapType.AMFPagingTargetPresentTAI:
 				recommendedRanNode.Present = context.RecommendRanNodePresentTAI
 				tai := ngapConvert.TaiToModels(*item.AMFPagingTarget.TAI)
@@ -516,14 +549,6 @@ func handleInitialUEMessageMain(ran *context.AmfRan,
 		// last Registration Request procedure
 		// Described in TS 23.502 4.2.2.2.2 step 4 (without UDSF deployment)
 		ranUe.Log.Infof("find AmfUe [%q:%q]", idType, id)
-		// TODO: Redesign overlapping ongoing procedures before narrowing this to SMC-vs-N2 handover.
-		if procedure := amfUe.OnGoing(ran.AnType).Procedure; procedure == context.OnGoingProcedureN2Handover {
-			ranUe.Log.Warn("Reject InitialUEMessage because N2 handover is ongoing")
-			gmm_message.SendRegistrationReject(ranUe, nasMessage.Cause5GMMCongestion, "")
-			ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextN2NormalRelease,
-				ngapType.CausePresentNas, ngapType.CauseNasPresentNormalRelease)
-			return
-		}
 		ranUe.Log.Debugf("AmfUe Attach RanUe [RanUeNgapID: %d]", ranUe.RanUeNgapId)
 		ranUe.HoldingAmfUe = amfUe
 	} else if regReqType != nasMessage.RegistrationType5GSInitialRegistration {
@@ -577,7 +602,34 @@ func handleInitialUEMessageMain(ran *context.AmfRan,
 		ran.Log.Errorf("libngap Encoder Error: %+v", err)
 	}
 	ranUe.InitialUEMessage = pdu
-	amf_nas.HandleNAS(ranUe, ngapType.ProcedureCodeInitialUEMessage, nASPDU.Value, true)
+
+	amfUe = ranUe.HoldingAmfUe
+	if amfUe == nil {
+		amf_nas.HandleNAS(ranUe, ngapType.ProcedureCodeInitialUEMessage, nASPDU.Value, true)
+		return
+	}
+	// The message of a known UE is handled in the event loop of the UE, after the pending events of the UE;
+	// the following NGAP messages of the RanUe are handled there too.
+	context.GetUeAffinity().Bind(ranUe.AmfUeNgapId, amfUe.EventKey())
+	if !amfUe.Post(func() {
+		handleInitialUeNas(ran, ranUe, amfUe, nASPDU.Value)
+	}) {
+		ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextN2NormalRelease,
+			ngapType.CausePresentMisc, ngapType.CauseMiscPresentControlProcessingOverload)
+	}
+}
+
+// handleInitialUeNas handles the NAS message of the Initial UE Message of a known UE in the event loop of the UE
+func handleInitialUeNas(ran *context.AmfRan, ranUe *context.RanUe, amfUe *context.AmfUe, nasPdu []byte) {
+	// TODO: Redesign overlapping ongoing procedures before narrowing this to SMC-vs-N2 handover.
+	if procedure := amfUe.OnGoing(ran.AnType).Procedure; procedure == context.OnGoingProcedureN2Handover {
+		ranUe.Log.Warn("Reject InitialUEMessage because N2 handover is ongoing")
+		gmm_message.SendRegistrationReject(ranUe, nasMessage.Cause5GMMCongestion, "")
+		ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextN2NormalRelease,
+			ngapType.CausePresentNas, ngapType.CauseNasPresentNormalRelease)
+		return
+	}
+	amf_nas.HandleNAS(ranUe, ngapType.ProcedureCodeInitialUEMessage, nasPdu, true)
 }
 
 func findAmfUe(ran *context.AmfRan, id, idType string) (*context.AmfUe, bool) {
@@ -601,7 +653,14 @@ func findAmfUe(ran *context.AmfRan, id, idType string) (*context.AmfUe, bool) {
 	case "5G-S-TMSI":
 		id = servedGuami.PlmnId.Mcc + servedGuami.PlmnId.Mnc + ngapConvert.BitStringToHex(&tmpRegionID) + id
 		ran.Log.Debugf("5G-S-TMSI %s", id)
-		amfUe, ok = amfSelf.AmfUeFindByGuti(id)
+		if amfUe, ok = amfSelf.AmfUeFindByGuti(id); !ok {
+			// the UE may be registered with a failed AMF of the set, which this AMF backs up
+			amfUe, ok = amfSelf.TakeOverUe(id)
+		}
+	}
+	if ok && amfUe.OldGuti != "" && strings.EqualFold(amfUe.Guti, id) {
+		// the UE uses its reallocated 5G-GUTI although its acknowledgement was not received (TS 24.501 5.4.4.6)
+		amfUe.ReleaseOldGuti()
 	}
 	return amfUe, ok
 }
@@ -1214,6 +1273,10 @@ func handleHandoverNotifyMain(ran *context.AmfRan,
 ) {
 	targetUe.Log.Info("Handle Handover notification")
 
+	var prevTai models.Tai
+	if targetUe.AmfUe != nil {
+		prevTai = targetUe.AmfUe.Tai
+	}
 	if userLocationInformation != nil {
 		targetUe.UpdateLocation(userLocationInformation)
 	}
@@ -1250,6 +1313,10 @@ func handleHandoverNotifyMain(ran *context.AmfRan,
 			utils.SuccessMetric,
 			business_metrics.HANDOVER_EMPTY_CAUSE, targetUe.HandOverStartTime)
 		gmm_common.AttachRanUeToAmfUeAndReleaseOldHandover(amfUe, sourceUe, targetUe)
+		ngap_message.SendBufferedDownlinkNas(amfUe, targetUe.Ran.AnType)
+		if !reflect.DeepEqual(prevTai, amfUe.Tai) {
+			gmm.ReallocateGutiOnMobility(amfUe, targetUe.Ran.AnType)
+		}
 	}
 
 	// TODO: The UE initiates Mobility Registration Update procedure as described in clause 4.2.2.2.2.
@@ -1313,6 +1380,7 @@ func handlePathSwitchRequestMain(ran *context.AmfRan,
 		ranUe.RanUeNgapId = rANUENGAPID.Value
 	}
 
+	prevTai := amfUe.Tai
 	ranUe.UpdateLocation(userLocationInformation)
 
 	var pduSessionResourceSwitchedList ngapType.PDUSessionResourceSwitchedList
@@ -1397,6 +1465,10 @@ func handlePathSwitchRequestMain(ran *context.AmfRan,
 		}
 		ngap_message.SendPathSwitchRequestAcknowledge(ranUe, pduSessionResourceSwitchedList,
 			pduSessionResourceReleasedListPSAck, false, nil, nil, nil, xnHandoverStartTime)
+		ngap_message.SendBufferedDownlinkNas(amfUe, ran.AnType)
+		if !reflect.DeepEqual(prevTai, amfUe.Tai) {
+			gmm.ReallocateGutiOnMobility(amfUe, ran.AnType)
+		}
 	} else if len(pduSessionResourceReleasedListPSFail.List) > 0 {
 		ngap_message.SendPathSwitchRequestFailure(ran, sourceAMFUENGAPID.Value, rANUENGAPID.Value,
 			&pduSessionResourceReleasedListPSFail, nil, business_metrics.HANDOVER_PDU_SESSION_RES_REL_LIST_ERR,
@@ -1788,6 +1860,10 @@ func handleHandoverCancelMain(ran *context.AmfRan,
 		}
 		ngap_message.SendUEContextReleaseCommand(targetUe, context.UeContextReleaseHandover, causePresent, causeValue)
 		ngap_message.SendHandoverCancelAcknowledge(sourceUe, nil)
+		if amfUe != nil {
+			// the UE stays in the source NG-RAN node
+			ngap_message.SendBufferedDownlinkNas(amfUe, sourceUe.Ran.AnType)
+		}
 	}
 }
 
@@ -1816,12 +1892,81 @@ func handleNASNonDeliveryIndicationMain(ran *context.AmfRan,
 	nASPDU *ngapType.NASPDU,
 	cause *ngapType.Cause,
 ) {
+	causePresent := ngapType.CausePresentNothing
+	var causeValue aper.Enumerated
 	if cause != nil {
-		printAndGetCause(ran, cause)
+		causePresent, causeValue = printAndGetCause(ran, cause)
 	}
 
-	if nASPDU != nil {
-		amf_nas.HandleNAS(ranUe, ngapType.ProcedureCodeNASNonDeliveryIndication, nASPDU.Value, false)
+	if nASPDU == nil {
+		return
+	}
+	amfUe := ranUe.AmfUe
+	if amfUe == nil {
+		ranUe.Log.Warn("Handle NASNonDeliveryIndication: AmfUe is nil")
+		return
+	}
+	// the NAS PDU is the downlink one sent by the AMF, the procedure which sent it is looked up
+	dlNas := ranUe.TakeDlNas(nASPDU.Value)
+	if dlNas == nil {
+		ranUe.Log.Warn("Handle NASNonDeliveryIndication: the NAS PDU was not sent recently, ignore it")
+		return
+	}
+
+	anType := ran.AnType
+	if isHandoverCause(causePresent, causeValue) {
+		ranUe.Log.Infof("%s NAS PDU not delivered due to handover, buffer it until the handover completes",
+			dlNas.Origin.Procedure)
+		amfUe.BufferDlNas(anType, dlNas, func(msgs []*context.DlNasMessage) {
+			amfUe.GmmLog.Warnf("Handover did not complete in %s, %d downlink NAS message(s) not delivered",
+				context.DlNasBufferTimeout, len(msgs))
+			for _, msg := range msgs {
+				failDlNasProcedure(amfUe, anType, msg)
+			}
+		})
+		return
+	}
+	failDlNasProcedure(amfUe, anType, dlNas)
+}
+
+// isHandoverCause reports whether the NG-RAN did not deliver the NAS PDU because of an ongoing handover
+func isHandoverCause(causePresent int, causeValue aper.Enumerated) bool {
+	if causePresent != ngapType.CausePresentRadioNetwork {
+		return false
+	}
+	switch causeValue {
+	case ngapType.CauseRadioNetworkPresentHandoverDesirableForRadioReason,
+		ngapType.CauseRadioNetworkPresentTimeCriticalHandover,
+		ngapType.CauseRadioNetworkPresentResourceOptimisationHandover,
+		ngapType.CauseRadioNetworkPresentPartialHandover,
+		ngapType.CauseRadioNetworkPresentNgIntraSystemHandoverTriggered,
+		ngapType.CauseRadioNetworkPresentNgInterSystemHandoverTriggered,
+		ngapType.CauseRadioNetworkPresentXnHandoverTriggered:
+		return true
+	}
+	return false
+}
+
+// failDlNasProcedure ends the procedure whose downlink NAS PDU could not be delivered to the UE.
+// Other 5GMM procedures are left to their retransmission timers.
+func failDlNasProcedure(amfUe *context.AmfUe, anType models.AccessType, dlNas *context.DlNasMessage) {
+	origin := dlNas.Origin
+	switch origin.Procedure {
+	case context.DlNasProcedureN1N2MessageTransfer:
+		amfUe.GmmLog.Warnf("N1 message of N1N2 Message Transfer (PDU Session ID: %d) not delivered",
+			origin.PduSessionId)
+		callback.SendN1MessageDeliveryFailureNotification(amfUe, origin.FailureNotifyUri, origin.ResourceUri,
+			models.N1N2MessageTransferCause_N1_MSG_NOT_TRANSFERRED)
+	case context.DlNasProcedureConfigurationUpdate:
+		amfUe.GmmLog.Warnf("Configuration Update Command not delivered over %s, abort the procedure", anType)
+		amfUe.AbortConfigurationUpdate()
+	case context.DlNasProcedureDlNasTransport:
+		// the 5GSM message was generated by the AMF itself (e.g. a rejected UL NAS Transport),
+		// there is no procedure left to fail
+		amfUe.GmmLog.Warnf("DL NAS Transport (PDU Session ID: %d) not delivered over %s",
+			origin.PduSessionId, anType)
+	default:
+		amfUe.GmmLog.Warnf("5GMM NAS PDU not delivered over %s", anType)
 	}
 }
 
@@ -2104,10 +2249,19 @@ func handleCellTrafficTraceMain(ran *context.AmfRan,
 	nGRANCGI *ngapType.NGRANCGI,
 	traceCollectionEntityIPAddress *ngapType.TransportLayerAddress,
 ) {
-	if nGRANTraceID != nil {
-		ranUe.Trsr = hex.EncodeToString(nGRANTraceID.Value[6:])
+	record := context.TraceRecord{
+		Timestamp: time.Now(),
+	}
 
-		ranUe.Log.Tracef("TRSR[%s]", ranUe.Trsr)
+	if nGRANTraceID != nil {
+		traceRef, trsr, err := context.TraceReferenceFromNgRanTraceId(*nGRANTraceID)
+		if err != nil {
+			ranUe.Log.Errorf("Handle CellTrafficTrace: %+v", err)
+			return
+		}
+		record.TraceRef = traceRef
+		record.Trsr = trsr
+		ranUe.Log.Tracef("TraceRef[%s] TRSR[%s]", traceRef, trsr)
 	}
 
 	if nGRANCGI != nil {
@@ -2116,10 +2270,18 @@ func handleCellTrafficTraceMain(ran *context.AmfRan,
 			plmnID := ngapConvert.PlmnIdToModels(nGRANCGI.NRCGI.PLMNIdentity)
 			cellID := ngapConvert.BitStringToHex(&nGRANCGI.NRCGI.NRCellIdentity.Value)
 			ranUe.Log.Debugf("NRCGI[plmn: %s, cellID: %s]", plmnID, cellID)
+			record.Ncgi = &models.Ncgi{
+				PlmnId:   &plmnID,
+				NrCellId: cellID,
+			}
 		case ngapType.NGRANCGIPresentEUTRACGI:
 			plmnID := ngapConvert.PlmnIdToModels(nGRANCGI.EUTRACGI.PLMNIdentity)
 			cellID := ngapConvert.BitStringToHex(&nGRANCGI.EUTRACGI.EUTRACellIdentity.Value)
 			ranUe.Log.Debugf("EUTRACGI[plmn: %s, cellID: %s]", plmnID, cellID)
+			record.Ecgi = &models.Ecgi{
+				PlmnId:      &plmnID,
+				EutraCellId: cellID,
+			}
 		}
 	}
 
@@ -2131,13 +2293,55 @@ func handleCellTrafficTraceMain(ran *context.AmfRan,
 		if tceIpv6 != "" {
 			ranUe.Log.Debugf("TCE IP Address[v6: %s]", tceIpv6)
 		}
+		record.TceIpv4Addr = tceIpv4
+		record.TceIpv6Addr = tceIpv6
 	}
 
-	// TODO: TS 32.422 4.2.2.10
+	// TS 32.422 4.2.2.10
 	// When AMF receives this new NG signaling message containing the Trace Recording Session Reference (TRSR)
 	// and Trace Reference (TR), the AMF shall look up the SUPI/IMEI(SV) of the given call from its database and
 	// shall send the SUPI/IMEI(SV) numbers together with the Trace Recording Session Reference and Trace Reference
 	// to the Trace Collection Entity.
+	if amfUe := ranUe.AmfUe; amfUe != nil {
+		record.Supi = amfUe.Supi
+		record.Pei = amfUe.Pei
+	} else {
+		ranUe.Log.Warn("Handle CellTrafficTrace: no UE context, the trace is reported without SUPI/IMEI")
+	}
+
+	amfSelf := context.GetSelf()
+	amfSelf.TraceCollector.Report(record)
+	if amfSelf.TraceCfg != nil && amfSelf.TraceCfg.TceUri != "" {
+		tceUri := amfSelf.TraceCfg.TceUri
+		if !consumer.GetConsumer().ReportCellTrafficTrace(tceUri, &record) {
+			ranUe.Log.Errorf("Report Cell Traffic Trace to TCE[%s] failed: too many pending reports", tceUri)
+		}
+	}
+}
+
+func handleTraceFailureIndicationMain(ran *context.AmfRan,
+	ranUe *context.RanUe,
+	nGRANTraceID *ngapType.NGRANTraceID,
+	cause *ngapType.Cause,
+) {
+	if cause != nil {
+		printAndGetCause(ran, cause)
+	}
+
+	// TS 38.413 8.10.2: the NG-RAN node could not start the trace session (e.g. due to handover),
+	// the trace session is deactivated so that it is activated again on the next setup of the UE context
+	if nGRANTraceID == nil {
+		return
+	}
+	traceRef, trsr, err := context.TraceReferenceFromNgRanTraceId(*nGRANTraceID)
+	if err != nil {
+		ranUe.Log.Errorf("Handle TraceFailureIndication: %+v", err)
+		return
+	}
+	ranUe.Log.Warnf("Trace failed: TraceRef[%s] TRSR[%s]", traceRef, trsr)
+	if ranUe.TraceActivated() && ranUe.Trsr == trsr {
+		ranUe.DeactivateTrace()
+	}
 }
 
 func printAndGetCause(ran *context.AmfRan, cause *ngapType.Cause) (present int, value aper.Enumerated) {
@@ -9758,21 +9758,6 @@ func handlerTraceFailureIndication(ran *context.AmfRan, initiatingMessage *ngapT
 	handleTraceFailureIndicationMain(ran, ranUe, nGRANTraceID /* may be nil */, cause /* may be nil */)
 }
 
-func handleTraceFailureIndicationMain(ran *context.AmfRan, ranUe *context.RanUe, nGRANTraceID *ngapType.NGRANTraceID, cause *ngapType.Cause) {
-	ran.Log.Error("Handle TraceFailureIndication: not implemented")
-	procedureCode := ngapType.ProcedureCodeTraceFailureIndication
-	triggeringMessage := ngapType.TriggeringMessagePresentInitiatingMessage
-	procedureCriticality := ngapType.CriticalityPresentIgnore
-	notImplementedCriticalityDiagnostics := buildCriticalityDiagnostics(&procedureCode, &triggeringMessage, &procedureCriticality, nil)
-	notImplementedCause := &ngapType.Cause{
-		Present: ngapType.CausePresentProtocol,
-		Protocol: &ngapType.CauseProtocol{
-			Value: ngapType.CauseProtocolPresentUnspecified,
-		},
-	}
-	ngap_message.SendErrorIndication(ran, nil, nil, notImplementedCause, &notImplementedCriticalityDiagnostics)
-}
-
 func handlerTraceStart(ran *context.AmfRan, initiatingMessage *ngapType.InitiatingMessage) {
 	var aMFUENGAPID *ngapType.AMFUENGAPID
 	var rANUENGAPID *ngapType.RANUENGAPID
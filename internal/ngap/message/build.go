@@ -124,6 +124,9 @@ func BuildNGSetupResponse(
 		servedGUAMIItem.GUAMI.AMFRegionID.Value = regionId
 		servedGUAMIItem.GUAMI.AMFSetID.Value = setId
 		servedGUAMIItem.GUAMI.AMFPointer.Value = prtId
+		if backupAmfName := amfSelf.BackupAmfName(guami); backupAmfName != "" {
+			servedGUAMIItem.BackupAMFName = &ngapType.AMFName{Value: backupAmfName}
+		}
 		servedGUAMIList.List = append(servedGUAMIList.List, servedGUAMIItem)
 	}
 
@@ -1113,15 +1116,13 @@ func BuildInitialContextSetupRequest(
 	initialContextSetupRequestIEs.List = append(initialContextSetupRequestIEs.List, ie)
 
 	// Trace Activation (optional)
-	if amfUe.TraceData != nil {
+	// TS 32.422 4.2.2.9
+	if amfUe.TraceData != nil && ranUe.TraceActivated() {
 		ie = ngapType.InitialContextSetupRequestIEs{}
 		ie.Id.Value = ngapType.ProtocolIEIDTraceActivation
 		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
 		ie.Value.Present = ngapType.InitialContextSetupRequestIEsPresentTraceActivation
-		ie.Value.TraceActivation = new(ngapType.TraceActivation)
-		// TS 32.422 4.2.2.9
-		// TODO: AMF allocate Trace Recording Session Reference
-		traceActivation := ngapConvert.TraceDataToNgap(*amfUe.TraceData, ranUe.Trsr)
+		traceActivation := ngapConvert.TraceDataToNgap(*ranUe.TraceData, ranUe.Trsr)
 		ie.Value.TraceActivation = &traceActivation
 		initialContextSetupRequestIEs.List = append(initialContextSetupRequestIEs.List, ie)
 	}
@@ -1141,6 +1142,8 @@ func BuildInitialContextSetupRequest(
 	}
 
 	// UE Radio Capability (optional)
+	// NOTE: the UE Radio Capability ID IE (TS 38.413 9.3.1.142) and the UE Radio Capability ID Mapping
+	// procedure are not part of the NGAP codec in use, so RACS is not supported and the capability is sent in full
 	if amfUe.UeRadioCapability != "" {
 		ie = ngapType.InitialContextSetupRequestIEs{}
 		ie.Id.Value = ngapType.ProtocolIEIDUERadioCapability
@@ -1860,6 +1863,17 @@ func BuildHandoverRequest(ue *context.RanUe, cause ngapType.Cause,
 	// handoverRequestIEs.List = append(handoverRequestIEs.List, ie)
 
 	// Trace Activation(optional)
+	// TS 32.422 4.2.2.9: the trace session continues in the target NG-RAN node
+	if amfUe.TraceData != nil && ue.TraceActivated() {
+		ie = ngapType.HandoverRequestIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDTraceActivation
+		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+		ie.Value.Present = ngapType.HandoverRequestIEsPresentTraceActivation
+		traceActivation := ngapConvert.TraceDataToNgap(*ue.TraceData, ue.Trsr)
+		ie.Value.TraceActivation = &traceActivation
+		handoverRequestIEs.List = append(handoverRequestIEs.List, ie)
+	}
+
 	// Masked IMEISV(optional)
 	// Mobility Restriction List(optional)
 	// Location Reporting Request Type(optional)
@@ -2756,8 +2770,60 @@ func BuildDownlinkNonUEAssociatedNRPPATransport(
 	return ngap.Encoder(pdu)
 }
 
-func BuildTraceStart() ([]byte, error) {
+func BuildTraceStart(ranUe *context.RanUe) ([]byte, error) {
+	if !ranUe.TraceActivated() {
+		return nil, fmt.Errorf("no trace is activated for the ranUe")
+	}
+
 	var pdu ngapType.NGAPPDU
+
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeTraceStart
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentIgnore
+
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentTraceStart
+	initiatingMessage.Value.TraceStart = new(ngapType.TraceStart)
+
+	traceStart := initiatingMessage.Value.TraceStart
+	traceStartIEs := &traceStart.ProtocolIEs
+
+	// AMF UE NGAP ID
+	ie := ngapType.TraceStartIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDAMFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.TraceStartIEsPresentAMFUENGAPID
+	ie.Value.AMFUENGAPID = new(ngapType.AMFUENGAPID)
+
+	aMFUENGAPID := ie.Value.AMFUENGAPID
+	aMFUENGAPID.Value = ranUe.AmfUeNgapId
+
+	traceStartIEs.List = append(traceStartIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.TraceStartIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.TraceStartIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = new(ngapType.RANUENGAPID)
+
+	rANUENGAPID := ie.Value.RANUENGAPID
+	rANUENGAPID.Value = ranUe.RanUeNgapId
+
+	traceStartIEs.List = append(traceStartIEs.List, ie)
+
+	// Trace Activation
+	ie = ngapType.TraceStartIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDTraceActivation
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.TraceStartIEsPresentTraceActivation
+	traceActivation := ngapConvert.TraceDataToNgap(*ranUe.TraceData, ranUe.Trsr)
+	ie.Value.TraceActivation = &traceActivation
+
+	traceStartIEs.List = append(traceStartIEs.List, ie)
+
 	return ngap.Encoder(pdu)
 }
 
@@ -2768,6 +2834,9 @@ func BuildDeactivateTrace(amfUe *context.AmfUe, anType models.AccessType) ([]byt
 	if !ok {
 		return nil, fmt.Errorf("ranUe for %s is nil", anType)
 	}
+	if !ranUe.TraceActivated() {
+		return nil, fmt.Errorf("no trace is activated for the ranUe")
+	}
 
 	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
 	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
@@ -2805,44 +2874,20 @@ func BuildDeactivateTrace(amfUe *context.AmfUe, anType models.AccessType) ([]byt
 	rANUENGAPID.Value = ranUe.RanUeNgapId
 
 	deactivateTraceIEs.List = append(deactivateTraceIEs.List, ie)
-	if amfUe.TraceData != nil {
-		// NG-RAN TraceID
-		ie = ngapType.DeactivateTraceIEs{}
-		ie.Id.Value = ngapType.ProtocolIEIDNGRANTraceID
-		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
-		ie.Value.Present = ngapType.DeactivateTraceIEsPresentNGRANTraceID
-		ie.Value.NGRANTraceID = new(ngapType.NGRANTraceID)
 
-		// TODO:composed of the following TS:32.422
-		traceData := *amfUe.TraceData
-		subStringSlice := strings.Split(traceData.TraceRef, "-")
+	// NG-RAN TraceID
+	ie = ngapType.DeactivateTraceIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDNGRANTraceID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.DeactivateTraceIEsPresentNGRANTraceID
+	ie.Value.NGRANTraceID = new(ngapType.NGRANTraceID)
 
-		if len(subStringSlice) != 2 {
-			logger.NgapLog.Warningln("TraceRef format is not correct")
-		}
+	// Trace Reference and the Trace Recording Session Reference of the activated trace session (TS 32.422)
+	traceActivation := ngapConvert.TraceDataToNgap(*ranUe.TraceData, ranUe.Trsr)
+	ie.Value.NGRANTraceID.Value = traceActivation.NGRANTraceID.Value
 
-		plmnID := models.PlmnId{}
-		plmnID.Mcc = subStringSlice[0][:3]
-		plmnID.Mnc = subStringSlice[0][3:]
-		traceID, err := hex.DecodeString(subStringSlice[1])
-		if err != nil {
-			logger.NgapLog.Errorf("[Build Error] DecodeString traceID error: %+v", err)
-		}
+	deactivateTraceIEs.List = append(deactivateTraceIEs.List, ie)
 
-		tmp := ngapConvert.PlmnIdToNgap(plmnID)
-		traceReference := []byte{}
-		traceReference = append(traceReference, tmp.Value...)
-		traceReference = append(traceReference, traceID...)
-		trsr := ranUe.Trsr
-		trsrNgap, err := hex.DecodeString(trsr)
-		if err != nil {
-			logger.NgapLog.Errorf(
-				"[Build Error] DecodeString trsr error: %+v", err)
-		}
-		ie.Value.NGRANTraceID.Value = append(ie.Value.NGRANTraceID.Value, traceReference...)
-		ie.Value.NGRANTraceID.Value = append(ie.Value.NGRANTraceID.Value, trsrNgap...)
-		deactivateTraceIEs.List = append(deactivateTraceIEs.List, ie)
-	}
 	return ngap.Encoder(pdu)
 }
 
@@ -3026,6 +3071,9 @@ func BuildAMFConfigurationUpdate(tNLassociationUsage ngapType.TNLAssociationUsag
 		servedGUAMIItem.GUAMI.AMFRegionID.Value = regionId
 		servedGUAMIItem.GUAMI.AMFSetID.Value = setId
 		servedGUAMIItem.GUAMI.AMFPointer.Value = prtId
+		if backupAmfName := amfSelf.BackupAmfName(guami); backupAmfName != "" {
+			servedGUAMIItem.BackupAMFName = &ngapType.AMFName{Value: backupAmfName}
+		}
 		servedGUAMIList.List = append(servedGUAMIList.List, servedGUAMIItem)
 	}
 
@@ -109,14 +109,19 @@ func AppendPDUSessionResourceToReleaseListRelCmd(list *ngapType.PDUSessionResour
 
 func BuildIEMobilityRestrictionList(ue *context.AmfUe) ngapType.MobilityRestrictionList {
 	mobilityRestrictionList := ngapType.MobilityRestrictionList{}
-	mobilityRestrictionList.ServingPLMN = ngapConvert.PlmnIdToNgap(ue.PlmnId)
+	// the restrictions of the inbound roamer apply in the serving PLMN
+	servingPlmnId := ue.PlmnId
+	if ue.IsInboundRoamer() {
+		servingPlmnId = ue.ServingPlmnId()
+	}
+	mobilityRestrictionList.ServingPLMN = ngapConvert.PlmnIdToNgap(servingPlmnId)
 
 	if ue.AccessAndMobilitySubscriptionData != nil && len(ue.AccessAndMobilitySubscriptionData.RatRestrictions) > 0 {
 		mobilityRestrictionList.RATRestrictions = new(ngapType.RATRestrictions)
 		ratRestrictions := mobilityRestrictionList.RATRestrictions
 		for _, ratType := range ue.AccessAndMobilitySubscriptionData.RatRestrictions {
 			item := ngapType.RATRestrictionsItem{}
-			item.PLMNIdentity = ngapConvert.PlmnIdToNgap(ue.PlmnId)
+			item.PLMNIdentity = ngapConvert.PlmnIdToNgap(servingPlmnId)
 			item.RATRestrictionInformation = ngapConvert.RATRestrictionInformationToNgap(ratType)
 			ratRestrictions.List = append(ratRestrictions.List, item)
 		}
@@ -127,7 +132,7 @@ func BuildIEMobilityRestrictionList(ue *context.AmfUe) ngapType.MobilityRestrict
 		forbiddenAreaInformation := mobilityRestrictionList.ForbiddenAreaInformation
 		for _, info := range ue.AccessAndMobilitySubscriptionData.ForbiddenAreas {
 			item := ngapType.ForbiddenAreaInformationItem{}
-			item.PLMNIdentity = ngapConvert.PlmnIdToNgap(ue.PlmnId)
+			item.PLMNIdentity = ngapConvert.PlmnIdToNgap(servingPlmnId)
 			for _, tac := range info.Tacs {
 				tacBytes, err := hex.DecodeString(tac)
 				if err != nil {
@@ -147,7 +152,7 @@ func BuildIEMobilityRestrictionList(ue *context.AmfUe) ngapType.MobilityRestrict
 		serviceAreaInformation := mobilityRestrictionList.ServiceAreaInformation
 
 		item := ngapType.ServiceAreaInformationItem{}
-		item.PLMNIdentity = ngapConvert.PlmnIdToNgap(ue.PlmnId)
+		item.PLMNIdentity = ngapConvert.PlmnIdToNgap(servingPlmnId)
 		var tacList []ngapType.TAC
 		for _, area := range ue.AmPolicyAssociation.ServAreaRes.Areas {
 			for _, tac := range area.Tacs {
@@ -181,7 +186,10 @@ func BuildUnavailableGUAMIList(guamiList []models.Guami) (unavailableGUAMIList n
 		item.GUAMI.AMFRegionID.Value = regionId
 		item.GUAMI.AMFSetID.Value = setId
 		item.GUAMI.AMFPointer.Value = ptrId
-		// TODO: item.TimerApproachForGUAMIRemoval and item.BackupAMFName not support yet
+		if backupAmfName := context.GetSelf().BackupAmfName(guami); backupAmfName != "" {
+			item.BackupAMFName = &ngapType.AMFName{Value: backupAmfName}
+		}
+		// TODO: item.TimerApproachForGUAMIRemoval not support yet
 		unavailableGUAMIList.List = append(unavailableGUAMIList.List, item)
 	}
 	return
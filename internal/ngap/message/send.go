@@ -1,11 +1,13 @@
 package message
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/amf/internal/logger"
 	business_metrics "github.com/free5gc/amf/internal/metrics/business"
+	amf_ngap_metrics "github.com/free5gc/amf/internal/metrics/ngap"
 	callback "github.com/free5gc/amf/internal/sbi/processor/notifier"
 	"github.com/free5gc/aper"
 	"github.com/free5gc/ngap/ngapType"
@@ -17,6 +19,11 @@ import (
 var emptyCause = ngapType.Cause{Present: 0}
 
 func SendToRan(ran *context.AmfRan, packet []byte) (bool, string) {
+	return sendToRan(ran, nil, packet)
+}
+
+// sendToRan sends the NGAP message to the RAN, of the UE if ranUe is not nil
+func sendToRan(ran *context.AmfRan, ranUe *context.RanUe, packet []byte) (bool, string) {
 	defer func() {
 		// This is workaround.
 		// TODO: Handle ran.Conn close event correctly
@@ -54,6 +61,7 @@ func SendToRan(ran *context.AmfRan, packet []byte) (bool, string) {
 	} else {
 		ran.Log.Debugf("Write %d bytes", n)
 	}
+	context.CaptureNgap(ran, ranUe, context.CaptureDirectionDownlink, time.Now(), packet)
 	return true, ""
 }
 
@@ -74,7 +82,7 @@ func SendToRanUe(ue *context.RanUe, packet []byte) (bool, string) {
 		ue.Log.Warn("AmfUe is nil")
 	}
 
-	return SendToRan(ran, packet)
+	return sendToRan(ran, ue, packet)
 }
 
 func NasSendToRan(ue *context.AmfUe, accessType models.AccessType, packet []byte) (bool, string) {
@@ -181,6 +189,15 @@ func SendNGResetAcknowledge(ran *context.AmfRan, partOfNGInterface *ngapType.UEA
 
 func SendDownlinkNasTransport(ue *context.RanUe, nasPdu []byte,
 	mobilityRestrictionList *ngapType.MobilityRestrictionList,
+) {
+	SendDownlinkNasTransportWithOrigin(ue, nasPdu, mobilityRestrictionList,
+		context.DlNasOrigin{Procedure: context.DlNasProcedureGmm})
+}
+
+// SendDownlinkNasTransportWithOrigin sends the NAS PDU and remembers the procedure it belongs to,
+// which is failed or resumed if the NG-RAN reports it as not delivered
+func SendDownlinkNasTransportWithOrigin(ue *context.RanUe, nasPdu []byte,
+	mobilityRestrictionList *ngapType.MobilityRestrictionList, origin context.DlNasOrigin,
 ) {
 	isDLNASTransportSent := false
 	additionalCause := ""
@@ -206,6 +223,31 @@ func SendDownlinkNasTransport(ue *context.RanUe, nasPdu []byte,
 		return
 	}
 	isDLNASTransportSent, additionalCause = SendToRanUe(ue, pkt)
+	if isDLNASTransportSent {
+		ue.RecordDlNas(&context.DlNasMessage{
+			NasPdu:                  nasPdu,
+			MobilityRestrictionList: mobilityRestrictionList,
+			Origin:                  origin,
+		})
+	}
+}
+
+// SendBufferedDownlinkNas re-sends the downlink NAS PDUs which were not delivered due to the handover,
+// once the UE is reachable through the RanUe again (TS 23.502 4.9.1.2.1, 4.9.1.3.3)
+func SendBufferedDownlinkNas(amfUe *context.AmfUe, anType models.AccessType) {
+	msgs := amfUe.TakeBufferedDlNas(anType)
+	if len(msgs) == 0 {
+		return
+	}
+	ranUe := amfUe.RanUe[anType]
+	if ranUe == nil {
+		amfUe.GmmLog.Errorf("Re-send buffered downlink NAS failed: RanUe of %s is nil", anType)
+		return
+	}
+	ranUe.Log.Infof("Re-send %d buffered downlink NAS message(s)", len(msgs))
+	for _, msg := range msgs {
+		SendDownlinkNasTransportWithOrigin(ranUe, msg.NasPdu, msg.MobilityRestrictionList, msg.Origin)
+	}
 }
 
 func SendPDUSessionResourceReleaseCommand(ue *context.RanUe, nasPdu []byte,
@@ -489,6 +531,8 @@ func SendInitialContextSetupRequest(
 		}
 	}
 
+	activateTrace(amfUe.RanUe[anType], amfUe)
+
 	pkt, err := BuildInitialContextSetupRequest(amfUe, anType, nasPdu, pduSessionResourceSetupRequestList,
 		rrcInactiveTransitionReportRequest, coreNetworkAssistanceInfo, emergencyFallbackIndicator)
 	if err != nil {
@@ -696,6 +740,9 @@ func SendHandoverRequest(sourceUe *context.RanUe, targetRan *context.AmfRan, cau
 	}
 
 	context.AttachSourceUeTargetUe(sourceUe, targetUe)
+	if targetUe != nil {
+		activateTrace(targetUe, amfUe)
+	}
 
 	pkt, err := BuildHandoverRequest(targetUe, cause, pduSessionResourceSetupListHOReq,
 		sourceToTargetTransparentContainer, nsci)
@@ -842,54 +889,40 @@ func SendDownlinkRanStatusTransfer(ue *context.RanUe, container ngapType.RANStat
 // is associated with non-3GPP access, the AMF sends a Paging message with associated access "non-3GPP" to
 // NG-RAN node(s) via 3GPP access.
 // more paging policy with 3gpp/non-3gpp access is described in TS 23.501 5.6.8
+// The paged RAN nodes follow the configured paging strategy (see AmfUe.PagingTargets): each T3513
+// retransmission moves to the next, wider, paging step.
 func SendPaging(ue *context.AmfUe, ngapBuf []byte) {
 	isPagingSent := false
 	additionalCause := ""
 	defer ngap_metrics.IncrMetricsSentMsg(ngap_metrics.PAGING, &isPagingSent, emptyCause, &additionalCause)
 
-	// var pagingPriority *ngapType.PagingPriority
 	if ue == nil {
 		additionalCause = ngap_metrics.AMF_UE_NIL_ERR
 		logger.NgapLog.Error("AmfUe is nil")
 		return
 	}
 
-	// if ppi != nil {
-	// pagingPriority = new(ngapType.PagingPriority)
-	// pagingPriority.Value = aper.Enumerated(*ppi)
-	// }
-	// pkt, err := BuildPaging(ue, pagingPriority, pagingOriginNon3GPP)
-	// if err != nil {
-	// 	ngaplog.Errorf("Build Paging failed : %s", err.Error())
-	// }
-	taiList := ue.RegistrationArea[models.AccessType__3_GPP_ACCESS]
-	context.GetSelf().AmfRanPool.Range(func(key, value interface{}) bool {
-		ran := value.(*context.AmfRan)
-		for _, item := range ran.SupportedTAList {
-			if context.InTaiList(item.Tai, taiList) {
-				ue.GmmLog.Infof("Send Paging to TAI(%+v, Tac:%+v)", item.Tai.PlmnId, item.Tai.Tac)
-				isPagingSent, additionalCause = SendToRan(ran, ngapBuf)
-				break
-			}
+	sendPagingToTargets := func(attempt int) {
+		step, rans := ue.PagingTargets(attempt)
+		if len(rans) == 0 {
+			additionalCause = ngap_metrics.RAN_NIL_ERR
+			ue.GmmLog.Warnf("No RAN node found to page the UE (attempt: %d)", attempt)
+			return
+		}
+		for _, ran := range rans {
+			ue.GmmLog.Infof("Send Paging to RAN[%s] (step: %s, attempt: %d)", ran.RanID(), step, attempt)
+			isPagingSent, additionalCause = SendToRan(ran, ngapBuf)
 		}
-		return true
-	})
+	}
+
+	sendPagingToTargets(0)
 
 	if context.GetSelf().T3513Cfg.Enable {
 		cfg := context.GetSelf().T3513Cfg
 		ue.GmmLog.Infof("Start T3513 timer")
-		ue.T3513 = context.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
+		ue.T3513 = ue.NewTimer(cfg.ExpireTime, cfg.MaxRetryTimes, func(expireTimes int32) {
 			ue.GmmLog.Warnf("T3513 expires, retransmit Paging (retry: %d)", expireTimes)
-			context.GetSelf().AmfRanPool.Range(func(key, value interface{}) bool {
-				ran := value.(*context.AmfRan)
-				for _, item := range ran.SupportedTAList {
-					if context.InTaiList(item.Tai, taiList) {
-						isPagingSent, additionalCause = SendToRan(ran, ngapBuf)
-						break
-					}
-				}
-				return true
-			})
+			sendPagingToTargets(int(expireTimes))
 		}, func() {
 			ue.GmmLog.Warnf("T3513 expires %d times, abort paging procedure", cfg.MaxRetryTimes)
 			ue.T3513 = nil // clear the timer
@@ -1182,6 +1215,78 @@ func SendDeactivateTrace(amfUe *context.AmfUe, anType models.AccessType) {
 	}
 
 	isDeactivateTraceSent, additionalCause = SendToRanUe(ranUe, pkt)
+	if isDeactivateTraceSent {
+		ranUe.DeactivateTrace()
+	}
+}
+
+// activateTrace starts the trace session of the traced AmfUe on the RanUe, so that the Trace Activation IE
+// of the message about to be built carries its Trace Recording Session Reference (TS 32.422 4.2.2.9)
+func activateTrace(ranUe *context.RanUe, amfUe *context.AmfUe) {
+	if ranUe == nil || amfUe.TraceData == nil {
+		return
+	}
+	if err := ranUe.ActivateTrace(amfUe.TraceData); err != nil {
+		ranUe.Log.Warnf("Trace Activation is not included: %+v", err)
+	}
+}
+
+// SendTraceUpdate aligns the trace sessions in the NG-RAN with the trace data of the AmfUe: a trace removed from
+// (or replaced in) the subscription is deactivated and the current one is started on each access where the UE
+// context is set up in the NG-RAN. Other accesses get the trace activated by Initial Context Setup.
+func SendTraceUpdate(amfUe *context.AmfUe) {
+	for anType, ranUe := range amfUe.RanUe {
+		if ranUe == nil || !ranUe.InitialContextSetup {
+			continue
+		}
+		if ranUe.TraceActivated() {
+			if amfUe.TraceData != nil && reflect.DeepEqual(*amfUe.TraceData, *ranUe.TraceData) {
+				continue
+			}
+			SendDeactivateTrace(amfUe, anType)
+		}
+		if amfUe.TraceData != nil {
+			SendTraceStart(amfUe, anType)
+		}
+	}
+}
+
+// SendTraceStart activates the subscriber/equipment trace of the AmfUe in the NG-RAN node
+// while the UE is already connected (TS 32.422 4.2.2.9)
+func SendTraceStart(amfUe *context.AmfUe, anType models.AccessType) {
+	isTraceStartSent := false
+	additionalCause := ""
+	defer ngap_metrics.IncrMetricsSentMsg(amf_ngap_metrics.TRACE_START, &isTraceStartSent, emptyCause, &additionalCause)
+
+	if amfUe == nil {
+		additionalCause = ngap_metrics.AMF_UE_NIL_ERR
+		logger.NgapLog.Error("AmfUe is nil")
+		return
+	}
+
+	ranUe := amfUe.RanUe[anType]
+	if ranUe == nil {
+		additionalCause = ngap_metrics.RAN_UE_NIL_ERR
+		logger.NgapLog.Error("RanUe is nil")
+		return
+	}
+
+	ranUe.Log.Info("Send Trace Start")
+
+	if err := ranUe.ActivateTrace(amfUe.TraceData); err != nil {
+		additionalCause = ngap_metrics.NGAP_MSG_BUILD_ERR
+		ranUe.Log.Errorf("Activate trace failed : %s", err.Error())
+		return
+	}
+
+	pkt, err := BuildTraceStart(ranUe)
+	if err != nil {
+		additionalCause = ngap_metrics.NGAP_MSG_BUILD_ERR
+		ranUe.Log.Errorf("Build TraceStart failed : %s", err.Error())
+		return
+	}
+
+	isTraceStartSent, additionalCause = SendToRanUe(ranUe, pkt)
 }
 
 // AOI List is from SMF
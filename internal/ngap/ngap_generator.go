@@ -562,7 +562,6 @@ syntaxCause = &ngapType.Cause{
 			msgName == "PWSFailureIndication" || // XXX not implemented
 			msgName == "PWSRestartIndication" || // XXX not implemented
 			msgName == "SecondaryRATDataUsageReport" || // XXX not implemented
-			msgName == "TraceFailureIndication" || // XXX not implemented
 			msgName == "WriteReplaceWarningResponse" { // XXX not implemented
 			stubCause := "CauseProtocolPresentUnspecified"
 			stubMessage := "not implemented"
@@ -1,40 +1,127 @@
 package ngap
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/amf/internal/logger"
+	business_metrics "github.com/free5gc/amf/internal/metrics/business"
+	"github.com/free5gc/ngap/ngapType"
 )
 
+// TaskClass is the class of a task, which decides how the task is admitted in the worker queue
+type TaskClass int
+
+const (
+	// TaskClassUe is an NGAP message associated to a UE known to the AMF
+	TaskClassUe TaskClass = iota
+	// TaskClassInitialUe is an Initial UE Message, from a UE not known to the AMF yet
+	TaskClassInitialUe
+	// TaskClassNonUe is an NGAP message not associated to a UE (NG Setup, NG Reset...) or an Error Indication,
+	// queued ahead of the UE traffic
+	TaskClassNonUe
+	// TaskClassUeEvent is a UE event
+	TaskClassUeEvent
+	// TaskClassUeRelease is a UE Context Release Complete, which releases the UE resources
+	TaskClassUeRelease
+	// TaskClassUeTimer is the expiry or the cancel of a UE timer
+	TaskClassUeTimer
+	numTaskClasses
+)
+
+// mandatory tells whether the tasks of the class are never dropped: they wait until there is room in the queue,
+// whatever the admission policy
+func (c TaskClass) mandatory() bool {
+	return c == TaskClassUeRelease || c == TaskClassUeTimer
+}
+
+func (c TaskClass) String() string {
+	switch c {
+	case TaskClassUe:
+		return "ue"
+	case TaskClassInitialUe:
+		return "initial-ue"
+	case TaskClassNonUe:
+		return "non-ue"
+	case TaskClassUeEvent:
+		return "ue-event"
+	case TaskClassUeRelease:
+		return "ue-release"
+	case TaskClassUeTimer:
+		return "ue-timer"
+	default:
+		return fmt.Sprintf("TaskClass(%d)", int(c))
+	}
+}
+
 // Task represents a work item to be processed by a worker.
-// It contains the UE identifier and the raw NGAP message.
+// It contains the UE key and the NGAP message, decoded once when the task is created.
 type Task struct {
-	UEID    uint64   // AMF-UE-NGAP-ID or RAN-UE-NGAP-ID
-	Conn    net.Conn // The network connection for this message
-	Message []byte   // The raw NGAP message bytes
+	UEID    uint64            // The UE key (see context.UeAffinity), or the NG-RAN node key for non-UE messages
+	Class   TaskClass         // How the task is admitted in the worker queue
+	Conn    net.Conn          // The network connection for this message
+	Message []byte            // The raw NGAP message bytes
+	PDU     *ngapType.NGAPPDU // The decoded message, nil if the message has not been decoded yet
+	Event   func()            // A UE event (SBI request, timer expiry) to run instead of an NGAP message
+
+	admitted time.Time // When the task was queued
+}
+
+// TaskHandler processes a task in the worker it was dispatched to.
+type TaskHandler func(task Task)
+
+// AdmissionPolicy decides whether a task is admitted in the worker queue, so that a full queue does not block
+// the SCTP association the message is read from.
+type AdmissionPolicy struct {
+	// MaxWait is how long the task waits for room in a full queue; 0 rejects it right away
+	MaxWait time.Duration
+	// ShedThreshold rejects the task once the queue is filled above this percent of its size; 0 disables it
+	ShedThreshold int
+}
+
+// Reasons a task is not admitted in the worker queue
+var (
+	ErrTaskQueueFull = errors.New("queue-full")
+	ErrTaskShed      = errors.New("shed")
+	ErrWorkerStopped = errors.New("stopped")
+)
+
+// WorkerStats is a snapshot of the load of a worker
+type WorkerStats struct {
+	ID            int
+	Depth         int           // Number of UE tasks waiting in the queue
+	PriorityDepth int           // Number of non-UE tasks waiting in the priority queue
+	Capacity      int           // Size of each queue
+	LastWait      time.Duration // Time the last processed task waited in the queue
 }
 
 // Worker represents a goroutine that processes tasks from its dedicated queue.
 type Worker struct {
-	ID       int
-	taskChan chan Task
-	stopChan chan struct{} // Signal channel for shutdown
-	stopOnce sync.Once     // Ensures stopChan is closed only once
-	handler  func(conn net.Conn, msg []byte)
-	wg       *sync.WaitGroup
+	ID           int
+	taskChan     chan Task
+	priorityChan chan Task     // Non-UE tasks, processed ahead of taskChan
+	stopChan     chan struct{} // Signal channel for shutdown
+	stopOnce     sync.Once     // Ensures stopChan is closed only once
+	handler      TaskHandler
+	wg           *sync.WaitGroup
+	lastWait     int64 // nanoseconds, accessed atomically
 }
 
 // NewWorker creates and starts a new worker goroutine.
-func NewWorker(id int, bufferSize int, handler func(conn net.Conn, msg []byte), wg *sync.WaitGroup) *Worker {
+func NewWorker(id int, bufferSize int, handler TaskHandler, wg *sync.WaitGroup) *Worker {
 	w := &Worker{
-		ID:       id,
-		taskChan: make(chan Task, bufferSize),
-		stopChan: make(chan struct{}),
-		handler:  handler,
-		wg:       wg,
+		ID:           id,
+		taskChan:     make(chan Task, bufferSize),
+		priorityChan: make(chan Task, bufferSize),
+		stopChan:     make(chan struct{}),
+		handler:      handler,
+		wg:           wg,
 	}
 	wg.Add(1)
 	go w.run()
@@ -52,11 +139,22 @@ func (w *Worker) run() {
 	logger.NgapLog.Infof("Worker %d started", w.ID)
 
 	for {
+		// Non-UE tasks go first
 		select {
+		case task := <-w.priorityChan:
+			w.process(task)
+			continue
+		default:
+		}
+
+		select {
+		case task := <-w.priorityChan:
+			w.process(task)
+
 		case task := <-w.taskChan:
 			logger.NgapLog.Debugf("Worker %d processing task for UE ID %d (ensuring per-UE sequentiality)",
 				w.ID, task.UEID)
-			w.handler(task.Conn, task.Message)
+			w.process(task)
 
 		case <-w.stopChan:
 			logger.NgapLog.Infof("Worker %d: shutdown signal received, draining queue...", w.ID)
@@ -70,28 +168,94 @@ func (w *Worker) run() {
 func (w *Worker) drainAndExit() {
 	for {
 		select {
-		case task := <-w.taskChan:
-			logger.NgapLog.Debugf("Worker %d processing residual task for UE ID %d", w.ID, task.UEID)
-			w.handler(task.Conn, task.Message)
+		case task := <-w.priorityChan:
+			logger.NgapLog.Debugf("Worker %d processing residual non-UE task", w.ID)
+			w.process(task)
 		default:
-			// Channel is empty, exit safely
-			logger.NgapLog.Infof("Worker %d: queue drained, stopped.", w.ID)
-			return
+			select {
+			case task := <-w.taskChan:
+				logger.NgapLog.Debugf("Worker %d processing residual task for UE ID %d", w.ID, task.UEID)
+				w.process(task)
+			default:
+				// Channel is empty, exit safely
+				logger.NgapLog.Infof("Worker %d: queue drained, stopped.", w.ID)
+				return
+			}
 		}
 	}
 }
 
-// Submit submits a task to this worker's queue.
-// Returns true if the task was successfully queued, false if the worker is stopped.
-func (w *Worker) Submit(task Task) bool {
+// process runs the UE event of the task, or hands the NGAP message to the handler.
+func (w *Worker) process(task Task) {
+	start := time.Now()
+	wait := start.Sub(task.admitted)
+	atomic.StoreInt64(&w.lastWait, int64(wait))
+	defer func() {
+		business_metrics.ObserveNgapWorkerTask(w.ID, wait, time.Since(start))
+		business_metrics.SetNgapWorkerQueueDepth(w.ID, len(w.taskChan)+len(w.priorityChan))
+	}()
+
+	if task.Event != nil {
+		task.Event()
+		return
+	}
+	w.handler(task)
+}
+
+// Submit queues the task in this worker's queue according to the admission policy.
+// Returns nil if the task was queued, or why it was not.
+func (w *Worker) Submit(task Task, policy AdmissionPolicy) error {
+	queue := w.taskChan
+	if task.Class == TaskClassNonUe {
+		queue = w.priorityChan
+	}
+
+	select {
+	case <-w.stopChan:
+		return ErrWorkerStopped
+	default:
+	}
+	if policy.ShedThreshold > 0 && cap(queue) > 0 && len(queue)*100 >= cap(queue)*policy.ShedThreshold {
+		return ErrTaskShed
+	}
+
+	task.admitted = time.Now()
+	select {
+	case queue <- task:
+		business_metrics.SetNgapWorkerQueueDepth(w.ID, len(w.taskChan)+len(w.priorityChan))
+		return nil
+	default:
+	}
+	if policy.MaxWait == 0 {
+		return ErrTaskQueueFull
+	}
+
+	var timeout <-chan time.Time
+	if policy.MaxWait > 0 {
+		timer := time.NewTimer(policy.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 	select {
-	case w.taskChan <- task:
-		// Successfully queued (blocks here if buffer is full, providing backpressure)
-		return true
+	case queue <- task:
+		business_metrics.SetNgapWorkerQueueDepth(w.ID, len(w.taskChan)+len(w.priorityChan))
+		return nil
+	case <-timeout:
+		return ErrTaskQueueFull
 	case <-w.stopChan:
-		// Worker stopped (either before submission or while waiting). Unblock and return false.
-		logger.NgapLog.Warnf("Worker %d stopped, rejecting task for UE ID %d", w.ID, task.UEID)
-		return false
+		// Worker stopped while waiting for room
+		return ErrWorkerStopped
+	}
+}
+
+// Stats returns a snapshot of the load of the worker.
+func (w *Worker) Stats() WorkerStats {
+	return WorkerStats{
+		ID:            w.ID,
+		Depth:         len(w.taskChan),
+		PriorityDepth: len(w.priorityChan),
+		Capacity:      cap(w.taskChan),
+		LastWait:      time.Duration(atomic.LoadInt64(&w.lastWait)),
 	}
 }
 
@@ -107,10 +271,12 @@ type UEScheduler struct {
 	workers    []*Worker
 	numWorkers int
 	wg         sync.WaitGroup
+	// The admission policy of each task class; a task of a class without policy waits until there is room
+	admission [numTaskClasses]*AdmissionPolicy
 }
 
 // NewUEScheduler creates a new UE scheduler with the specified number of workers.
-func NewUEScheduler(numWorkers int, taskBufferSize int, handler func(conn net.Conn, msg []byte)) *UEScheduler {
+func NewUEScheduler(numWorkers int, taskBufferSize int, handler TaskHandler) *UEScheduler {
 	if numWorkers <= 0 {
 		numWorkers = runtime.NumCPU()
 	}
@@ -129,14 +295,74 @@ func NewUEScheduler(numWorkers int, taskBufferSize int, handler func(conn net.Co
 	return scheduler
 }
 
-// DispatchTask dispatches a task to the appropriate worker based on UE ID hashing.
-func (s *UEScheduler) DispatchTask(task Task) bool {
+// SetAdmissionPolicy sets the admission policy of a task class. It must be set before tasks are dispatched.
+// The mandatory task classes have no admission policy.
+func (s *UEScheduler) SetAdmissionPolicy(class TaskClass, policy AdmissionPolicy) {
+	if class < 0 || class >= numTaskClasses || class.mandatory() {
+		return
+	}
+	s.admission[class] = &policy
+}
+
+// Admit queues the task in the worker of its UE ID according to the admission policy of its class.
+// Returns nil if the task was queued, or why it was not; every task not queued is logged and counted.
+func (s *UEScheduler) Admit(task Task) error {
 	workerIndex := s.hashUEID(task.UEID)
 	worker := s.workers[workerIndex]
 
+	policy := AdmissionPolicy{MaxWait: -1}
+	if task.Class >= 0 && task.Class < numTaskClasses && !task.Class.mandatory() && s.admission[task.Class] != nil {
+		policy = *s.admission[task.Class]
+	}
+
 	logger.NgapLog.Debugf("Dispatching UE ID %d to Worker %d (hash-based routing)",
 		task.UEID, workerIndex)
-	return worker.Submit(task)
+	err := worker.Submit(task, policy)
+	if err != nil {
+		business_metrics.IncrNgapSchedulerRejectedCounter(task.Class.String(), err.Error())
+		if task.Class.mandatory() {
+			logger.NgapLog.Errorf("Drop %s task for UE ID %d (%v)", task.Class, task.UEID, err)
+		} else {
+			logger.NgapLog.Warnf("Drop %s task for UE ID %d (%v)", task.Class, task.UEID, err)
+		}
+	}
+	return err
+}
+
+// DispatchTask dispatches a task to the appropriate worker based on UE ID hashing.
+// Returns false if the task was not admitted.
+func (s *UEScheduler) DispatchTask(task Task) bool {
+	return s.Admit(task) == nil
+}
+
+// Post dispatches a UE event to the worker of the UE key, so that it runs in sequence with the NGAP messages
+// and the other events of the UE. It implements context.UeEventLoop. An event shed or rejected by the admission
+// policy is not queued and false is returned; the caller drops it, as running it elsewhere breaks the sequence.
+func (s *UEScheduler) Post(key uint64, event func()) bool {
+	return s.Admit(Task{
+		UEID:  key,
+		Class: TaskClassUeEvent,
+		Event: event,
+	}) == nil
+}
+
+// PostTimer is Post for the events of the UE timers, which wait for room in the queue rather than being dropped.
+// It only returns false once the worker is stopped.
+func (s *UEScheduler) PostTimer(key uint64, event func()) bool {
+	return s.Admit(Task{
+		UEID:  key,
+		Class: TaskClassUeTimer,
+		Event: event,
+	}) == nil
+}
+
+// Stats returns a snapshot of the load of each worker, for overload decisions.
+func (s *UEScheduler) Stats() []WorkerStats {
+	stats := make([]WorkerStats, 0, len(s.workers))
+	for _, worker := range s.workers {
+		stats = append(stats, worker.Stats())
+	}
+	return stats
 }
 
 // hashUEID computes a hash of the UE ID and maps it to a worker index.
@@ -167,7 +393,9 @@ var (
 
 // InitScheduler initializes the global UE scheduler.
 // Should be called once during AMF startup.
-func InitScheduler(numWorkers int, taskBufferSize int, handler func(conn net.Conn, msg []byte)) {
+func InitScheduler(numWorkers int, taskBufferSize int, admission map[TaskClass]AdmissionPolicy,
+	handler TaskHandler,
+) {
 	globalSchedulerOnce.Do(func() {
 		// Apply sensible defaults if invalid values provided
 		if numWorkers <= 0 {
@@ -181,6 +409,11 @@ func InitScheduler(numWorkers int, taskBufferSize int, handler func(conn net.Con
 		defer schedulerMutex.Unlock()
 
 		globalScheduler = NewUEScheduler(numWorkers, taskBufferSize, handler)
+		for class, policy := range admission {
+			globalScheduler.SetAdmissionPolicy(class, policy)
+		}
+		// SBI requests and timer expiries of a UE run on the worker of its NGAP messages
+		context.SetUeEventLoop(globalScheduler)
 		logger.NgapLog.Infof("Global UE Scheduler initialized with %d workers, buffer size %d",
 			numWorkers, taskBufferSize)
 	})
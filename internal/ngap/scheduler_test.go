@@ -9,6 +9,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
 )
 
 // Mock connection for testing
@@ -28,7 +31,7 @@ func (m *mockConn) SetWriteDeadline(t time.Time) error { return nil }
 func TestHashUEID_Consistency(t *testing.T) {
 	// Test that the same UE ID always hashes to the same worker
 	numWorkers := 8
-	scheduler := NewUEScheduler(numWorkers, 100, func(conn net.Conn, msg []byte) {})
+	scheduler := NewUEScheduler(numWorkers, 100, func(task Task) {})
 	defer scheduler.Shutdown()
 
 	testUEIDs := []uint64{1, 100, 1000, 12345, 67890, 999999}
@@ -49,7 +52,7 @@ func TestHashUEID_Consistency(t *testing.T) {
 func TestHashUEID_Distribution(t *testing.T) {
 	// Test that UE IDs are distributed evenly across workers
 	numWorkers := 8
-	scheduler := NewUEScheduler(numWorkers, 100, func(conn net.Conn, msg []byte) {})
+	scheduler := NewUEScheduler(numWorkers, 100, func(task Task) {})
 	defer scheduler.Shutdown()
 
 	// Generate a large number of UE IDs
@@ -84,7 +87,7 @@ func TestHashUEID_Distribution(t *testing.T) {
 func TestHashUEID_Range(t *testing.T) {
 	// Test that hash function always returns valid worker index
 	numWorkers := 8
-	scheduler := NewUEScheduler(numWorkers, 100, func(conn net.Conn, msg []byte) {})
+	scheduler := NewUEScheduler(numWorkers, 100, func(task Task) {})
 	defer scheduler.Shutdown()
 
 	// Test with various UE IDs
@@ -117,7 +120,7 @@ func TestScheduler_ConcurrentTaskSubmission(t *testing.T) {
 	var processingWg sync.WaitGroup
 	processingWg.Add(numGoroutines * tasksPerGoroutine)
 
-	handler := func(conn net.Conn, msg []byte) {
+	handler := func(task Task) {
 		atomic.AddInt32(&processedCount, 1)
 		processingWg.Done()
 	}
@@ -183,9 +186,9 @@ func TestScheduler_PerUESequentiality(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(numMessages)
 
-	handler := func(conn net.Conn, msg []byte) {
+	handler := func(task Task) {
 		// Extract message sequence number from message
-		seqNum := int(msg[0])
+		seqNum := int(task.Message[0])
 		mu.Lock()
 		processedOrder = append(processedOrder, seqNum)
 		mu.Unlock()
@@ -231,9 +234,9 @@ func TestScheduler_MultipleUEsConcurrent(t *testing.T) {
 	var processingWg sync.WaitGroup
 	processingWg.Add(numUEs * messagesPerUE)
 
-	handler := func(conn net.Conn, msg []byte) {
-		ueID := uint64(msg[0])
-		seqNum := int(msg[1])
+	handler := func(task Task) {
+		ueID := uint64(task.Message[0])
+		seqNum := int(task.Message[1])
 
 		mu.Lock()
 		processedByUE[ueID] = append(processedByUE[ueID], seqNum)
@@ -292,7 +295,7 @@ func TestScheduler_GracefulShutdown(t *testing.T) {
 	var processingWg sync.WaitGroup
 	processingWg.Add(numTasks)
 
-	handler := func(conn net.Conn, msg []byte) {
+	handler := func(task Task) {
 		atomic.AddInt32(&processedCount, 1)
 		time.Sleep(10 * time.Millisecond)
 		processingWg.Done()
@@ -338,7 +341,7 @@ func TestScheduler_WorkerCount(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			scheduler := NewUEScheduler(tc.numWorkers, 100,
-				func(conn net.Conn, msg []byte) {})
+				func(task Task) {})
 			defer scheduler.Shutdown()
 
 			actualCount := len(scheduler.workers)
@@ -362,7 +365,7 @@ func TestScheduler_NonUEMessage(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(numMessages)
 
-	handler := func(conn net.Conn, msg []byte) {
+	handler := func(task Task) {
 		atomic.AddInt32(&processedCount, 1)
 		wg.Done()
 	}
@@ -396,3 +399,189 @@ func TestScheduler_NonUEMessage(t *testing.T) {
 		"All non-UE messages should be processed")
 	t.Logf("Non-UE messages routed to worker %d", expectedWorkerIndex)
 }
+
+func TestScheduler_UeEvents(t *testing.T) {
+	ue := &amf_context.AmfUe{}
+	// not synchronized: the NGAP messages and the events of the UE must never run concurrently
+	var handled []int
+
+	scheduler := NewUEScheduler(4, 100, func(task Task) {
+		handled = append(handled, int(task.Message[0]))
+	})
+	defer scheduler.Shutdown()
+	amf_context.SetUeEventLoop(scheduler)
+	defer amf_context.SetUeEventLoop(nil)
+
+	const numProducers = 8
+	var wg sync.WaitGroup
+	wg.Add(numProducers)
+	for p := 0; p < numProducers; p++ {
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if p%2 == 0 {
+					scheduler.DispatchTask(Task{UEID: ue.EventKey(), Conn: &mockConn{}, Message: []byte{byte(p)}})
+				} else {
+					ue.Run(func() { handled = append(handled, p) })
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// the events of one producer run in posting order, after the earlier ones
+	for i := 100; i < 110; i++ {
+		ue.Post(func() { handled = append(handled, i) })
+	}
+	var total int
+	ue.Run(func() { total = len(handled) })
+	require.Equal(t, numProducers*10+10, total)
+	assert.Equal(t, []int{100, 101, 102, 103, 104, 105, 106, 107, 108, 109}, handled[numProducers*10:])
+}
+
+func TestScheduler_UeTimerStopped(t *testing.T) {
+	ue := &amf_context.AmfUe{}
+
+	scheduler := NewUEScheduler(1, 100, func(task Task) {})
+	defer scheduler.Shutdown()
+	amf_context.SetUeEventLoop(scheduler)
+	defer amf_context.SetUeEventLoop(nil)
+
+	// block the event loop of the UE until the timer has expired and been canceled
+	release := make(chan struct{})
+	ue.Post(func() { <-release })
+
+	var fired int32
+	timer := ue.NewTimer(10*time.Millisecond, 1, func(expireTimes int32) {
+		atomic.AddInt32(&fired, 1)
+	}, func() {
+		atomic.AddInt32(&fired, 1)
+	})
+	time.Sleep(100 * time.Millisecond)
+	timer.Stop()
+	close(release)
+
+	// the expiry and cancel events queued before the stop are dropped
+	ue.Run(func() {})
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fired))
+}
+
+func TestScheduler_UeEventRejected(t *testing.T) {
+	ue := &amf_context.AmfUe{}
+	ue.ProducerLog = logger.ProducerLog
+
+	scheduler := NewUEScheduler(1, 1, func(task Task) {})
+	defer scheduler.Shutdown()
+	scheduler.SetAdmissionPolicy(TaskClassUeEvent, AdmissionPolicy{MaxWait: 10 * time.Millisecond})
+	amf_context.SetUeEventLoop(scheduler)
+	defer amf_context.SetUeEventLoop(nil)
+
+	// block the event loop of the UE and fill its queue
+	release := make(chan struct{})
+	defer close(release)
+	require.True(t, ue.Post(func() { <-release }))
+	require.Eventually(t, func() bool { return scheduler.Stats()[0].Depth == 0 }, time.Second, time.Millisecond)
+	require.True(t, ue.Post(func() {}))
+
+	// the events not admitted are dropped, never run in place
+	var ran int32
+	assert.False(t, ue.Post(func() { atomic.AddInt32(&ran, 1) }))
+	assert.ErrorIs(t, ue.Run(func() { atomic.AddInt32(&ran, 1) }), amf_context.ErrUeEventRejected)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+}
+
+func TestScheduler_Admission(t *testing.T) {
+	release := make(chan struct{})
+	scheduler := NewUEScheduler(1, 4, func(task Task) {})
+	defer scheduler.Shutdown()
+	scheduler.SetAdmissionPolicy(TaskClassInitialUe, AdmissionPolicy{ShedThreshold: 50})
+	scheduler.SetAdmissionPolicy(TaskClassUe, AdmissionPolicy{MaxWait: 10 * time.Millisecond})
+
+	// block the worker so that the queue fills up
+	require.NoError(t, scheduler.Admit(Task{Class: TaskClassUeEvent, Event: func() { <-release }}))
+	require.Eventually(t, func() bool { return scheduler.Stats()[0].Depth == 0 }, time.Second, time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, scheduler.Admit(Task{Class: TaskClassInitialUe}))
+	}
+	// new UEs are shed once the queue is half full, while the UEs being served still get in
+	require.ErrorIs(t, scheduler.Admit(Task{Class: TaskClassInitialUe}), ErrTaskShed)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, scheduler.Admit(Task{Class: TaskClassUe}))
+	}
+	require.ErrorIs(t, scheduler.Admit(Task{Class: TaskClassUe}), ErrTaskQueueFull)
+	// non-UE messages have their own queue
+	require.NoError(t, scheduler.Admit(Task{Class: TaskClassNonUe}))
+
+	stats := scheduler.Stats()[0]
+	assert.Equal(t, 4, stats.Depth)
+	assert.Equal(t, 1, stats.PriorityDepth)
+	assert.Equal(t, 4, stats.Capacity)
+	close(release)
+}
+
+func TestScheduler_MandatoryTasks(t *testing.T) {
+	release := make(chan struct{})
+	scheduler := NewUEScheduler(1, 2, func(task Task) {})
+	defer scheduler.Shutdown()
+	for class := TaskClass(0); class < numTaskClasses; class++ {
+		scheduler.SetAdmissionPolicy(class, AdmissionPolicy{ShedThreshold: 50})
+	}
+
+	// block the worker and fill up the queue
+	require.NoError(t, scheduler.Admit(Task{Class: TaskClassUeEvent, Event: func() { <-release }}))
+	require.Eventually(t, func() bool { return scheduler.Stats()[0].Depth == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, scheduler.Admit(Task{Class: TaskClassUeRelease}))
+	require.True(t, scheduler.PostTimer(0, func() {}))
+	require.ErrorIs(t, scheduler.Admit(Task{Class: TaskClassUe}), ErrTaskShed)
+
+	// the release and timer tasks wait for room rather than being dropped
+	admitted := make(chan error, 2)
+	go func() {
+		admitted <- scheduler.Admit(Task{Class: TaskClassUeRelease})
+	}()
+	go func() {
+		if scheduler.PostTimer(0, func() {}) {
+			admitted <- nil
+		} else {
+			admitted <- ErrWorkerStopped
+		}
+	}()
+	select {
+	case <-admitted:
+		t.Fatal("mandatory task admitted in a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-admitted)
+	}
+}
+
+func TestScheduler_NonUEMessagePriority(t *testing.T) {
+	var processed []TaskClass
+	var wg sync.WaitGroup
+	wg.Add(6)
+	scheduler := NewUEScheduler(1, 10, func(task Task) {
+		processed = append(processed, task.Class)
+		wg.Done()
+	})
+	defer scheduler.Shutdown()
+
+	release := make(chan struct{})
+	require.True(t, scheduler.Post(0, func() { <-release }))
+	for i := 0; i < 3; i++ {
+		require.True(t, scheduler.DispatchTask(Task{Class: TaskClassUe, Message: []byte{0x00}}))
+	}
+	for i := 0; i < 3; i++ {
+		require.True(t, scheduler.DispatchTask(Task{Class: TaskClassNonUe, Message: []byte{0x00}}))
+	}
+	close(release)
+	wg.Wait()
+
+	// the non-UE messages queued behind the UE messages are handled first
+	assert.Equal(t, []TaskClass{
+		TaskClassNonUe, TaskClassNonUe, TaskClassNonUe,
+		TaskClassUe, TaskClassUe, TaskClassUe,
+	}, processed)
+}
@@ -245,8 +245,10 @@ func handleConnection(conn *sctp.SCTPConn, bufsize uint32, handler NGAPHandler)
 	}
 }
 
-// dispatchToWorkerPool extracts the UE ID and dispatches the task to the appropriate worker.
-// For non-UE messages (e.g., NGSetupRequest), it dispatches to a default worker (worker 0).
+// dispatchToWorkerPool decodes the message once, looks up the UE key and dispatches the task carrying the decoded
+// message to the worker of the UE.
+// For non-UE messages (e.g., NGSetupRequest), it dispatches to the worker of the NG-RAN node, ahead of the UE traffic.
+// A message not admitted in the worker queue is dropped rather than blocking the association.
 func dispatchToWorkerPool(conn net.Conn, msg []byte, handler NGAPHandler) {
 	scheduler, err := ngap_internal.GetScheduler()
 	if err != nil {
@@ -256,25 +258,13 @@ func dispatchToWorkerPool(conn net.Conn, msg []byte, handler NGAPHandler) {
 		return
 	}
 
-	// Extract UE ID from the message
-	ueID, found := ngap_internal.ExtractUEID(msg)
-
-	// For non-UE messages or if extraction fails, use a fixed worker (worker 0)
-	// to handle connection-level messages like NGSetupRequest
-	if !found {
-		logger.NgapLog.Tracef("Non-UE message or UE ID not found, using default worker (0)")
-		ueID = 0
-	}
-
-	// Create and dispatch task
-	task := ngap_internal.Task{
-		UEID:    ueID,
-		Conn:    conn,
-		Message: msg,
+	// Decode the message and key it on the UE it belongs to; non-UE messages get key 0
+	task, err := ngap_internal.NewTask(conn, msg)
+	if err != nil {
+		logger.NgapLog.Errorf("NGAP decode error: %+v", err)
+		return
 	}
 
-	// Attempt to dispatch to worker pool
-	if !scheduler.DispatchTask(task) {
-		logger.NgapLog.Warnf("Drop packet for UE ID %d (Scheduler is shutting down)", ueID)
-	}
+	// Attempt to dispatch to worker pool; a task not admitted is logged and counted by the scheduler
+	_ = scheduler.Admit(task)
 }
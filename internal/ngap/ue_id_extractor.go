@@ -1,6 +1,10 @@
 package ngap
 
 import (
+	"hash/fnv"
+	"net"
+
+	"github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/amf/internal/logger"
 	"github.com/free5gc/ngap"
 	"github.com/free5gc/ngap/ngapType"
@@ -17,6 +21,11 @@ func ExtractUEID(msg []byte) (uint64, bool) {
 		return 0, false
 	}
 
+	return ExtractUEIDFromPDU(pdu)
+}
+
+// ExtractUEIDFromPDU extracts the UE identifier from an already decoded NGAP message.
+func ExtractUEIDFromPDU(pdu *ngapType.NGAPPDU) (uint64, bool) {
 	if pdu == nil {
 		logger.NgapLog.Trace("NGAP PDU is nil")
 		return 0, false
@@ -47,6 +56,51 @@ func ExtractUEID(msg []byte) (uint64, bool) {
 	return ueID, found
 }
 
+// ueAffinityKey returns the key of the UE the message belongs to, which is the same for all the UE-associated
+// messages of a UE (see context.UeAffinity). Non-UE messages are keyed on the NG-RAN node, so that the non-UE
+// messages of a node are handled in sequence and those of different nodes are spread over the workers.
+// It also returns whether the message is UE-associated.
+func ueAffinityKey(conn net.Conn, pdu *ngapType.NGAPPDU) (uint64, bool) {
+	ueID, found := ExtractUEIDFromPDU(pdu)
+	if !found {
+		return ranNodeKey(conn), false
+	}
+	if pdu.Present == ngapType.NGAPPDUPresentInitiatingMessage &&
+		pdu.InitiatingMessage.ProcedureCode.Value == ngapType.ProcedureCodeInitialUEMessage {
+		// the only message keyed on the RAN UE NGAP ID
+		return context.InitialUeKey(conn, int64(ueID)), true
+	}
+	return context.GetUeAffinity().Key(int64(ueID)), true
+}
+
+// ranNodeKey returns the key of the NG-RAN node on the connection
+func ranNodeKey(conn net.Conn) uint64 {
+	h := fnv.New64a()
+	if conn != nil {
+		if addr := conn.RemoteAddr(); addr != nil {
+			_, _ = h.Write([]byte(addr.String()))
+		}
+	}
+	return h.Sum64()
+}
+
+// taskClass returns the class the message is admitted with in the worker queues. A UE-associated message, an
+// Error Indication with an AMF UE NGAP ID included, stays in the ordered queue of the UE.
+func taskClass(pdu *ngapType.NGAPPDU, ueAssociated bool) TaskClass {
+	if pdu.Present == ngapType.NGAPPDUPresentInitiatingMessage &&
+		pdu.InitiatingMessage.ProcedureCode.Value == ngapType.ProcedureCodeInitialUEMessage {
+		return TaskClassInitialUe
+	}
+	if !ueAssociated {
+		return TaskClassNonUe
+	}
+	if pdu.Present == ngapType.NGAPPDUPresentSuccessfulOutcome &&
+		pdu.SuccessfulOutcome.ProcedureCode.Value == ngapType.ProcedureCodeUEContextRelease {
+		return TaskClassUeRelease
+	}
+	return TaskClassUe
+}
+
 // extractFromInitiatingMessage extracts UE ID from InitiatingMessage
 func extractFromInitiatingMessage(msg *ngapType.InitiatingMessage) (uint64, bool) {
 	if msg == nil {
@@ -51,6 +51,12 @@ func (s *Server) getHttpCallBackRoutes() []Route {
 			Pattern: "/deregistration/:ueid",
 			APIFunc: s.HTTPHandleDeregistrationNotification,
 		},
+		{
+			Name:    "SdmModificationNotify",
+			Method:  http.MethodPost,
+			Pattern: "/sdm-notify/:supi",
+			APIFunc: s.HTTPSdmModificationNotify,
+		},
 	}
 }
 
@@ -220,7 +226,20 @@ func (s *Server) HTTPHandleDeregistrationNotification(c *gin.Context) {
 		return
 	}
 
-	problemDetails, err := s.DeregistrationNotificationProcedure(ue, deregData)
+	var problemDetails *models.ProblemDetails
+	if runErr := ue.Run(func() {
+		problemDetails, err = s.DeregistrationNotificationProcedure(ue, deregData)
+	}); runErr != nil {
+		ue.GmmLog.Errorf("Deregistration Notification not handled: %v", runErr)
+		problemDetails = &models.ProblemDetails{
+			Status: http.StatusServiceUnavailable,
+			Cause:  "NF_CONGESTION",
+			Detail: runErr.Error(),
+		}
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(http.StatusServiceUnavailable, problemDetails)
+		return
+	}
 	if problemDetails != nil {
 		ue.GmmLog.Errorf("Deregistration Notification Procedure Failed Problem[%+v]", problemDetails)
 	} else if err != nil {
@@ -288,3 +307,36 @@ func (s *Server) DeregistrationNotificationProcedure(ue *amf_context.AmfUe, dere
 
 	return nil, nil
 }
+
+func (s *Server) HTTPSdmModificationNotify(c *gin.Context) {
+	var modificationNotification models.ModificationNotification
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		logger.CallbackLog.Errorf("Get Request Body error: %+v", err)
+		problemDetails := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(http.StatusInternalServerError, problemDetails)
+		return
+	}
+
+	err = openapi.Deserialize(&modificationNotification, requestBody, "application/json")
+	if err != nil {
+		problemDetails := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: reqbody + err.Error(),
+		}
+		logger.CallbackLog.Errorln(problemDetails.Detail)
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, http.StatusText(http.StatusBadRequest))
+		c.JSON(http.StatusBadRequest, problemDetails)
+		return
+	}
+
+	s.Processor().HandleSdmModificationNotify(c, modificationNotification)
+}
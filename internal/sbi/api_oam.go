@@ -4,6 +4,12 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/internal/sbi/processor"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/metrics/sbi"
 )
 
 func (s *Server) getOAMRoutes() []Route {
@@ -27,6 +33,150 @@ func (s *Server) getOAMRoutes() []Route {
 			Pattern: "/registered-ue-context/:supi",
 			APIFunc: s.HTTPRegisteredUEContext,
 		},
+		{
+			Name:    "TraceRecords",
+			Method:  http.MethodGet,
+			Pattern: "/trace-records",
+			APIFunc: s.HTTPTraceRecords,
+		},
+		{
+			Name:    "UETraceRecords",
+			Method:  http.MethodGet,
+			Pattern: "/trace-records/:supi",
+			APIFunc: s.HTTPTraceRecords,
+		},
+		{
+			Name:    "RanContext",
+			Method:  http.MethodGet,
+			Pattern: "/ran",
+			APIFunc: s.HTTPRanContext,
+		},
+		{
+			Name:    "RanContext",
+			Method:  http.MethodGet,
+			Pattern: "/ran/:ranId",
+			APIFunc: s.HTTPRanContext,
+		},
+		{
+			Name:    "UEContext",
+			Method:  http.MethodGet,
+			Pattern: "/ue-context",
+			APIFunc: s.HTTPUEContext,
+		},
+		{
+			Name:    "UEContext",
+			Method:  http.MethodGet,
+			Pattern: "/ue-context/:supi",
+			APIFunc: s.HTTPUEContext,
+		},
+		{
+			Name:    "EventSubscriptions",
+			Method:  http.MethodGet,
+			Pattern: "/event-subscriptions",
+			APIFunc: s.HTTPEventSubscriptions,
+		},
+		{
+			Name:    "N1N2Subscriptions",
+			Method:  http.MethodGet,
+			Pattern: "/n1n2-subscriptions",
+			APIFunc: s.HTTPN1N2Subscriptions,
+		},
+		{
+			Name:    "SliceOutages",
+			Method:  http.MethodGet,
+			Pattern: "/slice-outages",
+			APIFunc: s.HTTPSliceOutages,
+		},
+	}
+}
+
+// getOAMAdminRoutes returns the OAM routes acting on the AMF, its UEs and RANs
+func (s *Server) getOAMAdminRoutes() []Route {
+	return []Route{
+		{
+			Name:    "DeactivateTrace",
+			Method:  http.MethodDelete,
+			Pattern: "/registered-ue-context/:supi/trace",
+			APIFunc: s.HTTPDeactivateTrace,
+		},
+		{
+			Name:    "Drain",
+			Method:  http.MethodPost,
+			Pattern: "/drain",
+			APIFunc: s.HTTPDrain,
+		},
+		{
+			Name:    "NGReset",
+			Method:  http.MethodPost,
+			Pattern: "/ran/:ranId/ng-reset",
+			APIFunc: s.HTTPNGReset,
+		},
+		{
+			Name:    "DeregisterUE",
+			Method:  http.MethodPost,
+			Pattern: "/ue-context/:supi/deregister",
+			APIFunc: s.HTTPDeregisterUE,
+		},
+		{
+			Name:    "PageUE",
+			Method:  http.MethodPost,
+			Pattern: "/ue-context/:supi/paging",
+			APIFunc: s.HTTPPageUE,
+		},
+		{
+			Name:    "DetachUE",
+			Method:  http.MethodDelete,
+			Pattern: "/ue-context/:supi",
+			APIFunc: s.HTTPDetachUE,
+		},
+		{
+			Name:    "StartCapture",
+			Method:  http.MethodPost,
+			Pattern: "/captures",
+			APIFunc: s.HTTPStartCapture,
+		},
+		{
+			Name:    "Captures",
+			Method:  http.MethodGet,
+			Pattern: "/captures",
+			APIFunc: s.HTTPCaptures,
+		},
+		{
+			Name:    "Capture",
+			Method:  http.MethodGet,
+			Pattern: "/captures/:captureId",
+			APIFunc: s.HTTPCapture,
+		},
+		{
+			Name:    "CapturePcap",
+			Method:  http.MethodGet,
+			Pattern: "/captures/:captureId/pcap",
+			APIFunc: s.HTTPCapturePcap,
+		},
+		{
+			Name:    "StopCapture",
+			Method:  http.MethodPost,
+			Pattern: "/captures/:captureId/stop",
+			APIFunc: s.HTTPStopCapture,
+		},
+		{
+			Name:    "RemoveCapture",
+			Method:  http.MethodDelete,
+			Pattern: "/captures/:captureId",
+			APIFunc: s.HTTPRemoveCapture,
+		},
+		{
+			Name:    "SetSliceOutage",
+			Method:  http.MethodPut,
+			Pattern: "/slice-outages/:snssai",
+			APIFunc: s.HTTPSetSliceOutage,
+		},
+		{
+			Name:    "RemoveSliceOutage",
+			Method:  http.MethodDelete,
+			Pattern: "/slice-outages/:snssai",
+			APIFunc: s.HTTPRemoveSliceOutage,
+		},
 	}
 }
 
@@ -43,3 +193,165 @@ func (s *Server) HTTPRegisteredUEContext(c *gin.Context) {
 	s.setCorsHeader(c)
 	s.Processor().HandleOAMRegisteredUEContext(c)
 }
+
+func (s *Server) HTTPDeactivateTrace(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMDeactivateTrace(c)
+}
+
+func (s *Server) HTTPTraceRecords(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMTraceRecords(c)
+}
+
+func (s *Server) HTTPDrain(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMDrain(c)
+}
+
+func (s *Server) HTTPRanContext(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMRanContext(c)
+}
+
+func (s *Server) HTTPUEContext(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMUEContext(c)
+}
+
+func (s *Server) HTTPEventSubscriptions(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMEventSubscriptions(c)
+}
+
+func (s *Server) HTTPN1N2Subscriptions(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMN1N2Subscriptions(c)
+}
+
+func (s *Server) HTTPNGReset(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMNGReset(c)
+}
+
+func (s *Server) HTTPDeregisterUE(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMDeregisterUE(c)
+}
+
+func (s *Server) HTTPPageUE(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMPageUE(c)
+}
+
+func (s *Server) HTTPDetachUE(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMDetachUE(c)
+}
+
+func (s *Server) HTTPStartCapture(c *gin.Context) {
+	s.setCorsHeader(c)
+	var captureRequest processor.CaptureRequest
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		logger.ProducerLog.Errorf("Get Request Body error: %+v", err)
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetail.Cause)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	// an empty body captures all the NGAP messages
+	if len(requestBody) > 0 {
+		if err = openapi.Deserialize(&captureRequest, requestBody, "application/json"); err != nil {
+			problemDetail := reqbody + err.Error()
+			rsp := models.ProblemDetails{
+				Title:  "Malformed request syntax",
+				Status: http.StatusBadRequest,
+				Detail: problemDetail,
+			}
+			logger.ProducerLog.Errorln(problemDetail)
+			c.Set(sbi.IN_PB_DETAILS_CTX_STR, http.StatusText(http.StatusBadRequest))
+			c.JSON(http.StatusBadRequest, rsp)
+			return
+		}
+	}
+	s.Processor().HandleOAMStartCapture(c, captureRequest)
+}
+
+func (s *Server) HTTPCaptures(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMCaptures(c)
+}
+
+func (s *Server) HTTPCapture(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMCapture(c)
+}
+
+func (s *Server) HTTPCapturePcap(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMCapturePcap(c)
+}
+
+func (s *Server) HTTPStopCapture(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMStopCapture(c)
+}
+
+func (s *Server) HTTPRemoveCapture(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMRemoveCapture(c)
+}
+
+func (s *Server) HTTPSliceOutages(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMSliceOutages(c)
+}
+
+func (s *Server) HTTPSetSliceOutage(c *gin.Context) {
+	s.setCorsHeader(c)
+	var sliceOutageRequest processor.SliceOutageRequest
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		logger.ProducerLog.Errorf("Get Request Body error: %+v", err)
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetail.Cause)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	// an empty body puts the S-NSSAI out of service in the whole PLMN, without alternative
+	if len(requestBody) > 0 {
+		if err = openapi.Deserialize(&sliceOutageRequest, requestBody, "application/json"); err != nil {
+			problemDetail := reqbody + err.Error()
+			rsp := models.ProblemDetails{
+				Title:  "Malformed request syntax",
+				Status: http.StatusBadRequest,
+				Detail: problemDetail,
+			}
+			logger.ProducerLog.Errorln(problemDetail)
+			c.Set(sbi.IN_PB_DETAILS_CTX_STR, http.StatusText(http.StatusBadRequest))
+			c.JSON(http.StatusBadRequest, rsp)
+			return
+		}
+	}
+	s.Processor().HandleOAMSetSliceOutage(c, sliceOutageRequest)
+}
+
+func (s *Server) HTTPRemoveSliceOutage(c *gin.Context) {
+	s.setCorsHeader(c)
+	s.Processor().HandleOAMRemoveSliceOutage(c)
+}
@@ -167,6 +167,8 @@ func (s *namfService) CreateUEContextRequest(ue *amf_context.AmfUe, ueContextCre
 	if err != nil {
 		return nil, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAMF_COMM, "CreateUEContextRequest")
+	defer span.End()
 
 	creatuectxreq := Namf_Communication.CreateUEContextRequest{
 		UeContextId:            &ue.Supi,
@@ -213,6 +215,8 @@ func (s *namfService) ReleaseUEContextRequest(ue *amf_context.AmfUe, ngapCause m
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAMF_COMM, "ReleaseUEContextRequest")
+	defer span.End()
 
 	ueCtxReleaseReq := Namf_Communication.ReleaseUEContextRequest{
 		UeContextId:      &ueContextId,
@@ -276,6 +280,8 @@ func (s *namfService) UEContextTransferRequest(
 	if err != nil {
 		return nil, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAMF_COMM, "UEContextTransferRequest")
+	defer span.End()
 
 	ueCtxTransferReq := Namf_Communication.UEContextTransferRequest{
 		UeContextId:              &ueContextId,
@@ -335,6 +341,8 @@ func (s *namfService) RegistrationStatusUpdate(ue *amf_context.AmfUe, request mo
 	if err != nil {
 		return regStatusTransferComplete, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAMF_COMM, "RegistrationStatusUpdate")
+	defer span.End()
 
 	regStatusUpdateReq := Namf_Communication.RegistrationStatusUpdateRequest{
 		UeContextId:              &ueContextId,
@@ -73,6 +73,8 @@ func (s *nausfService) SendUEAuthenticationAuthenticateRequest(ue *amf_context.A
 	if err != nil {
 		return nil, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAUSF_AUTH, "SendUEAuthenticationAuthenticateRequest")
+	defer span.End()
 
 	authReq := Nausf_UEAuthentication.UeAuthenticationsPostRequest{
 		AuthenticationInfo: &authInfo,
@@ -125,6 +127,9 @@ func (s *nausfService) SendAuth5gAkaConfirmRequest(ue *amf_context.AmfUe, resSta
 	if err != nil {
 		return nil, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAUSF_AUTH, "SendAuth5gAkaConfirmRequest")
+	defer span.End()
+
 	// confirmUri.RequestURI() = "/nausf-auth/v1/ue-authentications/{authctxId}/5g-aka-confirmation"
 	// splituri = ["","nausf-auth","ue-authentications",{authctxId},"5g-aka-confirmation"]
 	// authctxId = {authctxId}
@@ -205,6 +210,8 @@ func (s *nausfService) SendEapAuthConfirmRequest(ue *amf_context.AmfUe, eapMsg n
 	if err != nil {
 		return nil, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NAUSF_AUTH, "SendEapAuthConfirmRequest")
+	defer span.End()
 
 	eapSession, localErr := client.DefaultApi.EapAuthMethod(ctx, &eapSessionReq)
 
@@ -1,9 +1,17 @@
 package consumer
 
 import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/tracing"
 	"github.com/free5gc/amf/pkg/app"
 	Namf_Communication "github.com/free5gc/openapi/amf/Communication"
 	Nausf_UEAuthentication "github.com/free5gc/openapi/ausf/UEAuthentication"
+	"github.com/free5gc/openapi/models"
 	Nnrf_NFDiscovery "github.com/free5gc/openapi/nrf/NFDiscovery"
 	Nnrf_NFManagement "github.com/free5gc/openapi/nrf/NFManagement"
 	Nnssf_NSSelection "github.com/free5gc/openapi/nssf/NSSelection"
@@ -30,6 +38,8 @@ type Consumer struct {
 	*nsmfService
 	*nudmService
 	*nausfService
+	*tceService
+	*nsacfService
 }
 
 func GetConsumer() *Consumer {
@@ -77,6 +87,27 @@ func NewConsumer(amf ConsumerAmf) (*Consumer, error) {
 		consumer:                c,
 		UEAuthenticationClients: make(map[string]*Nausf_UEAuthentication.APIClient),
 	}
+	c.tceService = &tceService{
+		consumer: c,
+		client:   &http.Client{Timeout: tceReportTimeout},
+	}
+	c.nsacfService = &nsacfService{
+		consumer: c,
+		client:   &http.Client{Timeout: nsacfRequestTimeout},
+	}
 	consumer = c
 	return c, nil
 }
+
+// startSbiSpan starts the client span of an SBI request sent for the UE, under the span of the message or procedure
+// of the UE being handled. The transport of the openapi clients sends its W3C trace-context in the request.
+func startSbiSpan(ctx context.Context, ue *amf_context.AmfUe, service models.ServiceName, operation string) (
+	context.Context, trace.Span,
+) {
+	if ue != nil {
+		ctx = ue.TraceContext(ctx)
+	}
+	return tracing.Tracer().Start(ctx, string(service)+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.AttrSbiService.String(string(service))))
+}
@@ -100,11 +100,26 @@ func (s *nnrfService) SendSearchNFInstances(nrfUri string, targetNfType, request
 	return result, err
 }
 
+// SearchNFInstancesInHomePlmn searches the NF instances of the home PLMN of the UE. For an inbound roamer, the request
+// targets its home PLMN and is sent to the NRF of the AMF (vNRF), which forwards it to the NRF of the home PLMN
+// (TS 23.502 4.17.5).
+func (s *nnrfService) SearchNFInstancesInHomePlmn(
+	ue *amf_context.AmfUe, nrfUri string, targetNfType, requestNfType models.NrfNfManagementNfType,
+	param *Nnrf_NFDiscovery.SearchNFInstancesRequest,
+) (*models.SearchResult, error) {
+	if ue.IsInboundRoamer() {
+		param.TargetPlmnList = []models.PlmnId{ue.HomePlmnId()}
+		param.RequesterPlmnList = []models.PlmnId{ue.ServingPlmnId()}
+		ue.GmmLog.Debugf("Search %s of home PLMN[%+v] from NRF[%s]", targetNfType, ue.HomePlmnId(), nrfUri)
+	}
+	return s.SendSearchNFInstances(nrfUri, targetNfType, requestNfType, param)
+}
+
 func (s *nnrfService) SearchUdmSdmInstance(
 	ue *amf_context.AmfUe, nrfUri string, targetNfType, requestNfType models.NrfNfManagementNfType,
 	param *Nnrf_NFDiscovery.SearchNFInstancesRequest,
 ) error {
-	resp, localErr := s.SendSearchNFInstances(nrfUri, targetNfType, requestNfType, param)
+	resp, localErr := s.SearchNFInstancesInHomePlmn(ue, nrfUri, targetNfType, requestNfType, param)
 	if localErr != nil {
 		return localErr
 	}
@@ -189,6 +204,10 @@ func (s *nnrfService) BuildNFInstance(context *amf_context.AMFContext) (
 	profile.NfInstanceId = context.NfId
 	profile.NfType = models.NrfNfManagementNfType_AMF
 	profile.NfStatus = models.NrfNfManagementNfStatus_REGISTERED
+	if amf_context.IsDraining() {
+		// not selected for new UEs during the planned removal
+		profile.NfStatus = models.NrfNfManagementNfStatus_UNDISCOVERABLE
+	}
 	var plmns []models.PlmnId
 	for _, plmnItem := range context.PlmnSupportList {
 		plmns = append(plmns, *plmnItem.PlmnId)
@@ -215,6 +234,8 @@ func (s *nnrfService) BuildNFInstance(context *amf_context.AMFContext) (
 	amfInfo.AmfRegionId = regionId
 	amfInfo.AmfSetId = setId
 	amfInfo.GuamiList = context.ServedGuamiList
+	// the GUAMIs of the AMF set this AMF takes over when their AMF fails, TS 29.510 6.1.6.2.11
+	amfInfo.BackupInfoAmfFailure = context.BackedUpGuamiList
 	if len(context.SupportTaiLists) == 0 {
 		err = fmt.Errorf("SupportTaiList is Empty in AMF")
 		return profile, err
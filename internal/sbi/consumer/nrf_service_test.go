@@ -0,0 +1,118 @@
+package consumer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	Nnrf_NFDiscovery "github.com/free5gc/openapi/nrf/NFDiscovery"
+)
+
+// newStandInServer returns a server of the handler speaking HTTP/2 without TLS like the SBI clients
+func newStandInServer(handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	return server
+}
+
+// newStandInNrf returns an NRF answering the discovery requests with the UDM profile of the SDM service at sdmUri,
+// reporting the target PLMN list of the last request
+func newStandInNrf(t *testing.T, sdmUri string, targetPlmnList *string) *httptest.Server {
+	return newStandInServer(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/nnrf-disc/v1/nf-instances", r.URL.Path)
+		*targetPlmnList = r.URL.Query().Get("target-plmn-list")
+		result := models.SearchResult{
+			NfInstances: []models.NrfNfDiscoveryNfProfile{{
+				NfInstanceId: "udm-466-92",
+				NfType:       models.NrfNfManagementNfType_UDM,
+				NfStatus:     models.NrfNfManagementNfStatus_REGISTERED,
+				NfServices: []models.NrfNfDiscoveryNfService{{
+					ServiceInstanceId: "nudm-sdm",
+					ServiceName:       models.ServiceName_NUDM_SDM,
+					Scheme:            models.UriScheme_HTTP,
+					NfServiceStatus:   models.NfServiceStatus_REGISTERED,
+					ApiPrefix:         sdmUri,
+				}},
+			}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(result))
+	})
+}
+
+func TestSearchNFInstancesInHomePlmn(t *testing.T) {
+	homePlmnId := models.PlmnId{Mcc: "466", Mnc: "92"}
+	servingPlmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+
+	// the UDM of the home PLMN restricts the access of its UE to the serving PLMN
+	var amDataPlmnId string
+	hUdm := newStandInServer(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/nudm-sdm/v2/imsi-466920000000001/am-data", r.URL.Path)
+		amDataPlmnId = r.URL.Query().Get("plmn-id")
+		amData := models.AccessAndMobilitySubscriptionData{
+			RoamingRestrictions: &models.RoamingRestrictions{AccessAllowed: false},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(amData))
+	})
+	defer hUdm.Close()
+	var vNrfTargetPlmnList string
+	vNrf := newStandInNrf(t, hUdm.URL, &vNrfTargetPlmnList)
+	defer vNrf.Close()
+
+	amfSelf := amf_context.GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{
+		PlmnId: &models.PlmnIdNid{Mcc: servingPlmnId.Mcc, Mnc: servingPlmnId.Mnc},
+		AmfId:  "cafe00",
+	}}
+	amfSelf.RoamingCfg = &factory.Roaming{
+		Partners: []factory.RoamingPartner{{PlmnId: &homePlmnId}},
+	}
+	defer func() {
+		amfSelf.ServedGuamiList = amfSelf.ServedGuamiList[:0]
+		amfSelf.RoamingCfg = nil
+	}()
+
+	c, err := NewConsumer(nil)
+	require.NoError(t, err)
+	ue := &amf_context.AmfUe{
+		Supi:   "imsi-466920000000001",
+		PlmnId: homePlmnId,
+		Tai:    models.Tai{PlmnId: &servingPlmnId, Tac: "000001"},
+		GmmLog: logger.GmmLog,
+	}
+	require.True(t, ue.IsInboundRoamer())
+
+	// the UDM of the inbound roamer is discovered in its home PLMN, the vNRF forwarding the discovery to the hNRF
+	err = c.SearchUdmSdmInstance(ue, vNrf.URL, models.NrfNfManagementNfType_UDM, models.NrfNfManagementNfType_AMF,
+		&Nnrf_NFDiscovery.SearchNFInstancesRequest{Supi: &ue.Supi})
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"mcc":"466","mnc":"92"}]`, vNrfTargetPlmnList)
+	require.Equal(t, hUdm.URL, ue.NudmSDMUri)
+	require.Equal(t, "udm-466-92", ue.UdmId)
+
+	// its access and mobility subscription data are the ones for the serving PLMN
+	problemDetails, err := c.SDMGetAmData(ue)
+	require.NoError(t, err)
+	require.Nil(t, problemDetails)
+	require.JSONEq(t, `{"mcc":"208","mnc":"93"}`, amDataPlmnId)
+	require.False(t, ue.AccessAndMobilitySubscriptionData.RoamingRestrictions.AccessAllowed)
+
+	// the discovery for the UE at home targets no PLMN
+	vNrfTargetPlmnList = ""
+	homeUe := &amf_context.AmfUe{Supi: "imsi-208930000000001", PlmnId: servingPlmnId, GmmLog: logger.GmmLog}
+	_, err = c.SearchNFInstancesInHomePlmn(homeUe, vNrf.URL, models.NrfNfManagementNfType_UDM,
+		models.NrfNfManagementNfType_AMF, &Nnrf_NFDiscovery.SearchNFInstancesRequest{})
+	require.NoError(t, err)
+	require.Empty(t, vNrfTargetPlmnList)
+}
@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/openapi/models"
+)
+
+const nsacfRequestTimeout = 3 * time.Second
+
+// Update flags and admission control results of the Nnsacf_NSAC_NumOfUEsUpdate service operation
+const (
+	nsacUpdateFlagIncrease = "INCREASE"
+	nsacUpdateFlagDecrease = "DECREASE"
+	nsacResultAccepted     = "ACCEPTED"
+)
+
+// nsacfService counts the UEs registered with the S-NSSAIs subject to network slice admission control at the NSACF.
+// The openapi module provides no Nnsacf client, the stand-in request and response below carry the information of
+// Nnsacf_NSAC_NumOfUEsUpdate (TS 29.536 5.2.2.2) for one UE and S-NSSAI, posted as JSON to the configured URI.
+type nsacfService struct {
+	consumer *Consumer
+
+	client *http.Client
+}
+
+type nsacUeRequest struct {
+	NfId       string            `json:"nfId"`
+	NfType     string            `json:"nfType"`
+	Supi       string            `json:"supi"`
+	AnType     models.AccessType `json:"anType"`
+	Snssai     models.Snssai     `json:"snssai"`
+	UpdateFlag string            `json:"updateFlag"`
+}
+
+type nsacUeResponse struct {
+	AcResult string `json:"acResult"`
+}
+
+// nsacfSliceAdmission is the network slice admission control of the NSACF
+type nsacfSliceAdmission struct {
+	service  *nsacfService
+	nsacfUri string
+}
+
+// SliceAdmission returns the network slice admission control of the NSACF at the URI
+func (s *nsacfService) SliceAdmission(nsacfUri string) amf_context.SliceAdmission {
+	return &nsacfSliceAdmission{
+		service:  s,
+		nsacfUri: nsacfUri,
+	}
+}
+
+func (a *nsacfSliceAdmission) Admit(supi string, snssai models.Snssai, anType models.AccessType) (bool, error) {
+	return a.service.numOfUesUpdate(a.nsacfUri, supi, snssai, anType, nsacUpdateFlagIncrease)
+}
+
+func (a *nsacfSliceAdmission) Release(supi string, snssai models.Snssai, anType models.AccessType) error {
+	_, err := a.service.numOfUesUpdate(a.nsacfUri, supi, snssai, anType, nsacUpdateFlagDecrease)
+	return err
+}
+
+func (s *nsacfService) numOfUesUpdate(nsacfUri, supi string, snssai models.Snssai, anType models.AccessType,
+	updateFlag string,
+) (bool, error) {
+	body, err := json.Marshal(&nsacUeRequest{
+		NfId:       amf_context.GetSelf().NfId,
+		NfType:     string(models.NrfNfManagementNfType_AMF),
+		Supi:       supi,
+		AnType:     anType,
+		Snssai:     snssai,
+		UpdateFlag: updateFlag,
+	})
+	if err != nil {
+		return false, fmt.Errorf("marshal NSAC request failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), nsacfRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nsacfUri, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if rspCloseErr := rsp.Body.Close(); rspCloseErr != nil {
+			logger.ConsumerLog.Errorf("Close NSACF response body failed: %+v", rspCloseErr)
+		}
+	}()
+	if rsp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("NSACF responded with status %d", rsp.StatusCode)
+	}
+	var nsacRsp nsacUeResponse
+	if err = json.NewDecoder(rsp.Body).Decode(&nsacRsp); err != nil {
+		return false, fmt.Errorf("decode NSAC response failed: %w", err)
+	}
+	return nsacRsp.AcResult == nsacResultAccepted, nil
+}
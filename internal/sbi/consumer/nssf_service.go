@@ -55,6 +55,9 @@ func (s *nssfService) NSSelectionGetForRegistration(ue *amf_context.AmfUe, reque
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NNSSF_NSSELECTION, "NSSelectionGetForRegistration")
+	defer span.End()
+
 	sliceInfo := models.SliceInfoForRegistration{
 		SubscribedNssai: ue.SubscribedNssai,
 	}
@@ -76,6 +79,10 @@ func (s *nssfService) NSSelectionGetForRegistration(ue *amf_context.AmfUe, reque
 		SliceInfoRequestForRegistration: &sliceInfo,
 		Tai:                             &ue.Tai, // TS 29.531 R15.3 6.1.3.2.3.1
 	}
+	if ue.IsInboundRoamer() {
+		homePlmnId := ue.HomePlmnId()
+		paramOpt.HomePlmnId = &homePlmnId
+	}
 
 	res, localErr := client.NetworkSliceInformationDocumentApi.NSSelectionGet(ctx,
 		&paramOpt)
@@ -107,9 +114,9 @@ func (s *nssfService) NSSelectionGetForRegistration(ue *amf_context.AmfUe, reque
 	return nil, nil
 }
 
-func (s *nssfService) NSSelectionGetForPduSession(ue *amf_context.AmfUe, snssai models.Snssai) (
-	*models.AuthorizedNetworkSliceInfo, *models.ProblemDetails, error,
-) {
+func (s *nssfService) NSSelectionGetForPduSession(ue *amf_context.AmfUe, snssai models.Snssai,
+	roamingIndication models.RoamingIndication,
+) (*models.AuthorizedNetworkSliceInfo, *models.ProblemDetails, error) {
 	client := s.getNSSelectionClient(ue.NssfUri)
 	if client == nil {
 		return nil, nil, openapi.ReportError("nssf not found")
@@ -118,7 +125,15 @@ func (s *nssfService) NSSelectionGetForPduSession(ue *amf_context.AmfUe, snssai
 	amfSelf := amf_context.GetSelf()
 	sliceInfoForPduSession := models.SliceInfoForPduSession{
 		SNssai:            &snssai,
-		RoamingIndication: models.RoamingIndication_NON_ROAMING, // not support roaming
+		RoamingIndication: roamingIndication,
+	}
+	for _, allowedNssai := range ue.AllowedNssai {
+		for _, allowedSnssai := range allowedNssai {
+			if allowedSnssai.MappedHomeSnssai != nil &&
+				openapi.SnssaiEqualFold(*allowedSnssai.AllowedSnssai, snssai) {
+				sliceInfoForPduSession.HomeSnssai = allowedSnssai.MappedHomeSnssai
+			}
+		}
 	}
 
 	testNfType := models.NrfNfManagementNfType_AMF
@@ -129,12 +144,23 @@ func (s *nssfService) NSSelectionGetForPduSession(ue *amf_context.AmfUe, snssai
 		SliceInfoRequestForPduSession: &sliceInfoForPduSession,
 		Tai:                           &ue.Tai, // TS 29.531 R15.3 6.1.3.2.3.1
 	}
+	if roamingIndication != models.RoamingIndication_NON_ROAMING {
+		homePlmnId := ue.HomePlmnId()
+		paramOpt.HomePlmnId = &homePlmnId
+		if sliceInfoForPduSession.HomeSnssai == nil {
+			homeSnssai := ue.HomeSnssai(snssai)
+			sliceInfoForPduSession.HomeSnssai = &homeSnssai
+		}
+	}
 
 	ctx, _, err := amf_context.GetSelf().GetTokenCtx(models.ServiceName_NNSSF_NSSELECTION,
 		models.NrfNfManagementNfType_NSSF)
 	if err != nil {
 		return nil, nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NNSSF_NSSELECTION, "NSSelectionGetForPduSession")
+	defer span.End()
+
 	res, localErr := client.NetworkSliceInformationDocumentApi.NSSelectionGet(ctx, &paramOpt)
 
 	if localErr == nil {
@@ -57,6 +57,8 @@ func (s *npcfService) AMPolicyControlCreate(
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NPCF_AM_POLICY_CONTROL, "AMPolicyControlCreate")
+	defer span.End()
 
 	policyAssociationRequest := models.PcfAmPolicyControlPolicyAssociationRequest{
 		NotificationUri: amfSelf.GetIPv4Uri() + factory.AmfCallbackResUriPrefix + "/am-policy/",
@@ -139,6 +141,8 @@ func (s *npcfService) AMPolicyControlUpdate(
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NPCF_AM_POLICY_CONTROL, "AMPolicyControlUpdate")
+	defer span.End()
 
 	var policyUpdateReq Npcf_AMPolicy.ReportObservedEventTriggersForIndividualAMPolicyAssociationRequest
 
@@ -196,6 +200,8 @@ func (s *npcfService) AMPolicyControlDelete(ue *amf_context.AmfUe) (problemDetai
 	if ctxErr != nil {
 		return nil, ctxErr
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NPCF_AM_POLICY_CONTROL, "AMPolicyControlDelete")
+	defer span.End()
 
 	var deleteReq Npcf_AMPolicy.DeleteIndividualAMPolicyAssociationRequest
 	deleteReq.SetPolAssoId(ue.PolicyAssociationId)
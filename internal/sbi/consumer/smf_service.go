@@ -64,7 +64,9 @@ func (s *nsmfService) SelectSmf(
 		smfUri string
 	)
 
-	ue.GmmLog.Infof("Select SMF [snssai: %+v, dnn: %+v]", snssai, dnn)
+	// the PDU session of the inbound roamer is home routed through a V-SMF and an H-SMF, or broken out locally
+	roamingIndication := ue.RoamingIndication(dnn)
+	ue.GmmLog.Infof("Select SMF [snssai: %+v, dnn: %+v, roaming: %s]", snssai, dnn, roamingIndication)
 
 	nrfUri := ue.ServingAMF().NrfUri // default NRF URI is pre-configured by AMF
 
@@ -84,7 +86,7 @@ func (s *nsmfService) SelectSmf(
 			}
 		}
 
-		response, problemDetails, err := s.consumer.NSSelectionGetForPduSession(ue, snssai)
+		response, problemDetails, err := s.consumer.NSSelectionGetForPduSession(ue, snssai, roamingIndication)
 		if err != nil {
 			err = fmt.Errorf("NSSelection Get Error[%+v]", err)
 			return nil, nasMessage.Cause5GMMPayloadWasNotForwarded, err
@@ -117,7 +119,9 @@ func (s *nsmfService) SelectSmf(
 		Dnn:          &dnn,
 		Snssais:      []models.Snssai{snssai},
 	}
-	if ue.PlmnId.Mcc != "" {
+	if roamingIndication != models.RoamingIndication_NON_ROAMING {
+		param.TargetPlmnList = append(param.TargetPlmnList, ue.ServingPlmnId())
+	} else if ue.PlmnId.Mcc != "" {
 		param.TargetPlmnList = append(param.TargetPlmnList, ue.PlmnId)
 	}
 	if amf_context.GetSelf().Locality != "" {
@@ -142,14 +146,52 @@ func (s *nsmfService) SelectSmf(
 		smfUri = util.SearchNFServiceUri(&result.NfInstances[index], models.ServiceName_NSMF_PDUSESSION,
 			models.NfServiceStatus_REGISTERED)
 		if smfUri != "" {
+			if roamingIndication == models.RoamingIndication_HOME_ROUTED_ROAMING {
+				smContext.SetVSmfID(result.NfInstances[index].NfInstanceId)
+			}
 			break
 		}
 	}
 	smContext.SetSmfID(smfID)
 	smContext.SetSmfUri(smfUri)
+
+	if roamingIndication == models.RoamingIndication_HOME_ROUTED_ROAMING {
+		if err = s.selectHomeSmf(ue, smContext); err != nil {
+			return nil, nasMessage.Cause5GMMPayloadWasNotForwarded, err
+		}
+	}
 	return smContext, 0, nil
 }
 
+// selectHomeSmf selects the SMF of the home PLMN (H-SMF) of the home routed PDU session of the inbound roamer, for
+// the HPLMN S-NSSAI of the PDU session
+func (s *nsmfService) selectHomeSmf(ue *amf_context.AmfUe, smContext *amf_context.SmContext) error {
+	dnn := smContext.Dnn()
+	homeSnssai := ue.HomeSnssai(smContext.Snssai())
+	param := Nnrf_NFDiscovery.SearchNFInstancesRequest{
+		ServiceNames: []models.ServiceName{models.ServiceName_NSMF_PDUSESSION},
+		Dnn:          &dnn,
+		Snssais:      []models.Snssai{homeSnssai},
+	}
+	result, err := s.consumer.SearchNFInstancesInHomePlmn(ue, amf_context.GetSelf().NrfUri,
+		models.NrfNfManagementNfType_SMF, models.NrfNfManagementNfType_AMF, &param)
+	if err != nil {
+		return err
+	}
+
+	// select the first H-SMF, TODO: select base on other info
+	for index := range result.NfInstances {
+		hSmfUri := util.SearchNFServiceUri(&result.NfInstances[index], models.ServiceName_NSMF_PDUSESSION,
+			models.NfServiceStatus_REGISTERED)
+		if hSmfUri != "" {
+			smContext.SetHSmfID(result.NfInstances[index].NfInstanceId)
+			smContext.SetHSmfUri(hSmfUri)
+			return nil
+		}
+	}
+	return fmt.Errorf("no H-SMF in home PLMN[%+v] for DNN[%s] and HPLMN S-NSSAI[%+v]", ue.HomePlmnId(), dnn, homeSnssai)
+}
+
 func (s *nsmfService) SendCreateSmContextRequest(ue *amf_context.AmfUe, smContext *amf_context.SmContext,
 	requestType *models.RequestType, nasPdu []byte) (
 	smContextRef string, errorResponse *models.PostSmContextsError,
@@ -173,8 +215,17 @@ func (s *nsmfService) SendCreateSmContextRequest(ue *amf_context.AmfUe, smContex
 	if err != nil {
 		return "", nil, nil, err
 	}
-	postSmContextReponse, localErr := client.SMContextsCollectionApi.
-		PostSmContexts(ctx, &postSmContextsRequest)
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NSMF_PDUSESSION, "SendCreateSmContextRequest")
+	defer span.End()
+
+	// the SMF may transfer the N1/N2 messages of the PDU session before it answers
+	var (
+		postSmContextReponse *Nsmf_PDUSession.PostSmContextsResponse
+		localErr             error
+	)
+	ue.AwaitNf(func() {
+		postSmContextReponse, localErr = client.SMContextsCollectionApi.PostSmContexts(ctx, &postSmContextsRequest)
+	})
 	if localErr == nil {
 		location := postSmContextReponse.Location
 
@@ -232,6 +283,14 @@ func (s *nsmfService) buildCreateSmContextRequest(ue *amf_context.AmfUe, smConte
 	if ue.RatType != "" {
 		smContextCreateData.RatType = ue.RatType
 	}
+	if ue.IsInboundRoamer() {
+		hplmnSnssai := ue.HomeSnssai(snssai)
+		smContextCreateData.HplmnSnssai = &hplmnSnssai
+		if hSmfUri := smContext.HSmfUri(); hSmfUri != "" {
+			smContextCreateData.HSmfUri = hSmfUri
+			smContextCreateData.HSmfId = smContext.HSmfID()
+		}
+	}
 	smContextCreateData.UeLocation = &ue.Location
 	smContextCreateData.UeTimeZone = ue.TimeZone
 	smContextCreateData.SmContextStatusUri = context.GetIPv4Uri() + factory.AmfCallbackResUriPrefix + "/smContextStatus/" +
@@ -274,7 +333,7 @@ func (s *nsmfService) SendUpdateSmContextActivateUpCnxState(
 			updateData.PresenceInLadn = models.PresenceState_IN_AREA
 		}
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 }
 
 func (s *nsmfService) SendUpdateSmContextDeactivateUpCnxState(ue *amf_context.AmfUe,
@@ -293,7 +352,7 @@ func (s *nsmfService) SendUpdateSmContextDeactivateUpCnxState(ue *amf_context.Am
 	if cause.Var5GmmCause != nil {
 		updateData.Var5gMmCauseValue = *cause.Var5GmmCause
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 }
 
 func (s *nsmfService) SendUpdateSmContextChangeAccessType(ue *amf_context.AmfUe,
@@ -302,7 +361,7 @@ func (s *nsmfService) SendUpdateSmContextChangeAccessType(ue *amf_context.AmfUe,
 ) {
 	updateData := models.SmfPduSessionSmContextUpdateData{}
 	updateData.AnTypeCanBeChanged = anTypeCanBeChanged
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 }
 
 func (s *nsmfService) SendUpdateSmContextN2Info(
@@ -314,7 +373,7 @@ func (s *nsmfService) SendUpdateSmContextN2Info(
 	updateData.N2SmInfo = new(models.RefToBinaryData)
 	updateData.N2SmInfo.ContentId = n2sminfocon
 	updateData.UeLocation = &ue.Location
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, n2SmInfo)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, n2SmInfo)
 }
 
 func (s *nsmfService) SendUpdateSmContextXnHandover(
@@ -336,7 +395,7 @@ func (s *nsmfService) SendUpdateSmContextXnHandover(
 			updateData.PresenceInLadn = models.PresenceState_OUT_OF_AREA
 		}
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, n2SmInfo)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, n2SmInfo)
 }
 
 func (s *nsmfService) SendUpdateSmContextXnHandoverFailed(
@@ -350,7 +409,7 @@ func (s *nsmfService) SendUpdateSmContextXnHandoverFailed(
 		updateData.N2SmInfo.ContentId = n2sminfocon
 	}
 	updateData.FailedToBeSwitched = true
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, n2SmInfo)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, n2SmInfo)
 }
 
 func (s *nsmfService) SendUpdateSmContextN2HandoverPreparing(
@@ -372,7 +431,7 @@ func (s *nsmfService) SendUpdateSmContextN2HandoverPreparing(
 	if amfid != "" {
 		updateData.TargetServingNfId = amfid
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, n2SmInfo)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, n2SmInfo)
 }
 
 func (s *nsmfService) SendUpdateSmContextN2HandoverPrepared(
@@ -386,7 +445,7 @@ func (s *nsmfService) SendUpdateSmContextN2HandoverPrepared(
 		updateData.N2SmInfo.ContentId = n2sminfocon
 	}
 	updateData.HoState = models.HoState_PREPARED
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, n2SmInfo)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, n2SmInfo)
 }
 
 func (s *nsmfService) SendUpdateSmContextN2HandoverComplete(
@@ -408,7 +467,7 @@ func (s *nsmfService) SendUpdateSmContextN2HandoverComplete(
 			updateData.PresenceInLadn = models.PresenceState_OUT_OF_AREA
 		}
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 }
 
 func (s *nsmfService) SendUpdateSmContextN2HandoverCanceled(ue *amf_context.AmfUe,
@@ -427,7 +486,7 @@ func (s *nsmfService) SendUpdateSmContextN2HandoverCanceled(ue *amf_context.AmfU
 	if cause.Var5GmmCause != nil {
 		updateData.Var5gMmCauseValue = *cause.Var5GmmCause
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 }
 
 func (s *nsmfService) SendUpdateSmContextHandoverBetweenAccessType(
@@ -440,7 +499,7 @@ func (s *nsmfService) SendUpdateSmContextHandoverBetweenAccessType(
 		updateData.N1SmMsg = new(models.RefToBinaryData)
 		updateData.N1SmMsg.ContentId = "N1Msg"
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, n1SmMsg, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, n1SmMsg, nil)
 }
 
 func (s *nsmfService) SendUpdateSmContextHandoverBetweenAMF(
@@ -462,10 +521,10 @@ func (s *nsmfService) SendUpdateSmContextHandoverBetweenAMF(
 			}
 		}
 	}
-	return s.consumer.SendUpdateSmContextRequest(smContext, &updateData, nil, nil)
+	return s.consumer.SendUpdateSmContextRequest(ue, smContext, &updateData, nil, nil)
 }
 
-func (s *nsmfService) SendUpdateSmContextRequest(smContext *amf_context.SmContext,
+func (s *nsmfService) SendUpdateSmContextRequest(ue *amf_context.AmfUe, smContext *amf_context.SmContext,
 	updateData *models.SmfPduSessionSmContextUpdateData, n1Msg []byte, n2Info []byte) (
 	response *models.UpdateSmContextResponse200, errorResponse *models.UpdateSmContextResponse400,
 	problemDetail *models.ProblemDetails, err1 error,
@@ -489,8 +548,16 @@ func (s *nsmfService) SendUpdateSmContextRequest(smContext *amf_context.SmContex
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	updateSmContextReponse, localErr := client.IndividualSMContextApi.
-		UpdateSmContext(ctx, &updateSmContextRequest)
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NSMF_PDUSESSION, "SendUpdateSmContextRequest")
+	defer span.End()
+
+	var (
+		updateSmContextReponse *Nsmf_PDUSession.UpdateSmContextResponse
+		localErr               error
+	)
+	ue.AwaitNf(func() {
+		updateSmContextReponse, localErr = client.IndividualSMContextApi.UpdateSmContext(ctx, &updateSmContextRequest)
+	})
 	if localErr == nil {
 		response = &updateSmContextReponse.UpdateSmContextResponse200
 	} else {
@@ -541,8 +608,13 @@ func (s *nsmfService) SendReleaseSmContextRequest(ue *amf_context.AmfUe, smConte
 	if err != nil {
 		return nil, err
 	}
-	_, localErr := client.IndividualSMContextApi.ReleaseSmContext(
-		ctx, &releaseSmContextRequest)
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NSMF_PDUSESSION, "SendReleaseSmContextRequest")
+	defer span.End()
+
+	var localErr error
+	ue.AwaitNf(func() {
+		_, localErr = client.IndividualSMContextApi.ReleaseSmContext(ctx, &releaseSmContextRequest)
+	})
 
 	if localErr == nil {
 		ue.DeleteSmContext(smContext.PduSessionID(), smContext.AccessType())
@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+)
+
+const (
+	tceReportTimeout = 5 * time.Second
+	// number of Cell Traffic Trace records waiting to be reported, the newer ones are dropped beyond
+	tceReportQueueSize = 1024
+)
+
+// tceService reports the Cell Traffic Trace records to the Trace Collection Entity.
+// TS 32.422 leaves the AMF-TCE interface open, the records are posted as JSON to the configured URI.
+// The records are queued and posted one by one from a goroutine of their own, so that the TCE does not
+// hold up the NGAP worker that handled the Cell Traffic Trace.
+type tceService struct {
+	consumer *Consumer
+
+	client    *http.Client
+	startOnce sync.Once
+	reports   chan tceReport
+}
+
+type tceReport struct {
+	tceUri string
+	record amf_context.TraceRecord
+}
+
+// ReportCellTrafficTrace queues the record to be reported to the TCE at tceUri,
+// it returns false if the queue is full and the record is dropped
+func (s *tceService) ReportCellTrafficTrace(tceUri string, record *amf_context.TraceRecord) bool {
+	s.startOnce.Do(func() {
+		s.reports = make(chan tceReport, tceReportQueueSize)
+		go s.reportLoop()
+	})
+	select {
+	case s.reports <- tceReport{tceUri: tceUri, record: *record}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *tceService) reportLoop() {
+	for report := range s.reports {
+		if err := s.postCellTrafficTrace(report.tceUri, &report.record); err != nil {
+			logger.ConsumerLog.Errorf("Report Cell Traffic Trace[%s] to TCE[%s] failed: %+v",
+				report.record.TraceRef, report.tceUri, err)
+		}
+	}
+}
+
+func (s *tceService) postCellTrafficTrace(tceUri string, record *amf_context.TraceRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal trace record failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tceReportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tceUri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rspCloseErr := rsp.Body.Close(); rspCloseErr != nil {
+			logger.ConsumerLog.Errorf("Close TCE response body failed: %+v", rspCloseErr)
+		}
+	}()
+	if rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("TCE responded with status %d", rsp.StatusCode)
+	}
+	return nil
+}
@@ -68,7 +68,9 @@ func (s *nudmService) getUEContextMngmntClient(uri string) *Nudm_UEContextManage
 	return client
 }
 
-func (s *nudmService) PutUpuAck(ue *amf_context.AmfUe, upuMacIue string) error {
+// PutUpuAck provides the UDM with the acknowledgement of the UE parameters update by the UE, or with the
+// unreachability of the UE (Nudm_SDM_Info, TS 23.502 4.20.2)
+func (s *nudmService) PutUpuAck(ue *amf_context.AmfUe, ackInfo *models.AcknowledgeInfo) error {
 	client := s.getSubscriberDMngmntClients(ue.NudmSDMUri)
 	if client == nil {
 		return openapi.ReportError("udm not found")
@@ -78,13 +80,12 @@ func (s *nudmService) PutUpuAck(ue *amf_context.AmfUe, upuMacIue string) error {
 	if err != nil {
 		return err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "PutUpuAck")
+	defer span.End()
 
-	ackInfo := models.AcknowledgeInfo{
-		UpuMacIue: upuMacIue,
-	}
 	upuReq := Nudm_SubscriberDataManagement.UpuAckRequest{
 		Supi:            &ue.Supi,
-		AcknowledgeInfo: &ackInfo,
+		AcknowledgeInfo: ackInfo,
 	}
 	_, err = client.ProvidingAcknowledgementOfUEParametersUpdateApi.
 		UpuAck(ctx, &upuReq)
@@ -92,17 +93,47 @@ func (s *nudmService) PutUpuAck(ue *amf_context.AmfUe, upuMacIue string) error {
 	return err
 }
 
+// PutSorAck provides the UDM with the acknowledgement of the steering of roaming information by the UE, or with
+// the unreachability of the UE (Nudm_SDM_Info, TS 23.122 C.2)
+func (s *nudmService) PutSorAck(ue *amf_context.AmfUe, ackInfo *models.AcknowledgeInfo) error {
+	client := s.getSubscriberDMngmntClients(ue.NudmSDMUri)
+	if client == nil {
+		return openapi.ReportError("udm not found")
+	}
+
+	ctx, _, err := amf_context.GetSelf().GetTokenCtx(models.ServiceName_NUDM_SDM, models.NrfNfManagementNfType_UDM)
+	if err != nil {
+		return err
+	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "PutSorAck")
+	defer span.End()
+
+	sorReq := Nudm_SubscriberDataManagement.SorAckInfoRequest{
+		Supi:            &ue.Supi,
+		AcknowledgeInfo: ackInfo,
+	}
+	_, err = client.ProvidingAcknowledgementOfSteeringOfRoamingApi.
+		SorAckInfo(ctx, &sorReq)
+
+	return err
+}
+
 func (s *nudmService) SDMGetAmData(ue *amf_context.AmfUe) (problemDetails *models.ProblemDetails, err error) {
 	client := s.getSubscriberDMngmntClients(ue.NudmSDMUri)
 	if client == nil {
 		return nil, openapi.ReportError("udm not found")
 	}
 
+	plmnId := ue.PlmnId
+	if ue.IsInboundRoamer() {
+		// the access and mobility subscription data of the inbound roamer are the ones for the serving PLMN
+		plmnId = ue.ServingPlmnId()
+	}
 	getAmDataParamReq := Nudm_SubscriberDataManagement.GetAmDataRequest{
 		Supi: &ue.Supi,
 		PlmnId: &models.PlmnIdNid{
-			Mnc: ue.PlmnId.Mnc,
-			Mcc: ue.PlmnId.Mcc,
+			Mnc: plmnId.Mnc,
+			Mcc: plmnId.Mcc,
 		},
 	}
 
@@ -110,11 +141,15 @@ func (s *nudmService) SDMGetAmData(ue *amf_context.AmfUe) (problemDetails *model
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "SDMGetAmData")
+	defer span.End()
 
 	data, localErr := client.AccessAndMobilitySubscriptionDataRetrievalApi.GetAmData(
 		ctx, &getAmDataParamReq)
 	if localErr == nil {
 		ue.AccessAndMobilitySubscriptionData = &data.AccessAndMobilitySubscriptionData
+		// Subscriber/equipment trace (TS 32.422 4.1.2.2), activated in the NG-RAN by the AMF
+		ue.TraceData = data.AccessAndMobilitySubscriptionData.TraceData
 		if len(data.AccessAndMobilitySubscriptionData.Gpsis) > 0 {
 			ue.Gpsi = data.AccessAndMobilitySubscriptionData.Gpsis[0] // TODO: select GPSI
 		}
@@ -155,6 +190,8 @@ func (s *nudmService) SDMGetSmfSelectData(ue *amf_context.AmfUe) (problemDetails
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "SDMGetSmfSelectData")
+	defer span.End()
 
 	data, localErr := client.SMFSelectionSubscriptionDataRetrievalApi.
 		GetSmfSelData(ctx, &paramReq)
@@ -196,6 +233,8 @@ func (s *nudmService) SDMGetUeContextInSmfData(
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "SDMGetUeContextInSmfData")
+	defer span.End()
 
 	getUeCtxInSmfDataReq := Nudm_SubscriberDataManagement.GetUeCtxInSmfDataRequest{
 		Supi: &ue.Supi,
@@ -227,6 +266,13 @@ func (s *nudmService) SDMGetUeContextInSmfData(
 	return problemDetails, err
 }
 
+// Subscriber data resources of a UE the AMF subscribes to the changes of
+const (
+	SdmResourceAmData        = "am-data"
+	SdmResourceNssai         = "nssai"
+	SdmResourceSmfSelectData = "smf-select-data"
+)
+
 func (s *nudmService) SDMSubscribe(ue *amf_context.AmfUe) (problemDetails *models.ProblemDetails, err error) {
 	client := s.getSubscriberDMngmntClients(ue.NudmSDMUri)
 	if client == nil {
@@ -234,9 +280,16 @@ func (s *nudmService) SDMSubscribe(ue *amf_context.AmfUe) (problemDetails *model
 	}
 
 	amfSelf := amf_context.GetSelf()
+	// the UDM notifies the changes of the subscriber data the AMF keeps in the UE context (TS 29.503 5.2.2.3.2)
 	sdmSubscription := models.SdmSubscription{
-		NfInstanceId: amfSelf.NfId,
-		PlmnId:       &ue.PlmnId,
+		NfInstanceId:      amfSelf.NfId,
+		CallbackReference: amfSelf.GetIPv4Uri() + factory.AmfCallbackResUriPrefix + "/sdm-notify/" + ue.Supi,
+		MonitoredResourceUris: []string{
+			ue.Supi + "/" + SdmResourceAmData,
+			ue.Supi + "/" + SdmResourceNssai,
+			ue.Supi + "/" + SdmResourceSmfSelectData,
+		},
+		PlmnId: &ue.PlmnId,
 	}
 
 	subscribeReq := Nudm_SubscriberDataManagement.SubscribeRequest{
@@ -248,6 +301,8 @@ func (s *nudmService) SDMSubscribe(ue *amf_context.AmfUe) (problemDetails *model
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "SDMSubscribe")
+	defer span.End()
 
 	resSubscription, localErr := client.SubscriptionCreationApi.Subscribe(
 		ctx, &subscribeReq)
@@ -292,31 +347,14 @@ func (s *nudmService) SDMGetSliceSelectionSubscriptionData(
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "SDMGetSliceSelectionSubscriptionData")
+	defer span.End()
 
 	nssai, localErr := client.SliceSelectionSubscriptionDataRetrievalApi.
 		GetNSSAI(ctx, &paramReq)
 
 	if localErr == nil {
-		for _, defaultSnssai := range nssai.Nssai.DefaultSingleNssais {
-			subscribedSnssai := models.SubscribedSnssai{
-				SubscribedSnssai: &models.Snssai{
-					Sst: defaultSnssai.Sst,
-					Sd:  defaultSnssai.Sd,
-				},
-				DefaultIndication: true,
-			}
-			ue.SubscribedNssai = append(ue.SubscribedNssai, subscribedSnssai)
-		}
-		for _, snssai := range nssai.Nssai.SingleNssais {
-			subscribedSnssai := models.SubscribedSnssai{
-				SubscribedSnssai: &models.Snssai{
-					Sst: snssai.Sst,
-					Sd:  snssai.Sd,
-				},
-				DefaultIndication: false,
-			}
-			ue.SubscribedNssai = append(ue.SubscribedNssai, subscribedSnssai)
-		}
+		ue.UpdateSubscribedNssai(&nssai.Nssai)
 	} else {
 		err = localErr
 		// API error
@@ -349,6 +387,8 @@ func (s *nudmService) SDMUnsubscribe(ue *amf_context.AmfUe) (problemDetails *mod
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_SDM, "SDMUnsubscribe")
+	defer span.End()
 
 	unsubscribeReq := Nudm_SubscriberDataManagement.UnsubscribeRequest{
 		UeId:           &ue.Supi,
@@ -392,6 +432,8 @@ func (s *nudmService) UeCmRegistration(
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_UECM, "UeCmRegistration")
+	defer span.End()
 
 	switch accessType {
 	case models.AccessType__3_GPP_ACCESS:
@@ -499,6 +541,8 @@ func (s *nudmService) UeCmDeregistration(
 	if err != nil {
 		return nil, err
 	}
+	ctx, span := startSbiSpan(ctx, ue, models.ServiceName_NUDM_UECM, "UeCmDeregistration")
+	defer span.End()
 
 	switch accessType {
 	case models.AccessType__3_GPP_ACCESS:
@@ -1,19 +1,24 @@
 package processor
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"reflect"
 	"runtime/debug"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/gmm"
 	gmm_common "github.com/free5gc/amf/internal/gmm/common"
-	gmm_message "github.com/free5gc/amf/internal/gmm/message"
 	"github.com/free5gc/amf/internal/logger"
 	amf_nas "github.com/free5gc/amf/internal/nas"
-	ngap_message "github.com/free5gc/amf/internal/ngap/message"
+	"github.com/free5gc/amf/internal/sbi/consumer"
+	"github.com/free5gc/nas/nasMessage"
 	"github.com/free5gc/ngap/ngapType"
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/util/metrics/sbi"
@@ -159,47 +164,25 @@ func (p *Processor) AmPolicyControlUpdateNotifyUpdateProcedure(polAssoID string,
 	}
 
 	if ue != nil {
-		// use go routine to write response first to ensure the order of the procedure
-		go func() {
-			defer func() {
-				if p := recover(); p != nil {
-					// Print stack for panic to log. Fatalf() will let program exit.
-					logger.CallbackLog.Fatalf("panic: %v\n%s", p, string(debug.Stack()))
-				}
-			}()
-
-			configurationUpdateCommandFlags := &context.ConfigurationUpdateCommandFlags{
-				NeedGUTI:            true,
-				NeedAllowedNSSAI:    true,
-				NeedConfiguredNSSAI: true,
-				NeedRejectNSSAI:     true,
-				NeedTaiList:         true,
-				NeedNITZ:            true,
-				NeedLadnInformation: true,
-			}
+		configurationUpdateCommandFlags := &context.ConfigurationUpdateCommandFlags{
+			NeedGUTI:            true,
+			NeedAllowedNSSAI:    true,
+			NeedConfiguredNSSAI: true,
+			NeedRejectNSSAI:     true,
+			NeedTaiList:         true,
+			NeedNITZ:            true,
+			NeedLadnInformation: true,
+		}
 
-			// UE is CM-Connected State
-			if ue.CmConnect(models.AccessType__3_GPP_ACCESS) {
-				gmm_message.SendConfigurationUpdateCommand(ue,
-					models.AccessType__3_GPP_ACCESS,
-					configurationUpdateCommandFlags,
-				)
-			} else {
-				// UE is CM-IDLE => paging
-				ue.ConfigurationUpdateCommandFlags = configurationUpdateCommandFlags
-
-				ue.SetOnGoing(models.AccessType__3_GPP_ACCESS, &context.OnGoing{
-					Procedure: context.OnGoingProcedurePaging,
-				})
-
-				pkg, err := ngap_message.BuildPaging(ue, nil, false)
-				if err != nil {
-					logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
-					return
-				}
-				ngap_message.SendPaging(ue, pkg)
-			}
-		}()
+		// posted to write response first to ensure the order of the procedure; the UE in CM-IDLE state is paged
+		if !ue.Post(func() {
+			ue.Lock.Lock()
+			defer ue.Lock.Unlock()
+			gmm.UpdateConfiguration(ue, models.AccessType__3_GPP_ACCESS, configurationUpdateCommandFlags)
+		}) {
+			ue.ProducerLog.Errorln("AM Policy Update not pushed to the UE: its event loop rejected the update")
+			return ueEventProblem(context.ErrUeEventRejected)
+		}
 	}
 	return nil
 }
@@ -382,3 +365,219 @@ func (p *Processor) N1MessageNotifyProcedure(n1MessageNotify models.N1MessageNot
 	}()
 	return nil
 }
+
+// TS 29.503 5.2.2.3.2 Data Change Notification To NF
+func (p *Processor) HandleSdmModificationNotify(c *gin.Context, notification models.ModificationNotification) {
+	logger.CallbackLog.Infoln("Handle SDM Modification Notification")
+
+	problemDetails := p.SdmModificationNotifyProcedure(c.Param("supi"), notification)
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (p *Processor) SdmModificationNotifyProcedure(supi string,
+	notification models.ModificationNotification,
+) *models.ProblemDetails {
+	ue, ok := context.GetSelf().AmfUeFindBySupi(supi)
+	if !ok {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+			Detail: fmt.Sprintf("Supi[%s] Not Found", supi),
+		}
+	}
+
+	// the changed resources are retrieved again rather than patched, the notification may carry partial changes,
+	// except the UE parameters update and steering of roaming information the UDM only notifies
+	changed := make(map[string]bool)
+	var upuInfo *models.UdmSdmUpuInfo
+	var sorInfo *models.UdmSdmSorInfo
+	for _, item := range notification.NotifyItems {
+		itemUpuInfo, itemSorInfo, others := parametersUpdateChanges(item)
+		if itemUpuInfo != nil {
+			upuInfo = itemUpuInfo
+		}
+		if itemSorInfo != nil {
+			sorInfo = itemSorInfo
+		}
+		if others {
+			changed[path.Base(item.ResourceId)] = true
+		}
+	}
+
+	// posted to write response first to ensure the order of the procedure
+	if !ue.Post(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+		p.applySubscriptionChanges(ue, changed)
+		if upuInfo != nil {
+			gmm.UpdateUeParameters(ue, upuInfo)
+		}
+		if sorInfo != nil {
+			gmm.SteerRoaming(ue, sorInfo)
+		}
+	}) {
+		ue.ProducerLog.Errorln("Subscription data change not applied: the event loop of the UE rejected it")
+		return ueEventProblem(context.ErrUeEventRejected)
+	}
+	return nil
+}
+
+// parametersUpdateChanges returns the UE parameters update and steering of roaming information the notify item
+// carries (TS 23.502 4.20.2, TS 23.122 C.4), and whether it has other changes
+func parametersUpdateChanges(item models.NotifyItem) (
+	upuInfo *models.UdmSdmUpuInfo, sorInfo *models.UdmSdmSorInfo, others bool,
+) {
+	if len(item.Changes) == 0 {
+		return nil, nil, true
+	}
+	for _, change := range item.Changes {
+		var err error
+		switch strings.TrimPrefix(change.Path, "/") {
+		case "upuInfo":
+			if change.Op != models.ChangeType_REMOVE {
+				upuInfo = new(models.UdmSdmUpuInfo)
+				err = decodeChangeValue(change.NewValue, upuInfo)
+			}
+		case "sorInfo":
+			if change.Op != models.ChangeType_REMOVE {
+				sorInfo = new(models.UdmSdmSorInfo)
+				err = decodeChangeValue(change.NewValue, sorInfo)
+			}
+		default:
+			others = true
+		}
+		if err != nil {
+			logger.CallbackLog.Warnf("Invalid %s change of %s: %+v", change.Path, item.ResourceId, err)
+			return nil, nil, true
+		}
+	}
+	return upuInfo, sorInfo, others
+}
+
+func decodeChangeValue(value map[string]interface{}, out interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// applySubscriptionChanges retrieves the changed subscriber data of the UE and applies the changes live:
+// the UE no longer allowed to access is deregistered, the PCF is notified of the changes of its policy inputs,
+// and the UE gets the updated NSSAI and mobility restrictions in a Configuration Update Command
+func (p *Processor) applySubscriptionChanges(ue *context.AmfUe, changed map[string]bool) {
+	var changes context.SubscriptionChanges
+	if changed[consumer.SdmResourceAmData] {
+		old := ue.AccessAndMobilitySubscriptionData
+		if problemDetails, err := p.Consumer().SDMGetAmData(ue); problemDetails != nil {
+			ue.ProducerLog.Errorf("SDM Get AmData Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			ue.ProducerLog.Errorf("SDM Get AmData Error[%+v]", err)
+		} else {
+			changes = ue.AmDataChanges(old)
+		}
+	}
+	nssaiChanged := false
+	if changed[consumer.SdmResourceNssai] {
+		old := ue.SubscribedNssai
+		if problemDetails, err := p.Consumer().SDMGetSliceSelectionSubscriptionData(ue); problemDetails != nil {
+			ue.ProducerLog.Errorf("SDM Get Slice Selection Subscription Data Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			ue.ProducerLog.Errorf("SDM Get Slice Selection Subscription Data Error[%+v]", err)
+		} else {
+			nssaiChanged = !reflect.DeepEqual(old, ue.SubscribedNssai)
+		}
+	}
+	smfSelectDataChanged := false
+	if changed[consumer.SdmResourceSmfSelectData] {
+		if problemDetails, err := p.Consumer().SDMGetSmfSelectData(ue); problemDetails != nil {
+			ue.ProducerLog.Errorf("SDM Get SmfSelectData Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			ue.ProducerLog.Errorf("SDM Get SmfSelectData Error[%+v]", err)
+		} else {
+			smfSelectDataChanged = true
+		}
+	}
+
+	if changes.DeregistrationCause != 0 {
+		p.deregisterBarredUe(ue, changes.DeregistrationCause)
+		return
+	}
+	// the new SMF selection data applies to the PDU sessions established from now on, and to the established ones
+	// released when their S-NSSAI and DNN are no longer subscribed
+	if smfSelectDataChanged {
+		gmm.ReleaseUnsubscribedPduSessions(ue)
+	}
+
+	triggers := changes.PolicyTriggers
+	if nssaiChanged {
+		ue.RestrictNssaiToSubscription()
+		triggers = append(triggers, models.PcfAmPolicyControlRequestTrigger_ALLOWED_NSSAI_CH)
+	}
+	if len(triggers) > 0 && ue.AmPolicyAssociation != nil {
+		updateRequest := models.PcfAmPolicyControlPolicyAssociationUpdateRequest{
+			Triggers: triggers,
+		}
+		if data := ue.AccessAndMobilitySubscriptionData; data != nil {
+			updateRequest.ServAreaRes = data.ServiceAreaRestriction
+			updateRequest.Rfsp = data.RfspIndex
+			if data.SubscribedUeAmbr != nil {
+				updateRequest.UeAmbr = &models.Ambr{
+					Uplink:   data.SubscribedUeAmbr.Uplink,
+					Downlink: data.SubscribedUeAmbr.Downlink,
+				}
+			}
+		}
+		for _, allowedSnssai := range ue.AllowedNssai[models.AccessType__3_GPP_ACCESS] {
+			updateRequest.AllowedSnssais = append(updateRequest.AllowedSnssais, *allowedSnssai.AllowedSnssai)
+		}
+		for _, allowedSnssai := range ue.AllowedNssai[models.AccessType_NON_3_GPP_ACCESS] {
+			updateRequest.N3gAllowedSnssais = append(updateRequest.N3gAllowedSnssais, *allowedSnssai.AllowedSnssai)
+		}
+		if problemDetails, err := p.Consumer().AMPolicyControlUpdate(ue, updateRequest); problemDetails != nil {
+			ue.ProducerLog.Errorf("AM Policy Control Update Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			ue.ProducerLog.Errorf("AM Policy Control Update Error[%+v]", err)
+		}
+	}
+
+	if !nssaiChanged && !changes.MobilityRestrictions {
+		return
+	}
+	// the Downlink NAS Transport of the command carries the new Mobility Restriction List to NG-RAN when the IE is
+	// enabled, as the Initial Context Setup Request does for the UE paged for it in CM-IDLE state
+	configurationUpdateCommandFlags := &context.ConfigurationUpdateCommandFlags{
+		NeedAllowedNSSAI:    nssaiChanged,
+		NeedConfiguredNSSAI: nssaiChanged,
+		NeedRejectNSSAI:     nssaiChanged,
+		NeedServiceAreaList: changes.MobilityRestrictions,
+	}
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		gmm.UpdateConfiguration(ue, anType, configurationUpdateCommandFlags)
+	}
+}
+
+// deregisterBarredUe deregisters the UE the subscription no longer allows to access the network:
+// over the accesses it is connected to with the 5GMM cause, and implicitly when it is connected to none
+func (p *Processor) deregisterBarredUe(ue *context.AmfUe, cause5GMM uint8) {
+	ue.ProducerLog.Infof("Subscription no longer allows the UE to access, deregister it (cause: %s)",
+		nasMessage.Cause5GMMToString(cause5GMM))
+	connected := false
+	for _, anType := range []models.AccessType{models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS} {
+		if !ue.CmConnect(anType) {
+			continue
+		}
+		connected = true
+		if err := gmm.InitiateDeregistration(ue, anType, false, cause5GMM); err != nil {
+			ue.ProducerLog.Errorf("Deregistration over %s failed: %+v", anType, err)
+		}
+	}
+	if !connected {
+		gmm_common.RemoveAmfUe(ue, true)
+	}
+}
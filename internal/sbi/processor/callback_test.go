@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestParametersUpdateChanges(t *testing.T) {
+	resourceId := "imsi-208930000000003/am-data"
+
+	// the UE parameters update and steering of roaming information are taken from the changes
+	upuInfo, sorInfo, others := parametersUpdateChanges(models.NotifyItem{
+		ResourceId: resourceId,
+		Changes: []models.ChangeItem{
+			{
+				Op:   models.ChangeType_REPLACE,
+				Path: "/upuInfo",
+				NewValue: map[string]interface{}{
+					"upuAckInd":   true,
+					"upuMacIausf": "000102030405060708090a0b0c0d0e0f",
+					"counterUpu":  "0001",
+				},
+			},
+			{
+				Op:   models.ChangeType_ADD,
+				Path: "/sorInfo",
+				NewValue: map[string]interface{}{
+					"ackInd":           true,
+					"provisioningTime": "2026-01-02T03:04:05Z",
+				},
+			},
+		},
+	})
+	require.False(t, others)
+	require.Equal(t, &models.UdmSdmUpuInfo{
+		UpuAckInd:   true,
+		UpuMacIausf: "000102030405060708090a0b0c0d0e0f",
+		CounterUpu:  "0001",
+	}, upuInfo)
+	require.NotNil(t, sorInfo)
+	require.True(t, sorInfo.AckInd)
+	require.Equal(t, "2026-01-02T03:04:05Z", sorInfo.ProvisioningTime.Format("2006-01-02T15:04:05Z07:00"))
+
+	// the other changes have the resource retrieved again
+	upuInfo, sorInfo, others = parametersUpdateChanges(models.NotifyItem{
+		ResourceId: resourceId,
+		Changes:    []models.ChangeItem{{Op: models.ChangeType_REPLACE, Path: "/rfspIndex"}},
+	})
+	require.True(t, others)
+	require.Nil(t, upuInfo)
+	require.Nil(t, sorInfo)
+	_, _, others = parametersUpdateChanges(models.NotifyItem{ResourceId: resourceId})
+	require.True(t, others)
+}
@@ -1,9 +1,11 @@
 package processor
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -108,46 +110,102 @@ func (p *Processor) CreateAMFEventSubscriptionProcedure(createEventSubscription
 		}
 	}
 
-	if subscription.AnyUE {
-		contextEventSubscription.IsAnyUe = true
-		ueEventSubscription.AnyUe = true
-		amfSelf.UePool.Range(func(key, value interface{}) bool {
-			ue := value.(*context.AmfUe)
-			ue.Lock.Lock()
-			ue.EventSubscriptionsInfo[newSubscriptionID] = new(context.AmfUeEventSubscription)
-			*ue.EventSubscriptionsInfo[newSubscriptionID] = ueEventSubscription
-			contextEventSubscription.UeSupiList = append(contextEventSubscription.UeSupiList, ue.Supi)
-			ue.Lock.Unlock()
-			return true
-		})
-	} else if subscription.GroupId != "" {
-		contextEventSubscription.IsGroupUe = true
+	// the subscription is installed and the immediate reports built in the event loop of each UE
+	subscribeUe := func(ue *context.AmfUe) (reports []models.AmfEventReport, subscribed bool) {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		if subscription.GroupId != "" && ue.GroupID != subscription.GroupId {
+			return nil, false
+		}
+		ue.EventSubscriptionsInfo[newSubscriptionID] = new(context.AmfUeEventSubscription)
+		*ue.EventSubscriptionsInfo[newSubscriptionID] = ueEventSubscription
+
+		if isImmediate {
+			p.subReports(ue, newSubscriptionID)
+		}
+		for i, flag := range immediateFlags {
+			if flag {
+				report, ok := p.newAmfEventReport(ue, subscription.EventList[i].Type, newSubscriptionID)
+				if ok {
+					reports = append(reports, report)
+				}
+			}
+		}
+		// delete subscription
+		if reportsLen := len(reports); reportsLen > 0 && (!reports[reportsLen-1].State.Active) {
+			delete(ue.EventSubscriptionsInfo, newSubscriptionID)
+		}
+		return reports, true
+	}
+
+	if subscription.AnyUE || subscription.GroupId != "" {
+		if subscription.AnyUE {
+			contextEventSubscription.IsAnyUe = true
+		} else {
+			contextEventSubscription.IsGroupUe = true
+		}
 		ueEventSubscription.AnyUe = true
+
+		// the UEs are subscribed concurrently; a UE whose event loop does not start the event in time is
+		// subscribed once its event loop runs the event, without immediate reports
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			lateUes  []string
+			ueErrors int
+		)
 		amfSelf.UePool.Range(func(key, value interface{}) bool {
 			ue := value.(*context.AmfUe)
-			ue.Lock.Lock()
-			if ue.GroupID == subscription.GroupId {
-				ue.EventSubscriptionsInfo[newSubscriptionID] = new(context.AmfUeEventSubscription)
-				*ue.EventSubscriptionsInfo[newSubscriptionID] = ueEventSubscription
-				contextEventSubscription.UeSupiList = append(contextEventSubscription.UeSupiList, ue.Supi)
-			}
-			ue.Lock.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var (
+					reports    []models.AmfEventReport
+					subscribed bool
+				)
+				err := ue.Run(func() {
+					reports, subscribed = subscribeUe(ue)
+				})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case errors.Is(err, context.ErrUeEventNotStarted) && ue.Post(func() { subscribeUe(ue) }):
+					lateUes = append(lateUes, ue.Supi)
+					contextEventSubscription.UeSupiList = append(contextEventSubscription.UeSupiList, ue.Supi)
+				case err != nil:
+					ueErrors++
+					logger.EeLog.Errorf("Subscription[%s] not installed for UE[%s]: %v", newSubscriptionID, ue.Supi, err)
+				case subscribed:
+					contextEventSubscription.UeSupiList = append(contextEventSubscription.UeSupiList, ue.Supi)
+					reportlist = append(reportlist, reports...)
+				}
+			}()
 			return true
 		})
+		wg.Wait()
+		if len(lateUes) > 0 {
+			logger.EeLog.Warnf("Subscription[%s]: no immediate report of %d UE(s) not answering in time %v",
+				newSubscriptionID, len(lateUes), lateUes)
+		}
+		if ueErrors > 0 {
+			logger.EeLog.Warnf("Subscription[%s]: not installed for %d UE(s)", newSubscriptionID, ueErrors)
+		}
 	} else {
-		if ue, ok := amfSelf.AmfUeFindBySupi(subscription.Supi); !ok {
+		ue, ok := amfSelf.AmfUeFindBySupi(subscription.Supi)
+		if !ok {
 			problemDetails := &models.ProblemDetails{
 				Status: http.StatusForbidden,
 				Cause:  "UE_NOT_SERVED_BY_AMF",
 			}
 			return nil, problemDetails
-		} else {
-			ue.Lock.Lock()
-			ue.EventSubscriptionsInfo[newSubscriptionID] = new(context.AmfUeEventSubscription)
-			*ue.EventSubscriptionsInfo[newSubscriptionID] = ueEventSubscription
-			contextEventSubscription.UeSupiList = append(contextEventSubscription.UeSupiList, ue.Supi)
-			ue.Lock.Unlock()
 		}
+		if err := ue.Run(func() {
+			reportlist, _ = subscribeUe(ue)
+		}); err != nil {
+			return nil, ueEventProblem(err)
+		}
+		contextEventSubscription.UeSupiList = append(contextEventSubscription.UeSupiList, ue.Supi)
 	}
 
 	// delete subscription
@@ -161,76 +219,6 @@ func (p *Processor) CreateAMFEventSubscriptionProcedure(createEventSubscription
 	createdEventSubscription.Subscription = subscription
 	createdEventSubscription.SubscriptionId = newSubscriptionID
 
-	// for immediate use
-	if subscription.AnyUE {
-		amfSelf.UePool.Range(func(key, value interface{}) bool {
-			ue := value.(*context.AmfUe)
-			ue.Lock.Lock()
-			defer ue.Lock.Unlock()
-
-			if isImmediate {
-				p.subReports(ue, newSubscriptionID)
-			}
-			for i, flag := range immediateFlags {
-				if flag {
-					report, ok := p.newAmfEventReport(ue, subscription.EventList[i].Type, newSubscriptionID)
-					if ok {
-						reportlist = append(reportlist, report)
-					}
-				}
-			}
-			// delete subscription
-			if reportlistLen := len(reportlist); reportlistLen > 0 && (!reportlist[reportlistLen-1].State.Active) {
-				delete(ue.EventSubscriptionsInfo, newSubscriptionID)
-			}
-			return true
-		})
-	} else if subscription.GroupId != "" {
-		amfSelf.UePool.Range(func(key, value interface{}) bool {
-			ue := value.(*context.AmfUe)
-			ue.Lock.Lock()
-			defer ue.Lock.Unlock()
-
-			if isImmediate {
-				p.subReports(ue, newSubscriptionID)
-			}
-			if ue.GroupID == subscription.GroupId {
-				for i, flag := range immediateFlags {
-					if flag {
-						report, ok := p.newAmfEventReport(ue, subscription.EventList[i].Type, newSubscriptionID)
-						if ok {
-							reportlist = append(reportlist, report)
-						}
-					}
-				}
-				// delete subscription
-				if reportlistLen := len(reportlist); reportlistLen > 0 && (!reportlist[reportlistLen-1].State.Active) {
-					delete(ue.EventSubscriptionsInfo, newSubscriptionID)
-				}
-			}
-			return true
-		})
-	} else {
-		ue, _ := amfSelf.AmfUeFindBySupi(subscription.Supi)
-		ue.Lock.Lock()
-		defer ue.Lock.Unlock()
-
-		if isImmediate {
-			p.subReports(ue, newSubscriptionID)
-		}
-		for i, flag := range immediateFlags {
-			if flag {
-				report, ok := p.newAmfEventReport(ue, subscription.EventList[i].Type, newSubscriptionID)
-				if ok {
-					reportlist = append(reportlist, report)
-				}
-			}
-		}
-		// delete subscription
-		if reportlistLen := len(reportlist); reportlistLen > 0 && (!reportlist[reportlistLen-1].State.Active) {
-			delete(ue.EventSubscriptionsInfo, newSubscriptionID)
-		}
-	}
 	if len(reportlist) > 0 {
 		createdEventSubscription.ReportList = reportlist
 		// delete subscription
@@ -270,9 +258,14 @@ func (p *Processor) DeleteAMFEventSubscriptionProcedure(subscriptionID string) *
 
 	for _, supi := range subscription.UeSupiList {
 		if ue, okAmfUeFindBySupi := amfSelf.AmfUeFindBySupi(supi); okAmfUeFindBySupi {
-			ue.Lock.Lock()
-			delete(ue.EventSubscriptionsInfo, subscriptionID)
-			ue.Lock.Unlock()
+			if !ue.Post(func() {
+				ue.Lock.Lock()
+				defer ue.Lock.Unlock()
+				delete(ue.EventSubscriptionsInfo, subscriptionID)
+			}) {
+				logger.EeLog.Errorf("Subscription[%s] left on UE[%s]: its event loop rejected the removal",
+					subscriptionID, supi)
+			}
 		}
 	}
 	amfSelf.DeleteEventSubscription(subscriptionID)
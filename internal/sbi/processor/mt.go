@@ -44,21 +44,25 @@ func (p *Processor) ProvideDomainSelectionInfoProcedure(ueContextID string, info
 		return nil, problemDetails
 	}
 
-	ue.Lock.Lock()
-	defer ue.Lock.Unlock()
-
 	ueContextInfo := new(models.UeContextInfo)
 
-	// TODO: Error Status 307, 403 in TS29.518 Table 6.3.3.3.3.1-3
-	anType := ue.GetAnType()
-	if anType != "" && infoClassQuery != "" {
-		ranUe := ue.RanUe[anType]
-		ueContextInfo.AccessType = anType
-		ueContextInfo.LastActTime = ranUe.LastActTime
-		ueContextInfo.RatType = ue.RatType
-		ueContextInfo.SupportedFeatures = ranUe.SupportedFeatures
-		ueContextInfo.SupportVoPS = ranUe.SupportVoPS
-		ueContextInfo.SupportVoPSn3gpp = ranUe.SupportVoPSn3gpp
+	if err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		// TODO: Error Status 307, 403 in TS29.518 Table 6.3.3.3.3.1-3
+		anType := ue.GetAnType()
+		if anType != "" && infoClassQuery != "" {
+			ranUe := ue.RanUe[anType]
+			ueContextInfo.AccessType = anType
+			ueContextInfo.LastActTime = ranUe.LastActTime
+			ueContextInfo.RatType = ue.RatType
+			ueContextInfo.SupportedFeatures = ranUe.SupportedFeatures
+			ueContextInfo.SupportVoPS = ranUe.SupportVoPS
+			ueContextInfo.SupportVoPSn3gpp = ranUe.SupportVoPSn3gpp
+		}
+	}); err != nil {
+		return nil, ueEventProblem(err)
 	}
 
 	return ueContextInfo, nil
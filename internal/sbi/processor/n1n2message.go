@@ -76,21 +76,10 @@ func (p *Processor) N1N2MessageTransferProcedure(ueContextID string, reqUri stri
 	locationHeader string, problemDetails *models.ProblemDetails,
 	transferErr *models.N1N2MessageTransferError,
 ) {
-	var (
-		requestData = n1n2MessageTransferRequest.JsonData
-		n2Info      = n1n2MessageTransferRequest.BinaryDataN2Information
-		n1Msg       = n1n2MessageTransferRequest.BinaryDataN1Message
-
-		ue        *context.AmfUe
-		ok        bool
-		smContext *context.SmContext
-		n1MsgType uint8
-		anType    = models.AccessType__3_GPP_ACCESS
-	)
-
 	amfSelf := context.GetSelf()
 
-	if ue, ok = amfSelf.AmfUeFindByUeContextID(ueContextID); !ok {
+	ue, ok := amfSelf.AmfUeFindByUeContextID(ueContextID)
+	if !ok {
 		logger.CtxLog.Warnf("AmfUe Context[%s] not found", ueContextID)
 		problemDetails = &models.ProblemDetails{
 			Status: http.StatusNotFound,
@@ -99,8 +88,35 @@ func (p *Processor) N1N2MessageTransferProcedure(ueContextID string, reqUri stri
 		return nil, "", problemDetails, nil
 	}
 
-	ue.Lock.Lock()
-	defer ue.Lock.Unlock()
+	if err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr = p.n1n2MessageTransfer(
+			ue, reqUri, n1n2MessageTransferRequest)
+	}); err != nil {
+		return nil, "", ueEventProblem(err), nil
+	}
+	return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
+}
+
+// n1n2MessageTransfer handles the N1N2 Message Transfer request in the event loop of the UE
+func (p *Processor) n1n2MessageTransfer(ue *context.AmfUe, reqUri string,
+	n1n2MessageTransferRequest models.N1N2MessageTransferRequest) (
+	n1n2MessageTransferRspData *models.N1N2MessageTransferRspData,
+	locationHeader string, problemDetails *models.ProblemDetails,
+	transferErr *models.N1N2MessageTransferError,
+) {
+	var (
+		requestData = n1n2MessageTransferRequest.JsonData
+		n2Info      = n1n2MessageTransferRequest.BinaryDataN2Information
+		n1Msg       = n1n2MessageTransferRequest.BinaryDataN1Message
+
+		ok        bool
+		smContext *context.SmContext
+		n1MsgType uint8
+		anType    = models.AccessType__3_GPP_ACCESS
+	)
 
 	if requestData.N1MessageContainer != nil {
 		switch requestData.N1MessageContainer.N1MessageClass {
@@ -221,7 +237,11 @@ func (p *Processor) N1N2MessageTransferProcedure(ueContextID string, reqUri stri
 			}
 			if n2Info == nil {
 				ue.ProducerLog.Debug("Forward N1 Message to UE")
-				ngap_message.SendDownlinkNasTransport(ue.RanUe[anType], nasPdu, nil)
+				ngap_message.SendDownlinkNasTransportWithOrigin(ue.RanUe[anType], nasPdu, nil, context.DlNasOrigin{
+					Procedure:        context.DlNasProcedureN1N2MessageTransfer,
+					PduSessionId:     requestData.PduSessionId,
+					FailureNotifyUri: requestData.N1n2FailureTxfNotifURI,
+				})
 				n1n2MessageTransferRspData = new(models.N1N2MessageTransferRspData)
 				n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_N1_N2_TRANSFER_INITIATED
 				return n1n2MessageTransferRspData, "", nil, nil
@@ -327,83 +347,96 @@ func (p *Processor) N1N2MessageTransferProcedure(ueContextID string, reqUri stri
 	if anType == models.AccessType__3_GPP_ACCESS {
 		if requestData.SkipInd && n2Info == nil {
 			n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_N1_MSG_NOT_TRANSFERRED
-		} else {
-			n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE
-			message := context.N1N2Message{
-				Request:     n1n2MessageTransferRequest,
-				Status:      n1n2MessageTransferRspData.Cause,
-				ResourceUri: locationHeader,
-			}
-			ue.N1N2Message = &message
-			ue.SetOnGoing(anType, &context.OnGoing{
-				Procedure: context.OnGoingProcedurePaging,
-				Ppi:       requestData.Ppi,
-			})
-
-			if onGoing.Ppi != 0 {
-				pagingPriority = new(ngapType.PagingPriority)
-				pagingPriority.Value = aper.Enumerated(onGoing.Ppi)
-			}
-			pkg, err := ngap_message.BuildPaging(ue, pagingPriority, false)
+			return n1n2MessageTransferRspData, locationHeader, nil, nil
+		}
+		n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE
+		ue.N1N2Message = &context.N1N2Message{
+			Request:     n1n2MessageTransferRequest,
+			Status:      n1n2MessageTransferRspData.Cause,
+			ResourceUri: locationHeader,
+		}
+
+		// TS 23.502 4.2.3.3 step 4c, TS 24.501 5.6.3.1: the UE is CM-CONNECTED over non-3GPP access,
+		// notify it over non-3GPP access instead of paging it
+		if ue.CmConnect(models.AccessType_NON_3_GPP_ACCESS) &&
+			ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Registered) {
+			ue.ProducerLog.Infof("UE is CM-IDLE in 3GPP access but CM-CONNECTED in non-3GPP access, send Notification")
+			nasMsg, err := gmm_message.BuildNotification(ue, models.AccessType__3_GPP_ACCESS)
 			if err != nil {
-				logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
+				logger.GmmLog.Errorf("Build Notification failed : %s", err.Error())
 				return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
 			}
-			ngap_message.SendPaging(ue, pkg)
+			gmm_message.SendNotification(ue.RanUe[models.AccessType_NON_3_GPP_ACCESS], nasMsg)
+			return n1n2MessageTransferRspData, locationHeader, nil, nil
+		}
+
+		ue.SetOnGoing(anType, &context.OnGoing{
+			Procedure: context.OnGoingProcedurePaging,
+			Ppi:       requestData.Ppi,
+		})
+		if requestData.Ppi != 0 {
+			pagingPriority = new(ngapType.PagingPriority)
+			pagingPriority.Value = aper.Enumerated(requestData.Ppi)
 		}
+		pkg, err := ngap_message.BuildPaging(ue, pagingPriority, false)
+		if err != nil {
+			logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
+			return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
+		}
+		ngap_message.SendPaging(ue, pkg)
 		// TODO: WAITING_FOR_ASYNCHRONOUS_TRANSFER
 		return n1n2MessageTransferRspData, locationHeader, nil, nil
-	} else {
-		// Case B (UE is CM-IDLE in Non-3GPP access but CM-CONNECTED in 3GPP access and the associated
-		// access type is Non-3GPP access)in subclause 5.2.2.3.1.2 of TS29518
-		if ue.CmConnect(models.AccessType__3_GPP_ACCESS) {
-			if n2Info == nil {
-				n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_N1_N2_TRANSFER_INITIATED
-				gmm_message.SendDLNASTransport(ue.RanUe[models.AccessType__3_GPP_ACCESS],
-					nasMessage.PayloadContainerTypeN1SMInfo, n1Msg, requestData.PduSessionId, 0, nil, 0)
-			} else {
-				n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE
-				message := context.N1N2Message{
-					Request:     n1n2MessageTransferRequest,
-					Status:      n1n2MessageTransferRspData.Cause,
-					ResourceUri: locationHeader,
-				}
-				ue.N1N2Message = &message
-				nasMsg, err := gmm_message.BuildNotification(ue, models.AccessType_NON_3_GPP_ACCESS)
-				if err != nil {
-					logger.GmmLog.Errorf("Build Notification failed : %s", err.Error())
-					return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
-				}
-				gmm_message.SendNotification(ue.RanUe[models.AccessType__3_GPP_ACCESS], nasMsg)
-			}
-			return n1n2MessageTransferRspData, locationHeader, nil, nil
+	}
+
+	// Case B (UE is CM-IDLE in Non-3GPP access but CM-CONNECTED in 3GPP access and the associated
+	// access type is Non-3GPP access)in subclause 5.2.2.3.1.2 of TS29518
+	if ue.CmConnect(models.AccessType__3_GPP_ACCESS) {
+		if n2Info == nil {
+			n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_N1_N2_TRANSFER_INITIATED
+			gmm_message.SendN1N2DLNASTransport(ue.RanUe[models.AccessType__3_GPP_ACCESS],
+				nasMessage.PayloadContainerTypeN1SMInfo, n1Msg, requestData, "")
 		} else {
-			// Case C ( UE is CM-IDLE in both Non-3GPP access and 3GPP access and the associated access ype is Non-3GPP access)
-			// in subclause 5.2.2.3.1.2 of TS29518
 			n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE
-			message := context.N1N2Message{
+			ue.N1N2Message = &context.N1N2Message{
 				Request:     n1n2MessageTransferRequest,
 				Status:      n1n2MessageTransferRspData.Cause,
 				ResourceUri: locationHeader,
 			}
-			ue.N1N2Message = &message
-
-			ue.SetOnGoing(anType, &context.OnGoing{
-				Procedure: context.OnGoingProcedurePaging,
-				Ppi:       requestData.Ppi,
-			})
-			if onGoing.Ppi != 0 {
-				pagingPriority = new(ngapType.PagingPriority)
-				pagingPriority.Value = aper.Enumerated(onGoing.Ppi)
-			}
-			pkg, err := ngap_message.BuildPaging(ue, pagingPriority, true)
+			nasMsg, err := gmm_message.BuildNotification(ue, models.AccessType_NON_3_GPP_ACCESS)
 			if err != nil {
-				logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
+				logger.GmmLog.Errorf("Build Notification failed : %s", err.Error())
+				return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
 			}
-			ngap_message.SendPaging(ue, pkg)
-			return n1n2MessageTransferRspData, locationHeader, nil, nil
+			gmm_message.SendNotification(ue.RanUe[models.AccessType__3_GPP_ACCESS], nasMsg)
 		}
+		return n1n2MessageTransferRspData, locationHeader, nil, nil
+	}
+
+	// Case C ( UE is CM-IDLE in both Non-3GPP access and 3GPP access and the associated access ype is Non-3GPP access)
+	// in subclause 5.2.2.3.1.2 of TS29518: page over 3GPP access with paging origin non-3GPP
+	n1n2MessageTransferRspData.Cause = models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE
+	ue.N1N2Message = &context.N1N2Message{
+		Request:     n1n2MessageTransferRequest,
+		Status:      n1n2MessageTransferRspData.Cause,
+		ResourceUri: locationHeader,
+	}
+
+	// the paging is carried over 3GPP access, so the ongoing procedure is tracked there
+	ue.SetOnGoing(models.AccessType__3_GPP_ACCESS, &context.OnGoing{
+		Procedure: context.OnGoingProcedurePaging,
+		Ppi:       requestData.Ppi,
+	})
+	if requestData.Ppi != 0 {
+		pagingPriority = new(ngapType.PagingPriority)
+		pagingPriority.Value = aper.Enumerated(requestData.Ppi)
+	}
+	pkg, err := ngap_message.BuildPaging(ue, pagingPriority, true)
+	if err != nil {
+		logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
+		return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
 	}
+	ngap_message.SendPaging(ue, pkg)
+	return n1n2MessageTransferRspData, locationHeader, nil, nil
 }
 
 func (p *Processor) HandleN1N2MessageTransferStatusRequest(c *gin.Context) {
@@ -24,27 +24,7 @@ func SendN1N2TransferFailureNotification(ue *amf_context.AmfUe, cause models.N1N
 	n1n2Message := ue.N1N2Message
 	uri := n1n2Message.Request.JsonData.N1n2FailureTxfNotifURI
 	if n1n2Message.Status == models.N1N2MessageTransferCause_ATTEMPTING_TO_REACH_UE && uri != "" {
-		configuration := Namf_Communication.NewConfiguration()
-		client := Namf_Communication.NewAPIClient(configuration)
-
-		n1N2MsgTxfrFailureNotificationReq := Namf_Communication.N1N2TransferFailureNotificationRequest{
-			N1N2MsgTxfrFailureNotification: &models.N1N2MsgTxfrFailureNotification{
-				Cause:          cause,
-				N1n2MsgDataUri: n1n2Message.ResourceUri,
-			},
-		}
-
-		ctx, pd, err := amf_context.GetSelf().GetTokenCtx(
-			models.ServiceName("namf-callback"), models.NrfNfManagementNfType_SMF)
-		if err != nil {
-			HttpLog.Warnf("SendN1N2TransferFailureNotification get token failed: %+v", pd)
-			return
-		}
-
-		_, err = client.N1N2MessageCollectionCollectionApi.
-			N1N2TransferFailureNotification(ctx, uri, &n1N2MsgTxfrFailureNotificationReq)
-
-		if err != nil {
+		if err := sendN1N2TransferFailureNotification(uri, n1n2Message.ResourceUri, cause); err != nil {
 			HttpLog.Errorln(err.Error())
 		} else {
 			ue.N1N2Message = nil
@@ -52,6 +32,44 @@ func SendN1N2TransferFailureNotification(ue *amf_context.AmfUe, cause models.N1N
 	}
 }
 
+// SendN1MessageDeliveryFailureNotification notifies the NF that the N1 message it transferred
+// could not be delivered to the UE in CM-CONNECTED state (e.g. NAS Non Delivery Indication)
+func SendN1MessageDeliveryFailureNotification(ue *amf_context.AmfUe, uri string, resourceUri string,
+	cause models.N1N2MessageTransferCause,
+) {
+	if uri == "" {
+		return
+	}
+	if err := sendN1N2TransferFailureNotification(uri, resourceUri, cause); err != nil {
+		ue.ProducerLog.Errorf("Send N1 message delivery failure notification failed: %+v", err)
+	}
+}
+
+func sendN1N2TransferFailureNotification(uri string, resourceUri string,
+	cause models.N1N2MessageTransferCause,
+) error {
+	configuration := Namf_Communication.NewConfiguration()
+	client := Namf_Communication.NewAPIClient(configuration)
+
+	n1N2MsgTxfrFailureNotificationReq := Namf_Communication.N1N2TransferFailureNotificationRequest{
+		N1N2MsgTxfrFailureNotification: &models.N1N2MsgTxfrFailureNotification{
+			Cause:          cause,
+			N1n2MsgDataUri: resourceUri,
+		},
+	}
+
+	ctx, pd, err := amf_context.GetSelf().GetTokenCtx(
+		models.ServiceName("namf-callback"), models.NrfNfManagementNfType_SMF)
+	if err != nil {
+		HttpLog.Warnf("SendN1N2TransferFailureNotification get token failed: %+v", pd)
+		return err
+	}
+
+	_, err = client.N1N2MessageCollectionCollectionApi.
+		N1N2TransferFailureNotification(ctx, uri, &n1N2MsgTxfrFailureNotificationReq)
+	return err
+}
+
 func SendN1MessageNotify(ue *amf_context.AmfUe, n1class models.N1MessageClass, n1Msg []byte,
 	registerContext *models.RegistrationContextContainer,
 ) {
@@ -2,11 +2,11 @@ package callback
 
 import (
 	"net/url"
-	"reflect"
 	"strings"
 
 	amf_context "github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/amf/pkg/factory"
 	Namf_Communication "github.com/free5gc/openapi/amf/Communication"
 	"github.com/free5gc/openapi/models"
 )
@@ -28,6 +28,8 @@ func callbackServiceNfType(svcName string) (models.NrfNfManagementNfType, bool)
 	}
 }
 
+// SendAmfStatusChangeNotify notifies the subscribers of the GUAMIs whose status changed (TS 29.518 5.2.2.5.3).
+// The GUAMIs are grouped by their backup AMF, notified as the target AMF of the removal of the GUAMIs.
 func SendAmfStatusChangeNotify(amfStatus string, guamiList []models.Guami) {
 	amfSelf := amf_context.GetSelf()
 
@@ -37,24 +39,37 @@ func SendAmfStatusChangeNotify(amfStatus string, guamiList []models.Guami) {
 		configuration := Namf_Communication.NewConfiguration()
 		client := Namf_Communication.NewAPIClient(configuration)
 		amfStatusNotification := models.AmfStatusChangeNotification{}
-		amfStatusInfo := models.AmfStatusInfo{}
 
+		infoIndex := make(map[string]int) // backup AMF name -> index in AmfStatusInfoList
 		for _, guami := range guamiList {
+			subscribed := false
 			for _, subGumi := range subscriptionData.GuamiList {
-				if reflect.DeepEqual(guami, subGumi) {
-					// AMF status is available
-					amfStatusInfo.GuamiList = append(amfStatusInfo.GuamiList, guami)
+				if factory.GuamiEqual(guami, subGumi) {
+					subscribed = true
+					break
 				}
 			}
+			if !subscribed {
+				continue
+			}
+			backupAmfName := amfSelf.BackupAmfName(guami)
+			i, ok := infoIndex[backupAmfName]
+			if !ok {
+				i = len(amfStatusNotification.AmfStatusInfoList)
+				infoIndex[backupAmfName] = i
+				amfStatusNotification.AmfStatusInfoList = append(amfStatusNotification.AmfStatusInfoList,
+					models.AmfStatusInfo{
+						StatusChange:     (models.StatusChange)(amfStatus),
+						TargetAmfRemoval: backupAmfName,
+					})
+			}
+			amfStatusNotification.AmfStatusInfoList[i].GuamiList = append(
+				amfStatusNotification.AmfStatusInfoList[i].GuamiList, guami)
 		}
-
-		amfStatusInfo = models.AmfStatusInfo{
-			StatusChange:     (models.StatusChange)(amfStatus),
-			TargetAmfRemoval: "",
-			TargetAmfFailure: "",
+		if len(amfStatusNotification.AmfStatusInfoList) == 0 {
+			return true
 		}
 
-		amfStatusNotification.AmfStatusInfoList = append(amfStatusNotification.AmfStatusInfoList, amfStatusInfo)
 		uri := subscriptionData.AmfStatusUri
 
 		amfStatusNotificationReq := Namf_Communication.AmfStatusChangeNotifyRequest{
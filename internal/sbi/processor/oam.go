@@ -1,14 +1,27 @@
 package processor
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mohae/deepcopy"
 
 	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/gmm"
+	gmm_common "github.com/free5gc/amf/internal/gmm/common"
 	"github.com/free5gc/amf/internal/logger"
+	ngap_message "github.com/free5gc/amf/internal/ngap/message"
+	"github.com/free5gc/amf/internal/util"
+	"github.com/free5gc/amf/pkg/factory"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/sctp"
 	"github.com/free5gc/util/metrics/sbi"
 )
 
@@ -128,3 +141,874 @@ func (p *Processor) buildUEContext(ue *context.AmfUe, accessType models.AccessTy
 	}
 	return nil
 }
+
+func (p *Processor) HandleOAMTraceRecords(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Trace Records")
+
+	c.JSON(http.StatusOK, context.GetSelf().TraceCollector.Records(c.Param("supi")))
+}
+
+func (p *Processor) HandleOAMDeactivateTrace(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Deactivate Trace")
+
+	problemDetails := p.OAMDeactivateTraceProcedure(c.Param("supi"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// OAMDeactivateTraceProcedure stops the subscriber/equipment trace of the UE
+// and deactivates its trace sessions in the NG-RAN (TS 32.422 4.1.4)
+func (p *Processor) OAMDeactivateTraceProcedure(supi string) *models.ProblemDetails {
+	ue, ok := context.GetSelf().AmfUeFindBySupi(supi)
+	if !ok {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+
+	var problemDetails *models.ProblemDetails
+	if err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		if ue.TraceData == nil {
+			problemDetails = &models.ProblemDetails{
+				Status: http.StatusNotFound,
+				Cause:  "TRACE_NOT_ACTIVATED",
+			}
+			return
+		}
+		ue.TraceData = nil
+		ngap_message.SendTraceUpdate(ue)
+	}); err != nil {
+		return ueEventProblem(err)
+	}
+	return problemDetails
+}
+
+func (p *Processor) HandleOAMDrain(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Drain")
+
+	problemDetails := p.OAMDrainProcedure(c.Query("timeout"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// OAMDrainProcedure starts the planned removal of the AMF, which terminates once its UEs have moved to
+// the other AMFs of the set or the timeout (a duration string, the configured one if empty) passes
+func (p *Processor) OAMDrainProcedure(timeout string) *models.ProblemDetails {
+	var d time.Duration
+	if timeout != "" {
+		var err error
+		if d, err = time.ParseDuration(timeout); err != nil || d <= 0 {
+			return &models.ProblemDetails{
+				Status: http.StatusBadRequest,
+				Cause:  "INVALID_QUERY_PARAM",
+				Detail: fmt.Sprintf("invalid timeout: %s", timeout),
+			}
+		}
+	}
+	if err := p.Drain(d); err != nil {
+		return &models.ProblemDetails{
+			Status: http.StatusConflict,
+			Cause:  "DRAINING",
+			Detail: err.Error(),
+		}
+	}
+	return nil
+}
+
+type SctpAssociation struct {
+	AssocId          int32
+	State            string
+	PeerDestinations uint16
+	PeerRwnd         uint32
+	LocalRwnd        uint32
+}
+
+type RanContext struct {
+	RanId           string
+	GlobalRanNodeId *models.GlobalRanNodeId
+	Name            string
+	AnType          models.AccessType
+	RatType         models.RatType
+	Address         string
+	SupportedTAList []context.SupportedTAI
+	UeCount         int
+	Sctp            *SctpAssociation
+}
+
+type RanContexts []RanContext
+
+func (p *Processor) HandleOAMRanContext(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle RAN Context")
+
+	ranContexts, problemDetails := p.OAMRanContextProcedure(c.Param("ranId"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.JSON(http.StatusOK, ranContexts)
+	}
+}
+
+// OAMRanContextProcedure returns the RANs connected to the AMF, only the one of the ID if not empty
+func (p *Processor) OAMRanContextProcedure(ranId string) (RanContexts, *models.ProblemDetails) {
+	ranContexts := RanContexts{}
+	context.GetSelf().AmfRanPool.Range(func(key, value interface{}) bool {
+		ran := value.(*context.AmfRan)
+		if ranId == "" || ran.Key() == ranId {
+			ranContexts = append(ranContexts, buildRanContext(ran))
+		}
+		return true
+	})
+	if ranId != "" && len(ranContexts) == 0 {
+		return nil, &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+	return ranContexts, nil
+}
+
+func buildRanContext(ran *context.AmfRan) RanContext {
+	ranContext := RanContext{
+		RanId:           ran.Key(),
+		GlobalRanNodeId: ran.RanId,
+		Name:            ran.Name,
+		AnType:          ran.AnType,
+		RatType:         ran.UeRatType(),
+		SupportedTAList: ran.SupportedTAList,
+	}
+	if ran.Conn != nil && ran.Conn.RemoteAddr() != nil {
+		ranContext.Address = ran.Conn.RemoteAddr().String()
+	}
+	ran.RanUeList.Range(func(key, value interface{}) bool {
+		ranContext.UeCount++
+		return true
+	})
+
+	// a RAN is in the RAN pool while its SCTP association is up
+	if conn, ok := ran.Conn.(*sctp.SCTPConn); ok {
+		ranContext.Sctp = &SctpAssociation{
+			State: "ESTABLISHED",
+		}
+		if info, err := conn.GetAssocInfo(); err != nil {
+			ran.Log.Warnf("Get SCTP association info error: %+v", err)
+			ranContext.Sctp.State = "UNKNOWN"
+		} else {
+			ranContext.Sctp.AssocId = int32(info.AssocID)
+			ranContext.Sctp.PeerDestinations = info.NumberPeerDestinations
+			ranContext.Sctp.PeerRwnd = info.PeerRwnd
+			ranContext.Sctp.LocalRwnd = info.LocalRwnd
+		}
+	}
+	return ranContext
+}
+
+func (p *Processor) HandleOAMNGReset(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle NG Reset")
+
+	problemDetails := p.OAMNGResetProcedure(c.Param("ranId"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// OAMNGResetProcedure resets the whole NG interface with the RAN; the UE-associated logical
+// NG-connections of its UEs are released once the RAN acknowledges it (TS 38.413 8.7.4.2.2)
+func (p *Processor) OAMNGResetProcedure(ranId string) *models.ProblemDetails {
+	var ran *context.AmfRan
+	context.GetSelf().AmfRanPool.Range(func(key, value interface{}) bool {
+		if r := value.(*context.AmfRan); r.Key() == ranId {
+			ran = r
+			return false
+		}
+		return true
+	})
+	if ran == nil {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+
+	ngap_message.SendNGReset(ran, ngapType.Cause{
+		Present: ngapType.CausePresentMisc,
+		Misc: &ngapType.CauseMisc{
+			Value: ngapType.CauseMiscPresentOmIntervention,
+		},
+	}, nil)
+	return nil
+}
+
+type UETimer struct {
+	Name          string
+	ExpireTimes   int32
+	MaxRetryTimes int32
+}
+
+type UEContextDetail struct {
+	Supi                string
+	Gpsi                string
+	Pei                 string
+	Guti                string
+	RatType             models.RatType
+	GmmStates           map[models.AccessType]string
+	CmStates            map[models.AccessType]models.CmState
+	OnGoingProcedures   map[models.AccessType]context.OnGoingProcedure
+	RegistrationArea    map[models.AccessType][]models.Tai
+	AllowedNssai        map[models.AccessType][]models.AllowedSnssai
+	CipheringAlgorithm  string
+	IntegrityAlgorithm  string
+	RunningTimers       []UETimer
+	PduSessions         []PduSession
+	EventSubscriptionId []string
+	Authentications     []context.AuthenticationRecord
+}
+
+type UEContextDetails []UEContextDetail
+
+func (p *Processor) HandleOAMUEContext(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle UE Context")
+
+	ueContexts, problemDetails := p.OAMUEContextProcedure(c.Param("supi"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.JSON(http.StatusOK, ueContexts)
+	}
+}
+
+// OAMUEContextProcedure returns the state of the UEs in the UE pool, registered or not,
+// only the one of the SUPI if not empty
+func (p *Processor) OAMUEContextProcedure(supi string) (UEContextDetails, *models.ProblemDetails) {
+	amfSelf := context.GetSelf()
+	ueContexts := UEContextDetails{}
+
+	if supi != "" {
+		ue, ok := amfSelf.AmfUeFindBySupi(supi)
+		if !ok {
+			return nil, &models.ProblemDetails{
+				Status: http.StatusNotFound,
+				Cause:  "CONTEXT_NOT_FOUND",
+			}
+		}
+		ueContext, err := readUEContextDetail(ue)
+		if err != nil {
+			return nil, ueEventProblem(err)
+		}
+		return append(ueContexts, ueContext), nil
+	}
+
+	var ues []*context.AmfUe
+	amfSelf.UePool.Range(func(key, value interface{}) bool {
+		ues = append(ues, value.(*context.AmfUe))
+		return true
+	})
+	for _, ue := range ues {
+		ueContext, err := readUEContextDetail(ue)
+		if err != nil {
+			ue.ProducerLog.Warnf("UE context left out of the OAM UE contexts: %v", err)
+			continue
+		}
+		ueContexts = append(ueContexts, ueContext)
+	}
+	return ueContexts, nil
+}
+
+// readUEContextDetail builds the state of the UE in the event loop of the UE
+func readUEContextDetail(ue *context.AmfUe) (UEContextDetail, error) {
+	var ueContext UEContextDetail
+	err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+		ueContext = buildUEContextDetail(ue)
+	})
+	return ueContext, err
+}
+
+func buildUEContextDetail(ue *context.AmfUe) UEContextDetail {
+	ueContext := UEContextDetail{
+		Supi:              ue.Supi,
+		Gpsi:              ue.Gpsi,
+		Pei:               ue.Pei,
+		Guti:              ue.Guti,
+		RatType:           ue.RatType,
+		GmmStates:         make(map[models.AccessType]string),
+		CmStates:          make(map[models.AccessType]models.CmState),
+		OnGoingProcedures: make(map[models.AccessType]context.OnGoingProcedure),
+		RegistrationArea:  deepcopy.Copy(ue.RegistrationArea).(map[models.AccessType][]models.Tai),
+		AllowedNssai:      deepcopy.Copy(ue.AllowedNssai).(map[models.AccessType][]models.AllowedSnssai),
+		Authentications:   ue.AuthenticationHistory(),
+	}
+
+	for anType, state := range ue.State {
+		if state == nil {
+			continue
+		}
+		ueContext.GmmStates[anType] = string(state.Current())
+		if ue.CmConnect(anType) {
+			ueContext.CmStates[anType] = models.CmState_CONNECTED
+		} else {
+			ueContext.CmStates[anType] = models.CmState_IDLE
+		}
+		ueContext.OnGoingProcedures[anType] = ue.OnGoing(anType).Procedure
+	}
+
+	if ue.SecurityContextAvailable {
+		ueContext.CipheringAlgorithm = fmt.Sprintf("NEA%d", ue.CipheringAlg)
+		ueContext.IntegrityAlgorithm = fmt.Sprintf("NIA%d", ue.IntegrityAlg)
+	}
+
+	for _, timer := range []struct {
+		name  string
+		timer *context.Timer
+	}{
+		{"T3513", ue.T3513},
+		{"T3522", ue.T3522},
+		{"T3550", ue.T3550},
+		{"T3555", ue.T3555},
+		{"T3560", ue.T3560},
+		{"T3565", ue.T3565},
+		{"T3570", ue.T3570},
+	} {
+		if timer.timer != nil && !timer.timer.Stopped() {
+			ueContext.RunningTimers = append(ueContext.RunningTimers, UETimer{
+				Name:          timer.name,
+				ExpireTimes:   timer.timer.ExpireTimes(),
+				MaxRetryTimes: timer.timer.MaxRetryTimes(),
+			})
+		}
+	}
+
+	ue.SmContextList.Range(func(key, value interface{}) bool {
+		smContext := value.(*context.SmContext)
+		ueContext.PduSessions = append(ueContext.PduSessions, PduSession{
+			PduSessionId: strconv.Itoa(int(smContext.PduSessionID())),
+			SmContextRef: smContext.SmContextRef(),
+			Sst:          strconv.Itoa(int(smContext.Snssai().Sst)),
+			Sd:           smContext.Snssai().Sd,
+			Dnn:          smContext.Dnn(),
+		})
+		return true
+	})
+
+	for subscriptionId := range ue.EventSubscriptionsInfo {
+		ueContext.EventSubscriptionId = append(ueContext.EventSubscriptionId, subscriptionId)
+	}
+	sort.Strings(ueContext.EventSubscriptionId)
+	return ueContext
+}
+
+func (p *Processor) HandleOAMDeregisterUE(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Deregister UE")
+
+	problemDetails := p.OAMDeregisterUEProcedure(c.Param("supi"), c.Query("accessType"),
+		c.Query("reRegistrationRequired"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// OAMDeregisterUEProcedure starts the network-initiated deregistration of the UE registered over the access
+// (3GPP access if empty), the UE asked to register again if reRegistrationRequired is "true". The UE in CM-IDLE
+// state is deregistered implicitly.
+func (p *Processor) OAMDeregisterUEProcedure(supi, accessType, reRegistrationRequired string) *models.ProblemDetails {
+	anType := models.AccessType__3_GPP_ACCESS
+	if accessType != "" {
+		anType = models.AccessType(accessType)
+	}
+	if anType != models.AccessType__3_GPP_ACCESS && anType != models.AccessType_NON_3_GPP_ACCESS {
+		return &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "INVALID_QUERY_PARAM",
+			Detail: fmt.Sprintf("invalid accessType: %s", accessType),
+		}
+	}
+	reRegistration := false
+	if reRegistrationRequired != "" {
+		var err error
+		if reRegistration, err = strconv.ParseBool(reRegistrationRequired); err != nil {
+			return &models.ProblemDetails{
+				Status: http.StatusBadRequest,
+				Cause:  "INVALID_QUERY_PARAM",
+				Detail: fmt.Sprintf("invalid reRegistrationRequired: %s", reRegistrationRequired),
+			}
+		}
+	}
+
+	ue, ok := context.GetSelf().AmfUeFindBySupi(supi)
+	if !ok {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+
+	var err error
+	if runErr := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+		err = gmm.InitiateDeregistration(ue, anType, reRegistration, 0)
+	}); runErr != nil {
+		return ueEventProblem(runErr)
+	}
+	if err != nil {
+		return &models.ProblemDetails{
+			Status: http.StatusConflict,
+			Cause:  "INVALID_UE_STATE",
+			Detail: err.Error(),
+		}
+	}
+	return nil
+}
+
+func (p *Processor) HandleOAMPageUE(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Page UE")
+
+	problemDetails := p.OAMPageUEProcedure(c.Param("supi"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// OAMPageUEProcedure pages the UE registered and in CM-IDLE over the 3GPP access
+func (p *Processor) OAMPageUEProcedure(supi string) *models.ProblemDetails {
+	ue, ok := context.GetSelf().AmfUeFindBySupi(supi)
+	if !ok {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+
+	var problemDetails *models.ProblemDetails
+	if err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		anType := models.AccessType__3_GPP_ACCESS
+		if !ue.State[anType].Is(context.Registered) || ue.CmConnect(anType) {
+			problemDetails = &models.ProblemDetails{
+				Status: http.StatusConflict,
+				Cause:  "INVALID_UE_STATE",
+				Detail: "UE is not registered in CM-IDLE over 3GPP access",
+			}
+			return
+		}
+		pkg, err := ngap_message.BuildPaging(ue, nil, false)
+		if err != nil {
+			ue.ProducerLog.Errorf("Build Paging failed : %s", err.Error())
+			problemDetails = &models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+			}
+			return
+		}
+		ue.SetOnGoing(anType, &context.OnGoing{
+			Procedure: context.OnGoingProcedurePaging,
+		})
+		ngap_message.SendPaging(ue, pkg)
+	}); err != nil {
+		return ueEventProblem(err)
+	}
+	return problemDetails
+}
+
+func (p *Processor) HandleOAMDetachUE(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Detach UE")
+
+	problemDetails := p.OAMDetachUEProcedure(c.Param("supi"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// OAMDetachUEProcedure locally detaches the UE without NAS signalling: its PDU sessions and AM policy
+// association are released, then its N2 connections, the UE context being removed once released
+func (p *Processor) OAMDetachUEProcedure(supi string) *models.ProblemDetails {
+	ue, ok := context.GetSelf().AmfUeFindBySupi(supi)
+	if !ok {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+
+	if err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		gmm_common.StopAll5GSMMTimers(ue)
+		connected := false
+		for _, ranUe := range ue.RanUe {
+			if ranUe != nil {
+				connected = true
+			}
+		}
+		if !connected {
+			gmm_common.RemoveAmfUe(ue, true)
+			return
+		}
+		gmm_common.ReleaseAmfUeSessions(ue)
+		for _, ranUe := range ue.RanUe {
+			if ranUe != nil {
+				ngap_message.SendUEContextReleaseCommand(ranUe, context.UeContextReleaseUeContext,
+					ngapType.CausePresentMisc, ngapType.CauseMiscPresentOmIntervention)
+			}
+		}
+	}); err != nil {
+		return ueEventProblem(err)
+	}
+	return nil
+}
+
+type EventSubscription struct {
+	SubscriptionId    string
+	IsAnyUe           bool
+	IsGroupUe         bool
+	UeSupiList        []string
+	Expiry            *time.Time
+	EventSubscription models.AmfEventSubscription
+}
+
+type EventSubscriptions []EventSubscription
+
+func (p *Processor) HandleOAMEventSubscriptions(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Event Subscriptions")
+
+	eventSubscriptions := EventSubscriptions{}
+	context.GetSelf().EventSubscriptions.Range(func(key, value interface{}) bool {
+		subscription := value.(*context.AMFContextEventSubscription)
+		eventSubscriptions = append(eventSubscriptions, EventSubscription{
+			SubscriptionId:    key.(string),
+			IsAnyUe:           subscription.IsAnyUe,
+			IsGroupUe:         subscription.IsGroupUe,
+			UeSupiList:        subscription.UeSupiList,
+			Expiry:            subscription.Expiry,
+			EventSubscription: subscription.EventSubscription,
+		})
+		return true
+	})
+	sort.Slice(eventSubscriptions, func(i, j int) bool {
+		return eventSubscriptions[i].SubscriptionId < eventSubscriptions[j].SubscriptionId
+	})
+	c.JSON(http.StatusOK, eventSubscriptions)
+}
+
+type N1N2Subscription struct {
+	Supi           string
+	SubscriptionId string
+	models.UeN1N2InfoSubscriptionCreateData
+}
+
+type N1N2Subscriptions []N1N2Subscription
+
+func (p *Processor) HandleOAMN1N2Subscriptions(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle N1N2 Subscriptions")
+
+	n1n2Subscriptions := N1N2Subscriptions{}
+	context.GetSelf().UePool.Range(func(key, value interface{}) bool {
+		ue := value.(*context.AmfUe)
+		ue.N1N2MessageSubscription.Range(func(key, value interface{}) bool {
+			n1n2Subscriptions = append(n1n2Subscriptions, N1N2Subscription{
+				Supi:                             ue.Supi,
+				SubscriptionId:                   strconv.FormatInt(key.(int64), 10),
+				UeN1N2InfoSubscriptionCreateData: value.(models.UeN1N2InfoSubscriptionCreateData),
+			})
+			return true
+		})
+		return true
+	})
+	c.JSON(http.StatusOK, n1n2Subscriptions)
+}
+
+// CaptureRequest starts a capture session of the messages matching the filter
+type CaptureRequest struct {
+	context.CaptureFilter
+	Nas        bool `json:"nas"`
+	MaxPackets int  `json:"maxPackets,omitempty"`
+}
+
+func (p *Processor) HandleOAMStartCapture(c *gin.Context, captureRequest CaptureRequest) {
+	logger.ProducerLog.Infof("[OAM] Handle Start Capture")
+
+	status, problemDetails := p.OAMStartCaptureProcedure(captureRequest)
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Header("Location", factory.AmfOamResUriPrefix+"/captures/"+status.Id)
+		c.JSON(http.StatusCreated, status)
+	}
+}
+
+// OAMStartCaptureProcedure starts capturing the NGAP messages, and the plaintext NAS messages if requested,
+// matching the filter of the request
+func (p *Processor) OAMStartCaptureProcedure(
+	captureRequest CaptureRequest,
+) (*context.CaptureSessionStatus, *models.ProblemDetails) {
+	if captureRequest.MaxPackets < 0 || captureRequest.MaxPackets > factory.AmfCaptureMaxPackets {
+		return nil, &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: fmt.Sprintf("invalid maxPackets: %d", captureRequest.MaxPackets),
+		}
+	}
+	if captureRequest.MaxPackets == 0 {
+		captureRequest.MaxPackets = factory.AmfCaptureDefaultMaxPackets
+	}
+	session, err := context.StartCapture(captureRequest.CaptureFilter, captureRequest.Nas, captureRequest.MaxPackets)
+	if err != nil {
+		return nil, &models.ProblemDetails{
+			Status: http.StatusTooManyRequests,
+			Cause:  "INSUFFICIENT_RESOURCES",
+			Detail: err.Error(),
+		}
+	}
+	logger.ProducerLog.Infof("[OAM] Capture[%s] started: %+v", session.Id, captureRequest)
+	status := session.Status()
+	return &status, nil
+}
+
+func (p *Processor) HandleOAMCaptures(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Captures")
+
+	captures := []context.CaptureSessionStatus{}
+	for _, session := range context.CaptureSessions() {
+		captures = append(captures, session.Status())
+	}
+	c.JSON(http.StatusOK, captures)
+}
+
+func (p *Processor) HandleOAMCapture(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Capture")
+
+	session, ok := context.FindCaptureSession(c.Param("captureId"))
+	if !ok {
+		problemDetails := captureNotFound()
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	c.JSON(http.StatusOK, session.Status())
+}
+
+// HandleOAMCapturePcap downloads the messages of the capture session as a pcapng file
+func (p *Processor) HandleOAMCapturePcap(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Capture PCAP")
+
+	session, ok := context.FindCaptureSession(c.Param("captureId"))
+	if !ok {
+		problemDetails := captureNotFound()
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	var pcap bytes.Buffer
+	if err := session.WritePcap(&pcap); err != nil {
+		logger.ProducerLog.Errorf("Write capture[%s] error: %+v", session.Id, err)
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+		}
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"amf-capture-%s.pcapng\"", session.Id))
+	c.Data(http.StatusOK, "application/vnd.tcpdump.pcap", pcap.Bytes())
+}
+
+func (p *Processor) HandleOAMStopCapture(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Stop Capture")
+
+	session, ok := context.FindCaptureSession(c.Param("captureId"))
+	if !ok {
+		problemDetails := captureNotFound()
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	session.Stop()
+	c.JSON(http.StatusOK, session.Status())
+}
+
+func (p *Processor) HandleOAMRemoveCapture(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Remove Capture")
+
+	if !context.RemoveCaptureSession(c.Param("captureId")) {
+		problemDetails := captureNotFound()
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func captureNotFound() *models.ProblemDetails {
+	return &models.ProblemDetails{
+		Status: http.StatusNotFound,
+		Cause:  "CONTEXT_NOT_FOUND",
+	}
+}
+
+// SliceOutageRequest puts an S-NSSAI out of service in tracking areas
+type SliceOutageRequest struct {
+	TaiList           []models.Tai   `json:"taiList,omitempty"`
+	AlternativeSnssai *models.Snssai `json:"alternativeSnssai,omitempty"`
+}
+
+func (p *Processor) HandleOAMSliceOutages(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Slice Outages")
+
+	c.JSON(http.StatusOK, context.SliceOutages())
+}
+
+func (p *Processor) HandleOAMSetSliceOutage(c *gin.Context, sliceOutageRequest SliceOutageRequest) {
+	logger.ProducerLog.Infof("[OAM] Handle Set Slice Outage")
+
+	outage, problemDetails := p.OAMSetSliceOutageProcedure(c.Param("snssai"), sliceOutageRequest)
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.JSON(http.StatusOK, outage)
+	}
+}
+
+// OAMSetSliceOutageProcedure puts the S-NSSAI (in hexadecimal) out of service in the tracking areas of the request,
+// the whole PLMN if none, and adapts the allowed NSSAI of the registered UEs in the background
+func (p *Processor) OAMSetSliceOutageProcedure(
+	snssaiHex string, sliceOutageRequest SliceOutageRequest,
+) (*context.SliceOutage, *models.ProblemDetails) {
+	snssai, problemDetails := snssaiFromPath(snssaiHex)
+	if problemDetails != nil {
+		return nil, problemDetails
+	}
+	alternativeSnssai := sliceOutageRequest.AlternativeSnssai
+	if alternativeSnssai != nil && openapi.SnssaiEqualFold(*alternativeSnssai, *snssai) {
+		return nil, &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: "alternativeSnssai is the S-NSSAI out of service",
+		}
+	}
+	outage := context.SliceOutage{
+		Snssai:            *snssai,
+		TaiList:           sliceOutageRequest.TaiList,
+		AlternativeSnssai: alternativeSnssai,
+	}
+	context.SetSliceOutage(outage)
+	logger.ProducerLog.Infof("[OAM] S-NSSAI[%s] out of service: %+v", snssaiHex, sliceOutageRequest)
+	go updateSliceAvailability()
+	return &outage, nil
+}
+
+func (p *Processor) HandleOAMRemoveSliceOutage(c *gin.Context) {
+	logger.ProducerLog.Infof("[OAM] Handle Remove Slice Outage")
+
+	problemDetails := p.OAMRemoveSliceOutageProcedure(c.Param("snssai"))
+	if problemDetails != nil {
+		c.Set(sbi.IN_PB_DETAILS_CTX_STR, problemDetails.Cause)
+		c.JSON(int(problemDetails.Status), problemDetails)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// OAMRemoveSliceOutageProcedure puts the S-NSSAI (in hexadecimal) back in service, and adapts the allowed NSSAI of
+// the registered UEs in the background
+func (p *Processor) OAMRemoveSliceOutageProcedure(snssaiHex string) *models.ProblemDetails {
+	snssai, problemDetails := snssaiFromPath(snssaiHex)
+	if problemDetails != nil {
+		return problemDetails
+	}
+	if !context.RemoveSliceOutage(*snssai) {
+		return &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+	}
+	logger.ProducerLog.Infof("[OAM] S-NSSAI[%s] back in service", snssaiHex)
+	go updateSliceAvailability()
+	return nil
+}
+
+const (
+	sliceAvailabilityRetryInterval = time.Second
+	sliceAvailabilityMaxRetries    = 5
+)
+
+func updateSliceAvailability() {
+	context.GetSelf().UePool.Range(func(key, value interface{}) bool {
+		postSliceAvailability(value.(*context.AmfUe), 0)
+		return true
+	})
+}
+
+// postSliceAvailability posts the update of the slice availability to the event loop of the UE, posting it again
+// later while the event loop rejects it
+func postSliceAvailability(ue *context.AmfUe, retries int) {
+	if ue.Post(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		gmm.UpdateSliceAvailability(ue)
+	}) {
+		return
+	}
+	if retries >= sliceAvailabilityMaxRetries {
+		ue.ProducerLog.Errorf("Slice availability not updated, allowed NSSAI left stale: "+
+			"the event loop of the UE rejected it %d times", retries+1)
+		return
+	}
+	ue.ProducerLog.Warnf("Slice availability update rejected by the event loop of the UE, retry in %v",
+		sliceAvailabilityRetryInterval)
+	time.AfterFunc(sliceAvailabilityRetryInterval, func() {
+		postSliceAvailability(ue, retries+1)
+	})
+}
+
+// snssaiFromPath parses the S-NSSAI in hexadecimal, SST on 2 digits followed by the SD on 6 digits if any
+func snssaiFromPath(snssaiHex string) (*models.Snssai, *models.ProblemDetails) {
+	invalid := &models.ProblemDetails{
+		Status: http.StatusBadRequest,
+		Cause:  "INVALID_QUERY_PARAM",
+		Detail: fmt.Sprintf("invalid S-NSSAI: %s", snssaiHex),
+	}
+	if len(snssaiHex) != 2 && len(snssaiHex) != 8 {
+		return nil, invalid
+	}
+	if _, err := strconv.ParseUint(snssaiHex, 16, 32); err != nil {
+		return nil, invalid
+	}
+	snssai, err := util.SnssaiHexToModels(snssaiHex)
+	if err != nil {
+		return nil, invalid
+	}
+	return snssai, nil
+}
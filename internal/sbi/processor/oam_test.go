@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestOAMRanContextProcedure(t *testing.T) {
+	amfSelf := context.GetSelf()
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	ran := &context.AmfRan{
+		RanPresent: context.RanPresentGNbId,
+		RanId: &models.GlobalRanNodeId{
+			PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"},
+			GNbId:  &models.GNbId{BitLength: 24, GNBValue: "000102"},
+		},
+		Name:   "gnb-1",
+		AnType: models.AccessType__3_GPP_ACCESS,
+		Conn:   conn,
+		Log:    logger.NgapLog,
+	}
+	ran.RanUeList.Store(int64(1), &context.RanUe{})
+	amfSelf.AmfRanPool.Store(conn, ran)
+	defer amfSelf.AmfRanPool.Delete(conn)
+
+	p := &Processor{}
+	ranContexts, problemDetails := p.OAMRanContextProcedure("gnb-20893-000102")
+	require.Nil(t, problemDetails)
+	require.Len(t, ranContexts, 1)
+	require.Equal(t, "gnb-20893-000102", ranContexts[0].RanId)
+	require.Equal(t, models.RatType_NR, ranContexts[0].RatType)
+	require.Equal(t, 1, ranContexts[0].UeCount)
+	require.Nil(t, ranContexts[0].Sctp)
+
+	_, problemDetails = p.OAMRanContextProcedure("gnb-20893-ffffff")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, int32(http.StatusNotFound), problemDetails.Status)
+
+	problemDetails = p.OAMNGResetProcedure("gnb-20893-ffffff")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, int32(http.StatusNotFound), problemDetails.Status)
+}
+
+func TestOAMDeregisterUEProcedureInvalidQuery(t *testing.T) {
+	p := &Processor{}
+
+	problemDetails := p.OAMDeregisterUEProcedure("imsi-208930000000001", "5G_ACCESS", "")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "INVALID_QUERY_PARAM", problemDetails.Cause)
+
+	problemDetails = p.OAMDeregisterUEProcedure("imsi-208930000000001", "", "maybe")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "INVALID_QUERY_PARAM", problemDetails.Cause)
+
+	problemDetails = p.OAMDeregisterUEProcedure("imsi-208930000000001", "", "true")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "CONTEXT_NOT_FOUND", problemDetails.Cause)
+}
+
+func TestOAMDeregisterIdleUE(t *testing.T) {
+	p := &Processor{}
+	amfSelf := context.GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		amfSelf.ServedGuamiList = nil
+	}()
+	ue := amfSelf.NewAmfUe("imsi-208930000000002")
+	defer ue.Remove()
+	ue.State[models.AccessType__3_GPP_ACCESS].Set(context.Registered)
+
+	// the UE in CM-IDLE state is deregistered implicitly
+	require.Nil(t, p.OAMDeregisterUEProcedure(ue.Supi, "", ""))
+	require.True(t, ue.State[models.AccessType__3_GPP_ACCESS].Is(context.Deregistered))
+
+	problemDetails := p.OAMDeregisterUEProcedure(ue.Supi, "", "")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "INVALID_UE_STATE", problemDetails.Cause)
+}
+
+func TestOAMUEContextProcedure(t *testing.T) {
+	p := &Processor{}
+	amfSelf := context.GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		amfSelf.ServedGuamiList = nil
+	}()
+	ue := amfSelf.NewAmfUe("imsi-208930000000005")
+	defer ue.Remove()
+	ue.RegistrationArea[models.AccessType__3_GPP_ACCESS] = []models.Tai{
+		{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000001"},
+	}
+
+	ueContexts, problemDetails := p.OAMUEContextProcedure(ue.Supi)
+	require.Nil(t, problemDetails)
+	require.Len(t, ueContexts, 1)
+	require.Equal(t, ue.RegistrationArea, ueContexts[0].RegistrationArea)
+
+	// the state returned is not changed by the UE afterwards
+	ue.RegistrationArea[models.AccessType__3_GPP_ACCESS][0].Tac = "000002"
+	require.Equal(t, "000001", ueContexts[0].RegistrationArea[models.AccessType__3_GPP_ACCESS][0].Tac)
+}
+
+func TestOAMDeactivateTrace(t *testing.T) {
+	p := &Processor{}
+	amfSelf := context.GetSelf()
+	amfSelf.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		amfSelf.ServedGuamiList = nil
+	}()
+	ue := amfSelf.NewAmfUe("imsi-208930000000003")
+	defer ue.Remove()
+	ue.TraceData = &models.TraceData{TraceRef: "20893-000001"}
+
+	require.Nil(t, p.OAMDeactivateTraceProcedure(ue.Supi))
+	require.Nil(t, ue.TraceData)
+
+	problemDetails := p.OAMDeactivateTraceProcedure(ue.Supi)
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "TRACE_NOT_ACTIVATED", problemDetails.Cause)
+}
+
+func TestOAMSliceOutageProcedures(t *testing.T) {
+	p := &Processor{}
+
+	_, problemDetails := p.OAMSetSliceOutageProcedure("1", SliceOutageRequest{})
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "INVALID_QUERY_PARAM", problemDetails.Cause)
+
+	_, problemDetails = p.OAMSetSliceOutageProcedure("01010203", SliceOutageRequest{
+		AlternativeSnssai: &models.Snssai{Sst: 1, Sd: "010203"},
+	})
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "MANDATORY_IE_INCORRECT", problemDetails.Cause)
+
+	outage, problemDetails := p.OAMSetSliceOutageProcedure("01010203", SliceOutageRequest{
+		AlternativeSnssai: &models.Snssai{Sst: 1, Sd: "112233"},
+	})
+	require.Nil(t, problemDetails)
+	require.Equal(t, models.Snssai{Sst: 1, Sd: "010203"}, outage.Snssai)
+	require.Len(t, context.SliceOutages(), 1)
+
+	require.Nil(t, p.OAMRemoveSliceOutageProcedure("01010203"))
+	problemDetails = p.OAMRemoveSliceOutageProcedure("01010203")
+	require.NotNil(t, problemDetails)
+	require.Equal(t, "CONTEXT_NOT_FOUND", problemDetails.Cause)
+	require.Empty(t, context.SliceOutages())
+}
@@ -1,14 +1,20 @@
 package processor
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/free5gc/amf/internal/sbi/consumer"
 	"github.com/free5gc/amf/pkg/app"
+	"github.com/free5gc/openapi/models"
 )
 
 type ProcessorAmf interface {
 	app.App
 
 	Consumer() *consumer.Consumer
+	// Drain starts the planned removal of the AMF, terminating it once drained
+	Drain(timeout time.Duration) error
 }
 
 type Processor struct {
@@ -21,3 +27,12 @@ func NewProcessor(amf ProcessorAmf) (*Processor, error) {
 	}
 	return p, nil
 }
+
+// ueEventProblem returns the problem details of a request whose UE event the event loop of the UE did not run
+func ueEventProblem(err error) *models.ProblemDetails {
+	return &models.ProblemDetails{
+		Status: http.StatusServiceUnavailable,
+		Cause:  "NF_CONGESTION",
+		Detail: err.Error(),
+	}
+}
@@ -54,6 +54,19 @@ func (p *Processor) CreateUEContextProcedure(ueContextID string, createUeContext
 		}
 		return nil, ueContextCreateError
 	}
+	if seafData := ueContextCreateData.UeContext.SeafData; seafData != nil &&
+		(seafData.KeyAmfHDerivationInd || seafData.KeyAmfChangeInd) {
+		// the KAMF' of an N2 handover, derived from the downlink NAS COUNT (TS 33.501 6.9.3), is not supported
+		logger.ProducerLog.Warnf("UE[%s]: horizontal KAMF derivation on N2 handover not supported", ueContextID)
+		return nil, &models.CreateUeContextResponse403{
+			JsonData: &models.UeContextCreateError{
+				Error: &models.ProblemDetails{
+					Status: http.StatusForbidden,
+					Cause:  "HANDOVER_FAILURE",
+				},
+			},
+		}
+	}
 	// create the UE context in target amf
 	ue := amfSelf.NewAmfUe(ueContextID)
 	ue.Lock.Lock()
@@ -119,7 +132,8 @@ func (p *Processor) CreateUEContextProcedure(ueContextID string, createUeContext
 	// ueContextCreateData.UeContext.EventSubscriptionList
 	// ueContextCreateData.UeContext.MmContextList
 	// ue.CurPduSession.PduSessionId = ueContextCreateData.UeContext.SessionContextList.
-	// ue.TraceData = ueContextCreateData.UeContext.TraceData
+	// the trace continues in the target NG-RAN node through the Trace Activation of Handover Request
+	ue.TraceData = ueContextCreateData.UeContext.TraceData
 	createUeContextResponse := new(models.CreateUeContextResponse201)
 	createUeContextResponse.JsonData = &models.UeContextCreatedData{
 		UeContext: &models.UeContext{
@@ -333,6 +347,8 @@ func (p *Processor) UEContextTransferProcedure(ueContextID string,
 		p.HandleMobiRegUe(ue, ueContextTransferRspData, ueContextTransferResponse)
 
 	case models.TransferReason_MOBI_REG_UE_VALIDATED:
+		// the new AMF authenticated the UE itself: no N1 message is decoded here, and no security context, hence no
+		// KAMF derived horizontally, is transferred
 		ueContextTransferRspData.UeContext = p.buildUEContextModel(ue, UeContextTransferReqData.Reason)
 		p.HandleMobiRegUe(ue, ueContextTransferRspData, ueContextTransferResponse)
 
@@ -395,6 +411,16 @@ func (p *Processor) buildUEContextModel(ue *context.AmfUe, reason models.Transfe
 		SeafData.Ncc = int32(ue.NCC)
 		SeafData.KeyAmfChangeInd = false
 		SeafData.KeyAmfHDerivationInd = false
+		if context.GetSelf().HorizontalKamfDerivation {
+			// the new AMF gets a KAMF' derived from the uplink NAS COUNT of the forwarded Registration Request,
+			// which nas_security.Decode has just set, the UE being told to derive the same one (TS 33.501 6.9.3)
+			if kamf, err := ue.HorizontalKamf(); err != nil {
+				ue.ProducerLog.Errorf("Horizontal KAMF derivation failed: %+v", err)
+			} else {
+				KeyAmf.KeyVal = kamf
+				SeafData.KeyAmfChangeInd = true
+			}
+		}
 		ueContext.SeafData = SeafData
 		mmContext.NasSecurityMode = NasSecurityMode
 		if ue.UESecurityCapability.Buffer != nil {
@@ -597,48 +623,51 @@ func (p *Processor) RegistrationStatusUpdateProcedure(ueContextID string,
 		return nil, problemDetails
 	}
 
-	ue.Lock.Lock()
-	defer ue.Lock.Unlock()
-
-	ueRegStatusUpdateRspData := new(models.UeRegStatusUpdateRspData)
-
-	if ueRegStatusUpdateReqData.TransferStatus == models.UeContextTransferStatus_TRANSFERRED {
-		// remove the individual ueContext resource and release any PDU session(s)
-		for _, pduSessionId := range ueRegStatusUpdateReqData.ToReleaseSessionList {
-			cause := models.SmfPduSessionCause_REL_DUE_TO_SLICE_NOT_AVAILABLE
-			causeAll := &context.CauseAll{
-				Cause: &cause,
-			}
-			smContext, okSmContextFindByPDUSessionID := ue.SmContextFindByPDUSessionID(pduSessionId)
-			if !okSmContextFindByPDUSessionID {
-				ue.ProducerLog.Errorf("SmContext[PDU Session ID:%d] not found", pduSessionId)
-				continue
-			}
-			problem, err := p.Consumer().SendReleaseSmContextRequest(ue, smContext, causeAll, "", nil)
-			if problem != nil {
-				logger.GmmLog.Errorf("Release SmContext[pduSessionId: %d] Failed Problem[%+v]", pduSessionId, problem)
-			} else if err != nil {
-				logger.GmmLog.Errorf("Release SmContext[pduSessionId: %d] Error[%v]", pduSessionId, err.Error())
+	if err := ue.Run(func() {
+		ue.Lock.Lock()
+		defer ue.Lock.Unlock()
+
+		if ueRegStatusUpdateReqData.TransferStatus == models.UeContextTransferStatus_TRANSFERRED {
+			// remove the individual ueContext resource and release any PDU session(s)
+			for _, pduSessionId := range ueRegStatusUpdateReqData.ToReleaseSessionList {
+				cause := models.SmfPduSessionCause_REL_DUE_TO_SLICE_NOT_AVAILABLE
+				causeAll := &context.CauseAll{
+					Cause: &cause,
+				}
+				smContext, okSmContextFindByPDUSessionID := ue.SmContextFindByPDUSessionID(pduSessionId)
+				if !okSmContextFindByPDUSessionID {
+					ue.ProducerLog.Errorf("SmContext[PDU Session ID:%d] not found", pduSessionId)
+					continue
+				}
+				problem, err := p.Consumer().SendReleaseSmContextRequest(ue, smContext, causeAll, "", nil)
+				if problem != nil {
+					logger.GmmLog.Errorf("Release SmContext[pduSessionId: %d] Failed Problem[%+v]", pduSessionId, problem)
+				} else if err != nil {
+					logger.GmmLog.Errorf("Release SmContext[pduSessionId: %d] Error[%v]", pduSessionId, err.Error())
+				}
 			}
-		}
 
-		if ueRegStatusUpdateReqData.PcfReselectedInd {
-			problem, err := p.Consumer().AMPolicyControlDelete(ue)
-			if problem != nil {
-				logger.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problem)
-			} else if err != nil {
-				logger.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
+			if ueRegStatusUpdateReqData.PcfReselectedInd {
+				problem, err := p.Consumer().AMPolicyControlDelete(ue)
+				if problem != nil {
+					logger.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problem)
+				} else if err != nil {
+					logger.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
+				}
 			}
+			// TODO: Currently only consider the 3GPP access type
+			if !ue.UeCmRegistered[models.AccessType__3_GPP_ACCESS] {
+				gmm_common.RemoveAmfUe(ue, false)
+			}
+		} else {
+			// NOT_TRANSFERRED
+			logger.CommLog.Debug("[AMF] RegistrationStatusUpdate: NOT_TRANSFERRED")
 		}
-		// TODO: Currently only consider the 3GPP access type
-		if !ue.UeCmRegistered[models.AccessType__3_GPP_ACCESS] {
-			gmm_common.RemoveAmfUe(ue, false)
-		}
-	} else {
-		// NOT_TRANSFERRED
-		logger.CommLog.Debug("[AMF] RegistrationStatusUpdate: NOT_TRANSFERRED")
+	}); err != nil {
+		return nil, ueEventProblem(err)
 	}
 
+	ueRegStatusUpdateRspData := new(models.UeRegStatusUpdateRspData)
 	ueRegStatusUpdateRspData.RegStatusTransferComplete = true
 	return ueRegStatusUpdateRspData, nil
 }
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestCreateUEContextHorizontalKamf(t *testing.T) {
+	p := &Processor{}
+	createUeContextRequest := models.CreateUeContextRequest{
+		JsonData: &models.UeContextCreateData{
+			UeContext: &models.UeContext{
+				Supi:     "imsi-208930000000007",
+				SeafData: &models.SeafData{KeyAmfChangeInd: true},
+			},
+			TargetId: &models.NgRanTargetId{
+				RanNodeId: &models.GlobalRanNodeId{},
+				Tai:       &models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "000001"},
+			},
+			PduSessionList:     []models.N2SmInformation{},
+			SourceToTargetData: &models.N2InfoContent{},
+			N2NotifyUri:        "http://127.0.0.1/n2-notify",
+		},
+	}
+
+	// the KAMF' of an N2 handover is not supported
+	response, createError := p.CreateUEContextProcedure("imsi-208930000000007", createUeContextRequest)
+	require.Nil(t, response)
+	require.NotNil(t, createError)
+	require.Equal(t, int32(http.StatusForbidden), createError.JsonData.Error.Status)
+	_, ok := context.GetSelf().AmfUeFindBySupi("imsi-208930000000007")
+	require.False(t, ok)
+}
+
+func TestBuildUEContextModelUeValidated(t *testing.T) {
+	p := &Processor{}
+	amfSelf := context.GetSelf()
+	amfSelf.HorizontalKamfDerivation = true
+	amfSelf.ServedGuamiList = []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	defer func() {
+		amfSelf.HorizontalKamfDerivation = false
+		amfSelf.ServedGuamiList = nil
+	}()
+	ue := amfSelf.NewAmfUe("imsi-208930000000008")
+	defer ue.Remove()
+	ue.Kamf = "000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f"
+
+	ueContext := p.buildUEContextModel(ue, models.TransferReason_MOBI_REG)
+	require.NotNil(t, ueContext.SeafData)
+	require.True(t, ueContext.SeafData.KeyAmfChangeInd)
+	require.NotEqual(t, ue.Kamf, ueContext.SeafData.KeyAmf.KeyVal)
+
+	// the UE authenticated by the new AMF gets no security context
+	ueContext = p.buildUEContextModel(ue, models.TransferReason_MOBI_REG_UE_VALIDATED)
+	require.Nil(t, ueContext.SeafData)
+	require.Empty(t, ueContext.MmContextList)
+}
@@ -11,11 +11,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	amf_context "github.com/free5gc/amf/internal/context"
 	"github.com/free5gc/amf/internal/logger"
 	"github.com/free5gc/amf/internal/sbi/consumer"
 	"github.com/free5gc/amf/internal/sbi/processor"
+	"github.com/free5gc/amf/internal/tracing"
 	util_oauth "github.com/free5gc/amf/internal/util"
 	"github.com/free5gc/amf/pkg/app"
 	"github.com/free5gc/amf/pkg/factory"
@@ -69,6 +71,10 @@ func newRouter(s *Server) *gin.Engine {
 	router := logger_util.NewGinWithLogrus(logger.GinLog)
 
 	router.Use(metrics.InboundMetrics())
+	if s.Config().GetTracing() != nil {
+		// continue the traces of the requests from their W3C trace-context
+		router.Use(otelgin.Middleware(tracing.ServiceName))
+	}
 	amfHttpCallBackGroup := router.Group(factory.AmfCallbackResUriPrefix)
 	amfHttpCallBackRoutes := s.getHttpCallBackRoutes()
 	callbackAuthCheck := util_oauth.NewRouterAuthorizationCheck(models.ServiceName("namf-callback"))
@@ -119,6 +125,14 @@ func newRouter(s *Server) *gin.Engine {
 				routerAuthorizationCheck.Check(c, amf_context.GetSelf())
 			})
 			applyRoutes(amfOAMGroup, amfOAMRoutes)
+			// the control actions also require the OAM admin scope
+			amfOAMAdminGroup := amfOAMGroup.Group("")
+			adminAuthorizationCheck := util_oauth.NewRouterAuthorizationCheck(
+				models.ServiceName(factory.AmfOamAdminScope))
+			amfOAMAdminGroup.Use(func(c *gin.Context) {
+				adminAuthorizationCheck.Check(c, amf_context.GetSelf())
+			})
+			applyRoutes(amfOAMAdminGroup, s.getOAMAdminRoutes())
 		case models.ServiceName_NAMF_MBS_COMM:
 			amfMbsComGroup := router.Group(factory.AmfMbsComResUriPrefix)
 			amfMbsComRoutes := s.getMbsCommunicationRoutes()
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/free5gc/amf/pkg/factory"
+)
+
+const (
+	instrumentationName = "github.com/free5gc/amf"
+	// ServiceName is the name of the AMF in its spans
+	ServiceName = "amf"
+)
+
+// Span attributes of the AMF
+const (
+	AttrSupi              = attribute.Key("amf.ue.supi")
+	AttrAccessType        = attribute.Key("amf.ue.access_type")
+	AttrNasMessageType    = attribute.Key("nas.message_type")
+	AttrNgapProcedureCode = attribute.Key("ngap.procedure_code")
+	AttrNgapPdu           = attribute.Key("ngap.pdu")
+	AttrSbiService        = attribute.Key("sbi.service")
+)
+
+// enabled is set once a tracer provider is installed, to skip building the spans otherwise
+var enabled atomic.Bool
+
+// Enabled reports whether the spans are exported
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Tracer returns the tracer of the AMF spans; spans are not recorded until Init installs a tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init installs the tracer provider exporting the spans as configured, and the W3C trace-context propagator
+// extracting and injecting the parent spans of the SBI requests.
+// The returned function flushes the pending spans and stops the exporter.
+func Init(cfg *factory.Tracing, nfInstanceId string) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch cfg.Exporter {
+	case factory.TracingExporterOtlp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case factory.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case factory.TracingExporterFile:
+		file, err = os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open tracing file: %w", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", cfg.Exporter)
+	}
+	if err != nil {
+		if file != nil {
+			_ = file.Close()
+		}
+		return nil, fmt.Errorf("create %s span exporter: %w", cfg.Exporter, err)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", ServiceName)}
+	if nfInstanceId != "" {
+		attrs = append(attrs, attribute.String("service.instance.id", nfInstanceId))
+	}
+	samplingRatio := cfg.SamplingRatio
+	if samplingRatio == 0 {
+		samplingRatio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+		// the spans of the SBI requests of a sampled procedure are sampled as well
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	enabled.Store(true)
+
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if errClose := file.Close(); err == nil {
+				err = errClose
+			}
+		}
+		return err
+	}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,6 +39,11 @@ const (
 	sctpDefaultMaxAttempts       = 2
 	sctpDefaultMaxInitTimeout    = 2
 	ngapDefaultPort              = 38412
+	AmfTraceDefaultMaxRecords    = 1024
+	AmfDrainDefaultTimeout       = 60 * time.Second
+	AmfCaptureDefaultMaxPackets  = 10000
+	AmfCaptureMaxPackets         = 100000
+	AmfCaptureMaxSessions        = 8
 	AmfCallbackResUriPrefix      = "/namf-callback/v1"
 	AmfCommResUriPrefix          = "/namf-comm/v1"
 	AmfEvtsResUriPrefix          = "/namf-evts/v1"
@@ -46,6 +52,7 @@ const (
 	AmfOamResUriPrefix           = "/namf-oam/v1"
 	AmfMbsComResUriPrefix        = "/namf-mbs-comm/v1"
 	AmfMbsBCResUriPrefix         = "/namf-mbs-bc/v1"
+	AmfOamAdminScope             = "namf-oam:admin"
 )
 
 type Config struct {
@@ -109,6 +116,19 @@ type Configuration struct {
 	DefaultUECtxReq        bool              `yaml:"defaultUECtxReq,omitempty" valid:"type(bool),optional"`
 	NgapWorkerPoolSize     int               `yaml:"ngapWorkerPoolSize,omitempty" valid:"type(int),optional"`
 	NgapTaskBufferSize     int               `yaml:"ngapTaskBufferSize,omitempty" valid:"type(int),optional"`
+	NgapAdmission          *NgapAdmission    `yaml:"ngapAdmission,omitempty" valid:"optional"`
+	UeContextStore         *UeContextStore   `yaml:"ueContextStore,omitempty" valid:"optional"`
+	AmfSet                 *AmfSet           `yaml:"amfSet,omitempty" valid:"optional"`
+	Paging                 *Paging           `yaml:"paging,omitempty" valid:"optional"`
+	Trace                  *Trace            `yaml:"trace,omitempty" valid:"optional"`
+	Drain                  *Drain            `yaml:"drain,omitempty" valid:"optional"`
+	Tracing                *Tracing          `yaml:"tracing,omitempty" valid:"optional"`
+	GutiReallocation       *GutiReallocation `yaml:"gutiReallocation,omitempty" valid:"optional"`
+	NasSecurity            *NasSecurity      `yaml:"nasSecurity,omitempty" valid:"optional"`
+	Reauthentication       *Reauthentication `yaml:"reauthentication,omitempty" valid:"optional"`
+	SliceAdmission         *SliceAdmission   `yaml:"sliceAdmission,omitempty" valid:"optional"`
+	SliceOutages           []SliceOutage     `yaml:"sliceOutages,omitempty" valid:"optional"`
+	Roaming                *Roaming          `yaml:"roaming,omitempty" valid:"optional"`
 }
 
 type Logger struct {
@@ -314,6 +334,84 @@ func (c *Configuration) validate() (bool, error) {
 		}
 	}
 
+	if c.NgapAdmission != nil {
+		if _, err := c.NgapAdmission.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.UeContextStore != nil {
+		if _, err := c.UeContextStore.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.AmfSet != nil {
+		if _, err := c.AmfSet.validate(c.ServedGumaiList, c.UeContextStore); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Paging != nil {
+		if _, err := c.Paging.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Trace != nil {
+		if _, err := c.Trace.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Drain != nil {
+		if _, err := c.Drain.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Tracing != nil {
+		if _, err := c.Tracing.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.GutiReallocation != nil {
+		if _, err := c.GutiReallocation.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.NasSecurity != nil {
+		if _, err := c.NasSecurity.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Reauthentication != nil {
+		if _, err := c.Reauthentication.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.SliceAdmission != nil {
+		if _, err := c.SliceAdmission.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	for _, outage := range c.SliceOutages {
+		if _, err := outage.validate(); err != nil {
+			return false, err
+		}
+	}
+
+	if c.Roaming != nil {
+		if _, err := c.Roaming.validate(); err != nil {
+			return false, err
+		}
+	}
+
 	if _, err := govalidator.ValidateStruct(c); err != nil {
 		return false, appendInvalid(err)
 	}
@@ -409,6 +507,9 @@ func (t *Tls) validate() (bool, error) {
 type Security struct {
 	IntegrityOrder []string `yaml:"integrityOrder,omitempty" valid:"-"`
 	CipheringOrder []string `yaml:"cipheringOrder,omitempty" valid:"-"`
+	// HorizontalKamfDerivation hands a KAMF derived horizontally to the new AMF of a UE on inter-AMF mobility,
+	// instead of the KAMF in use (TS 33.501 6.9.3)
+	HorizontalKamfDerivation bool `yaml:"horizontalKamfDerivation,omitempty" valid:"optional"`
 }
 
 func (s *Security) validate() (bool, error) {
@@ -711,6 +812,505 @@ func (f *NetworkFeatureSupport5GS) validate() (bool, error) {
 	return true, nil
 }
 
+// Paging steps, tried in order on the initial paging and each T3513 retransmission
+const (
+	PagingStepLastRan          = "lastRan"
+	PagingStepRecommended      = "recommended"
+	PagingStepLastTai          = "lastTai"
+	PagingStepRegistrationArea = "registrationArea"
+)
+
+type Paging struct {
+	// Strategy is the ordered list of paging steps; the last step is repeated once the list is exhausted
+	Strategy []string `yaml:"strategy,omitempty" valid:"optional"`
+	// Paging with a PPI in 1..PriorityThreshold skips the escalation and pages the whole registration area
+	PriorityThreshold int32               `yaml:"priorityThreshold,omitempty" valid:"optional"`
+	SlicePolicyList   []PagingSlicePolicy `yaml:"slicePolicyList,omitempty" valid:"optional"`
+}
+
+type PagingSlicePolicy struct {
+	Snssai   *models.Snssai `yaml:"snssai" valid:"required"`
+	Strategy []string       `yaml:"strategy" valid:"required"`
+}
+
+func validatePagingStrategy(strategy []string) error {
+	var errs govalidator.Errors
+	for _, step := range strategy {
+		switch step {
+		case PagingStepLastRan, PagingStepRecommended, PagingStepLastTai, PagingStepRegistrationArea:
+		default:
+			errs = append(errs, fmt.Errorf("invalid paging step: %s, should be %s, %s, %s or %s", step,
+				PagingStepLastRan, PagingStepRecommended, PagingStepLastTai, PagingStepRegistrationArea))
+		}
+	}
+	if len(errs) > 0 {
+		return error(errs)
+	}
+	return nil
+}
+
+func (p *Paging) validate() (bool, error) {
+	var errs govalidator.Errors
+
+	if err := validatePagingStrategy(p.Strategy); err != nil {
+		errs = append(errs, err)
+	}
+	if result := govalidator.InRangeInt(p.PriorityThreshold, 0, 8); !result {
+		err := fmt.Errorf("invalid priorityThreshold: %d, should be in the range of 0~8", p.PriorityThreshold)
+		errs = append(errs, err)
+	}
+	for _, policy := range p.SlicePolicyList {
+		if policy.Snssai == nil {
+			errs = append(errs, fmt.Errorf("paging slicePolicyList: snssai is nil"))
+			continue
+		}
+		if len(policy.Strategy) == 0 {
+			errs = append(errs, fmt.Errorf("paging slicePolicyList: strategy of snssai %+v is empty", *policy.Snssai))
+		}
+		if err := validatePagingStrategy(policy.Strategy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := govalidator.ValidateStruct(p); err != nil {
+		return false, appendInvalid(err)
+	}
+
+	if len(errs) > 0 {
+		return false, error(errs)
+	}
+	return true, nil
+}
+
+// Admission policies of the NGAP worker queues
+const (
+	NgapAdmissionWait = "wait"
+	NgapAdmissionDrop = "drop"
+)
+
+// NgapAdmission configures, per message class, how the NGAP messages and UE events are admitted in the queues
+// of the NGAP workers once the queues fill up, so that the SCTP associations are never blocked for long
+type NgapAdmission struct {
+	// NonUe applies to the messages not associated to a UE (NG Setup, NG Reset, Error Indication...),
+	// which are queued ahead of the UE traffic
+	NonUe *NgapAdmissionPolicy `yaml:"nonUe,omitempty" valid:"optional"`
+	// InitialUe applies to the Initial UE Messages, i.e. to the UEs not known to the AMF yet
+	InitialUe *NgapAdmissionPolicy `yaml:"initialUe,omitempty" valid:"optional"`
+	// Ue applies to the other UE-associated messages
+	Ue *NgapAdmissionPolicy `yaml:"ue,omitempty" valid:"optional"`
+	// UeEvent applies to the SBI requests and timer expiries of the UEs
+	UeEvent *NgapAdmissionPolicy `yaml:"ueEvent,omitempty" valid:"optional"`
+}
+
+type NgapAdmissionPolicy struct {
+	// Policy is what happens to a message finding the queue full: it waits up to MaxWait for room (wait,
+	// the default), or is dropped right away (drop)
+	Policy  string        `yaml:"policy,omitempty" valid:"in(wait|drop),optional"`
+	MaxWait time.Duration `yaml:"maxWait,omitempty" valid:"optional"`
+	// ShedThreshold drops the messages once the queue is filled above this percent of its size; 0 disables it
+	ShedThreshold int `yaml:"shedThreshold,omitempty" valid:"optional"`
+}
+
+func (a *NgapAdmission) validate() (bool, error) {
+	var errs govalidator.Errors
+	for name, policy := range map[string]*NgapAdmissionPolicy{
+		"nonUe": a.NonUe, "initialUe": a.InitialUe, "ue": a.Ue, "ueEvent": a.UeEvent,
+	} {
+		if policy == nil {
+			continue
+		}
+		if _, err := govalidator.ValidateStruct(policy); err != nil {
+			errs = append(errs, fmt.Errorf("ngapAdmission %s: %w", name, appendInvalid(err)))
+		}
+		if policy.Policy != NgapAdmissionDrop && policy.MaxWait <= 0 {
+			errs = append(errs, fmt.Errorf("ngapAdmission %s: invalid maxWait: %s, should be positive with the %s policy",
+				name, policy.MaxWait, NgapAdmissionWait))
+		}
+		if result := govalidator.InRangeInt(policy.ShedThreshold, 0, 100); !result {
+			errs = append(errs, fmt.Errorf("ngapAdmission %s: invalid shedThreshold: %d, should be in the range of 0~100",
+				name, policy.ShedThreshold))
+		}
+	}
+	if len(errs) > 0 {
+		return false, error(errs)
+	}
+	return true, nil
+}
+
+// UE context store types
+const (
+	UeContextStoreMemory = "memory"
+	UeContextStoreBolt   = "bolt"
+)
+
+// UeContextStore configures where the contexts of the registered UEs are kept across AMF restarts
+type UeContextStore struct {
+	// Type is memory (kept across the restarts of the AMF context only) or bolt (kept in a database file)
+	Type string `yaml:"type" valid:"required,in(memory|bolt)"`
+	// Path is the database file of the bolt store
+	Path string `yaml:"path,omitempty" valid:"optional"`
+	// KeyFile is the file of the hex-encoded AES key (128, 192 or 256 bits) encrypting the NAS security keys of the
+	// stored UE contexts, shared by the AMFs of the set. Without it, the keys are not stored and the restored UEs
+	// are authenticated again.
+	KeyFile string `yaml:"keyFile,omitempty" valid:"optional"`
+}
+
+func (s *UeContextStore) validate() (bool, error) {
+	if _, err := govalidator.ValidateStruct(s); err != nil {
+		return false, appendInvalid(err)
+	}
+	if s.Type == UeContextStoreBolt && s.Path == "" {
+		return false, fmt.Errorf("ueContextStore: path is required for the %s store", UeContextStoreBolt)
+	}
+	return true, nil
+}
+
+// AmfSet configures the operation of the AMF within its AMF set (TS 23.501 5.21.2)
+type AmfSet struct {
+	// BackupAmfList is the AMF which takes over a served GUAMI when this AMF fails, advertised to NG-RAN
+	BackupAmfList []BackupAmf `yaml:"backupAmfList,omitempty" valid:"optional"`
+	// BackedUpGuamiList is the GUAMIs of the other AMFs of the set which this AMF takes over when they fail.
+	// Taking over the UE contexts needs a UE context store shared by the AMFs of the set: the memory and bolt
+	// stores are local to the AMF and not allowed with it. Without a store, the UEs of a backed up GUAMI
+	// register again with this AMF, which requests their context from the old AMF (Namf UEContextTransfer).
+	BackedUpGuamiList []models.Guami `yaml:"backedUpGuamiList,omitempty" valid:"optional"`
+}
+
+type BackupAmf struct {
+	Guami models.Guami `yaml:"guami" valid:"required"`
+	// AmfName is the AMF Name of the backup AMF, TS 38.413 9.3.3.21
+	AmfName string `yaml:"amfName" valid:"required,length(1|150)"`
+}
+
+func (s *AmfSet) validate(servedGuamiList []models.Guami, store *UeContextStore) (bool, error) {
+	var errs govalidator.Errors
+	if len(s.BackedUpGuamiList) > 0 && store != nil &&
+		(store.Type == UeContextStoreMemory || store.Type == UeContextStoreBolt) {
+		errs = append(errs, fmt.Errorf("amfSet: backedUpGuamiList needs a UE context store shared by the AMF set,"+
+			" the %s store is local to the AMF", store.Type))
+	}
+	for _, backup := range s.BackupAmfList {
+		if _, err := govalidator.ValidateStruct(backup); err != nil {
+			return false, appendInvalid(err)
+		}
+		if !ContainsGuami(servedGuamiList, backup.Guami) {
+			errs = append(errs, fmt.Errorf("amfSet: backup AMF %s configured for GUAMI %s which is not served",
+				backup.AmfName, backup.Guami.AmfId))
+		}
+	}
+	for _, guami := range s.BackedUpGuamiList {
+		if guami.PlmnId == nil {
+			return false, fmt.Errorf("amfSet: backedUpGuamiList: PlmnId is nil")
+		}
+		if result := govalidator.StringMatches(guami.AmfId, "^[A-Fa-f0-9]{6}$"); !result {
+			errs = append(errs, fmt.Errorf("invalid amfId: %s,"+
+				" should be 3 bytes hex string, range: 000000~FFFFFF", guami.AmfId))
+			continue
+		}
+		if ContainsGuami(servedGuamiList, guami) {
+			errs = append(errs, fmt.Errorf("amfSet: backed up GUAMI %s is served by this AMF", guami.AmfId))
+			continue
+		}
+		sameSet := false
+		for _, served := range servedGuamiList {
+			if amfSetOf(served.AmfId) == amfSetOf(guami.AmfId) {
+				sameSet = true
+				break
+			}
+		}
+		if !sameSet {
+			errs = append(errs, fmt.Errorf("amfSet: backed up GUAMI %s is not in the AMF set of a served GUAMI",
+				guami.AmfId))
+		}
+	}
+	if len(errs) > 0 {
+		return false, error(errs)
+	}
+	return true, nil
+}
+
+// GuamiEqual reports whether the GUAMIs are the same, the AMF IDs compared case-insensitively
+func GuamiEqual(a, b models.Guami) bool {
+	if a.PlmnId == nil || b.PlmnId == nil {
+		return false
+	}
+	return a.PlmnId.Mcc == b.PlmnId.Mcc && a.PlmnId.Mnc == b.PlmnId.Mnc && strings.EqualFold(a.AmfId, b.AmfId)
+}
+
+// ContainsGuami reports whether the GUAMI is in the list, compared as by GuamiEqual
+func ContainsGuami(guamiList []models.Guami, guami models.Guami) bool {
+	for _, g := range guamiList {
+		if GuamiEqual(g, guami) {
+			return true
+		}
+	}
+	return false
+}
+
+// amfSetOf returns the AMF Region ID and AMF Set ID of the AMF Identifier, without the AMF Pointer
+func amfSetOf(amfId string) int64 {
+	id, err := strconv.ParseInt(amfId, 16, 32)
+	if err != nil {
+		return -1
+	}
+	return id >> 6
+}
+
+// Trace configures where the Cell Traffic Trace reports (TS 32.422 4.2.2.10) are delivered
+type Trace struct {
+	// TceUri is the Trace Collection Entity the reports are posted to; reports are only kept locally if empty
+	TceUri string `yaml:"tceUri,omitempty" valid:"url,optional"`
+	// MaxRecords is the number of reports kept locally for retrieval through OAM
+	MaxRecords int `yaml:"maxRecords,omitempty" valid:"optional"`
+}
+
+func (t *Trace) validate() (bool, error) {
+	if t.MaxRecords < 0 {
+		return false, fmt.Errorf("invalid trace maxRecords: %d, should not be negative", t.MaxRecords)
+	}
+	if _, err := govalidator.ValidateStruct(t); err != nil {
+		return false, appendInvalid(err)
+	}
+	return true, nil
+}
+
+// Drain configures the planned removal of the AMF (TS 23.501 5.21.2.2.1)
+type Drain struct {
+	// Timeout is how long the registered UEs are given to move to the other AMFs of the set before shutdown
+	Timeout time.Duration `yaml:"timeout,omitempty" valid:"optional"`
+}
+
+func (d *Drain) validate() (bool, error) {
+	if d.Timeout < 0 {
+		return false, fmt.Errorf("invalid drain timeout: %s, should not be negative", d.Timeout)
+	}
+	return true, nil
+}
+
+// GutiReallocation configures when the AMF assigns a new 5G-GUTI to a registered UE outside of the registration
+// procedures, through the generic UE configuration update procedure (TS 33.501 6.12.3, TS 24.501 5.4.4)
+type GutiReallocation struct {
+	// Interval is the age of the 5G-GUTI of a UE from which it is reallocated once the UE is CM-CONNECTED
+	Interval time.Duration `yaml:"interval,omitempty" valid:"optional"`
+	// ServiceRequests is the number of service requests of a UE after which its 5G-GUTI is reallocated
+	ServiceRequests int `yaml:"serviceRequests,omitempty" valid:"optional"`
+	// OnMobility reallocates the 5G-GUTI of a UE handed over to another tracking area
+	OnMobility bool `yaml:"onMobility,omitempty" valid:"type(bool),optional"`
+}
+
+func (g *GutiReallocation) validate() (bool, error) {
+	if g.Interval < 0 {
+		return false, fmt.Errorf("invalid gutiReallocation interval: %s, should not be negative", g.Interval)
+	}
+	if g.ServiceRequests < 0 {
+		return false, fmt.Errorf("invalid gutiReallocation serviceRequests: %d, should not be negative",
+			g.ServiceRequests)
+	}
+	return true, nil
+}
+
+const (
+	NasSecurityFailureActionDrop    = "drop"
+	NasSecurityFailureActionRelease = "release"
+)
+
+// NasSecurity configures how the AMF handles a UE repeatedly sending uplink NAS messages failing the
+// integrity check, replayed or not protected as required (TS 24.501 4.4.4.3, TS 33.501 6.4.3.1)
+type NasSecurity struct {
+	// MaxFailures is the number of consecutive failures of a UE raising an alarm, 0 for no alarm
+	MaxFailures int `yaml:"maxFailures,omitempty" valid:"optional"`
+	// FailureAction is drop to keep discarding the messages of the UE once MaxFailures is reached, release to
+	// also release its NAS signalling connection
+	FailureAction string `yaml:"failureAction,omitempty" valid:"optional,in(drop|release)"`
+}
+
+func (n *NasSecurity) validate() (bool, error) {
+	if n.MaxFailures < 0 {
+		return false, fmt.Errorf("invalid nasSecurity maxFailures: %d, should not be negative", n.MaxFailures)
+	}
+	if _, err := govalidator.ValidateStruct(n); err != nil {
+		return false, appendInvalid(err)
+	}
+	return true, nil
+}
+
+// Reauthentication configures when the AMF runs the primary authentication of a UE that still has a valid NAS
+// security context (TS 33.501 6.1.2). Without it, only the UEs sending an initial registration are authenticated
+// again, which remains the case with it unless skipOnInitialRegistration is set.
+type Reauthentication struct {
+	// SkipOnInitialRegistration keeps the valid security context of the UE sending an initial registration instead
+	// of authenticating it again
+	SkipOnInitialRegistration bool `yaml:"skipOnInitialRegistration,omitempty" valid:"type(bool),optional"`
+	// Registrations is the number of registrations of a UE after its authentication from which it is authenticated
+	// again
+	Registrations int `yaml:"registrations,omitempty" valid:"optional"`
+	// Interval is the time since the authentication of a UE from which it is authenticated again on registration
+	Interval time.Duration `yaml:"interval,omitempty" valid:"optional"`
+	// OnPlmnChange authenticates the UE registering in another PLMN than the one it was authenticated in
+	OnPlmnChange bool `yaml:"onPlmnChange,omitempty" valid:"type(bool),optional"`
+}
+
+func (r *Reauthentication) validate() (bool, error) {
+	if r.Registrations < 0 {
+		return false, fmt.Errorf("invalid reauthentication registrations: %d, should not be negative", r.Registrations)
+	}
+	if r.Interval < 0 {
+		return false, fmt.Errorf("invalid reauthentication interval: %s, should not be negative", r.Interval)
+	}
+	return true, nil
+}
+
+// Network slice admission control modes
+const (
+	SliceAdmissionModeLocal = "local"
+	SliceAdmissionModeNsacf = "nsacf"
+)
+
+// SliceAdmission configures the network slice admission control of the maximum number of UEs registered with an
+// S-NSSAI (TS 23.501 5.15.11.1)
+type SliceAdmission struct {
+	// Mode is local (the AMF counts the UEs it serves) or nsacf (the UEs are counted by the NSACF at NsacfUri)
+	Mode     string `yaml:"mode" valid:"required,in(local|nsacf)"`
+	NsacfUri string `yaml:"nsacfUri,omitempty" valid:"url,optional"`
+	// BackOffTimer is the time the UE rejected for the maximum number of UEs of an S-NSSAI is not admitted again
+	BackOffTimer time.Duration `yaml:"backOffTimer,omitempty" valid:"optional"`
+	// Quotas is the S-NSSAIs subject to network slice admission control
+	Quotas []SliceQuota `yaml:"quotas" valid:"required"`
+}
+
+type SliceQuota struct {
+	Snssai *models.Snssai `yaml:"snssai" valid:"required"`
+	// MaxUes is the maximum number of UEs registered with the S-NSSAI, enforced by the NSACF in nsacf mode
+	MaxUes int `yaml:"maxUes,omitempty" valid:"optional"`
+}
+
+func (s *SliceAdmission) validate() (bool, error) {
+	if _, err := govalidator.ValidateStruct(s); err != nil {
+		return false, appendInvalid(err)
+	}
+	if s.Mode == SliceAdmissionModeNsacf && s.NsacfUri == "" {
+		return false, fmt.Errorf("sliceAdmission: nsacfUri is required in %s mode", SliceAdmissionModeNsacf)
+	}
+	if s.BackOffTimer < 0 {
+		return false, fmt.Errorf("invalid sliceAdmission backOffTimer: %s, should not be negative", s.BackOffTimer)
+	}
+	for _, quota := range s.Quotas {
+		if quota.Snssai == nil {
+			return false, fmt.Errorf("sliceAdmission: quota without snssai")
+		}
+		if result := govalidator.InRangeInt(quota.Snssai.Sst, 0, 255); !result {
+			return false, fmt.Errorf("invalid sliceAdmission sst: %d, should be in the range of 0~255", quota.Snssai.Sst)
+		}
+		if sd := quota.Snssai.Sd; sd != "" && !govalidator.StringMatches(sd, "^[A-Fa-f0-9]{6}$") {
+			return false, fmt.Errorf("invalid sliceAdmission sd: %s, should be 3 bytes hex string", sd)
+		}
+		if s.Mode == SliceAdmissionModeLocal && quota.MaxUes <= 0 {
+			return false, fmt.Errorf("invalid sliceAdmission quota maxUes: %d, should be positive", quota.MaxUes)
+		}
+	}
+	return true, nil
+}
+
+// SliceOutage is an S-NSSAI of the PLMN support list out of service in some tracking areas at start, the operator
+// changing the outages through OAM afterwards
+type SliceOutage struct {
+	Snssai *models.Snssai `yaml:"snssai" valid:"required"`
+	// TaiList is the tracking areas the S-NSSAI is out of service in, the whole PLMN when empty
+	TaiList []models.Tai `yaml:"taiList,omitempty" valid:"optional"`
+	// AlternativeSnssai replaces the S-NSSAI for the UEs where it is out of service (TS 23.501 5.15.19)
+	AlternativeSnssai *models.Snssai `yaml:"alternativeSnssai,omitempty" valid:"optional"`
+}
+
+func (o *SliceOutage) validate() (bool, error) {
+	if o.Snssai == nil {
+		return false, fmt.Errorf("sliceOutages: outage without snssai")
+	}
+	if o.AlternativeSnssai != nil && *o.AlternativeSnssai == *o.Snssai {
+		return false, fmt.Errorf("sliceOutages: snssai %+v is its own alternative", *o.Snssai)
+	}
+	return true, nil
+}
+
+// Roaming configures the inbound roamers the AMF serves, the UEs of the home PLMNs of its roaming partners. Without
+// it, the UEs of all PLMNs are served as if they were at home.
+type Roaming struct {
+	Partners []RoamingPartner `yaml:"partners" valid:"required"`
+}
+
+// RoamingPartner is a home PLMN whose UEs are served by the AMF
+type RoamingPartner struct {
+	PlmnId *models.PlmnId `yaml:"plmnId" valid:"required"`
+	// LocalBreakoutDnns is the DNNs of the PDU sessions broken out locally in the serving PLMN, the PDU sessions
+	// of the other DNNs being home routed
+	LocalBreakoutDnns []string `yaml:"localBreakoutDnns,omitempty" valid:"optional"`
+	// SnssaiMappings maps the S-NSSAIs of the serving PLMN to the S-NSSAIs of the home PLMN, the S-NSSAIs without
+	// mapping having the same value in both
+	SnssaiMappings []SnssaiMapping `yaml:"snssaiMappings,omitempty" valid:"optional"`
+}
+
+type SnssaiMapping struct {
+	ServingSnssai *models.Snssai `yaml:"servingSnssai" valid:"required"`
+	HomeSnssai    *models.Snssai `yaml:"homeSnssai" valid:"required"`
+}
+
+func (r *Roaming) validate() (bool, error) {
+	if _, err := govalidator.ValidateStruct(r); err != nil {
+		return false, appendInvalid(err)
+	}
+	for _, partner := range r.Partners {
+		if partner.PlmnId == nil {
+			return false, fmt.Errorf("roaming: partner without plmnId")
+		}
+		if mcc := partner.PlmnId.Mcc; !govalidator.StringMatches(mcc, "^[0-9]{3}$") {
+			return false, fmt.Errorf("invalid roaming partner mcc: %s, should be a 3-digit number", mcc)
+		}
+		if mnc := partner.PlmnId.Mnc; !govalidator.StringMatches(mnc, "^[0-9]{2,3}$") {
+			return false, fmt.Errorf("invalid roaming partner mnc: %s, should be a 2 or 3-digit number", mnc)
+		}
+		for _, mapping := range partner.SnssaiMappings {
+			if mapping.ServingSnssai == nil || mapping.HomeSnssai == nil {
+				return false, fmt.Errorf("roaming: snssai mapping of partner %s%s without servingSnssai or homeSnssai",
+					partner.PlmnId.Mcc, partner.PlmnId.Mnc)
+			}
+		}
+	}
+	return true, nil
+}
+
+const (
+	TracingExporterOtlp   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+)
+
+// Tracing configures the OpenTelemetry spans of the UE procedures and of the SBI requests they cause
+type Tracing struct {
+	// Exporter is otlp (OTLP over HTTP to Endpoint), stdout or file (JSON spans appended to Path)
+	Exporter string `yaml:"exporter" valid:"required,in(otlp|stdout|file)"`
+	// Endpoint is the host:port of the OTLP collector
+	Endpoint string `yaml:"endpoint,omitempty" valid:"optional"`
+	// Insecure sends the spans to the OTLP collector over HTTP instead of HTTPS
+	Insecure bool   `yaml:"insecure,omitempty" valid:"type(bool),optional"`
+	Path     string `yaml:"path,omitempty" valid:"optional"`
+	// SamplingRatio is the ratio of the traces started by the AMF that are sampled, all of them if 0
+	SamplingRatio float64 `yaml:"samplingRatio,omitempty" valid:"optional"`
+}
+
+func (t *Tracing) validate() (bool, error) {
+	if _, err := govalidator.ValidateStruct(t); err != nil {
+		return false, appendInvalid(err)
+	}
+	if t.Exporter == TracingExporterOtlp && t.Endpoint == "" {
+		return false, fmt.Errorf("tracing: endpoint is required for the %s exporter", TracingExporterOtlp)
+	}
+	if t.Exporter == TracingExporterFile && t.Path == "" {
+		return false, fmt.Errorf("tracing: path is required for the %s exporter", TracingExporterFile)
+	}
+	if t.SamplingRatio < 0 || t.SamplingRatio > 1 {
+		return false, fmt.Errorf("invalid tracing samplingRatio: %v, should be between 0 and 1", t.SamplingRatio)
+	}
+	return true, nil
+}
+
 type TimerValue struct {
 	Enable        bool          `yaml:"enable" valid:"type(bool)"`
 	ExpireTime    time.Duration `yaml:"expireTime" valid:"type(time.Duration)"`
@@ -1053,3 +1653,159 @@ func (c *Config) GetNgapTaskBufferSize() int {
 	}
 	return 1000 // Default buffer size
 }
+
+// GetNgapAdmission returns the admission policy of each message class, the default one if not configured
+func (c *Config) GetNgapAdmission() *NgapAdmission {
+	c.RLock()
+	defer c.RUnlock()
+	admission := &NgapAdmission{
+		// NG Setup and NG Reset must get through
+		NonUe: &NgapAdmissionPolicy{Policy: NgapAdmissionWait, MaxWait: time.Second},
+		// shed new UEs first, in favor of the UEs being served
+		InitialUe: &NgapAdmissionPolicy{Policy: NgapAdmissionDrop, ShedThreshold: 80},
+		Ue:        &NgapAdmissionPolicy{Policy: NgapAdmissionWait, MaxWait: 200 * time.Millisecond},
+		UeEvent:   &NgapAdmissionPolicy{Policy: NgapAdmissionWait, MaxWait: time.Second},
+	}
+	if c.Configuration != nil && c.Configuration.NgapAdmission != nil {
+		configured := c.Configuration.NgapAdmission
+		if configured.NonUe != nil {
+			admission.NonUe = configured.NonUe
+		}
+		if configured.InitialUe != nil {
+			admission.InitialUe = configured.InitialUe
+		}
+		if configured.Ue != nil {
+			admission.Ue = configured.Ue
+		}
+		if configured.UeEvent != nil {
+			admission.UeEvent = configured.UeEvent
+		}
+	}
+	return admission
+}
+
+// GetUeContextStore returns the UE context store configuration, nil if the UE contexts are not kept
+func (c *Config) GetUeContextStore() *UeContextStore {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.UeContextStore
+	}
+	return nil
+}
+
+// GetAmfSet returns the AMF set configuration, nil if no backup AMF is configured
+func (c *Config) GetAmfSet() *AmfSet {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.AmfSet
+	}
+	return nil
+}
+
+func (c *Config) GetTrace() *Trace {
+	c.RLock()
+	defer c.RUnlock()
+	trace := &Trace{
+		MaxRecords: AmfTraceDefaultMaxRecords,
+	}
+	if c.Configuration != nil && c.Configuration.Trace != nil {
+		trace.TceUri = c.Configuration.Trace.TceUri
+		if c.Configuration.Trace.MaxRecords > 0 {
+			trace.MaxRecords = c.Configuration.Trace.MaxRecords
+		}
+	}
+	return trace
+}
+
+// GetDrainTimeout returns how long the AMF drains before shutdown, AmfDrainDefaultTimeout if not configured
+func (c *Config) GetDrainTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil && c.Configuration.Drain != nil && c.Configuration.Drain.Timeout > 0 {
+		return c.Configuration.Drain.Timeout
+	}
+	return AmfDrainDefaultTimeout
+}
+
+// GetTracing returns the tracing configuration, nil if tracing is disabled
+func (c *Config) GetTracing() *Tracing {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.Tracing
+	}
+	return nil
+}
+
+// GetGutiReallocation returns the 5G-GUTI reallocation policy, nil if the 5G-GUTI is only reallocated on registration
+func (c *Config) GetGutiReallocation() *GutiReallocation {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.GutiReallocation
+	}
+	return nil
+}
+
+// GetNasSecurity returns the NAS security policy, nil if the failing messages are only discarded
+func (c *Config) GetNasSecurity() *NasSecurity {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.NasSecurity
+	}
+	return nil
+}
+
+// GetReauthentication returns the re-authentication policy, nil if the UEs are authenticated again on each initial
+// registration only
+func (c *Config) GetReauthentication() *Reauthentication {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.Reauthentication
+	}
+	return nil
+}
+
+func (c *Config) GetSliceAdmission() *SliceAdmission {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.SliceAdmission
+	}
+	return nil
+}
+
+func (c *Config) GetSliceOutages() []SliceOutage {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.SliceOutages
+	}
+	return nil
+}
+
+// GetRoaming returns the roaming partners, nil if the UEs of all PLMNs are served as if they were at home
+func (c *Config) GetRoaming() *Roaming {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil {
+		return c.Configuration.Roaming
+	}
+	return nil
+}
+
+func (c *Config) GetPaging() *Paging {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration != nil && c.Configuration.Paging != nil {
+		return c.Configuration.Paging
+	}
+	// Page the whole registration area on every attempt by default
+	return &Paging{
+		Strategy: []string{PagingStepRegistrationArea},
+	}
+}
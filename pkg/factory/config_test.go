@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/asaskevich/govalidator"
+
+	"github.com/free5gc/openapi/models"
 )
 
 func TestSctp_validate(t *testing.T) {
@@ -110,3 +112,41 @@ func TestSctp_validate(t *testing.T) {
 		})
 	}
 }
+
+func TestAmfSet_validate(t *testing.T) {
+	servedGuamiList := []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}}
+	amfSet := &AmfSet{
+		BackedUpGuamiList: []models.Guami{{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe01"}},
+	}
+	tests := []struct {
+		name    string
+		store   *UeContextStore
+		wantErr bool
+	}{
+		{
+			name: "test OK -- no UE context store",
+		},
+		{
+			name:    "test Error -- memory store",
+			store:   &UeContextStore{Type: UeContextStoreMemory},
+			wantErr: true,
+		},
+		{
+			name:    "test Error -- bolt store",
+			store:   &UeContextStore{Type: UeContextStoreBolt, Path: "ue.db"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := amfSet.validate(servedGuamiList, tt.store)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AmfSet.validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == tt.wantErr {
+				t.Errorf("AmfSet.validate() = %v, want %v", got, !tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+	ngap_message "github.com/free5gc/amf/internal/ngap/message"
+	callback "github.com/free5gc/amf/internal/sbi/processor/notifier"
+	"github.com/free5gc/ngap/ngapType"
+	"github.com/free5gc/openapi/models"
+)
+
+// drainPollInterval is how often the UE pool is checked during the drain
+const drainPollInterval = time.Second
+
+var ErrAlreadyDraining = errors.New("AMF is already draining")
+
+// Drain starts the planned removal of the AMF (TS 23.501 5.21.2.2.1). The GUAMIs of the AMF are marked unavailable
+// towards NG-RAN, NRF and the AMF status subscribers, new registrations are rerouted to the other AMFs of the set,
+// and the CM-CONNECTED UEs are released so that their next request reaches another AMF of the set, which takes
+// the UE context over by UE context transfer. The CM-IDLE UEs are kept as they are: their next request reaches
+// another AMF, which takes their context over by UE context transfer too. The AMF terminates once the contexts of
+// all the UEs are taken over or the timeout passes; a timeout of 0 is the configured one.
+func (a *AmfApp) Drain(timeout time.Duration) error {
+	if !amf_context.StartDraining() {
+		return ErrAlreadyDraining
+	}
+	if timeout <= 0 {
+		timeout = a.cfg.GetDrainTimeout()
+	}
+	go a.drain(timeout)
+	return nil
+}
+
+func (a *AmfApp) drain(timeout time.Duration) {
+	logger.MainLog.Infof("Drain AMF: terminate once no UE context is left or in %s", timeout)
+	ctx, cancel := context.WithTimeout(a.ctx, timeout)
+	defer cancel()
+	amfSelf := a.Context()
+
+	sendAMFStatusIndication(amfSelf)
+	callback.SendAmfStatusChangeNotify((string)(models.StatusChange_UNAVAILABLE), amfSelf.ServedGuamiList)
+	releaseConnectedUes(amfSelf)
+	// the NRF is retried until the drain ends, not to delay the UEs
+	go a.updateNFInstance(ctx)
+
+	if remaining := waitUesTakenOver(ctx, amfSelf); remaining > 0 {
+		logger.MainLog.Warnf("Drain AMF: terminate with %d UE context(s) left, %d UE(s) CM-CONNECTED",
+			remaining, countConnectedUes(amfSelf))
+	} else {
+		logger.MainLog.Infof("Drain AMF: all UE contexts taken over")
+	}
+	a.Terminate()
+}
+
+// waitUesTakenOver waits until the UE pool is empty or the context is done, and returns the number of UE contexts
+// left
+func waitUesTakenOver(ctx context.Context, amfSelf *amf_context.AMFContext) int {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for remaining := countUes(amfSelf); remaining > 0; remaining = countUes(amfSelf) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return remaining
+		}
+	}
+	return 0
+}
+
+// sendAMFStatusIndication notifies the RANs that the served GUAMIs are unavailable, with their backup AMFs
+func sendAMFStatusIndication(amfSelf *amf_context.AMFContext) {
+	unavailableGuamiList := ngap_message.BuildUnavailableGUAMIList(amfSelf.ServedGuamiList)
+	amfSelf.AmfRanPool.Range(func(key, value interface{}) bool {
+		ran := value.(*amf_context.AmfRan)
+		ngap_message.SendAMFStatusIndication(ran, unavailableGuamiList)
+		return true
+	})
+}
+
+// updateNFInstance updates the NF profile in the NRF, undiscoverable while draining
+func (a *AmfApp) updateNFInstance(ctx context.Context) {
+	profile, err := a.Consumer().BuildNFInstance(a.Context())
+	if err != nil {
+		logger.MainLog.Errorf("Build AMF Profile Error: %+v", err)
+		return
+	}
+	if _, _, err = a.Consumer().SendRegisterNFInstance(ctx, a.Context().NrfUri, a.Context().NfId,
+		&profile); err != nil {
+		logger.MainLog.Warnf("Update NF Instance failed: %+v", err)
+	}
+}
+
+// releaseConnectedUes releases the N2 connections of the CM-CONNECTED UEs, keeping their contexts
+func releaseConnectedUes(amfSelf *amf_context.AMFContext) {
+	amfSelf.UePool.Range(func(key, value interface{}) bool {
+		ue := value.(*amf_context.AmfUe)
+		ue.Post(func() {
+			for _, anType := range []models.AccessType{
+				models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS,
+			} {
+				ranUe := ue.RanUe[anType]
+				if ranUe == nil || !ue.CmConnect(anType) {
+					continue
+				}
+				ngap_message.SendUEContextReleaseCommand(ranUe, amf_context.UeContextN2NormalRelease,
+					ngapType.CausePresentMisc, ngapType.CauseMiscPresentOmIntervention)
+			}
+		})
+		return true
+	})
+}
+
+// countUes counts the UE contexts of the UE pool
+func countUes(amfSelf *amf_context.AMFContext) int {
+	count := 0
+	amfSelf.UePool.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// countConnectedUes counts the N2 connections of the UEs over all the RANs
+func countConnectedUes(amfSelf *amf_context.AMFContext) int {
+	count := 0
+	amfSelf.AmfRanPool.Range(func(key, value interface{}) bool {
+		ran := value.(*amf_context.AmfRan)
+		ran.RanUeList.Range(func(key, value interface{}) bool {
+			count++
+			return true
+		})
+		return true
+	})
+	return count
+}
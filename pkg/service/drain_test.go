@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	amf_context "github.com/free5gc/amf/internal/context"
+	"github.com/free5gc/amf/internal/logger"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestWaitUesTakenOver(t *testing.T) {
+	amfSelf := amf_context.GetSelf()
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	ran := &amf_context.AmfRan{
+		AnType: models.AccessType__3_GPP_ACCESS,
+		Conn:   conn,
+		Log:    logger.NgapLog,
+	}
+	amfSelf.AmfRanPool.Store(conn, ran)
+	defer amfSelf.AmfRanPool.Delete(conn)
+	require.Equal(t, 0, waitUesTakenOver(context.Background(), amfSelf))
+
+	// the CM-CONNECTED UEs and the CM-IDLE UEs hold the drain until their contexts are taken over
+	connectedUe := &amf_context.AmfUe{Supi: "imsi-208930000000001"}
+	amfSelf.UePool.Store(connectedUe.Supi, connectedUe)
+	ran.RanUeList.Store(int64(1), &amf_context.RanUe{})
+	idleUe := &amf_context.AmfUe{Supi: "imsi-208930000000002"}
+	amfSelf.UePool.Store(idleUe.Supi, idleUe)
+	defer amfSelf.UePool.Delete(idleUe.Supi)
+	require.Equal(t, 1, countConnectedUes(amfSelf))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, 2, waitUesTakenOver(ctx, amfSelf))
+
+	ran.RanUeList.Delete(int64(1))
+	amfSelf.UePool.Delete(connectedUe.Supi)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, 1, waitUesTakenOver(ctx, amfSelf))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		amfSelf.UePool.Delete(idleUe.Supi)
+	}()
+	require.Equal(t, 0, waitUesTakenOver(context.Background(), amfSelf))
+}
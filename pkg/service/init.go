@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -14,12 +18,12 @@ import (
 	"github.com/free5gc/amf/internal/logger"
 	business_metrics "github.com/free5gc/amf/internal/metrics/business"
 	"github.com/free5gc/amf/internal/ngap"
-	ngap_message "github.com/free5gc/amf/internal/ngap/message"
 	ngap_service "github.com/free5gc/amf/internal/ngap/service"
 	"github.com/free5gc/amf/internal/sbi"
 	"github.com/free5gc/amf/internal/sbi/consumer"
 	"github.com/free5gc/amf/internal/sbi/processor"
 	callback "github.com/free5gc/amf/internal/sbi/processor/notifier"
+	"github.com/free5gc/amf/internal/tracing"
 	"github.com/free5gc/amf/pkg/app"
 	"github.com/free5gc/amf/pkg/factory"
 	"github.com/free5gc/openapi/models"
@@ -49,8 +53,15 @@ type AmfApp struct {
 	consumer      *consumer.Consumer
 	sbiServer     *sbi.Server
 	metricsServer *metrics.Server
+
+	ueContextStore amf_context.UeContextStore
+	// flushes the spans and stops their exporter, nil if tracing is disabled
+	shutdownTracing func(context.Context) error
 }
 
+// tracingShutdownTimeout bounds the export of the pending spans on termination
+const tracingShutdownTimeout = 5 * time.Second
+
 func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*AmfApp, error) {
 	amf := &AmfApp{
 		cfg: cfg,
@@ -59,6 +70,15 @@ func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*Am
 	amf.SetLogLevel(cfg.GetLogLevel())
 	amf.SetReportCaller(cfg.GetLogReportCaller())
 
+	if tracingCfg := cfg.GetTracing(); tracingCfg != nil {
+		shutdown, err := tracing.Init(tracingCfg, cfg.GetNfInstanceId())
+		if err != nil {
+			return amf, err
+		}
+		logger.InitLog.Infof("Tracing enabled, spans exported to %s", tracingCfg.Exporter)
+		amf.shutdownTracing = shutdown
+	}
+
 	consumer, err := consumer.NewConsumer(amf)
 	if err != nil {
 		return amf, err
@@ -121,6 +141,21 @@ func getCustomMetrics(cfg *factory.Config) map[utils.MetricTypeEnabled][]prometh
 
 	business_metrics.EnableUeConnectivityMetrics()
 
+	customMetrics[business_metrics.NGAP_SCHEDULER_METRICS] = business_metrics.GetNgapSchedulerHandlerMetrics(
+		cfg.GetMetricsNamespace())
+
+	business_metrics.EnableNgapSchedulerMetrics()
+
+	customMetrics[business_metrics.NAS_SECURITY_METRICS] = business_metrics.GetNasSecurityHandlerMetrics(
+		cfg.GetMetricsNamespace())
+
+	business_metrics.EnableNasSecurityMetrics()
+
+	customMetrics[business_metrics.SLICE_ADMISSION_METRICS] = business_metrics.GetSliceAdmissionHandlerMetrics(
+		cfg.GetMetricsNamespace())
+
+	business_metrics.EnableSliceAdmissionMetrics()
+
 	return customMetrics
 }
 
@@ -185,9 +220,28 @@ func (a *AmfApp) SetReportCaller(reportCaller bool) {
 	logger.Log.SetReportCaller(reportCaller)
 }
 
+// ngapAdmission returns the admission policy of each NGAP task class
+func ngapAdmission(admission *factory.NgapAdmission) map[ngap.TaskClass]ngap.AdmissionPolicy {
+	policy := func(p *factory.NgapAdmissionPolicy) ngap.AdmissionPolicy {
+		policy := ngap.AdmissionPolicy{ShedThreshold: p.ShedThreshold}
+		if p.Policy != factory.NgapAdmissionDrop {
+			policy.MaxWait = p.MaxWait
+		}
+		return policy
+	}
+	return map[ngap.TaskClass]ngap.AdmissionPolicy{
+		ngap.TaskClassNonUe:     policy(admission.NonUe),
+		ngap.TaskClassInitialUe: policy(admission.InitialUe),
+		ngap.TaskClassUe:        policy(admission.Ue),
+		ngap.TaskClassUeEvent:   policy(admission.UeEvent),
+	}
+}
+
 func (a *AmfApp) Start() {
 	self := a.Context()
 	amf_context.InitAmfContext(self)
+	a.initSliceAdmission()
+	a.restoreUeContexts()
 
 	// Initialize NGAP worker pool and scheduler
 	workerPoolSize := a.cfg.GetNgapWorkerPoolSize()
@@ -196,7 +250,7 @@ func (a *AmfApp) Start() {
 	logger.InitLog.Infof("Initializing NGAP worker pool with %d workers (buffer size: %d)",
 		workerPoolSize, taskBufferSize)
 
-	ngap.InitScheduler(workerPoolSize, taskBufferSize, ngap.Dispatch)
+	ngap.InitScheduler(workerPoolSize, taskBufferSize, ngapAdmission(a.cfg.GetNgapAdmission()), ngap.HandleTask)
 
 	ngapHandler := ngap_service.NGAPHandler{
 		HandleMessage:         ngap.Dispatch,
@@ -236,6 +290,78 @@ func (a *AmfApp) Start() {
 	a.WaitRoutineStopped()
 }
 
+// restoreUeContexts opens the configured UE context store and restores the UE contexts kept in it,
+// before the NG-RAN nodes connect
+func (a *AmfApp) restoreUeContexts() {
+	cfg := a.cfg.GetUeContextStore()
+	if cfg == nil {
+		return
+	}
+	var store amf_context.UeContextStore
+	switch cfg.Type {
+	case factory.UeContextStoreBolt:
+		boltStore, err := amf_context.NewBoltUeContextStore(cfg.Path)
+		if err != nil {
+			logger.InitLog.Errorf("UE contexts are not kept across restarts: %+v", err)
+			return
+		}
+		store = boltStore
+	default:
+		store = amf_context.NewMemoryUeContextStore()
+	}
+
+	if err := loadUeContextStoreKey(cfg.KeyFile); err != nil {
+		logger.InitLog.Errorf("UE security contexts are not kept across restarts: %+v", err)
+	} else if cfg.KeyFile == "" {
+		logger.InitLog.Warnf("UE security contexts are not kept across restarts: no keyFile configured")
+	}
+
+	restored, err := a.Context().RestoreUeContexts(store)
+	if err != nil {
+		logger.InitLog.Errorf("Restore UE contexts error: %+v", err)
+	}
+	logger.InitLog.Infof("Restored %d UE context(s) from the %s store", restored, cfg.Type)
+	if sliceAdmission := a.cfg.GetSliceAdmission(); sliceAdmission != nil && restored > 0 {
+		// the network slice admission control set up before counts the restored UEs again
+		if notAdmitted := a.Context().ReadmitRegisteredUes(sliceAdmission); notAdmitted > 0 {
+			logger.InitLog.Warnf("%d S-NSSAI(s) of the restored UEs not admitted again", notAdmitted)
+		}
+	}
+	a.ueContextStore = store
+	amf_context.SetUeContextStore(store)
+}
+
+// loadUeContextStoreKey sets the key of the file encrypting the security keys of the stored UE contexts
+func loadUeContextStoreKey(keyFile string) error {
+	if keyFile == "" {
+		return nil
+	}
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return fmt.Errorf("decode key of %s: %w", keyFile, err)
+	}
+	return amf_context.SetUeContextStoreKey(key)
+}
+
+// initSliceAdmission sets the configured network slice admission control of the S-NSSAIs
+func (a *AmfApp) initSliceAdmission() {
+	cfg := a.cfg.GetSliceAdmission()
+	if cfg == nil {
+		return
+	}
+	switch cfg.Mode {
+	case factory.SliceAdmissionModeNsacf:
+		amf_context.SetSliceAdmission(a.Consumer().SliceAdmission(cfg.NsacfUri))
+	default:
+		amf_context.SetSliceAdmission(amf_context.NewLocalSliceAdmission(cfg.Quotas))
+	}
+	logger.InitLog.Infof("Network slice admission control of %d S-NSSAI(s) in %s mode", len(cfg.Quotas), cfg.Mode)
+}
+
 // Used in AMF planned removal procedure
 func (a *AmfApp) Terminate() {
 	a.cancel()
@@ -292,18 +418,19 @@ func (a *AmfApp) terminateProcedure() {
 	logger.MainLog.Infof("Terminating AMF...")
 	a.CallServerStop()
 
-	// TODO: forward registered UE contexts to target AMF in the same AMF set if there is one
+	// the RANs and SBI subscribers have been notified when the drain started
+	drained := amf_context.IsDraining()
 
 	// ngap
 	// send AMF status indication to ran to notify ran that this AMF will be unavailable
-	logger.MainLog.Infof("Send AMF Status Indication to Notify RANs due to AMF terminating")
 	amfSelf := a.Context()
-	unavailableGuamiList := ngap_message.BuildUnavailableGUAMIList(amfSelf.ServedGuamiList)
-	amfSelf.AmfRanPool.Range(func(key, value interface{}) bool {
-		ran := value.(*amf_context.AmfRan)
-		ngap_message.SendAMFStatusIndication(ran, unavailableGuamiList)
-		return true
-	})
+	if !drained {
+		logger.MainLog.Infof("Send AMF Status Indication to Notify RANs due to AMF terminating")
+		sendAMFStatusIndication(amfSelf)
+	}
+
+	// the UEs in CM-CONNECTED are kept with their latest NAS COUNTs, while their event loops still run
+	amfSelf.CheckpointUeContexts()
 
 	// Shutdown NGAP worker pool and scheduler
 	logger.MainLog.Infof("Shutting down NGAP worker pool and scheduler...")
@@ -312,7 +439,9 @@ func (a *AmfApp) terminateProcedure() {
 	ngap_service.Stop()
 
 	// notify SBI subscribers before deregistering so NRF still recognizes AMF as a valid OAuth client
-	callback.SendAmfStatusChangeNotify((string)(models.StatusChange_UNAVAILABLE), amfSelf.ServedGuamiList)
+	if !drained {
+		callback.SendAmfStatusChangeNotify((string)(models.StatusChange_UNAVAILABLE), amfSelf.ServedGuamiList)
+	}
 
 	// deregister with NRF
 	problemDetails, err_deg := a.Consumer().SendDeregisterNFInstance()
@@ -323,4 +452,19 @@ func (a *AmfApp) terminateProcedure() {
 	} else {
 		logger.MainLog.Infof("[AMF] Deregister from NRF successfully")
 	}
+
+	if a.ueContextStore != nil {
+		amf_context.SetUeContextStore(nil)
+		if err := a.ueContextStore.Close(); err != nil {
+			logger.MainLog.Errorf("Close UE context store error: %+v", err)
+		}
+	}
+
+	if a.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := a.shutdownTracing(ctx); err != nil {
+			logger.MainLog.Errorf("Flush spans error: %+v", err)
+		}
+	}
 }